@@ -0,0 +1,660 @@
+//Bounding volume hierarchy over triangle meshes for ray, closest point and
+//containment queries. Nodes are split with a binned surface area heuristic and
+//stored flat with each leaf referencing a contiguous range of triangles
+package bvh
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/geom"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/triangle"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/scene"
+)
+
+//Build Parameters
+const BVH_LEAF_SIZE = 4
+const BVH_BINS = 16
+const BVH_TRAVERSAL_COST = 1.0
+const BVH_INTERSECT_COST = 1.0
+
+//Ray Epsilon guarding against self intersection and parallel triangles
+const BVH_EPSILON = 1e-7
+
+type Node struct {
+	Min   [3]float32
+	Max   [3]float32
+	Left  int //Index of the left child, the right child follows its subtree
+	Right int
+	Start int //Leaf range into Order, Count 0 for interior nodes
+	Count int
+}
+
+type BVH struct {
+	Positions []float32 //Vertex positions xyz
+	Indices   []int     //Triangle vertex indices, three per triangle
+	Nodes     []Node
+	Order     []int //Triangle ids in leaf order
+	LeafSize  int
+}
+
+//Hit holds a ray triangle intersection. U and V are the barycentric weights of
+//the second and third triangle vertices
+type Hit struct {
+	T        float32
+	U        float32
+	V        float32
+	Triangle int
+}
+
+//Contact holds the first touch of a swept sphere. T is the fraction of the
+//motion, Point the sphere center at contact and Normal the unit contact normal
+//pointing toward the sphere
+type Contact struct {
+	T        float32
+	Point    vector.Vec
+	Normal   vector.Vec
+	Triangle int
+}
+
+//Closest holds the nearest surface point to a query point
+type Closest struct {
+	Point    vector.Vec
+	Distance float32
+	U        float32
+	V        float32
+	Triangle int
+}
+
+//New builds a hierarchy over the triangles given by vertex indices into
+//positions, nil indices reading positions as a linear triangle list
+func New(positions []float32, indices []int) *BVH {
+	if indices == nil {
+		indices = make([]int, len(positions)/9*3)
+		for i := range indices {
+			indices[i] = i
+		}
+	}
+	b := BVH{Positions: positions, Indices: indices, LeafSize: BVH_LEAF_SIZE}
+	b.Build()
+	return &b
+}
+
+//FromMesh builds a hierarchy over the linear triangle list of a mesh
+func FromMesh(m *mesh.Mesh) *BVH {
+	positions := make([]float32, 0, len(m.Vertexes)/3*9)
+	for _, v := range m.Vertexes[:len(m.Vertexes)/3*3] {
+		positions = append(positions, v[0], v[1], v[2])
+	}
+	return New(positions, nil)
+}
+
+//FromPrimitive builds a hierarchy over a glTF mesh primitive in its local space
+func FromPrimitive(scn *scene.Scene, mesh_index int, primitive_index int) (*BVH, error) {
+	positions, indices, err := scn.PrimitiveTriangles(mesh_index, primitive_index)
+	if err != nil {
+		return nil, err
+	}
+	return New(positions, indices), nil
+}
+
+//Triangles returns the number of triangles
+func (b *BVH) Triangles() int {
+	return len(b.Indices) / 3
+}
+
+//Vertex returns vertex k of triangle tri
+func (b *BVH) Vertex(tri int, k int) [3]float32 {
+	v := b.Indices[tri*3+k] * 3
+	return [3]float32{b.Positions[v], b.Positions[v+1], b.Positions[v+2]}
+}
+
+//Triangle returns the vertices of triangle tri
+func (b *BVH) Triangle(tri int) (vector.Vec, vector.Vec, vector.Vec) {
+	p, q, r := b.Vertex(tri, 0), b.Vertex(tri, 1), b.Vertex(tri, 2)
+	return p[:], q[:], r[:]
+}
+
+//Normal returns the unit geometric normal of triangle tri, wound counter clockwise
+func (b *BVH) Normal(tri int) vector.Vec {
+	p, q, r := b.Triangle(tri)
+	return vector.Norm(vector.Cross(vector.Sub(q, p), vector.Sub(r, p)))
+}
+
+//Bounds returns the bounding box of the mesh
+func (b *BVH) Bounds() ([3]float32, [3]float32) {
+	if len(b.Nodes) == 0 {
+		return [3]float32{}, [3]float32{}
+	}
+	return b.Nodes[0].Min, b.Nodes[0].Max
+}
+
+type primitive struct {
+	min      [3]float32
+	max      [3]float32
+	centroid [3]float32
+}
+
+type bin struct {
+	min   [3]float32
+	max   [3]float32
+	count int
+}
+
+func empty() ([3]float32, [3]float32) {
+	inf := float32(math.Inf(1))
+	return [3]float32{inf, inf, inf}, [3]float32{-inf, -inf, -inf}
+}
+
+func grow(min *[3]float32, max *[3]float32, lo [3]float32, hi [3]float32) {
+	for a := 0; a < 3; a++ {
+		if lo[a] < min[a] {
+			min[a] = lo[a]
+		}
+		if hi[a] > max[a] {
+			max[a] = hi[a]
+		}
+	}
+}
+
+func area(min [3]float32, max [3]float32) float32 {
+	dx, dy, dz := max[0]-min[0], max[1]-min[1], max[2]-min[2]
+	if dx < 0 || dy < 0 || dz < 0 {
+		return 0
+	}
+	return 2 * (dx*dy + dy*dz + dz*dx)
+}
+
+//Build rebuilds the hierarchy after the positions or indices changed
+func (b *BVH) Build() {
+	n := b.Triangles()
+	prims := make([]primitive, n)
+	b.Order = make([]int, n)
+	for t := 0; t < n; t++ {
+		min, max := empty()
+		for k := 0; k < 3; k++ {
+			v := b.Vertex(t, k)
+			grow(&min, &max, v, v)
+		}
+		prims[t] = primitive{min, max, [3]float32{(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2}}
+		b.Order[t] = t
+	}
+	b.Nodes = make([]Node, 0, 2*n/b.LeafSize+1)
+	//An empty hierarchy has no nodes, a root with Count 0 would read as interior
+	if n == 0 {
+		return
+	}
+	b.split(prims, 0, n)
+}
+
+//split appends the node over Order[start:end] and recursively its children
+func (b *BVH) split(prims []primitive, start int, end int) int {
+	index := len(b.Nodes)
+	node := Node{Start: start, Count: end - start}
+	node.Min, node.Max = empty()
+	cmin, cmax := empty()
+	for _, t := range b.Order[start:end] {
+		grow(&node.Min, &node.Max, prims[t].min, prims[t].max)
+		grow(&cmin, &cmax, prims[t].centroid, prims[t].centroid)
+	}
+	b.Nodes = append(b.Nodes, node)
+	if node.Count <= b.LeafSize {
+		return index
+	}
+
+	//Binned SAH over the centroid bounds of each axis
+	bestAxis, bestBin := -1, 0
+	bestCost := float32(BVH_INTERSECT_COST * node.Count)
+	for a := 0; a < 3; a++ {
+		extent := cmax[a] - cmin[a]
+		if extent <= 0 {
+			continue
+		}
+		bins := [BVH_BINS]bin{}
+		for i := range bins {
+			bins[i].min, bins[i].max = empty()
+		}
+		for _, t := range b.Order[start:end] {
+			k := binIndex(prims[t].centroid[a], cmin[a], extent)
+			bins[k].count++
+			grow(&bins[k].min, &bins[k].max, prims[t].min, prims[t].max)
+		}
+		//Sweep right to left then evaluate each split plane left to right
+		right := [BVH_BINS]float32{}
+		rmin, rmax := empty()
+		rcount := 0
+		counts := [BVH_BINS]int{}
+		for i := BVH_BINS - 1; i > 0; i-- {
+			grow(&rmin, &rmax, bins[i].min, bins[i].max)
+			rcount += bins[i].count
+			right[i] = area(rmin, rmax)
+			counts[i] = rcount
+		}
+		lmin, lmax := empty()
+		lcount := 0
+		parent := area(node.Min, node.Max)
+		for i := 0; i < BVH_BINS-1; i++ {
+			grow(&lmin, &lmax, bins[i].min, bins[i].max)
+			lcount += bins[i].count
+			if lcount == 0 || counts[i+1] == 0 {
+				continue
+			}
+			cost := BVH_TRAVERSAL_COST + BVH_INTERSECT_COST*(area(lmin, lmax)*float32(lcount)+right[i+1]*float32(counts[i+1]))/parent
+			if cost < bestCost {
+				bestCost, bestAxis, bestBin = cost, a, i
+			}
+		}
+	}
+
+	mid := start
+	if bestAxis >= 0 {
+		extent := cmax[bestAxis] - cmin[bestAxis]
+		for i := start; i < end; i++ {
+			if binIndex(prims[b.Order[i]].centroid[bestAxis], cmin[bestAxis], extent) <= bestBin {
+				b.Order[i], b.Order[mid] = b.Order[mid], b.Order[i]
+				mid++
+			}
+		}
+	} else if node.Count > 2*b.LeafSize {
+		//No split beats a leaf yet the leaf is too large, split at the median
+		//of the widest centroid axis
+		axis := 0
+		for a := 1; a < 3; a++ {
+			if cmax[a]-cmin[a] > cmax[axis]-cmin[axis] {
+				axis = a
+			}
+		}
+		order := b.Order[start:end]
+		sort.Slice(order, func(i int, j int) bool { return prims[order[i]].centroid[axis] < prims[order[j]].centroid[axis] })
+		mid = (start + end) / 2
+	} else {
+		return index
+	}
+	if mid == start || mid == end {
+		return index
+	}
+
+	b.Nodes[index].Count = 0
+	left := b.split(prims, start, mid)
+	right := b.split(prims, mid, end)
+	b.Nodes[index].Left = left
+	b.Nodes[index].Right = right
+	return index
+}
+
+func binIndex(c float32, min float32, extent float32) int {
+	k := int(float32(BVH_BINS) * (c - min) / extent)
+	if k >= BVH_BINS {
+		k = BVH_BINS - 1
+	}
+	if k < 0 {
+		k = 0
+	}
+	return k
+}
+
+//slab returns the entry distance of the ray into a node box, false when missed
+//within tmax
+func slab(n *Node, origin [3]float32, inv [3]float32, tmax float32) (float32, bool) {
+	return slabPadded(n, origin, inv, tmax, 0)
+}
+
+//slabPadded tests the ray against the node box grown by pad
+func slabPadded(n *Node, origin [3]float32, inv [3]float32, tmax float32, pad float32) (float32, bool) {
+	t0, t1 := float32(0), tmax
+	for a := 0; a < 3; a++ {
+		near := (n.Min[a] - pad - origin[a]) * inv[a]
+		far := (n.Max[a] + pad - origin[a]) * inv[a]
+		if near > far {
+			near, far = far, near
+		}
+		//NaN from 0 * Inf leaves the interval unchanged
+		if near > t0 {
+			t0 = near
+		}
+		if far < t1 {
+			t1 = far
+		}
+		if t0 > t1 {
+			return 0, false
+		}
+	}
+	return t0, true
+}
+
+//IntersectTriangle is the Möller–Trumbore ray triangle test returning the hit
+//distance and the barycentric weights of the second and third vertices
+func (b *BVH) IntersectTriangle(tri int, origin [3]float32, dir [3]float32) (float32, float32, float32, bool) {
+	p, q, r := b.Vertex(tri, 0), b.Vertex(tri, 1), b.Vertex(tri, 2)
+	e1 := [3]float64{float64(q[0] - p[0]), float64(q[1] - p[1]), float64(q[2] - p[2])}
+	e2 := [3]float64{float64(r[0] - p[0]), float64(r[1] - p[1]), float64(r[2] - p[2])}
+	d := [3]float64{float64(dir[0]), float64(dir[1]), float64(dir[2])}
+	h := cross64(d, e2)
+	det := dot64(e1, h)
+	if math.Abs(det) < BVH_EPSILON*math.Sqrt(dot64(e1, e1)*dot64(e2, e2)) {
+		return 0, 0, 0, false
+	}
+	inv := 1 / det
+	s := [3]float64{float64(origin[0] - p[0]), float64(origin[1] - p[1]), float64(origin[2] - p[2])}
+	u := dot64(s, h) * inv
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+	qv := cross64(s, e1)
+	v := dot64(d, qv) * inv
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+	t := dot64(e2, qv) * inv
+	return float32(t), float32(u), float32(v), t >= 0
+}
+
+func cross64(a [3]float64, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func dot64(a [3]float64, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+//traverse visits the triangles of leaves hit by the ray front to back, visit
+//returning the new maximum distance or a negative value to stop
+func (b *BVH) traverse(origin [3]float32, dir [3]float32, tmax float32, visit func(tri int, t float32, u float32, v float32) float32) {
+	if len(b.Nodes) == 0 {
+		return
+	}
+	inv := [3]float32{1 / dir[0], 1 / dir[1], 1 / dir[2]}
+	stack := make([]int, 0, 64)
+	stack = append(stack, 0)
+	for len(stack) > 0 {
+		n := &b.Nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if _, ok := slab(n, origin, inv, tmax); !ok {
+			continue
+		}
+		if n.Count > 0 {
+			for _, tri := range b.Order[n.Start : n.Start+n.Count] {
+				if t, u, v, ok := b.IntersectTriangle(tri, origin, dir); ok && t <= tmax {
+					if tmax = visit(tri, t, u, v); tmax < 0 {
+						return
+					}
+				}
+			}
+			continue
+		}
+		//Push the far child first so the near child is visited first
+		tl, hl := slab(&b.Nodes[n.Left], origin, inv, tmax)
+		tr, hr := slab(&b.Nodes[n.Right], origin, inv, tmax)
+		if hl && hr {
+			if tl < tr {
+				stack = append(stack, n.Right, n.Left)
+			} else {
+				stack = append(stack, n.Left, n.Right)
+			}
+		} else if hl {
+			stack = append(stack, n.Left)
+		} else if hr {
+			stack = append(stack, n.Right)
+		}
+	}
+}
+
+func toArray(v vector.Vec) [3]float32 {
+	return [3]float32{v[0], v[1], v[2]}
+}
+
+//Intersect returns the nearest triangle hit by the ray within tmax
+func (b *BVH) Intersect(ray geom.Ray, tmax float32) (Hit, bool) {
+	hit := Hit{T: tmax, Triangle: -1}
+	b.traverse(toArray(*ray.Origin), toArray(*ray.Ray), tmax, func(tri int, t float32, u float32, v float32) float32 {
+		if t < hit.T || hit.Triangle < 0 {
+			hit = Hit{t, u, v, tri}
+		}
+		return hit.T
+	})
+	return hit, hit.Triangle >= 0
+}
+
+//Occluded reports whether any triangle is hit by the ray within tmax
+func (b *BVH) Occluded(ray geom.Ray, tmax float32) bool {
+	found := false
+	b.traverse(toArray(*ray.Origin), toArray(*ray.Ray), tmax, func(tri int, t float32, u float32, v float32) float32 {
+		found = true
+		return -1
+	})
+	return found
+}
+
+//Crossings returns the number of triangles hit by the ray within tmax
+func (b *BVH) Crossings(ray geom.Ray, tmax float32) int {
+	count := 0
+	b.traverse(toArray(*ray.Origin), toArray(*ray.Ray), tmax, func(tri int, t float32, u float32, v float32) float32 {
+		count++
+		return tmax
+	})
+	return count
+}
+
+//Inside reports whether a point lies inside a closed mesh by the majority of the
+//crossing parities of three skewed rays, robust to rays grazing edges
+func (b *BVH) Inside(p vector.Vec) bool {
+	dirs := [3]vector.Vec{{0.5773, 0.5774, 0.5775}, {-0.3427, 0.8127, -0.4712}, {0.2213, -0.4117, -0.8841}}
+	inf := float32(math.Inf(1))
+	votes := 0
+	for _, d := range dirs {
+		if b.Crossings(geom.NewRay(p, d), inf)%2 == 1 {
+			votes++
+		}
+	}
+	return votes >= 2
+}
+
+//boxDistance2 returns the squared distance from p to a node box
+func boxDistance2(n *Node, p [3]float32) float32 {
+	d := float32(0)
+	for a := 0; a < 3; a++ {
+		if p[a] < n.Min[a] {
+			d += (n.Min[a] - p[a]) * (n.Min[a] - p[a])
+		} else if p[a] > n.Max[a] {
+			d += (p[a] - n.Max[a]) * (p[a] - n.Max[a])
+		}
+	}
+	return d
+}
+
+//ClosestOnTriangle returns the point of triangle tri nearest to p and its
+//barycentric weights of the second and third vertices, Ericson 5.1.5
+func (b *BVH) ClosestOnTriangle(tri int, p [3]float32) ([3]float32, float32, float32) {
+	a, bv, c := b.Vertex(tri, 0), b.Vertex(tri, 1), b.Vertex(tri, 2)
+	sub := func(x [3]float32, y [3]float32) [3]float32 { return [3]float32{x[0] - y[0], x[1] - y[1], x[2] - y[2]} }
+	dot := func(x [3]float32, y [3]float32) float32 { return x[0]*y[0] + x[1]*y[1] + x[2]*y[2] }
+	at := func(v float32, w float32) [3]float32 {
+		u := 1 - v - w
+		return [3]float32{u*a[0] + v*bv[0] + w*c[0], u*a[1] + v*bv[1] + w*c[1], u*a[2] + v*bv[2] + w*c[2]}
+	}
+	ab, ac, ap := sub(bv, a), sub(c, a), sub(p, a)
+	d1, d2 := dot(ab, ap), dot(ac, ap)
+	if d1 <= 0 && d2 <= 0 {
+		return a, 0, 0
+	}
+	bp := sub(p, bv)
+	d3, d4 := dot(ab, bp), dot(ac, bp)
+	if d3 >= 0 && d4 <= d3 {
+		return bv, 1, 0
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		v := d1 / (d1 - d3)
+		return at(v, 0), v, 0
+	}
+	cp := sub(p, c)
+	d5, d6 := dot(ab, cp), dot(ac, cp)
+	if d6 >= 0 && d5 <= d6 {
+		return c, 0, 1
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		w := d2 / (d2 - d6)
+		return at(0, w), 0, w
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return at(1-w, w), 1 - w, w
+	}
+	denom := 1 / (va + vb + vc)
+	v, w := vb*denom, vc*denom
+	return at(v, w), v, w
+}
+
+//ClosestPoint returns the surface point nearest to p within maxDist
+func (b *BVH) ClosestPoint(p vector.Vec, maxDist float32) (Closest, bool) {
+	best := Closest{Distance: maxDist, Triangle: -1}
+	if len(b.Nodes) == 0 {
+		return best, false
+	}
+	q := toArray(p)
+	best2 := maxDist * maxDist
+	stack := make([]int, 0, 64)
+	stack = append(stack, 0)
+	for len(stack) > 0 {
+		n := &b.Nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if boxDistance2(n, q) > best2 {
+			continue
+		}
+		if n.Count > 0 {
+			for _, tri := range b.Order[n.Start : n.Start+n.Count] {
+				c, u, v := b.ClosestOnTriangle(tri, q)
+				d := (c[0]-q[0])*(c[0]-q[0]) + (c[1]-q[1])*(c[1]-q[1]) + (c[2]-q[2])*(c[2]-q[2])
+				if d <= best2 {
+					best2 = d
+					best = Closest{vector.Vec{c[0], c[1], c[2]}, 0, u, v, tri}
+				}
+			}
+			continue
+		}
+		dl, dr := boxDistance2(&b.Nodes[n.Left], q), boxDistance2(&b.Nodes[n.Right], q)
+		if dl < dr {
+			stack = append(stack, n.Right, n.Left)
+		} else {
+			stack = append(stack, n.Left, n.Right)
+		}
+	}
+	best.Distance = float32(math.Sqrt(float64(best2)))
+	return best, best.Triangle >= 0
+}
+
+//Sweep returns the first contact of a sphere of radius r moving from p0 to p1,
+//continuous collision detection for particles that would tunnel through thin
+//walls within a single step
+func (b *BVH) Sweep(p0 vector.Vec, p1 vector.Vec, r float32) (Contact, bool) {
+	best := Contact{T: 1, Triangle: -1}
+	if len(b.Nodes) == 0 {
+		return best, false
+	}
+	origin := toArray(p0)
+	dir := [3]float32{p1[0] - p0[0], p1[1] - p0[1], p1[2] - p0[2]}
+	inv := [3]float32{1 / dir[0], 1 / dir[1], 1 / dir[2]}
+	stack := make([]int, 0, 64)
+	stack = append(stack, 0)
+	for len(stack) > 0 {
+		n := &b.Nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if _, ok := slabPadded(n, origin, inv, best.T, r); !ok {
+			continue
+		}
+		if n.Count == 0 {
+			stack = append(stack, n.Right, n.Left)
+			continue
+		}
+		for _, tri := range b.Order[n.Start : n.Start+n.Count] {
+			p, q, c := b.Triangle(tri)
+			t := triangle.InitTriangle(p, q, c)
+			if s, normal, ok := t.Sweep(p0, p1, r); ok && (s < best.T || best.Triangle < 0) {
+				best = Contact{s, nil, normal, tri}
+			}
+		}
+	}
+	if best.Triangle < 0 {
+		return best, false
+	}
+	best.Point = vector.Vec{p0[0] + dir[0]*best.T, p0[1] + dir[1]*best.T, p0[2] + dir[2]*best.T}
+	return best, true
+}
+
+//Hits returns the sorted distances of all triangle crossings of the ray within
+//tmax, merging crossings closer than eps such as a ray through a shared edge
+func (b *BVH) Hits(ray geom.Ray, tmax float32, eps float32) []float32 {
+	hits := []float32{}
+	b.traverse(toArray(*ray.Origin), toArray(*ray.Ray), tmax, func(tri int, t float32, u float32, v float32) float32 {
+		hits = append(hits, t)
+		return tmax
+	})
+	sort.Slice(hits, func(i int, j int) bool { return hits[i] < hits[j] })
+	merged := hits[:0]
+	for _, t := range hits {
+		if len(merged) == 0 || t-merged[len(merged)-1] > eps {
+			merged = append(merged, t)
+		}
+	}
+	return merged
+}
+
+//Voxelize returns the cells of the grid of spacing dx anchored at the mesh bounds
+//minimum whose centers lie inside a closed mesh by the parity of the crossings
+//along their z column, with the number of cells covering the bounds per axis
+func (b *BVH) Voxelize(dx float32) ([3]int, [][3]int) {
+	min, max := b.Bounds()
+	var n [3]int
+	for a := 0; a < 3; a++ {
+		n[a] = int(math.Ceil(float64((max[a] - min[a]) / dx)))
+		if n[a] < 1 {
+			n[a] = 1
+		}
+	}
+	cells := [][3]int{}
+	for i := 0; i < n[0]; i++ {
+		for j := 0; j < n[1]; j++ {
+			origin := vector.Vec{min[0] + (float32(i)+0.5)*dx, min[1] + (float32(j)+0.5)*dx, min[2] - dx}
+			hits := b.Hits(geom.NewRay(origin, vector.Vec{0, 0, 1}), max[2]-min[2]+2*dx, 1e-5*dx)
+			for k := 0; k < n[2]; k++ {
+				z := min[2] + (float32(k)+0.5)*dx
+				crossed := 0
+				for _, t := range hits {
+					if origin[2]+t < z {
+						crossed++
+					}
+				}
+				if crossed%2 == 1 {
+					cells = append(cells, [3]int{i, j, k})
+				}
+			}
+		}
+	}
+	return n, cells
+}
+
+//Winding returns the generalized winding number of the mesh about p, the sum of
+//the signed triangle solid angles over 4 pi. It is 1 inside and 0 outside a
+//closed outward wound mesh and degrades smoothly across holes, Jacobson 2013
+func (b *BVH) Winding(p vector.Vec) float32 {
+	q := [3]float64{float64(p[0]), float64(p[1]), float64(p[2])}
+	sum := float64(0)
+	for tri := 0; tri < b.Triangles(); tri++ {
+		var r [3][3]float64
+		var l [3]float64
+		for k := 0; k < 3; k++ {
+			v := b.Vertex(tri, k)
+			r[k] = [3]float64{float64(v[0]) - q[0], float64(v[1]) - q[1], float64(v[2]) - q[2]}
+			l[k] = math.Sqrt(dot64(r[k], r[k]))
+		}
+		//Van Oosterom and Strackee solid angle
+		num := dot64(r[0], cross64(r[1], r[2]))
+		den := l[0]*l[1]*l[2] + dot64(r[0], r[1])*l[2] + dot64(r[1], r[2])*l[0] + dot64(r[2], r[0])*l[1]
+		sum += 2 * math.Atan2(num, den)
+	}
+	return float32(sum / (4 * math.Pi))
+}
@@ -0,0 +1,188 @@
+package bvh
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/triangle"
+	"github.com/andewx/dieselfluid/gltf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/scene"
+)
+
+//soup returns n random small triangles in the unit cube
+func soup(n int, rng *rand.Rand) []float32 {
+	positions := make([]float32, 0, n*9)
+	for t := 0; t < n; t++ {
+		c := [3]float32{rng.Float32(), rng.Float32(), rng.Float32()}
+		for k := 0; k < 3; k++ {
+			for a := 0; a < 3; a++ {
+				positions = append(positions, c[a]+rng.Float32()*0.1-0.05)
+			}
+		}
+	}
+	return positions
+}
+
+func TestQueries(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	b := New(soup(2000, rng), nil)
+	if len(b.Nodes) < 2*b.Triangles()/(2*BVH_LEAF_SIZE) {
+		t.Errorf("Hierarchy of %d nodes over %d triangles\n", len(b.Nodes), b.Triangles())
+	}
+	for _, n := range b.Nodes {
+		if n.Count > 2*BVH_LEAF_SIZE {
+			t.Fatalf("Leaf holds %d triangles\n", n.Count)
+		}
+	}
+
+	inf := float32(math.Inf(1))
+	for q := 0; q < 200; q++ {
+		origin := vector.Vec{rng.Float32()*2 - 0.5, rng.Float32()*2 - 0.5, rng.Float32()*2 - 0.5}
+		ray := geom.NewRay(origin, vector.Vec{rng.Float32() - 0.5, rng.Float32() - 0.5, rng.Float32() - 0.5})
+		exact := Hit{T: inf, Triangle: -1}
+		crossings := 0
+		for tri := 0; tri < b.Triangles(); tri++ {
+			if d, u, v, ok := b.IntersectTriangle(tri, toArray(origin), toArray(*ray.Ray)); ok {
+				crossings++
+				if d < exact.T {
+					exact = Hit{d, u, v, tri}
+				}
+			}
+		}
+		hit, ok := b.Intersect(ray, inf)
+		if ok != (exact.Triangle >= 0) || hit != exact {
+			t.Fatalf("Ray hit %v expected %v\n", hit, exact)
+		}
+		if b.Crossings(ray, inf) != crossings || b.Occluded(ray, inf) != ok {
+			t.Fatalf("Ray crossings %d expected %d\n", b.Crossings(ray, inf), crossings)
+		}
+		if ok {
+			p, q, r := b.Triangle(hit.Triangle)
+			w := vector.Add(vector.Scale(p, 1-hit.U-hit.V), vector.Add(vector.Scale(q, hit.U), vector.Scale(r, hit.V)))
+			if vector.Dist(w, ray.At(hit.T)) > 1e-4 {
+				t.Fatalf("Barycentric point %v ray point %v\n", w, ray.At(hit.T))
+			}
+		}
+
+		closest := inf
+		for tri := 0; tri < b.Triangles(); tri++ {
+			c, _, _ := b.ClosestOnTriangle(tri, toArray(origin))
+			closest = float32(math.Min(float64(closest), float64(vector.Dist(c[:], origin))))
+		}
+		near, ok := b.ClosestPoint(origin, inf)
+		if !ok || math.Abs(float64(near.Distance-closest)) > 1e-6 || math.Abs(float64(vector.Dist(near.Point, origin)-closest)) > 1e-5 {
+			t.Fatalf("Closest distance %f expected %f\n", near.Distance, closest)
+		}
+		if _, ok := b.ClosestPoint(origin, closest*0.99); ok {
+			t.Fatalf("Closest point found beyond the search distance\n")
+		}
+	}
+}
+
+func TestInside(t *testing.T) {
+	box := mesh.Box(2, 1, 1, vector.Vec{0.5, 0, 0})
+	b := FromMesh(&box)
+	rng := rand.New(rand.NewSource(9))
+	for q := 0; q < 500; q++ {
+		p := vector.Vec{rng.Float32()*4 - 1.5, rng.Float32()*2 - 1, rng.Float32()*2 - 1}
+		inside := p[0] > -0.5 && p[0] < 1.5 && p[1] > -0.5 && p[1] < 0.5 && p[2] > -0.5 && p[2] < 0.5
+		if b.Inside(p) != inside {
+			t.Fatalf("Point %v inside %v\n", p, inside)
+		}
+	}
+	if min, max := b.Bounds(); min != [3]float32{-0.5, -0.5, -0.5} || max != [3]float32{1.5, 0.5, 0.5} {
+		t.Errorf("Box bounds %v %v\n", min, max)
+	}
+	if n, cells := b.Voxelize(0.25); n != [3]int{8, 4, 4} || len(cells) != 8*4*4 {
+		t.Errorf("Box voxelized into %v grid with %d solid cells\n", n, len(cells))
+	}
+
+	//Queries on an empty hierarchy find nothing
+	e := New(nil, nil)
+	if _, ok := e.Intersect(geom.NewRay(vector.Vec{0, 0, 2}, vector.Vec{0, 0, -1}), 10); ok || e.Inside(vector.Vec{0, 0, 0}) {
+		t.Errorf("Empty hierarchy hit\n")
+	}
+	if _, ok := e.ClosestPoint(vector.Vec{0, 0, 0}, 10); ok {
+		t.Errorf("Empty hierarchy closest point\n")
+	}
+	if _, ok := e.Sweep(vector.Vec{0, 0, 0}, vector.Vec{1, 0, 0}, 0.1); ok {
+		t.Errorf("Empty hierarchy sweep contact\n")
+	}
+}
+
+func TestPrimitive(t *testing.T) {
+	//Unit square as a two triangle strip with unsigned short indices
+	data := make([]byte, 48+8)
+	for i, v := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 1, 0} {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	for i, v := range []uint16{0, 1, 2, 3} {
+		binary.LittleEndian.PutUint16(data[48+i*2:], v)
+	}
+	indices, mode := 1, scene.MODE_TRIANGLE_STRIP
+	scn := scene.Scene{Buffers: [][]byte{data}, Root: &gltf.GlTF{
+		Buffers:     []*gltf.Buffer{{ByteLength: len(data)}},
+		BufferViews: []*gltf.BufferView{{Buffer: 0, ByteLength: 48}, {Buffer: 0, ByteOffset: 48, ByteLength: 8}},
+		Accessors: []*gltf.Accessor{
+			{BufferView: 0, ComponentType: scene.COMPONENT_FLOAT, Count: 4, Type: "VEC3"},
+			{BufferView: 1, ComponentType: scene.COMPONENT_UNSIGNED_SHORT, Count: 4, Type: "SCALAR"},
+		},
+		Meshes: []*gltf.Mesh{{Primitives: []*gltf.MeshPrimitive{{Attributes: map[string]int{"POSITION": 0}, Indices: &indices, Mode: &mode}}}},
+	}}
+	b, err := FromPrimitive(&scn, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Triangles() != 2 || b.Normal(0)[2] != 1 || b.Normal(1)[2] != 1 {
+		t.Fatalf("Strip triangles %d indices %v\n", b.Triangles(), b.Indices)
+	}
+	hit, ok := b.Intersect(geom.NewRay(vector.Vec{0.8, 0.7, 2}, vector.Vec{0, 0, -1}), 10)
+	if !ok || hit.Triangle != 1 || math.Abs(float64(hit.T-2)) > 1e-6 {
+		t.Errorf("Square hit %v\n", hit)
+	}
+	if _, err := FromPrimitive(&scn, 0, 1); err == nil {
+		t.Errorf("Missing primitive accepted\n")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	rng := rand.New(rand.NewSource(13))
+	b := New(soup(500, rng), nil)
+	r := float32(0.02)
+	hits := 0
+	for q := 0; q < 300; q++ {
+		p0 := vector.Vec{rng.Float32()*1.4 - 0.2, rng.Float32()*1.4 - 0.2, rng.Float32()*1.4 - 0.2}
+		p1 := vector.Add(p0, vector.Vec{rng.Float32() - 0.5, rng.Float32() - 0.5, rng.Float32() - 0.5})
+		exact := float32(2)
+		for tri := 0; tri < b.Triangles(); tri++ {
+			p, q, c := b.Triangle(tri)
+			tr := triangle.InitTriangle(p, q, c)
+			if s, _, ok := tr.Sweep(p0, p1, r); ok && s < exact {
+				exact = s
+			}
+		}
+		contact, ok := b.Sweep(p0, p1, r)
+		if ok != (exact <= 1) || (ok && contact.T != exact) {
+			t.Fatalf("Sweep contact %v expected %f\n", contact, exact)
+		}
+		if start, _ := b.ClosestPoint(p0, 1); !ok || start.Distance < r {
+			continue
+		}
+		hits++
+		//A sphere starting clear touches the surface at the contact without overlap
+		if near, _ := b.ClosestPoint(contact.Point, 1); math.Abs(float64(near.Distance-r)) > 1e-4 {
+			t.Fatalf("Contact at distance %f from the surface\n", near.Distance)
+		}
+		if vector.Dot(contact.Normal, vector.Sub(p1, p0)) >= 0 {
+			t.Fatalf("Contact normal %v along the motion\n", contact.Normal)
+		}
+	}
+	if hits == 0 {
+		t.Errorf("No swept contacts\n")
+	}
+}
@@ -0,0 +1,143 @@
+package convex
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/transform"
+)
+
+func TestQuickhull(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	points := []vector.Vec{}
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 2; y++ {
+			for z := 0; z < 2; z++ {
+				points = append(points, vector.Vec{float32(x), float32(y), float32(z)})
+			}
+		}
+	}
+	//Interior points and points on the faces do not become hull vertices
+	for i := 0; i < 500; i++ {
+		points = append(points, vector.Vec{rng.Float32(), rng.Float32(), rng.Float32()})
+		points = append(points, vector.Vec{rng.Float32(), rng.Float32(), 1})
+	}
+	h, err := Quickhull(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Points) != 8 || math.Abs(float64(h.Volume()-1)) > 1e-5 || !h.Indexed().IsWatertight() {
+		t.Fatalf("Cube hull %d vertices %d triangles volume %f\n", len(h.Points), h.Triangles(), h.Volume())
+	}
+	for _, p := range points {
+		if !h.Contains(p, 1e-5) {
+			t.Errorf("Hull misses %v\n", p)
+		}
+	}
+	if s := h.Support(vector.Vec{1, 1, -1}); s[0] != 1 || s[1] != 1 || s[2] != 0 {
+		t.Errorf("Support %v\n", s)
+	}
+
+	//Points on a sphere are all hull vertices
+	sphere := []vector.Vec{}
+	for i := 0; i < 400; i++ {
+		sphere = append(sphere, vector.Norm(vector.Vec{float32(rng.NormFloat64()), float32(rng.NormFloat64()), float32(rng.NormFloat64())}))
+	}
+	h, err = Quickhull(sphere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Points) != 400 || h.Triangles() != 2*400-4 || h.Volume() > 4*math.Pi/3 || h.Volume() < 3.9 {
+		t.Errorf("Sphere hull %d vertices %d triangles volume %f\n", len(h.Points), h.Triangles(), h.Volume())
+	}
+	if _, err := Quickhull([]vector.Vec{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}}); err == nil {
+		t.Errorf("Coplanar points accepted\n")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	box := mesh.Box(1, 1, 1, vector.Vec{0, 0, 0})
+	cube, err := FromMesh(&box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(x float32, y float32, z float32) *Transformed {
+		t := transform.Transform{Matrix: matrix.Mat4(1.0)}
+		return &Transformed{cube, t.Translate(vector.Vec{x, y, z})}
+	}
+
+	//Separated cubes, face to face and edge to edge
+	d := Distance(at(0, 0, 0), at(3, 0.2, 0.1))
+	if math.Abs(float64(d.Distance-2)) > 1e-4 || d.Normal[0] < 0.999 || math.Abs(float64(d.PointA[0]-0.5)) > 1e-4 || math.Abs(float64(d.PointB[0]-2.5)) > 1e-4 {
+		t.Errorf("Face distance %v\n", d)
+	}
+	if d := Distance(at(0, 0, 0), at(2, 2, 0)); math.Abs(float64(d.Distance)-math.Sqrt2) > 1e-4 {
+		t.Errorf("Edge distance %f\n", d.Distance)
+	}
+
+	//Rotated cube corner towards a sphere
+	sphere := &geom.Sphere{Radius: 0.5, Transform: &transform.Transform{Matrix: matrix.Mat4(1.0)}}
+	sphere.Transform.Matrix[12] = 3
+	rotation := matrix.Mat4(1.0)
+	c, s := float32(math.Cos(math.Pi/4)), float32(math.Sin(math.Pi/4))
+	rotation[0], rotation[1], rotation[4], rotation[5] = c, -s, s, c
+	d = Distance(&Transformed{cube, rotation}, sphere)
+	if expected := 3 - 0.5 - math.Sqrt2/2; math.Abs(float64(d.Distance)-expected) > 1e-4 || !Intersects(sphere, sphere) {
+		t.Errorf("Rotated cube to sphere distance %f expected %f\n", d.Distance, expected)
+	}
+
+	//Overlapping cubes report the penetration depth along the shallowest axis
+	d = Distance(at(0, 0, 0), at(0.2, 0.7, 0.1))
+	if math.Abs(float64(d.Distance+0.3)) > 1e-4 || d.Normal[1] < 0.999 || !Intersects(at(0, 0, 0), at(0.2, 0.7, 0.1)) {
+		t.Errorf("Penetration %v\n", d)
+	}
+	if diff := vector.Sub(d.PointA, d.PointB); math.Abs(float64(vector.Dot(diff, d.Normal)-0.3)) > 1e-4 {
+		t.Errorf("Deepest points %v %v\n", d.PointA, d.PointB)
+	}
+	if Intersects(at(0, 0, 0), at(1.01, 0, 0)) {
+		t.Errorf("Separated cubes intersect\n")
+	}
+}
+
+func TestDecompose(t *testing.T) {
+	//A U of three boxes, legs 1 by 3 and a 1 by 1 bridge
+	vertices := []vector.Vec{}
+	for _, b := range [][3]float32{{0.5, 1.5, 0.5}, {1.5, 0.5, 0.5}, {2.5, 1.5, 0.5}} {
+		h := float32(3)
+		if b[0] == 1.5 {
+			h = 1
+		}
+		box := mesh.Box(1, h, 1, vector.Vec{b[0], b[1], b[2]})
+		vertices = append(vertices, box.Vertexes...)
+	}
+	u := mesh.InitMesh(vertices, vector.Vec{0, 0, 0})
+	whole, _ := FromMesh(&u)
+	hulls, err := Decompose(&u, DefaultDecompose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	volume := float32(0)
+	for _, h := range hulls {
+		volume += h.Volume()
+		if h.Contains(vector.Vec{1.5, 2, 0.5}, 0) {
+			t.Errorf("Hull fills the gap of the U\n")
+		}
+	}
+	if len(hulls) < 2 || len(hulls) > DECOMPOSE_HULLS || volume < 7 || volume > 7.7 || whole.Volume() != 9 {
+		t.Errorf("Decomposed into %d hulls volume %f\n", len(hulls), volume)
+	}
+	for _, p := range []vector.Vec{{0.5, 2.5, 0.5}, {2.5, 2.5, 0.5}, {1.5, 0.5, 0.5}} {
+		inside := false
+		for _, h := range hulls {
+			inside = inside || h.Contains(p, 0)
+		}
+		if !inside {
+			t.Errorf("Point %v in no hull\n", p)
+		}
+	}
+}
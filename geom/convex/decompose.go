@@ -0,0 +1,230 @@
+package convex
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/bvh"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Decomposition Defaults
+const DECOMPOSE_RESOLUTION = 32
+const DECOMPOSE_HULLS = 16
+const DECOMPOSE_CONCAVITY = 0.02
+const DECOMPOSE_PLANES = 8
+
+type DecomposeParams struct {
+	Resolution int     //Voxels along the longest side of the mesh bounds
+	MaxHulls   int     //Maximum number of convex parts
+	Concavity  float32 //Accepted hull volume in excess of a part as a fraction of the mesh volume
+	Planes     int     //Candidate cutting planes per axis
+}
+
+//DefaultDecompose returns the default decomposition parameters
+func DefaultDecompose() DecomposeParams {
+	return DecomposeParams{DECOMPOSE_RESOLUTION, DECOMPOSE_HULLS, DECOMPOSE_CONCAVITY, DECOMPOSE_PLANES}
+}
+
+//voxels is the solid voxelization of a mesh, cells of size dx from min
+type voxels struct {
+	min   [3]float32
+	dx    float32
+	n     [3]int
+	cells [][3]int
+	index map[[3]int]int
+}
+
+//part is a connected set of voxels with its convex hull and concavity, the hull
+//volume in excess of the voxels relative to the whole volume
+type part struct {
+	cells     []int
+	hull      *Hull
+	concavity float32
+}
+
+func voxelize(m *mesh.Mesh, resolution int) *voxels {
+	tree := bvh.FromMesh(m)
+	min, max := tree.Bounds()
+	longest := float32(0)
+	for a := 0; a < 3; a++ {
+		longest = float32(math.Max(float64(longest), float64(max[a]-min[a])))
+	}
+	v := voxels{min: min, dx: longest / float32(resolution), index: map[[3]int]int{}}
+	v.n, v.cells = tree.Voxelize(v.dx)
+	for c, cell := range v.cells {
+		v.index[cell] = c
+	}
+	return &v
+}
+
+var faceNeighbors = [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+//hull returns the convex hull of the voxel corners on the surface of the cells
+func (v *voxels) hull(cells []int) (*Hull, error) {
+	in := make(map[int]bool, len(cells))
+	for _, c := range cells {
+		in[c] = true
+	}
+	corners := map[[3]int]bool{}
+	for _, c := range cells {
+		cell := v.cells[c]
+		exposed := false
+		for _, d := range faceNeighbors {
+			n, ok := v.index[[3]int{cell[0] + d[0], cell[1] + d[1], cell[2] + d[2]}]
+			exposed = exposed || !ok || !in[n]
+		}
+		if !exposed {
+			continue
+		}
+		for k := 0; k < 8; k++ {
+			corners[[3]int{cell[0] + k&1, cell[1] + (k>>1)&1, cell[2] + (k>>2)&1}] = true
+		}
+	}
+	points := make([]vector.Vec, 0, len(corners))
+	for c := range corners {
+		points = append(points, vector.Vec{v.min[0] + float32(c[0])*v.dx, v.min[1] + float32(c[1])*v.dx, v.min[2] + float32(c[2])*v.dx})
+	}
+	return Quickhull(points)
+}
+
+//components splits the cells into face connected groups
+func (v *voxels) components(cells []int) [][]int {
+	label := make(map[int]int, len(cells))
+	for _, c := range cells {
+		label[c] = -1
+	}
+	groups := [][]int{}
+	for _, seed := range cells {
+		if label[seed] >= 0 {
+			continue
+		}
+		group := []int{seed}
+		label[seed] = len(groups)
+		for q := 0; q < len(group); q++ {
+			cell := v.cells[group[q]]
+			for _, d := range faceNeighbors {
+				n, ok := v.index[[3]int{cell[0] + d[0], cell[1] + d[1], cell[2] + d[2]}]
+				if l, member := label[n]; ok && member && l < 0 {
+					label[n] = len(groups)
+					group = append(group, n)
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func (v *voxels) part(cells []int, total float32) (*part, error) {
+	h, err := v.hull(cells)
+	if err != nil {
+		return nil, err
+	}
+	solid := float32(len(cells)) * v.dx * v.dx * v.dx
+	return &part{cells, h, float32(math.Max(0, float64(h.Volume()-solid))) / total}, nil
+}
+
+//Decompose approximates a closed mesh by convex hulls in the manner of V-HACD.
+//The mesh is voxelized and its parts are recursively cut by the axis aligned
+//plane minimizing the summed concavity of the two sides, the most concave part
+//first, until every part is within the concavity or the hull count is reached.
+//The hulls enclose the voxels of their parts
+func Decompose(m *mesh.Mesh, p DecomposeParams) ([]*Hull, error) {
+	v := voxelize(m, p.Resolution)
+	if len(v.cells) == 0 {
+		return nil, fmt.Errorf("Mesh encloses no voxels at resolution %d", p.Resolution)
+	}
+	total := float32(len(v.cells)) * v.dx * v.dx * v.dx
+	all := make([]int, len(v.cells))
+	for i := range all {
+		all[i] = i
+	}
+	parts := []*part{}
+	for _, cells := range v.components(all) {
+		pt, err := v.part(cells, total)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, pt)
+	}
+
+	for len(parts) < p.MaxHulls {
+		worst := -1
+		for i, pt := range parts {
+			if pt.concavity > p.Concavity && (worst < 0 || pt.concavity > parts[worst].concavity) {
+				worst = i
+			}
+		}
+		if worst < 0 {
+			break
+		}
+		pieces, err := v.cut(parts[worst], p.Planes, total)
+		if err != nil {
+			return nil, err
+		}
+		if pieces == nil || len(parts)-1+len(pieces) > p.MaxHulls {
+			//Uncuttable or over the budget, accept the part as it is
+			parts[worst].concavity = 0
+			continue
+		}
+		parts = append(append(parts[:worst:worst], parts[worst+1:]...), pieces...)
+	}
+
+	hulls := make([]*Hull, len(parts))
+	for i, pt := range parts {
+		hulls[i] = pt.hull
+	}
+	return hulls, nil
+}
+
+//cut splits a part along the best of the candidate planes on each axis into
+//the connected pieces of both sides, nil when the part is one voxel thick
+func (v *voxels) cut(pt *part, planes int, total float32) ([]*part, error) {
+	var best []*part
+	cost := float32(math.Inf(1))
+	for a := 0; a < 3; a++ {
+		lo, hi := math.MaxInt32, math.MinInt32
+		for _, c := range pt.cells {
+			if x := v.cells[c][a]; x < lo {
+				lo = x
+			}
+			if x := v.cells[c][a]; x > hi {
+				hi = x
+			}
+		}
+		tried := map[int]bool{}
+		for s := 1; s <= planes; s++ {
+			plane := lo + (hi-lo+1)*s/(planes+1)
+			if plane <= lo || plane > hi || tried[plane] {
+				continue
+			}
+			tried[plane] = true
+			below, above := []int{}, []int{}
+			for _, c := range pt.cells {
+				if v.cells[c][a] < plane {
+					below = append(below, c)
+				} else {
+					above = append(above, c)
+				}
+			}
+			pieces := []*part{}
+			sum := float32(0)
+			for _, side := range [][]int{below, above} {
+				for _, cells := range v.components(side) {
+					piece, err := v.part(cells, total)
+					if err != nil {
+						return nil, err
+					}
+					pieces = append(pieces, piece)
+					sum += piece.concavity
+				}
+			}
+			if sum < cost {
+				best, cost = pieces, sum
+			}
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,320 @@
+package convex
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/geom"
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//GJK and EPA Limits
+const GJK_ITERATIONS = 64
+const EPA_ITERATIONS = 128
+const GJK_EPSILON = 1e-6
+
+//Transformed places a convex shape with an affine transform, world points being
+//the upper 3x3 A of the matrix applied as with CrossVec plus the translation in
+//Transform[12:15] as set by transform.Translate. The support of the placed shape
+//is A s(A^T d) offset by the translation
+type Transformed struct {
+	Shape     geom.Convex
+	Transform matrix.Mat
+}
+
+//Support returns the farthest point of the placed shape along d
+func (t *Transformed) Support(d vector.Vec) vector.Vec {
+	m := t.Transform
+	local := vector.Vec{m[0]*d[0] + m[4]*d[1] + m[8]*d[2], m[1]*d[0] + m[5]*d[1] + m[9]*d[2], m[2]*d[0] + m[6]*d[1] + m[10]*d[2]}
+	s := t.Shape.Support(local)
+	return vector.Vec{
+		m[0]*s[0] + m[1]*s[1] + m[2]*s[2] + m[12],
+		m[4]*s[0] + m[5]*s[1] + m[6]*s[2] + m[13],
+		m[8]*s[0] + m[9]*s[1] + m[10]*s[2] + m[14],
+	}
+}
+
+//Proximity is the result of a distance query between shapes A and B. Distance
+//is the separation or the negative penetration depth when the shapes overlap,
+//PointA and PointB the closest or deepest points and Normal the unit direction
+//moving B away from A
+type Proximity struct {
+	Distance float32
+	PointA   vector.Vec
+	PointB   vector.Vec
+	Normal   vector.Vec
+}
+
+//vertex is a point of the Minkowski difference A - B with its support points
+type vertex struct {
+	w, a, b vec3
+}
+
+func support(a geom.Convex, b geom.Convex, d vec3) vertex {
+	pa := toVec3(a.Support(d.vec()))
+	pb := toVec3(b.Support(d.scale(-1).vec()))
+	return vertex{pa.sub(pb), pa, pb}
+}
+
+//closest reduces the simplex to the smallest sub simplex holding the point
+//closest to the origin, returning that point and its barycentric weights. A
+//tetrahedron containing the origin is kept whole with inside set
+func closest(s []vertex) ([]vertex, []float64, vec3, bool) {
+	switch len(s) {
+	case 1:
+		return s, []float64{1}, s[0].w, false
+	case 2:
+		a, b := s[0].w, s[1].w
+		ab := b.sub(a)
+		t := -a.dot(ab)
+		if t <= 0 || ab.dot(ab) == 0 {
+			return s[:1], []float64{1}, a, false
+		}
+		if t >= ab.dot(ab) {
+			return s[1:], []float64{1}, b, false
+		}
+		t /= ab.dot(ab)
+		return s, []float64{1 - t, t}, a.add(ab.scale(t)), false
+	case 3:
+		return closestTriangle(s)
+	}
+	//Tetrahedron, the origin inside when it is behind every face
+	faces := [4][4]int{{0, 1, 2, 3}, {0, 3, 1, 2}, {0, 2, 3, 1}, {1, 3, 2, 0}}
+	inside := true
+	var best []vertex
+	var weights []float64
+	var point vec3
+	dist := math.Inf(1)
+	for _, f := range faces {
+		a, b, c, d := s[f[0]].w, s[f[1]].w, s[f[2]].w, s[f[3]].w
+		n := b.sub(a).cross(c.sub(a))
+		if n.dot(a.scale(-1))*n.dot(d.sub(a)) < 0 {
+			inside = false
+			sub, w, p, _ := closestTriangle([]vertex{s[f[0]], s[f[1]], s[f[2]]})
+			if p.dot(p) < dist {
+				best, weights, point, dist = sub, w, p, p.dot(p)
+			}
+		}
+	}
+	if inside {
+		return s, nil, vec3{}, true
+	}
+	return best, weights, point, false
+}
+
+//closestTriangle finds the closest point of a simplex triangle to the origin by
+//its Voronoi regions, Ericson 5.1.5
+func closestTriangle(s []vertex) ([]vertex, []float64, vec3, bool) {
+	a, b, c := s[0].w, s[1].w, s[2].w
+	ab, ac, ap := b.sub(a), c.sub(a), a.scale(-1)
+	d1, d2 := ab.dot(ap), ac.dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		return []vertex{s[0]}, []float64{1}, a, false
+	}
+	bp := b.scale(-1)
+	d3, d4 := ab.dot(bp), ac.dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		return []vertex{s[1]}, []float64{1}, b, false
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		t := d1 / (d1 - d3)
+		return []vertex{s[0], s[1]}, []float64{1 - t, t}, a.add(ab.scale(t)), false
+	}
+	cp := c.scale(-1)
+	d5, d6 := ab.dot(cp), ac.dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		return []vertex{s[2]}, []float64{1}, c, false
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		t := d2 / (d2 - d6)
+		return []vertex{s[0], s[2]}, []float64{1 - t, t}, a.add(ac.scale(t)), false
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		t := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return []vertex{s[1], s[2]}, []float64{1 - t, t}, b.add(c.sub(b).scale(t)), false
+	}
+	denom := 1 / (va + vb + vc)
+	v, w := vb*denom, vc*denom
+	return s, []float64{1 - v - w, v, w}, a.add(ab.scale(v)).add(ac.scale(w)), false
+}
+
+//gjk runs the Gilbert Johnson Keerthi distance algorithm on the Minkowski
+//difference A - B. Returns the final simplex with the weights of its closest
+//point to the origin, or the simplex enclosing the origin when overlapping
+func gjk(a geom.Convex, b geom.Convex) ([]vertex, []float64, vec3, bool) {
+	s := []vertex{support(a, b, vec3{1, 0, 0})}
+	weights := []float64{1}
+	v := s[0].w
+	for i := 0; i < GJK_ITERATIONS; i++ {
+		if v.dot(v) < GJK_EPSILON*GJK_EPSILON {
+			return s, weights, v, true
+		}
+		w := support(a, b, v.scale(-1))
+		//No support point gets closer to the origin than v
+		if v.dot(v)-v.dot(w.w) <= GJK_EPSILON*v.dot(v) {
+			return s, weights, v, false
+		}
+		for _, u := range s {
+			if u.w == w.w {
+				return s, weights, v, false
+			}
+		}
+		reduced, wts, p, inside := closest(append(append([]vertex{}, s...), w))
+		if inside {
+			return reduced, nil, vec3{}, true
+		}
+		if p.dot(p) >= v.dot(v) {
+			return s, weights, v, false
+		}
+		s, weights, v = reduced, wts, p
+	}
+	return s, weights, v, false
+}
+
+//Intersects reports whether the convex shapes overlap
+func Intersects(a geom.Convex, b geom.Convex) bool {
+	_, _, _, overlap := gjk(a, b)
+	return overlap
+}
+
+//Distance returns the separation of the convex shapes with their closest points
+//by GJK, or the penetration depth with the deepest points by the expanding
+//polytope algorithm when they overlap
+func Distance(a geom.Convex, b geom.Convex) Proximity {
+	s, weights, v, overlap := gjk(a, b)
+	if overlap {
+		return epa(a, b, s)
+	}
+	pa, pb := vec3{}, vec3{}
+	for i, u := range s {
+		pa = pa.add(u.a.scale(weights[i]))
+		pb = pb.add(u.b.scale(weights[i]))
+	}
+	d := math.Sqrt(v.dot(v))
+	return Proximity{float32(d), pa.vec(), pb.vec(), v.scale(-1).norm().vec()}
+}
+
+//epaFace is a polytope triangle with its outward normal and origin distance
+type epaFace struct {
+	v [3]int
+	n vec3
+	d float64
+}
+
+//epa expands the simplex enclosing the origin into the Minkowski difference
+//towards its boundary face nearest the origin, giving the penetration depth
+func epa(a geom.Convex, b geom.Convex, s []vertex) Proximity {
+	//Blow a degenerate simplex up into a tetrahedron
+	axes := []vec3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {-1, 0, 0}, {0, -1, 0}, {0, 0, -1}}
+	for len(s) < 4 {
+		directions := axes
+		if len(s) == 2 {
+			e := s[1].w.sub(s[0].w)
+			directions = []vec3{}
+			for _, x := range axes[:3] {
+				if n := e.cross(x); n.dot(n) > 0 {
+					directions = append(directions, n, n.scale(-1))
+				}
+			}
+		} else if len(s) == 3 {
+			n := s[1].w.sub(s[0].w).cross(s[2].w.sub(s[0].w))
+			directions = []vec3{n, n.scale(-1)}
+		}
+		grown := false
+		for _, d := range directions {
+			w := support(a, b, d)
+			if far := w.w.sub(s[0].w).dot(d.norm()); far > GJK_EPSILON {
+				s = append(s, w)
+				grown = true
+				break
+			}
+		}
+		if !grown {
+			//Flat difference, the shapes only touch
+			return Proximity{0, s[0].a.vec(), s[0].b.vec(), vector.Vec{0, 0, 0}}
+		}
+	}
+
+	points := append([]vertex{}, s...)
+	center := vec3{}
+	for _, u := range points {
+		center = center.add(u.w.scale(0.25))
+	}
+	newFace := func(i int, j int, k int) (epaFace, bool) {
+		n := points[j].w.sub(points[i].w).cross(points[k].w.sub(points[i].w))
+		if n.dot(n) == 0 {
+			return epaFace{}, false
+		}
+		n = n.norm()
+		if n.dot(points[i].w.sub(center)) < 0 {
+			j, k = k, j
+			n = n.scale(-1)
+		}
+		return epaFace{[3]int{i, j, k}, n, n.dot(points[i].w)}, true
+	}
+	faces := []epaFace{}
+	for _, f := range [][3]int{{0, 1, 2}, {0, 3, 1}, {0, 2, 3}, {1, 3, 2}} {
+		if face, ok := newFace(f[0], f[1], f[2]); ok {
+			faces = append(faces, face)
+		}
+	}
+
+	var nearest epaFace
+	for i := 0; i < EPA_ITERATIONS && len(faces) > 0; i++ {
+		nearest = faces[0]
+		for _, f := range faces[1:] {
+			if f.d < nearest.d {
+				nearest = f
+			}
+		}
+		w := support(a, b, nearest.n)
+		if w.w.dot(nearest.n)-nearest.d <= GJK_EPSILON*math.Max(1, nearest.d) {
+			break
+		}
+		points = append(points, w)
+		p := len(points) - 1
+		//Remove the faces seen from w and close the hole along its horizon
+		edges := map[[2]int]bool{}
+		kept := faces[:0]
+		for _, f := range faces {
+			if f.n.dot(w.w.sub(points[f.v[0]].w)) > 0 {
+				for k := 0; k < 3; k++ {
+					e := [2]int{f.v[k], f.v[(k+1)%3]}
+					if edges[[2]int{e[1], e[0]}] {
+						delete(edges, [2]int{e[1], e[0]})
+					} else {
+						edges[e] = true
+					}
+				}
+				continue
+			}
+			kept = append(kept, f)
+		}
+		faces = kept
+		for e := range edges {
+			n := points[e[1]].w.sub(points[e[0]].w).cross(w.w.sub(points[e[0]].w))
+			if n.dot(n) == 0 {
+				continue
+			}
+			n = n.norm()
+			faces = append(faces, epaFace{[3]int{e[0], e[1], p}, n, n.dot(points[e[0]].w)})
+		}
+	}
+
+	//Deepest points from the barycentric weights of the origin projection
+	q := nearest.n.scale(nearest.d)
+	x, y, z := points[nearest.v[0]], points[nearest.v[1]], points[nearest.v[2]]
+	n := y.w.sub(x.w).cross(z.w.sub(x.w))
+	area := n.dot(n)
+	u, v := 1.0/3, 1.0/3
+	if area > 0 {
+		u = y.w.sub(q).cross(z.w.sub(q)).dot(n) / area
+		v = z.w.sub(q).cross(x.w.sub(q)).dot(n) / area
+	}
+	pa := x.a.scale(u).add(y.a.scale(v)).add(z.a.scale(1 - u - v))
+	pb := x.b.scale(u).add(y.b.scale(v)).add(z.b.scale(1 - u - v))
+	return Proximity{float32(-nearest.d), pa.vec(), pb.vec(), nearest.n.vec()}
+}
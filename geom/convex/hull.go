@@ -0,0 +1,283 @@
+//Convex shapes for rigid colliders. Quickhull convex hulls of point sets and
+//meshes, approximate convex decomposition of concave meshes into voxelized
+//parts and GJK/EPA distance and penetration queries on support mappings
+package convex
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Relative tolerance of points above a hull face
+const HULL_EPSILON = 1e-6
+
+//Hull is a closed convex polyhedron with outward wound triangles. Planes holds
+//the outward unit normal and offset n.x = d of each triangle
+type Hull struct {
+	Points  []vector.Vec
+	Indices []int
+	Planes  [][4]float32
+}
+
+type vec3 [3]float64
+
+func toVec3(v vector.Vec) vec3 {
+	return vec3{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a vec3) add(b vec3) vec3 {
+	return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func (a vec3) scale(k float64) vec3 {
+	return vec3{a[0] * k, a[1] * k, a[2] * k}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a vec3) norm() vec3 {
+	if l := math.Sqrt(a.dot(a)); l > 0 {
+		return a.scale(1 / l)
+	}
+	return a
+}
+
+func (a vec3) vec() vector.Vec {
+	return vector.Vec{float32(a[0]), float32(a[1]), float32(a[2])}
+}
+
+//face is a quickhull triangle with the points left above it
+type face struct {
+	v       [3]int
+	n       vec3
+	d       float64
+	outside []int
+	dead    bool
+}
+
+//Quickhull computes the convex hull of the points, Barber et al. The hull is
+//grown from an initial tetrahedron by repeatedly adding the farthest point
+//above a face, replacing the faces it sees with a cone to their horizon.
+//Returns an error for fewer than four points or coplanar points
+func Quickhull(points []vector.Vec) (*Hull, error) {
+	if len(points) < 4 {
+		return nil, fmt.Errorf("Convex hull needs at least 4 points, got %d", len(points))
+	}
+	p := make([]vec3, len(points))
+	scale := 0.0
+	for i, v := range points {
+		p[i] = toVec3(v)
+		for a := 0; a < 3; a++ {
+			scale = math.Max(scale, math.Abs(p[i][a]))
+		}
+	}
+	eps := HULL_EPSILON * 3 * math.Max(scale, 1e-30)
+
+	//Initial tetrahedron from the widest axis extremes
+	i0, i1 := 0, 0
+	for a := 0; a < 3; a++ {
+		lo, hi := 0, 0
+		for i := range p {
+			if p[i][a] < p[lo][a] {
+				lo = i
+			}
+			if p[i][a] > p[hi][a] {
+				hi = i
+			}
+		}
+		if d := p[hi].sub(p[lo]); d.dot(d) > p[i1].sub(p[i0]).dot(p[i1].sub(p[i0])) {
+			i0, i1 = lo, hi
+		}
+	}
+	axis := p[i1].sub(p[i0]).norm()
+	i2, best := -1, eps
+	for i := range p {
+		if d := p[i].sub(p[i0]).cross(axis); math.Sqrt(d.dot(d)) > best {
+			i2, best = i, math.Sqrt(d.dot(d))
+		}
+	}
+	if i2 < 0 {
+		return nil, fmt.Errorf("Convex hull points are collinear")
+	}
+	normal := p[i1].sub(p[i0]).cross(p[i2].sub(p[i0])).norm()
+	i3, best := -1, eps
+	for i := range p {
+		if d := math.Abs(p[i].sub(p[i0]).dot(normal)); d > best {
+			i3, best = i, d
+		}
+	}
+	if i3 < 0 {
+		return nil, fmt.Errorf("Convex hull points are coplanar")
+	}
+	if p[i3].sub(p[i0]).dot(normal) > 0 {
+		i1, i2 = i2, i1
+	}
+
+	faces := []*face{}
+	edges := map[[2]int]int{}
+	add := func(a int, b int, c int) int {
+		n := p[b].sub(p[a]).cross(p[c].sub(p[a])).norm()
+		faces = append(faces, &face{v: [3]int{a, b, c}, n: n, d: n.dot(p[a])})
+		f := len(faces) - 1
+		edges[[2]int{a, b}], edges[[2]int{b, c}], edges[[2]int{c, a}] = f, f, f
+		return f
+	}
+	assign := func(candidates []int, targets []int, skip int) {
+		for _, i := range candidates {
+			if i == skip {
+				continue
+			}
+			for _, f := range targets {
+				if p[i].dot(faces[f].n)-faces[f].d > eps {
+					faces[f].outside = append(faces[f].outside, i)
+					break
+				}
+			}
+		}
+	}
+	initial := []int{add(i0, i1, i2), add(i0, i3, i1), add(i1, i3, i2), add(i2, i3, i0)}
+	all := make([]int, 0, len(p))
+	for i := range p {
+		if i != i0 && i != i1 && i != i2 && i != i3 {
+			all = append(all, i)
+		}
+	}
+	assign(all, initial, -1)
+
+	queue := append([]int{}, initial...)
+	for len(queue) > 0 {
+		f := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if faces[f].dead || len(faces[f].outside) == 0 {
+			continue
+		}
+		apex, far := -1, 0.0
+		for _, i := range faces[f].outside {
+			if d := p[i].dot(faces[f].n) - faces[f].d; d > far {
+				apex, far = i, d
+			}
+		}
+
+		//Faces seen from the apex, connected across their edges
+		visible := map[int]bool{f: true}
+		stack := []int{f}
+		for len(stack) > 0 {
+			g := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for k := 0; k < 3; k++ {
+				a, b := faces[g].v[k], faces[g].v[(k+1)%3]
+				if h, ok := edges[[2]int{b, a}]; ok && !visible[h] && p[apex].dot(faces[h].n)-faces[h].d > eps {
+					visible[h] = true
+					stack = append(stack, h)
+				}
+			}
+		}
+		horizon := [][2]int{}
+		orphans := []int{}
+		for g := range visible {
+			for k := 0; k < 3; k++ {
+				a, b := faces[g].v[k], faces[g].v[(k+1)%3]
+				if h, ok := edges[[2]int{b, a}]; ok && !visible[h] {
+					horizon = append(horizon, [2]int{a, b})
+				}
+			}
+			orphans = append(orphans, faces[g].outside...)
+		}
+		for g := range visible {
+			for k := 0; k < 3; k++ {
+				delete(edges, [2]int{faces[g].v[k], faces[g].v[(k+1)%3]})
+			}
+			faces[g].dead = true
+			faces[g].outside = nil
+		}
+		cone := make([]int, 0, len(horizon))
+		for _, e := range horizon {
+			cone = append(cone, add(e[0], e[1], apex))
+		}
+		assign(orphans, cone, apex)
+		queue = append(queue, cone...)
+	}
+
+	//Compact the hull vertices
+	h := Hull{}
+	remap := map[int]int{}
+	for _, f := range faces {
+		if f.dead {
+			continue
+		}
+		for _, v := range f.v {
+			if _, ok := remap[v]; !ok {
+				remap[v] = len(h.Points)
+				h.Points = append(h.Points, p[v].vec())
+			}
+			h.Indices = append(h.Indices, remap[v])
+		}
+		h.Planes = append(h.Planes, [4]float32{float32(f.n[0]), float32(f.n[1]), float32(f.n[2]), float32(f.d)})
+	}
+	return &h, nil
+}
+
+//FromMesh computes the convex hull of the mesh vertices
+func FromMesh(m *mesh.Mesh) (*Hull, error) {
+	return Quickhull(m.Vertexes)
+}
+
+//Triangles returns the number of hull triangles
+func (h *Hull) Triangles() int {
+	return len(h.Indices) / 3
+}
+
+//Support returns the hull vertex farthest along d
+func (h *Hull) Support(d vector.Vec) vector.Vec {
+	best, far := 0, float32(math.Inf(-1))
+	for i, p := range h.Points {
+		if s := vector.Dot(p, d); s > far {
+			best, far = i, s
+		}
+	}
+	p := h.Points[best]
+	return vector.Vec{p[0], p[1], p[2]}
+}
+
+//Volume returns the enclosed volume
+func (h *Hull) Volume() float32 {
+	v := 0.0
+	for t := 0; t < len(h.Indices); t += 3 {
+		a, b, c := toVec3(h.Points[h.Indices[t]]), toVec3(h.Points[h.Indices[t+1]]), toVec3(h.Points[h.Indices[t+2]])
+		v += a.dot(b.cross(c)) / 6
+	}
+	return float32(v)
+}
+
+//Contains reports whether p lies inside the hull or within eps of its faces
+func (h *Hull) Contains(p vector.Vec, eps float32) bool {
+	for _, plane := range h.Planes {
+		if plane[0]*p[0]+plane[1]*p[1]+plane[2]*p[2]-plane[3] > eps {
+			return false
+		}
+	}
+	return true
+}
+
+//Indexed returns the hull as an indexed mesh sharing its vertices
+func (h *Hull) Indexed() *mesh.Indexed {
+	return mesh.NewIndexed(h.Points, h.Indices)
+}
+
+//Mesh returns the hull triangle list, usable as a particle collider
+func (h *Hull) Mesh() mesh.Mesh {
+	return h.Indexed().Mesh()
+}
@@ -0,0 +1,75 @@
+package grid
+
+import (
+	"math"
+
+	V "github.com/andewx/dieselfluid/math/vector"
+)
+
+//Domain is an axis aligned simulation region with per axis periodicity. Periodic
+//axes wrap positions and measure separations by the minimum image convention
+type Domain struct {
+	Min      V.Vec
+	Max      V.Vec
+	Periodic [3]bool
+}
+
+//NewDomain creates a non periodic domain from bounds
+func NewDomain(min V.Vec, max V.Vec) *Domain {
+	return &Domain{V.Vec{min[0], min[1], min[2]}, V.Vec{max[0], max[1], max[2]}, [3]bool{false, false, false}}
+}
+
+//GridDomain creates the domain covered by the grid cells so that a periodic
+//axis repeats the grid lattice spacing across the wrap
+func GridDomain(g Grid) *Domain {
+	max := V.Add(g.min_bounds, V.ScaleVar(g.step, g.Div))
+	return NewDomain(g.min_bounds, max)
+}
+
+//SetPeriodic sets the periodicity of each axis
+func (d *Domain) SetPeriodic(x bool, y bool, z bool) {
+	d.Periodic = [3]bool{x, y, z}
+}
+
+//Size returns the domain extents
+func (d *Domain) Size() V.Vec {
+	return V.Sub(d.Max, d.Min)
+}
+
+//Contains checks if a position is inside the domain bounds
+func (d *Domain) Contains(pos V.Vec) bool {
+	for k := 0; k < 3; k++ {
+		if pos[k] < d.Min[k] || pos[k] > d.Max[k] {
+			return false
+		}
+	}
+	return true
+}
+
+//Separation returns b - a, using the nearest periodic image of b on periodic axes
+func (d *Domain) Separation(a V.Vec, b V.Vec) V.Vec {
+	sep := V.Vec{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	for k := 0; k < 3; k++ {
+		if d.Periodic[k] {
+			l := d.Max[k] - d.Min[k]
+			sep[k] -= l * float32(math.Floor(float64(sep[k]/l)+0.5))
+		}
+	}
+	return sep
+}
+
+//Wrap remaps a position into the domain on periodic axes @MUTATES pos
+func (d *Domain) Wrap(pos V.Vec) bool {
+	wrapped := false
+	for k := 0; k < 3; k++ {
+		if !d.Periodic[k] {
+			continue
+		}
+		l := d.Max[k] - d.Min[k]
+		if pos[k] < d.Min[k] || pos[k] >= d.Max[k] {
+			pos[k] -= l * float32(math.Floor(float64((pos[k]-d.Min[k])/l)))
+			wrapped = true
+		}
+	}
+	return wrapped
+}
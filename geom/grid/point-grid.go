@@ -75,3 +75,18 @@ func (g Grid) UpdateGrid(sclVar V.Vec, TransOrigin V.Vec, DimVec V.Vec) Grid {
 	g.origin = V.Add(g.origin, TransOrigin)
 	return g
 }
+
+//Min returns the position of grid element [0,0,0]
+func (g Grid) Min() V.Vec {
+	return V.Vec{g.min_bounds[0], g.min_bounds[1], g.min_bounds[2]}
+}
+
+//Step returns the grid spacing per axis
+func (g Grid) Step() V.Vec {
+	return V.Vec{g.step[0], g.step[1], g.step[2]}
+}
+
+//Dims returns the number of grid divisions per axis
+func (g Grid) Dims() [3]int {
+	return [3]int{int(g.Div[0]), int(g.Div[1]), int(g.Div[2])}
+}
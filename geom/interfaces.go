@@ -15,6 +15,12 @@ type Collider interface {
 	GenerateBoundaryParticles(density float32) [][3]float32
 }
 
+//Convex Interface defines the support mapping of a convex shape, the farthest
+//point of the shape along a direction, used by the GJK and EPA queries
+type Convex interface {
+	Support(d Vec.Vec) Vec.Vec
+}
+
 //GridPoint Returns single point from 3D Index Grid Reference
 type GridPoint interface {
 	GridPosition(i int, j int, k int) Vec.Vec
@@ -0,0 +1,155 @@
+package mesh
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Weight of the perpendicular planes holding boundary edges in place
+const QUADRIC_BOUNDARY = 100.0
+
+//quadric is the symmetric 4x4 error quadric of Garland and Heckbert stored as
+//its upper triangle a2 ab ac ad b2 bc bd c2 cd d2, measuring the summed squared
+//distance to the accumulated planes
+type quadric [10]float64
+
+//planeQuadric returns the quadric of the plane n.x + d = 0 with unit normal n
+func planeQuadric(n vector.Vec, p vector.Vec, w float64) quadric {
+	a, b, c := float64(n[0]), float64(n[1]), float64(n[2])
+	d := -(a*float64(p[0]) + b*float64(p[1]) + c*float64(p[2]))
+	return quadric{w * a * a, w * a * b, w * a * c, w * a * d, w * b * b, w * b * c, w * b * d, w * c * c, w * c * d, w * d * d}
+}
+
+func (q quadric) add(r quadric) quadric {
+	for i := range q {
+		q[i] += r[i]
+	}
+	return q
+}
+
+//cost returns the quadric error at p
+func (q quadric) cost(p vector.Vec) float64 {
+	x, y, z := float64(p[0]), float64(p[1]), float64(p[2])
+	return q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x + q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y + q[7]*z*z + 2*q[8]*z + q[9]
+}
+
+//optimum solves for the point minimizing the quadric, failing when the planes
+//do not pin a point as on flat or cylindrical regions
+func (q quadric) optimum() (vector.Vec, bool) {
+	a := [3][3]float64{{q[0], q[1], q[2]}, {q[1], q[4], q[5]}, {q[2], q[5], q[7]}}
+	b := [3]float64{-q[3], -q[6], -q[8]}
+	det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) - a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) + a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+	trace := a[0][0] + a[1][1] + a[2][2]
+	if math.Abs(det) <= 1e-6*trace*trace*trace {
+		return nil, false
+	}
+	p := vector.Vec{0, 0, 0}
+	for i := 0; i < 3; i++ {
+		m := a
+		for r := 0; r < 3; r++ {
+			m[r][i] = b[r]
+		}
+		p[i] = float32((m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) - m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) + m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])) / det)
+	}
+	return p, true
+}
+
+//collapseItem is an edge collapse in the decimation queue, stale once either
+//vertex changed after the stamps were taken
+type collapseItem struct {
+	cost  float64
+	u, v  int
+	p     vector.Vec
+	stamp [2]int
+}
+
+type collapseQueue []collapseItem
+
+func (q collapseQueue) Len() int               { return len(q) }
+func (q collapseQueue) Less(a int, b int) bool { return q[a].cost < q[b].cost }
+func (q collapseQueue) Swap(a int, b int)      { q[a], q[b] = q[b], q[a] }
+func (q *collapseQueue) Push(x interface{})    { *q = append(*q, x.(collapseItem)) }
+func (q *collapseQueue) Pop() (item interface{}) {
+	item, *q = (*q)[len(*q)-1], (*q)[:len(*q)-1]
+	return
+}
+
+//Decimate simplifies the mesh by quadric error edge collapses, Garland and
+//Heckbert, cheapest first until at most target triangles remain or the next
+//collapse would move the surface further than maxError from the original
+//planes. Target 0 decimates by the error alone and maxError 0 by the count
+//alone. Collapses keep the surface manifold, do not fold triangles over and
+//boundaries are held by perpendicular planes. Returns the triangle count
+func (m *Indexed) Decimate(target int, maxError float32) int {
+	m.Connect()
+	e := newEditor(m)
+	quadrics := make([]quadric, len(m.Positions))
+	for t := 0; t < m.Triangles(); t++ {
+		n := e.normal(t, -1, nil)
+		if vector.Mag(n) == 0 {
+			continue
+		}
+		n = vector.Norm(n)
+		q := planeQuadric(n, m.Positions[m.Indices[t*3]], 1)
+		for k := 0; k < 3; k++ {
+			quadrics[m.Indices[t*3+k]] = quadrics[m.Indices[t*3+k]].add(q)
+			if m.Twins[t*3+k] >= 0 {
+				continue
+			}
+			a, b := m.Edge(t*3 + k)
+			side := vector.Sub(m.Positions[b], m.Positions[a])
+			w := QUADRIC_BOUNDARY * float64(vector.Dot(side, side))
+			edge := planeQuadric(vector.Norm(vector.Cross(side, n)), m.Positions[a], w)
+			quadrics[a] = quadrics[a].add(edge)
+			quadrics[b] = quadrics[b].add(edge)
+		}
+	}
+
+	stamps := make([]int, len(m.Positions))
+	candidate := func(u int, v int) collapseItem {
+		q := quadrics[u].add(quadrics[v])
+		item := collapseItem{math.Inf(1), u, v, nil, [2]int{stamps[u], stamps[v]}}
+		if p, ok := q.optimum(); ok {
+			item.cost, item.p = q.cost(p), p
+		}
+		mid := vector.Scale(vector.Add(m.Positions[u], m.Positions[v]), 0.5)
+		for _, p := range []vector.Vec{m.Positions[u], m.Positions[v], mid} {
+			if c := q.cost(p); c < item.cost {
+				item.cost, item.p = c, vector.Vec{p[0], p[1], p[2]}
+			}
+		}
+		item.cost = math.Max(item.cost, 0)
+		return item
+	}
+	queue := &collapseQueue{}
+	for h, twin := range m.Twins {
+		if a, b := m.Edge(h); twin < 0 || a < b {
+			heap.Push(queue, candidate(a, b))
+		}
+	}
+
+	limit := float64(maxError) * float64(maxError)
+	for queue.Len() > 0 && e.live > target {
+		item := heap.Pop(queue).(collapseItem)
+		if item.stamp[0] != stamps[item.u] || item.stamp[1] != stamps[item.v] {
+			continue
+		}
+		if maxError > 0 && item.cost > limit {
+			break
+		}
+		if !e.canCollapse(item.u, item.v, item.p) {
+			continue
+		}
+		e.collapse(item.u, item.v, item.p)
+		quadrics[item.u] = quadrics[item.u].add(quadrics[item.v])
+		stamps[item.u]++
+		stamps[item.v]++
+		for w := range e.ring(item.u) {
+			heap.Push(queue, candidate(item.u, w))
+		}
+	}
+	e.finish()
+	return m.Triangles()
+}
@@ -0,0 +1,192 @@
+package mesh
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//icosphere returns a unit sphere from a subdivided icosahedron
+func icosphere(subdivisions int) *Indexed {
+	g := float32((1 + math.Sqrt(5)) / 2)
+	positions := []vector.Vec{{-1, g, 0}, {1, g, 0}, {-1, -g, 0}, {1, -g, 0}, {0, -1, g}, {0, 1, g}, {0, -1, -g}, {0, 1, -g}, {g, 0, -1}, {g, 0, 1}, {-g, 0, -1}, {-g, 0, 1}}
+	indices := []int{0, 11, 5, 0, 5, 1, 0, 1, 7, 0, 7, 10, 0, 10, 11, 1, 5, 9, 5, 11, 4, 11, 10, 2, 10, 7, 6, 7, 1, 8,
+		3, 9, 4, 3, 4, 2, 3, 2, 6, 3, 6, 8, 3, 8, 9, 4, 9, 5, 2, 4, 11, 6, 2, 10, 8, 6, 7, 9, 8, 1}
+	for i := range positions {
+		positions[i] = vector.Norm(positions[i])
+	}
+	for s := 0; s < subdivisions; s++ {
+		midpoints := map[[2]int]int{}
+		midpoint := func(a int, b int) int {
+			if a > b {
+				a, b = b, a
+			}
+			if m, ok := midpoints[[2]int{a, b}]; ok {
+				return m
+			}
+			positions = append(positions, vector.Norm(vector.Add(positions[a], positions[b])))
+			midpoints[[2]int{a, b}] = len(positions) - 1
+			return len(positions) - 1
+		}
+		next := []int{}
+		for t := 0; t < len(indices); t += 3 {
+			a, b, c := indices[t], indices[t+1], indices[t+2]
+			ab, bc, ca := midpoint(a, b), midpoint(b, c), midpoint(c, a)
+			next = append(next, a, ab, ca, b, bc, ab, c, ca, bc, ab, bc, ca)
+		}
+		indices = next
+	}
+	return NewIndexed(positions, indices)
+}
+
+//grid returns an open unit square in the xy plane of n by n quads
+func grid(n int) *Indexed {
+	positions := []vector.Vec{}
+	indices := []int{}
+	for j := 0; j <= n; j++ {
+		for i := 0; i <= n; i++ {
+			positions = append(positions, vector.Vec{float32(i) / float32(n), float32(j) / float32(n), 0})
+			if i < n && j < n {
+				a := j*(n+1) + i
+				indices = append(indices, a, a+1, a+n+2, a, a+n+2, a+n+1)
+			}
+		}
+	}
+	return NewIndexed(positions, indices)
+}
+
+//radii returns the mean and standard deviation of the vertex distances from the origin
+func radii(m *Indexed) (float64, float64) {
+	sum, sum2 := 0.0, 0.0
+	for _, p := range m.Positions {
+		r := float64(vector.Mag(p))
+		sum += r
+		sum2 += r * r
+	}
+	n := float64(len(m.Positions))
+	return sum / n, math.Sqrt(math.Max(sum2/n-sum*sum/n/n, 0))
+}
+
+func area(m *Indexed) float32 {
+	a := float32(0)
+	for t := 0; t < m.Triangles(); t++ {
+		p, q, r := m.Positions[m.Indices[t*3]], m.Positions[m.Indices[t*3+1]], m.Positions[m.Indices[t*3+2]]
+		a += vector.Mag(vector.Cross(vector.Sub(q, p), vector.Sub(r, p))) / 2
+	}
+	return a
+}
+
+func TestDecimate(t *testing.T) {
+	sphere := icosphere(4)
+	volume := sphere.Volume()
+	sphere.VertexNormals()
+	if n := sphere.Decimate(500, 0); n > 500 || n < 450 || !sphere.IsWatertight() || len(sphere.Normals) != len(sphere.Positions) {
+		t.Fatalf("Decimated sphere to %d triangles watertight %v\n", n, sphere.IsWatertight())
+	}
+	if v := sphere.Volume(); math.Abs(float64(v-volume)) > 0.03*float64(volume) {
+		t.Errorf("Decimated volume %f of %f\n", v, volume)
+	}
+	for _, p := range sphere.Positions {
+		if r := vector.Mag(p); r < 0.95 || r > 1.02 {
+			t.Fatalf("Decimated vertex %v radius %f\n", p, r)
+		}
+	}
+	//The error bound stops before the sphere loses its shape
+	bounded := icosphere(4)
+	if n := bounded.Decimate(0, 5e-3); n < 500 || n >= 5120 {
+		t.Errorf("Error bounded decimation kept %d triangles\n", n)
+	}
+
+	//A plane collapses to a few triangles keeping its boundary
+	plane := grid(20)
+	if n := plane.Decimate(0, 1e-4); n > 40 || len(plane.BoundaryEdges()) == 0 || !plane.IsManifold() {
+		t.Errorf("Decimated plane to %d triangles\n", n)
+	}
+	if min, max := plane.Bounds(); min != [3]float32{0, 0, 0} || max != [3]float32{1, 1, 0} || math.Abs(float64(area(plane)-1)) > 1e-4 {
+		t.Errorf("Decimated plane bounds %v %v area %f\n", min, max, area(plane))
+	}
+}
+
+func TestSmooth(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noisy := func() *Indexed {
+		m := icosphere(3)
+		for i, p := range m.Positions {
+			m.Positions[i] = vector.Scale(p, 1+0.05*(rng.Float32()-0.5))
+		}
+		return m
+	}
+	taubin, laplacian := noisy(), noisy()
+	_, noise := radii(taubin)
+	volume := taubin.Volume()
+	taubin.Taubin(10, SMOOTH_LAMBDA, SMOOTH_MU)
+	laplacian.Laplacian(20, SMOOTH_LAMBDA)
+	if _, deviation := radii(taubin); deviation > noise/2 {
+		t.Errorf("Taubin radius deviation %f from %f\n", deviation, noise)
+	}
+	shrunk := math.Abs(float64(laplacian.Volume()-volume)) / float64(volume)
+	if kept := math.Abs(float64(taubin.Volume()-volume)) / float64(volume); kept > 0.03 || shrunk < 2*kept {
+		t.Errorf("Taubin volume change %f Laplacian %f\n", kept, shrunk)
+	}
+	laplacian.ScaleVolume(volume)
+	if math.Abs(float64(laplacian.Volume()-volume)) > 1e-4 {
+		t.Errorf("Rescaled volume %f of %f\n", laplacian.Volume(), volume)
+	}
+
+	//Open boundaries stay in place
+	plane := grid(8)
+	plane.Positions[40] = vector.Vec{0.5, 0.5, 0.2}
+	plane.Taubin(5, SMOOTH_LAMBDA, SMOOTH_MU)
+	if min, max := plane.Bounds(); min[0] != 0 || min[1] != 0 || max[0] != 1 || max[1] != 1 || plane.Positions[40][2] > 0.1 {
+		t.Errorf("Smoothed plane bounds %v %v peak %v\n", min, max, plane.Positions[40])
+	}
+}
+
+func TestRemesh(t *testing.T) {
+	sphere := icosphere(2)
+	volume := sphere.Volume()
+	if err := sphere.Remesh(0, 1); err == nil {
+		t.Errorf("Remesh accepted a zero edge length\n")
+	}
+	if err := sphere.Remesh(0.1, 5); err != nil {
+		t.Fatal(err)
+	}
+	if !sphere.IsWatertight() || math.Abs(float64(sphere.Volume()-volume)) > 0.03*float64(volume) {
+		t.Fatalf("Remeshed sphere watertight %v volume %f of %f\n", sphere.IsWatertight(), sphere.Volume(), volume)
+	}
+	total, short, long := float32(0), 0, 0
+	for h := range sphere.Indices {
+		a, b := sphere.Edge(h)
+		d := vector.Dist(sphere.Positions[a], sphere.Positions[b])
+		total += d
+		if d < 0.05 {
+			short++
+		}
+		if d > 0.15 {
+			long++
+		}
+	}
+	if mean := total / float32(len(sphere.Indices)); mean < 0.08 || mean > 0.12 || short+long > len(sphere.Indices)/20 {
+		t.Errorf("Remeshed mean edge %f with %d short and %d long edges\n", mean, short, long)
+	}
+	regular := 0
+	e := newEditor(sphere)
+	for v := range sphere.Positions {
+		if n := len(e.ring(v)); n >= 5 && n <= 7 {
+			regular++
+		}
+	}
+	if regular < len(sphere.Positions)*9/10 {
+		t.Errorf("Remeshed valences regular at %d of %d vertices\n", regular, len(sphere.Positions))
+	}
+
+	plane := grid(4)
+	if err := plane.Remesh(0.05, 3); err != nil {
+		t.Fatal(err)
+	}
+	if min, max := plane.Bounds(); min != [3]float32{0, 0, 0} || max != [3]float32{1, 1, 0} || math.Abs(float64(area(plane)-1)) > 1e-4 || !plane.IsManifold() {
+		t.Errorf("Remeshed plane bounds %v %v area %f\n", min, max, area(plane))
+	}
+}
@@ -0,0 +1,237 @@
+package mesh
+
+import (
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Minimum cosine between a triangle normal before and after a local edit
+const EDIT_NORMAL = 0.2
+
+//editor tracks the triangles around each vertex for local edits of an indexed
+//mesh. Removed triangles stay in the index buffer until finish drops them
+type editor struct {
+	m       *Indexed
+	faces   [][]int
+	removed []bool
+	live    int
+}
+
+func newEditor(m *Indexed) *editor {
+	e := editor{m: m, faces: make([][]int, len(m.Positions)), removed: make([]bool, m.Triangles()), live: m.Triangles()}
+	for i, v := range m.Indices {
+		e.faces[v] = append(e.faces[v], i/3)
+	}
+	return &e
+}
+
+//corner returns the corner of vertex v in triangle t or -1
+func (e *editor) corner(t int, v int) int {
+	for k := 0; k < 3; k++ {
+		if e.m.Indices[t*3+k] == v {
+			return k
+		}
+	}
+	return -1
+}
+
+//ring returns the neighbors of v with the number of triangles on each edge
+func (e *editor) ring(v int) map[int]int {
+	r := make(map[int]int, 8)
+	for _, t := range e.faces[v] {
+		k := e.corner(t, v)
+		r[e.m.Indices[t*3+(k+1)%3]]++
+		r[e.m.Indices[t*3+(k+2)%3]]++
+	}
+	return r
+}
+
+//boundary reports whether v lies on an open or non manifold edge
+func (e *editor) boundary(v int) bool {
+	for _, n := range e.ring(v) {
+		if n != 2 {
+			return true
+		}
+	}
+	return false
+}
+
+//shared returns the triangles containing both u and v
+func (e *editor) shared(u int, v int) []int {
+	shared := []int{}
+	for _, t := range e.faces[u] {
+		if e.corner(t, v) >= 0 {
+			shared = append(shared, t)
+		}
+	}
+	return shared
+}
+
+//third returns the vertex of triangle t other than u and v
+func (e *editor) third(t int, u int, v int) int {
+	for k := 0; k < 3; k++ {
+		if w := e.m.Indices[t*3+k]; w != u && w != v {
+			return w
+		}
+	}
+	return -1
+}
+
+//vertex appends a vertex at p
+func (e *editor) vertex(p vector.Vec) int {
+	e.m.Positions = append(e.m.Positions, p)
+	e.faces = append(e.faces, nil)
+	return len(e.m.Positions) - 1
+}
+
+//add appends the triangle a b c
+func (e *editor) add(a int, b int, c int) int {
+	t := len(e.removed)
+	e.m.Indices = append(e.m.Indices, a, b, c)
+	e.removed = append(e.removed, false)
+	for _, v := range []int{a, b, c} {
+		e.faces[v] = append(e.faces[v], t)
+	}
+	e.live++
+	return t
+}
+
+//detach removes triangle t from the triangles around v
+func (e *editor) detach(v int, t int) {
+	faces := e.faces[v][:0]
+	for _, f := range e.faces[v] {
+		if f != t {
+			faces = append(faces, f)
+		}
+	}
+	e.faces[v] = faces
+}
+
+//replace moves corner k of triangle t to vertex v
+func (e *editor) replace(t int, k int, v int) {
+	e.detach(e.m.Indices[t*3+k], t)
+	e.m.Indices[t*3+k] = v
+	e.faces[v] = append(e.faces[v], t)
+}
+
+func (e *editor) remove(t int) {
+	e.removed[t] = true
+	e.live--
+	for k := 0; k < 3; k++ {
+		e.detach(e.m.Indices[t*3+k], t)
+	}
+}
+
+//normal returns the unnormalized normal of triangle t with vertex v moved to p
+func (e *editor) normal(t int, v int, p vector.Vec) vector.Vec {
+	q := [3]vector.Vec{}
+	for k := 0; k < 3; k++ {
+		q[k] = e.m.Positions[e.m.Indices[t*3+k]]
+		if e.m.Indices[t*3+k] == v {
+			q[k] = p
+		}
+	}
+	return vector.Cross(vector.Sub(q[1], q[0]), vector.Sub(q[2], q[0]))
+}
+
+//folds reports whether moving v to p turns a triangle around v, other than
+//those containing skip, away from its normal or collapses it
+func (e *editor) folds(v int, p vector.Vec, skip int) bool {
+	for _, t := range e.faces[v] {
+		if e.corner(t, skip) >= 0 {
+			continue
+		}
+		after := e.normal(t, v, p)
+		if vector.Mag(after) < 1e-12 || vector.Dot(vector.Norm(e.normal(t, -1, nil)), vector.Norm(after)) < EDIT_NORMAL {
+			return true
+		}
+	}
+	return false
+}
+
+//canCollapse checks that merging edge u v into a vertex at p keeps the surface
+//manifold by the link condition of Dey et al, the rings of u and v meeting only
+//at the opposite vertices of the edge triangles, and folds no triangle over
+func (e *editor) canCollapse(u int, v int, p vector.Vec) bool {
+	shared := e.shared(u, v)
+	if len(shared) == 0 || len(shared) > 2 {
+		return false
+	}
+	ru, rv := e.ring(u), e.ring(v)
+	if len(shared) == 2 {
+		//An interior edge joining two boundaries would pinch the surface
+		bu, bv := false, false
+		for _, n := range ru {
+			bu = bu || n != 2
+		}
+		for _, n := range rv {
+			bv = bv || n != 2
+		}
+		if bu && bv {
+			return false
+		}
+	}
+	opposite := map[int]bool{}
+	for _, t := range shared {
+		opposite[e.third(t, u, v)] = true
+	}
+	for w := range ru {
+		if _, ok := rv[w]; ok && w != v && !opposite[w] {
+			return false
+		}
+	}
+	//Link edges, the triangle sides facing u and v, must not coincide as in a tetrahedron
+	links := map[[2]int]bool{}
+	for _, t := range e.faces[u] {
+		if e.corner(t, v) < 0 {
+			a, b := e.others(t, u)
+			links[[2]int{a, b}] = true
+		}
+	}
+	for _, t := range e.faces[v] {
+		if e.corner(t, u) < 0 {
+			if a, b := e.others(t, v); links[[2]int{a, b}] {
+				return false
+			}
+		}
+	}
+	return !e.folds(u, p, v) && !e.folds(v, p, u)
+}
+
+//others returns the ordered vertices of triangle t other than v
+func (e *editor) others(t int, v int) (int, int) {
+	k := e.corner(t, v)
+	a, b := e.m.Indices[t*3+(k+1)%3], e.m.Indices[t*3+(k+2)%3]
+	if a > b {
+		a, b = b, a
+	}
+	return a, b
+}
+
+//collapse merges v into u placed at p, removing the triangles on the edge
+func (e *editor) collapse(u int, v int, p vector.Vec) {
+	e.m.Positions[u] = p
+	for _, t := range e.shared(u, v) {
+		e.remove(t)
+	}
+	for _, t := range append([]int{}, e.faces[v]...) {
+		e.replace(t, e.corner(t, v), u)
+	}
+}
+
+//finish drops the removed triangles and unused vertices, reconnects and keeps
+//vertex normals up to date when the mesh had them
+func (e *editor) finish() {
+	indices := make([]int, 0, e.live*3)
+	for t, removed := range e.removed {
+		if !removed {
+			indices = append(indices, e.m.Indices[t*3:t*3+3]...)
+		}
+	}
+	normals := e.m.Normals != nil
+	e.m.Indices = indices
+	e.m.Normals = nil
+	e.m.Compact()
+	if normals {
+		e.m.VertexNormals()
+	}
+}
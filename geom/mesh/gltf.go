@@ -0,0 +1,138 @@
+package mesh
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/scene"
+)
+
+//FromPrimitive reads a glTF mesh primitive in its local space as an indexed
+//mesh without welding
+func FromPrimitive(scn *scene.Scene, mesh_index int, primitive_index int) (*Indexed, error) {
+	positions, indices, err := scn.PrimitiveTriangles(mesh_index, primitive_index)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]vector.Vec, len(positions)/3)
+	for i := range points {
+		points[i] = vector.Vec{positions[i*3], positions[i*3+1], positions[i*3+2]}
+	}
+	return NewIndexed(points, append([]int{}, indices...)), nil
+}
+
+//Minimal glTF 2.0 document for a single indexed mesh. The generated schema
+//types in the gltf package marshal every optional field, which loaders reject
+type gltfDocument struct {
+	Asset       map[string]string `json:"asset"`
+	Scene       int               `json:"scene"`
+	Scenes      []gltfScene       `json:"scenes"`
+	Nodes       []gltfNode        `json:"nodes"`
+	Meshes      []gltfMesh        `json:"meshes"`
+	Accessors   []gltfAccessor    `json:"accessors"`
+	BufferViews []gltfBufferView  `json:"bufferViews"`
+	Buffers     []gltfBuffer      `json:"buffers"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name string `json:"name,omitempty"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    int            `json:"indices"`
+	Mode       int            `json:"mode"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int    `json:"byteLength"`
+	Uri        string `json:"uri"`
+}
+
+//glTF buffer targets
+const (
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+)
+
+//GLTF encodes the mesh as a self contained glTF 2.0 document with the vertex
+//positions, normals and triangle indices embedded as a base64 buffer. Vertex
+//normals are computed when the mesh has none
+func (m *Indexed) GLTF(name string) ([]byte, error) {
+	if len(m.Normals) != len(m.Positions) {
+		m.VertexNormals()
+	}
+	n := len(m.Positions)
+	buf := make([]byte, n*24+len(m.Indices)*4)
+	for v := 0; v < n; v++ {
+		for a := 0; a < 3; a++ {
+			binary.LittleEndian.PutUint32(buf[(v*3+a)*4:], math.Float32bits(m.Positions[v][a]))
+			binary.LittleEndian.PutUint32(buf[n*12+(v*3+a)*4:], math.Float32bits(m.Normals[v][a]))
+		}
+	}
+	for i, index := range m.Indices {
+		binary.LittleEndian.PutUint32(buf[n*24+i*4:], uint32(index))
+	}
+	var min, max []float32
+	if n > 0 {
+		lo, hi := m.Bounds()
+		min, max = lo[:], hi[:]
+	}
+
+	doc := gltfDocument{
+		Asset:  map[string]string{"version": "2.0", "generator": "dieselfluid"},
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Name: name, Mesh: 0}},
+		Meshes: []gltfMesh{{Primitives: []gltfPrimitive{{Attributes: map[string]int{"POSITION": 0, "NORMAL": 1}, Indices: 2, Mode: scene.MODE_TRIANGLES}}}},
+		Accessors: []gltfAccessor{
+			{BufferView: 0, ComponentType: scene.COMPONENT_FLOAT, Count: n, Type: "VEC3", Min: min, Max: max},
+			{BufferView: 1, ComponentType: scene.COMPONENT_FLOAT, Count: n, Type: "VEC3"},
+			{BufferView: 2, ComponentType: scene.COMPONENT_UNSIGNED_INT, Count: len(m.Indices), Type: "SCALAR"},
+		},
+		BufferViews: []gltfBufferView{
+			{Buffer: 0, ByteOffset: 0, ByteLength: n * 12, Target: gltfArrayBuffer},
+			{Buffer: 0, ByteOffset: n * 12, ByteLength: n * 12, Target: gltfArrayBuffer},
+			{Buffer: 0, ByteOffset: n * 24, ByteLength: len(m.Indices) * 4, Target: gltfElementArray},
+		},
+		Buffers: []gltfBuffer{{ByteLength: len(buf), Uri: "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(buf)}},
+	}
+	return json.Marshal(doc)
+}
+
+//ExportGLTF writes the mesh as a self contained glTF 2.0 file
+func (m *Indexed) ExportGLTF(filename string, name string) error {
+	data, err := m.GLTF(name)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,112 @@
+package mesh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Unit Scales to Meters
+const (
+	UNIT_METER      = 1.0
+	UNIT_CENTIMETER = 0.01
+	UNIT_MILLIMETER = 0.001
+	UNIT_INCH       = 0.0254
+	UNIT_FOOT       = 0.3048
+)
+
+//ImportOptions maps file coordinates into the simulation frame. Positions are
+//multiplied by Scale, for instance UNIT_MILLIMETER for CAD exports, then placed
+//by the 4x4 Transform laid out like transform.Transform, the upper 3x3 rotating
+//and scaling and Transform[12:15] translating. A zero Scale and a nil Transform
+//leave them unchanged
+type ImportOptions struct {
+	Scale     float32
+	Transform matrix.Mat
+}
+
+//Load imports an OBJ, STL or PLY mesh file selected by its extension
+func Load(filename string, opts *ImportOptions) (Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Mesh{}, err
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".obj":
+		return ReadOBJ(file, opts)
+	case ".stl":
+		return ReadSTL(file, opts)
+	case ".ply":
+		return ReadPLY(file, opts)
+	}
+	return Mesh{}, fmt.Errorf("Unsupported mesh format %s", filepath.Ext(filename))
+}
+
+//build expands indexed triangles into a linear triangle list in the simulation
+//frame with face normals following the file winding. Transforms that mirror the
+//mesh reverse the winding to keep facing
+func build(positions []float32, indices []int, opts *ImportOptions) (Mesh, error) {
+	if len(indices) == 0 {
+		return Mesh{}, fmt.Errorf("Mesh has no triangles")
+	}
+	scale := float32(1)
+	var m matrix.Mat
+	if opts != nil {
+		if opts.Scale != 0 {
+			scale = opts.Scale
+		}
+		if opts.Transform != nil {
+			if len(opts.Transform) != 16 {
+				return Mesh{}, fmt.Errorf("Import transform must be a 4x4 matrix")
+			}
+			m = opts.Transform
+		}
+	}
+	flip := scale < 0
+	if m != nil {
+		det := m[0]*(m[5]*m[10]-m[6]*m[9]) - m[1]*(m[4]*m[10]-m[6]*m[8]) + m[2]*(m[4]*m[9]-m[5]*m[8])
+		flip = flip != (det < 0)
+	}
+
+	count := len(positions) / 3
+	points := make([]vector.Vec, count)
+	for i := range points {
+		p := vector.Vec{positions[i*3] * scale, positions[i*3+1] * scale, positions[i*3+2] * scale}
+		if m != nil {
+			q := vector.Vec{m[12], m[13], m[14]}
+			for a := 0; a < 3; a++ {
+				q[a] += m[a*4]*p[0] + m[a*4+1]*p[1] + m[a*4+2]*p[2]
+			}
+			p = q
+		}
+		points[i] = p
+	}
+	g := Mesh{Vertexes: make([]vector.Vec, 0, len(indices)), Normals: make([]vector.Vec, 0, len(indices)/3)}
+	for t := 0; t+2 < len(indices); t += 3 {
+		a, b, c := indices[t], indices[t+1], indices[t+2]
+		for _, v := range []int{a, b, c} {
+			if v < 0 || v >= count {
+				return Mesh{}, fmt.Errorf("Vertex index %d out of range", v)
+			}
+		}
+		if flip {
+			b, c = c, b
+		}
+		g.Vertexes = append(g.Vertexes, points[a], points[b], points[c])
+		g.Normals = append(g.Normals, vector.Norm(vector.Cross(vector.Sub(points[b], points[a]), vector.Sub(points[c], points[a]))))
+	}
+	return g, nil
+}
+
+//fan triangulates a convex polygon of vertex indices
+func fan(polygon []int, indices []int) []int {
+	for k := 2; k < len(polygon); k++ {
+		indices = append(indices, polygon[0], polygon[k-1], polygon[k])
+	}
+	return indices
+}
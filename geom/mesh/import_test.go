@@ -0,0 +1,149 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Unit cube corners and outward wound quads
+var cubeCorners = [8][3]float32{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}
+var cubeQuads = [6][4]int{{0, 3, 2, 1}, {4, 5, 6, 7}, {0, 1, 5, 4}, {2, 3, 7, 6}, {0, 4, 7, 3}, {1, 2, 6, 5}}
+
+//volume returns the signed enclosed volume, positive for outward winding
+func volume(m Mesh) float32 {
+	v := float32(0)
+	for i := 0; i+2 < len(m.Vertexes); i += 3 {
+		v += vector.Dot(m.Vertexes[i], vector.Cross(m.Vertexes[i+1], m.Vertexes[i+2])) / 6
+	}
+	return v
+}
+
+func cubeTriangles() [][3]int {
+	tris := [][3]int{}
+	for _, q := range cubeQuads {
+		tris = append(tris, [3]int{q[0], q[1], q[2]}, [3]int{q[0], q[2], q[3]})
+	}
+	return tris
+}
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mesh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	//OBJ quads with texture and normal references and negative indices
+	obj := strings.Builder{}
+	obj.WriteString("# cube\no cube\n")
+	for _, c := range cubeCorners {
+		fmt.Fprintf(&obj, "v %g %g %g\n", c[0], c[1], c[2])
+	}
+	obj.WriteString("vt 0 0\nvn 0 0 1\n")
+	for n, q := range cubeQuads {
+		if n%2 == 0 {
+			fmt.Fprintf(&obj, "f %d/1/1 %d/1/1 %d//1 %d\n", q[0]+1, q[1]+1, q[2]+1, q[3]+1)
+		} else {
+			fmt.Fprintf(&obj, "f %d %d %d %d\n", q[0]-8, q[1]-8, q[2]-8, q[3]-8)
+		}
+	}
+
+	stl := strings.Builder{}
+	stl.WriteString("solid cube\n")
+	binarySTL := bytes.Buffer{}
+	binarySTL.Write([]byte(strings.Repeat("solid binary", 10)[:STL_HEADER]))
+	binary.Write(&binarySTL, binary.LittleEndian, uint32(12))
+	for _, tri := range cubeTriangles() {
+		stl.WriteString(" facet normal 0 0 0\n  outer loop\n")
+		binary.Write(&binarySTL, binary.LittleEndian, [3]float32{})
+		for _, v := range tri {
+			c := cubeCorners[v]
+			fmt.Fprintf(&stl, "   vertex %g %g %g\n", c[0], c[1], c[2])
+			binary.Write(&binarySTL, binary.LittleEndian, c)
+		}
+		stl.WriteString("  endloop\n endfacet\n")
+		binary.Write(&binarySTL, binary.LittleEndian, uint16(0))
+	}
+	stl.WriteString("endsolid cube\n")
+
+	header := "element vertex 8\nproperty float x\nproperty float y\nproperty float z\nproperty uchar red\nelement face 6\nproperty list uchar int vertex_indices\nend_header\n"
+	ply := strings.Builder{}
+	ply.WriteString("ply\nformat ascii 1.0\ncomment cube\n" + header)
+	binaryPLY := bytes.Buffer{}
+	binaryPLY.WriteString("ply\nformat binary_big_endian 1.0\n" + header)
+	for _, c := range cubeCorners {
+		fmt.Fprintf(&ply, "%g %g %g 255\n", c[0], c[1], c[2])
+		binary.Write(&binaryPLY, binary.BigEndian, c)
+		binaryPLY.WriteByte(255)
+	}
+	for _, q := range cubeQuads {
+		fmt.Fprintf(&ply, "4 %d %d %d %d\n", q[0], q[1], q[2], q[3])
+		binaryPLY.WriteByte(4)
+		binary.Write(&binaryPLY, binary.BigEndian, [4]int32{int32(q[0]), int32(q[1]), int32(q[2]), int32(q[3])})
+	}
+
+	files := map[string][]byte{
+		"cube.obj":        []byte(obj.String()),
+		"cube.stl":        []byte(stl.String()),
+		"cube_binary.STL": binarySTL.Bytes(),
+		"cube.ply":        []byte(ply.String()),
+		"cube_binary.ply": binaryPLY.Bytes(),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Load(path, nil)
+		if err != nil {
+			t.Fatalf("%s: %v\n", name, err)
+		}
+		if len(m.Vertexes) != 36 || len(m.Normals) != 12 || math.Abs(float64(volume(m)-1)) > 1e-5 {
+			t.Errorf("%s: %d vertices volume %f\n", name, len(m.Vertexes), volume(m))
+		}
+		//Normals follow the outward winding of the file
+		for i, n := range m.Normals {
+			c := vector.Scale(vector.Add(m.Vertexes[i*3], vector.Add(m.Vertexes[i*3+1], m.Vertexes[i*3+2])), 1.0/3)
+			if vector.Mag(n) < 0.99 || vector.Dot(n, vector.Sub(c, vector.Vec{0.5, 0.5, 0.5})) <= 0 {
+				t.Errorf("%s: triangle %d normal %v\n", name, i, n)
+			}
+		}
+
+		//Millimeter CAD units with a mirroring transform keep the outward winding
+		mirror := matrix.Mat4(1.0)
+		mirror[0] = -1
+		mirror[12] = 2
+		m, err = Load(path, &ImportOptions{Scale: UNIT_MILLIMETER * 100, Transform: mirror})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(float64(volume(m)-1e-3)) > 1e-8 || m.Vertexes[0][0] > 2 || m.Vertexes[0][0] < 1.9 {
+			t.Errorf("%s: scaled volume %g first vertex %v\n", name, volume(m), m.Vertexes[0])
+		}
+	}
+
+	if _, err := ReadSTL(strings.NewReader("not a mesh"), nil); err == nil {
+		t.Errorf("Invalid STL accepted\n")
+	}
+	if _, err := ReadOBJ(strings.NewReader("v 0 0 0\nf 1 2 3\n"), nil); err == nil {
+		t.Errorf("OBJ index out of range accepted\n")
+	}
+	negative := "ply\nformat ascii 1.0\nelement vertex 3\nproperty float x\nproperty float y\nproperty float z\n" +
+		"element face 1\nproperty list char int vertex_indices\nend_header\n0 0 0\n1 0 0\n0 1 0\n-1 0 1 2\n"
+	if _, err := ReadPLY(strings.NewReader(negative), nil); err == nil {
+		t.Errorf("PLY negative list length accepted\n")
+	}
+	if _, err := Load(filepath.Join(dir, "cube.fbx"), nil); err == nil {
+		t.Errorf("Unsupported format accepted\n")
+	}
+}
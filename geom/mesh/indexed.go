@@ -0,0 +1,403 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Half Edge Twin Markers
+const EDGE_BOUNDARY = -1
+const EDGE_NONMANIFOLD = -2
+
+//Indexed is a shared vertex triangle mesh with half edge adjacency. Half edge
+//3t+k of triangle t runs from Indices[3t+k] to Indices[3t+(k+1)%3] and Twins
+//holds the opposite half edge, EDGE_BOUNDARY for open edges or EDGE_NONMANIFOLD
+//for edges shared by more than two triangles. Normals are per vertex
+type Indexed struct {
+	Positions []vector.Vec
+	Normals   []vector.Vec
+	Indices   []int
+	Twins     []int
+}
+
+//NewIndexed creates an indexed mesh and builds its adjacency
+func NewIndexed(positions []vector.Vec, indices []int) *Indexed {
+	m := Indexed{Positions: positions, Indices: indices[:len(indices)/3*3]}
+	m.Connect()
+	return &m
+}
+
+//FromMesh welds the triangle list vertices closer than tolerance into an
+//indexed mesh, dropping triangles collapsed by the welding
+func FromMesh(g *Mesh, tolerance float32) *Indexed {
+	indices := make([]int, len(g.Vertexes)/3*3)
+	for i := range indices {
+		indices[i] = i
+	}
+	m := Indexed{Positions: g.Vertexes[:len(indices)], Indices: indices}
+	m.Weld(tolerance)
+	return &m
+}
+
+//Triangles returns the number of triangles
+func (m *Indexed) Triangles() int {
+	return len(m.Indices) / 3
+}
+
+//Edge returns the start and end vertex of half edge e
+func (m *Indexed) Edge(e int) (int, int) {
+	return m.Indices[e], m.Indices[e-e%3+(e+1)%3]
+}
+
+//Connect rebuilds the half edge twins after the indices changed
+func (m *Indexed) Connect() {
+	m.Twins = make([]int, len(m.Indices))
+	edges := make(map[[2]int][]int, len(m.Indices))
+	for e := range m.Indices {
+		a, b := m.Edge(e)
+		if a > b {
+			a, b = b, a
+		}
+		edges[[2]int{a, b}] = append(edges[[2]int{a, b}], e)
+	}
+	for _, shared := range edges {
+		switch len(shared) {
+		case 1:
+			m.Twins[shared[0]] = EDGE_BOUNDARY
+		case 2:
+			m.Twins[shared[0]], m.Twins[shared[1]] = shared[1], shared[0]
+		default:
+			for _, e := range shared {
+				m.Twins[e] = EDGE_NONMANIFOLD
+			}
+		}
+	}
+}
+
+//Weld merges vertices closer than tolerance, keeping the first of each cluster,
+//removes unused vertices and triangles collapsed to an edge or a point
+func (m *Indexed) Weld(tolerance float32) {
+	cell := tolerance
+	if cell <= 0 {
+		cell = 1
+	}
+	key := func(p vector.Vec) [3]int64 {
+		return [3]int64{int64(math.Floor(float64(p[0] / cell))), int64(math.Floor(float64(p[1] / cell))), int64(math.Floor(float64(p[2] / cell)))}
+	}
+	grid := make(map[[3]int64][]int)
+	remap := make([]int, len(m.Positions))
+	positions := []vector.Vec{}
+	for i, p := range m.Positions {
+		remap[i] = -1
+		k := key(p)
+		for dx := int64(-1); dx <= 1 && remap[i] < 0; dx++ {
+			for dy := int64(-1); dy <= 1 && remap[i] < 0; dy++ {
+				for dz := int64(-1); dz <= 1 && remap[i] < 0; dz++ {
+					for _, j := range grid[[3]int64{k[0] + dx, k[1] + dy, k[2] + dz}] {
+						if d := vector.Dist(positions[j], p); d <= tolerance {
+							remap[i] = j
+							break
+						}
+					}
+				}
+			}
+		}
+		if remap[i] < 0 {
+			remap[i] = len(positions)
+			grid[k] = append(grid[k], len(positions))
+			positions = append(positions, vector.Vec{p[0], p[1], p[2]})
+		}
+	}
+
+	indices := make([]int, 0, len(m.Indices))
+	for t := 0; t+2 < len(m.Indices); t += 3 {
+		a, b, c := remap[m.Indices[t]], remap[m.Indices[t+1]], remap[m.Indices[t+2]]
+		if a != b && b != c && c != a {
+			indices = append(indices, a, b, c)
+		}
+	}
+	m.Positions = positions
+	m.Indices = indices
+	m.Normals = nil
+	m.Compact()
+}
+
+//Compact removes vertices not referenced by any triangle and reconnects
+func (m *Indexed) Compact() {
+	remap := make([]int, len(m.Positions))
+	for i := range remap {
+		remap[i] = -1
+	}
+	positions := make([]vector.Vec, 0, len(m.Positions))
+	normals := []vector.Vec{}
+	for i, v := range m.Indices {
+		if remap[v] < 0 {
+			remap[v] = len(positions)
+			positions = append(positions, m.Positions[v])
+			if len(m.Normals) == len(m.Positions) {
+				normals = append(normals, m.Normals[v])
+			}
+		}
+		m.Indices[i] = remap[v]
+	}
+	m.Positions = positions
+	m.Normals = nil
+	if len(normals) == len(positions) && len(positions) > 0 {
+		m.Normals = normals
+	}
+	m.Connect()
+}
+
+//Neighbors returns the triangles across the three edges of triangle t, -1 for
+//boundary and non manifold edges
+func (m *Indexed) Neighbors(t int) [3]int {
+	n := [3]int{}
+	for k := 0; k < 3; k++ {
+		n[k] = -1
+		if twin := m.Twins[t*3+k]; twin >= 0 {
+			n[k] = twin / 3
+		}
+	}
+	return n
+}
+
+//BoundaryEdges returns the half edges without a twin
+func (m *Indexed) BoundaryEdges() []int {
+	edges := []int{}
+	for e, twin := range m.Twins {
+		if twin == EDGE_BOUNDARY {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+//NonManifoldEdges returns the half edges shared by more than two triangles
+func (m *Indexed) NonManifoldEdges() []int {
+	edges := []int{}
+	for e, twin := range m.Twins {
+		if twin == EDGE_NONMANIFOLD {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+//fans counts the triangle fans around each vertex, more than one fan making the
+//vertex non manifold as where two cones touch at their tips. Triangle corners
+//at the same vertex are joined across the twin edges containing it
+func (m *Indexed) fans() []int {
+	parent := make([]int, len(m.Indices))
+	for c := range parent {
+		parent[c] = c
+	}
+	find := func(c int) int {
+		for parent[c] != c {
+			parent[c] = parent[parent[c]]
+			c = parent[c]
+		}
+		return c
+	}
+	corner := func(t int, v int) int {
+		for k := 0; k < 3; k++ {
+			if m.Indices[t*3+k] == v {
+				return t*3 + k
+			}
+		}
+		return -1
+	}
+	for e, twin := range m.Twins {
+		if twin < 0 {
+			continue
+		}
+		a, b := m.Edge(e)
+		for _, v := range []int{a, b} {
+			if x, y := corner(e/3, v), corner(twin/3, v); x >= 0 && y >= 0 {
+				parent[find(x)] = find(y)
+			}
+		}
+	}
+	fans := make([]int, len(m.Positions))
+	for c := range m.Indices {
+		if find(c) == c {
+			fans[m.Indices[c]]++
+		}
+	}
+	return fans
+}
+
+//IsManifold reports whether every edge joins at most two triangles and every
+//vertex has a single triangle fan
+func (m *Indexed) IsManifold() bool {
+	if len(m.NonManifoldEdges()) > 0 {
+		return false
+	}
+	for _, f := range m.fans() {
+		if f > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+//IsWatertight reports whether the mesh is a manifold without boundary edges
+func (m *Indexed) IsWatertight() bool {
+	return len(m.BoundaryEdges()) == 0 && m.IsManifold()
+}
+
+//flip reverses the winding of triangle t
+func (m *Indexed) flip(t int) {
+	m.Indices[t*3+1], m.Indices[t*3+2] = m.Indices[t*3+2], m.Indices[t*3+1]
+}
+
+//Orient makes the winding consistent across each connected component so that
+//neighbors traverse shared edges in opposite directions, then turns closed
+//components outward by their signed volume. Returns the number of flipped
+//triangles or an error for non orientable surfaces such as a Mobius strip
+func (m *Indexed) Orient() (int, error) {
+	n := m.Triangles()
+	adjacent := make(map[[2]int][]int, len(m.Indices))
+	for e := range m.Indices {
+		a, b := m.Edge(e)
+		if a > b {
+			a, b = b, a
+		}
+		adjacent[[2]int{a, b}] = append(adjacent[[2]int{a, b}], e/3)
+	}
+	flipped := make([]bool, n)
+	visited := make([]bool, n)
+	count := 0
+	for seed := 0; seed < n; seed++ {
+		if visited[seed] {
+			continue
+		}
+		component := []int{seed}
+		visited[seed] = true
+		closed := true
+		for q := 0; q < len(component); q++ {
+			t := component[q]
+			for k := 0; k < 3; k++ {
+				a, b := m.Edge(t*3 + k)
+				lo, hi := a, b
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				shared := adjacent[[2]int{lo, hi}]
+				if len(shared) != 2 {
+					closed = false
+					continue
+				}
+				u := shared[0]
+				if u == t {
+					u = shared[1]
+				}
+				//The neighbor must run the edge from b to a
+				same := false
+				for j := 0; j < 3; j++ {
+					if x, y := m.Edge(u*3 + j); x == a && y == b {
+						same = true
+					}
+				}
+				if !visited[u] {
+					visited[u] = true
+					if same {
+						m.flip(u)
+						flipped[u] = !flipped[u]
+					}
+					component = append(component, u)
+				} else if same {
+					return count, fmt.Errorf("Mesh is not orientable")
+				}
+			}
+		}
+		if closed && m.signedVolume(component) < 0 {
+			for _, t := range component {
+				m.flip(t)
+				flipped[t] = !flipped[t]
+			}
+		}
+	}
+	for _, f := range flipped {
+		if f {
+			count++
+		}
+	}
+	m.Connect()
+	return count, nil
+}
+
+func (m *Indexed) signedVolume(triangles []int) float32 {
+	v := float32(0)
+	for _, t := range triangles {
+		a, b, c := m.Positions[m.Indices[t*3]], m.Positions[m.Indices[t*3+1]], m.Positions[m.Indices[t*3+2]]
+		v += vector.Dot(a, vector.Cross(b, c)) / 6
+	}
+	return v
+}
+
+//Volume returns the signed enclosed volume, positive for outward winding
+func (m *Indexed) Volume() float32 {
+	all := make([]int, m.Triangles())
+	for t := range all {
+		all[t] = t
+	}
+	return m.signedVolume(all)
+}
+
+//FaceNormal returns the unit normal of triangle t
+func (m *Indexed) FaceNormal(t int) vector.Vec {
+	a, b, c := m.Positions[m.Indices[t*3]], m.Positions[m.Indices[t*3+1]], m.Positions[m.Indices[t*3+2]]
+	return vector.Norm(vector.Cross(vector.Sub(b, a), vector.Sub(c, a)))
+}
+
+//VertexNormals computes vertex normals as the face normals weighted by the
+//triangle angle at the vertex, Thurmer and Wuthrich
+func (m *Indexed) VertexNormals() []vector.Vec {
+	normals := make([]vector.Vec, len(m.Positions))
+	for i := range normals {
+		normals[i] = vector.Vec{0, 0, 0}
+	}
+	for t := 0; t < m.Triangles(); t++ {
+		n := m.FaceNormal(t)
+		for k := 0; k < 3; k++ {
+			p := m.Positions[m.Indices[t*3+k]]
+			u := vector.Norm(vector.Sub(m.Positions[m.Indices[t*3+(k+1)%3]], p))
+			v := vector.Norm(vector.Sub(m.Positions[m.Indices[t*3+(k+2)%3]], p))
+			angle := float32(math.Acos(math.Max(-1, math.Min(1, float64(vector.Dot(u, v))))))
+			normals[m.Indices[t*3+k]] = vector.Add(normals[m.Indices[t*3+k]], vector.Scale(n, angle))
+		}
+	}
+	for i := range normals {
+		normals[i] = vector.Norm(normals[i])
+	}
+	m.Normals = normals
+	return normals
+}
+
+//Bounds returns the bounding box of the vertices
+func (m *Indexed) Bounds() ([3]float32, [3]float32) {
+	inf := float32(math.Inf(1))
+	min, max := [3]float32{inf, inf, inf}, [3]float32{-inf, -inf, -inf}
+	for _, p := range m.Positions {
+		for a := 0; a < 3; a++ {
+			min[a] = float32(math.Min(float64(min[a]), float64(p[a])))
+			max[a] = float32(math.Max(float64(max[a]), float64(p[a])))
+		}
+	}
+	return min, max
+}
+
+//Mesh returns the triangle list with outward face normals for wound meshes
+func (m *Indexed) Mesh() Mesh {
+	g := Mesh{Vertexes: make([]vector.Vec, len(m.Indices)), Normals: make([]vector.Vec, m.Triangles())}
+	for i, v := range m.Indices {
+		p := m.Positions[v]
+		g.Vertexes[i] = vector.Vec{p[0], p[1], p[2]}
+	}
+	for t := range g.Normals {
+		g.Normals[t] = m.FaceNormal(t)
+	}
+	return g
+}
@@ -0,0 +1,122 @@
+package mesh
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/gltf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/scene"
+)
+
+func TestIndexed(t *testing.T) {
+	box := Box(2, 1, 1, vector.Vec{0, 0, 0})
+	for i, n := range box.Normals {
+		if vector.Dot(n, box.Vertexes[i*3]) <= 0 {
+			t.Errorf("Box triangle %d normal %v points inward\n", i, n)
+		}
+	}
+	m := FromMesh(&box, 1e-5)
+	if len(m.Positions) != 8 || m.Triangles() != 12 || !m.IsWatertight() {
+		t.Fatalf("Welded box %d vertices %d triangles watertight %v\n", len(m.Positions), m.Triangles(), m.IsWatertight())
+	}
+	//The box triangles are wound inconsistently
+	flipped, err := m.Orient()
+	if err != nil || flipped == 0 || math.Abs(float64(m.Volume()-2)) > 1e-5 {
+		t.Fatalf("Oriented %d triangles volume %f error %v\n", flipped, m.Volume(), err)
+	}
+	for e, twin := range m.Twins {
+		a, b := m.Edge(e)
+		if c, d := m.Edge(twin); c != b || d != a {
+			t.Fatalf("Half edge %d (%d %d) twin (%d %d)\n", e, a, b, c, d)
+		}
+	}
+	if again, _ := m.Orient(); again != 0 {
+		t.Errorf("Oriented mesh flipped %d triangles again\n", again)
+	}
+	for i, n := range m.VertexNormals() {
+		p := m.Positions[i]
+		corner := vector.Norm(vector.Vec{p[0] / 1, p[1] / 0.5, p[2] / 0.5})
+		if vector.Dot(n, corner) < 0.9 {
+			t.Errorf("Corner %v normal %v\n", p, n)
+		}
+	}
+	if min, max := m.Bounds(); min != [3]float32{-1, -0.5, -0.5} || max != [3]float32{1, 0.5, 0.5} {
+		t.Errorf("Bounds %v %v\n", min, max)
+	}
+	g := m.Mesh()
+	if len(g.Vertexes) != 36 || vector.Dot(g.Normals[0], g.Vertexes[0]) <= 0 {
+		t.Errorf("Triangle list normal %v at %v\n", g.Normals[0], g.Vertexes[0])
+	}
+
+	//Removing a triangle opens the box
+	open := NewIndexed(m.Positions, append([]int{}, m.Indices[3:]...))
+	if len(open.BoundaryEdges()) != 3 || !open.IsManifold() || open.IsWatertight() {
+		t.Errorf("Open box boundary edges %d\n", len(open.BoundaryEdges()))
+	}
+	//Two tetrahedra touching at a vertex and three triangles on an edge
+	tetra := []int{0, 2, 1, 0, 1, 3, 1, 2, 3, 2, 0, 3}
+	points := []vector.Vec{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {-1, 0, 0}, {0, -1, 0}, {0, 0, -1}}
+	touching := append(append([]int{}, tetra...), 0, 5, 4, 0, 4, 6, 0, 6, 5, 4, 5, 6)
+	if pinched := NewIndexed(points, touching); !NewIndexed(points, tetra).IsWatertight() || pinched.IsManifold() || len(pinched.NonManifoldEdges()) != 0 {
+		t.Errorf("Pinched vertex accepted as manifold\n")
+	}
+	if fin := NewIndexed(points, append(append([]int{}, tetra...), 0, 1, 5)); fin.IsManifold() || len(fin.NonManifoldEdges()) != 3 {
+		t.Errorf("Edge shared by three triangles %d\n", len(fin.NonManifoldEdges()))
+	}
+
+	//A Mobius strip has no consistent orientation
+	strip := []vector.Vec{}
+	for i := 0; i < 12; i++ {
+		a := 2 * math.Pi * float64(i) / 12
+		for _, s := range []float64{-0.2, 0.2} {
+			r := 1 + s*math.Cos(a/2)
+			strip = append(strip, vector.Vec{float32(r * math.Cos(a)), float32(r * math.Sin(a)), float32(s * math.Sin(a/2))})
+		}
+	}
+	twisted := []int{}
+	for i := 0; i < 12; i++ {
+		a, b, c, d := 2*i, 2*i+1, 2*(i+1), 2*(i+1)+1
+		if i == 11 {
+			c, d = 1, 0
+		}
+		twisted = append(twisted, a, b, d, a, d, c)
+	}
+	if _, err := NewIndexed(strip, twisted).Orient(); err == nil {
+		t.Errorf("Mobius strip oriented\n")
+	}
+}
+
+func TestIndexedGLTF(t *testing.T) {
+	box := Box(1, 1, 1, vector.Vec{0.5, 0.5, 0.5})
+	m := FromMesh(&box, 1e-5)
+	m.Orient()
+	data, err := m.GLTF("box")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := gltf.GlTF{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	uri := doc.Buffers[0].Uri
+	buffer, err := base64.StdEncoding.DecodeString(uri[strings.Index(uri, ",")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	scn := scene.Scene{Root: &doc, Buffers: [][]byte{buffer}}
+	read, err := FromPrimitive(&scn, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Triangles() != 12 || len(read.Positions) != 8 || !read.IsWatertight() || math.Abs(float64(read.Volume()-1)) > 1e-6 {
+		t.Errorf("glTF round trip %d triangles volume %f\n", read.Triangles(), read.Volume())
+	}
+	normals, _, err := scn.AccessorFloats(doc.Meshes[0].Primitives[0].Attributes["NORMAL"])
+	if err != nil || len(normals) != 24 || normals[0] != m.Normals[0][0] {
+		t.Errorf("glTF normals %v error %v\n", normals, err)
+	}
+}
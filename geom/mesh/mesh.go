@@ -19,15 +19,15 @@ func InitMesh(vertices []vector.Vec, origin vector.Vec) Mesh {
 	nMesh.Vertexes = vertices
 	nMesh.Normals = make([]vector.Vec, len(vertices)/3)
 	index := 0
-	//Makes the normals from triangle vertices - We would like all normals to be inward
-	//Default Point towards zero vec
-	for i := 0; i < len(vertices)-3; i += 3 {
+	//Makes the normals from triangle vertices - normals are outward like the
+	//wound normals of the importers and Indexed.Mesh, pointing away from origin
+	for i := 0; i+3 <= len(vertices); i += 3 {
 		thisTriangle := T.InitTriangle(vertices[i], vertices[i+1], vertices[i+2])
 		n := thisTriangle.Normal()
 		v0 := vector.Sub(vertices[i], origin)
 		dv0 := vector.Dot(n, v0)
-		if dv0 > 0 {
-			vector.Scale(n, -1.0)
+		if dv0 < 0 {
+			n = vector.Scale(n, -1.0)
 		}
 		nMesh.Normals[i/3] = n
 		index++
@@ -36,23 +36,26 @@ func InitMesh(vertices []vector.Vec, origin vector.Vec) Mesh {
 	return nMesh
 }
 
-//Collision checks for collision with underlying mesh
+//Collision sweeps a particle of radius r from P along V over dt against the mesh
+//and reports the earliest contact
 //Returns Normal, Barycentric Coords, Collision Point, Collision Bool
 func (g *Mesh) Collision(P vector.Vec, V vector.Vec, dt float64, r float32) (vector.Vec, vector.Vec, vector.Vec, bool) {
 
-	VERTS := len(g.Vertexes)
+	VERTS := len(g.Vertexes) / 3 * 3
+	P1 := vector.Add(P, vector.Scale(V, float32(dt)))
+	first, hit := -1, float32(2)
 
 	for i := 0; i < VERTS; i += 3 {
-		normal := g.Normals[i/3]
 		triangle := T.InitTriangle(g.Vertexes[i], g.Vertexes[i+1], g.Vertexes[i+2])
-		fN, coord, p0, c0 := triangle.BarycentricCollision(P, V, normal, dt, r)
-
-		if c0 {
-			return fN, coord, p0, true
+		if s, _, c0 := triangle.Sweep(P, P1, r); c0 && s < hit {
+			first, hit = i, s
 		}
-
 	}
 
+	if first >= 0 {
+		triangle := T.InitTriangle(g.Vertexes[first], g.Vertexes[first+1], g.Vertexes[first+2])
+		return triangle.BarycentricCollision(P, V, g.Normals[first/3], dt, r)
+	}
 	return vector.Vec{}, vector.Vec{}, vector.Vec{}, false
 }
 
@@ -0,0 +1,63 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+//ReadOBJ imports the vertices and faces of a Wavefront OBJ mesh. Polygons are fan
+//triangulated, negative indices count back from the last vertex and texture,
+//normal, group and material statements are ignored
+func ReadOBJ(r io.Reader, opts *ImportOptions) (Mesh, error) {
+	positions := []float32{}
+	indices := []int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "v":
+			if len(fields) < 4 {
+				return Mesh{}, fmt.Errorf("OBJ line %d: vertex needs three coordinates", line)
+			}
+			for _, f := range fields[1:4] {
+				x, err := strconv.ParseFloat(f, 32)
+				if err != nil {
+					return Mesh{}, fmt.Errorf("OBJ line %d: %v", line, err)
+				}
+				positions = append(positions, float32(x))
+			}
+		case "f":
+			polygon := make([]int, 0, len(fields)-1)
+			for _, f := range fields[1:] {
+				//Vertex references are v, v/vt, v//vn or v/vt/vn
+				v, err := strconv.Atoi(strings.SplitN(f, "/", 2)[0])
+				if err != nil || v == 0 {
+					return Mesh{}, fmt.Errorf("OBJ line %d: invalid vertex reference %s", line, f)
+				}
+				if v < 0 {
+					v += len(positions) / 3
+				} else {
+					v--
+				}
+				polygon = append(polygon, v)
+			}
+			if len(polygon) < 3 {
+				return Mesh{}, fmt.Errorf("OBJ line %d: face needs three vertices", line)
+			}
+			indices = fan(polygon, indices)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Mesh{}, err
+	}
+	return build(positions, indices, opts)
+}
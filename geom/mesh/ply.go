@@ -0,0 +1,219 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//PLY_LIST_LIMIT bounds the length of a list property such as a face polygon
+const PLY_LIST_LIMIT = 1 << 16
+
+type plyProperty struct {
+	name      string
+	kind      string
+	list      bool
+	countKind string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+//plyReader decodes scalar values from the ASCII or binary body
+type plyReader struct {
+	in     *bufio.Reader
+	ascii  bool
+	order  binary.ByteOrder
+	buffer [8]byte
+}
+
+func plySize(kind string) int {
+	switch kind {
+	case "char", "int8", "uchar", "uint8":
+		return 1
+	case "short", "int16", "ushort", "uint16":
+		return 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		return 4
+	case "double", "float64":
+		return 8
+	}
+	return 0
+}
+
+func (r *plyReader) token() (string, error) {
+	word := []byte{}
+	for {
+		c, err := r.in.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(word) > 0 {
+				return string(word), nil
+			}
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if len(word) > 0 {
+				return string(word), nil
+			}
+			continue
+		}
+		word = append(word, c)
+	}
+}
+
+func (r *plyReader) value(kind string) (float64, error) {
+	if r.ascii {
+		t, err := r.token()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(t, 64)
+	}
+	size := plySize(kind)
+	b := r.buffer[:size]
+	if _, err := io.ReadFull(r.in, b); err != nil {
+		return 0, err
+	}
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(r.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(r.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(r.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(r.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(r.order.Uint32(b))), nil
+	}
+	return math.Float64frombits(r.order.Uint64(b)), nil
+}
+
+//ReadPLY imports the vertex positions and faces of an ASCII or binary PLY mesh.
+//Polygonal faces are fan triangulated and other elements and properties are
+//skipped
+func ReadPLY(r io.Reader, opts *ImportOptions) (Mesh, error) {
+	in := bufio.NewReader(r)
+	reader := plyReader{in: in}
+	elements := []*plyElement{}
+	for line := 0; ; line++ {
+		text, err := in.ReadString('\n')
+		if err != nil {
+			return Mesh{}, fmt.Errorf("PLY header: %v", err)
+		}
+		fields := strings.Fields(text)
+		if line == 0 {
+			if len(fields) != 1 || fields[0] != "ply" {
+				return Mesh{}, fmt.Errorf("Not a PLY file")
+			}
+			continue
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "end_header" {
+			break
+		}
+		switch fields[0] {
+		case "format":
+			if len(fields) < 2 {
+				return Mesh{}, fmt.Errorf("PLY header line %d: missing format", line+1)
+			}
+			switch fields[1] {
+			case "ascii":
+				reader.ascii = true
+			case "binary_little_endian":
+				reader.order = binary.LittleEndian
+			case "binary_big_endian":
+				reader.order = binary.BigEndian
+			default:
+				return Mesh{}, fmt.Errorf("Unsupported PLY format %s", fields[1])
+			}
+		case "element":
+			if len(fields) < 3 {
+				return Mesh{}, fmt.Errorf("PLY header line %d: element needs a name and count", line+1)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return Mesh{}, fmt.Errorf("PLY header line %d: %v", line+1, err)
+			}
+			elements = append(elements, &plyElement{name: fields[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return Mesh{}, fmt.Errorf("PLY header line %d: property outside an element", line+1)
+			}
+			e := elements[len(elements)-1]
+			if len(fields) == 5 && fields[1] == "list" {
+				if plySize(fields[2]) == 0 || plySize(fields[3]) == 0 {
+					return Mesh{}, fmt.Errorf("PLY header line %d: unknown property type", line+1)
+				}
+				e.properties = append(e.properties, plyProperty{fields[4], fields[3], true, fields[2]})
+			} else if len(fields) == 3 && plySize(fields[1]) > 0 {
+				e.properties = append(e.properties, plyProperty{fields[2], fields[1], false, ""})
+			} else {
+				return Mesh{}, fmt.Errorf("PLY header line %d: invalid property", line+1)
+			}
+		}
+	}
+	if !reader.ascii && reader.order == nil {
+		return Mesh{}, fmt.Errorf("PLY header has no format")
+	}
+
+	positions := []float32{}
+	indices := []int{}
+	for _, e := range elements {
+		for i := 0; i < e.count; i++ {
+			vertex := [3]float32{}
+			for _, p := range e.properties {
+				if !p.list {
+					x, err := reader.value(p.kind)
+					if err != nil {
+						return Mesh{}, fmt.Errorf("PLY %s %d: %v", e.name, i, err)
+					}
+					switch p.name {
+					case "x":
+						vertex[0] = float32(x)
+					case "y":
+						vertex[1] = float32(x)
+					case "z":
+						vertex[2] = float32(x)
+					}
+					continue
+				}
+				n, err := reader.value(p.countKind)
+				if err != nil {
+					return Mesh{}, fmt.Errorf("PLY %s %d: %v", e.name, i, err)
+				}
+				if n < 0 || n > PLY_LIST_LIMIT {
+					return Mesh{}, fmt.Errorf("PLY %s %d: invalid list length %g", e.name, i, n)
+				}
+				polygon := make([]int, int(n))
+				for k := range polygon {
+					x, err := reader.value(p.kind)
+					if err != nil {
+						return Mesh{}, fmt.Errorf("PLY %s %d: %v", e.name, i, err)
+					}
+					polygon[k] = int(x)
+				}
+				if e.name == "face" && (p.name == "vertex_indices" || p.name == "vertex_index") && len(polygon) >= 3 {
+					indices = fan(polygon, indices)
+				}
+			}
+			if e.name == "vertex" {
+				positions = append(positions, vertex[:]...)
+			}
+		}
+	}
+	return build(positions, indices, opts)
+}
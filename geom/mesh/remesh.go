@@ -0,0 +1,179 @@
+package mesh
+
+import (
+	"fmt"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Minimum cosine between the triangles of an edge for flipping, sharper edges
+//being kept as features
+const REMESH_FEATURE = 0.9
+
+//Remesh runs isotropic remeshing, Botsch and Kobbelt, towards edges of the
+//target length. Each iteration splits edges longer than 4/3 length, collapses
+//edges shorter than 4/5 length, flips edges towards valence six and relaxes
+//the vertices tangentially. Boundary vertices stay in place. The target length
+//must be positive
+func (m *Indexed) Remesh(length float32, iterations int) error {
+	if length <= 0 {
+		return fmt.Errorf("Remesh edge length must be positive, got %f", length)
+	}
+	normals := m.Normals != nil
+	for i := 0; i < iterations; i++ {
+		m.splitLong(4 * length / 3)
+		m.collapseShort(4*length/5, 4*length/3)
+		m.flipValence()
+		m.relaxTangential()
+	}
+	m.Normals = nil
+	if normals {
+		m.VertexNormals()
+	}
+	return nil
+}
+
+//splitLong splits the longest edge of each triangle at its midpoint until no
+//edge is longer than max
+func (m *Indexed) splitLong(max float32) {
+	e := newEditor(m)
+	for t := 0; t < len(e.removed); t++ {
+		longest, a, b := max, -1, -1
+		for k := 0; k < 3; k++ {
+			u, v := m.Indices[t*3+k], m.Indices[t*3+(k+1)%3]
+			if d := vector.Dist(m.Positions[u], m.Positions[v]); d > longest {
+				longest, a, b = d, u, v
+			}
+		}
+		if a < 0 {
+			continue
+		}
+		c := e.vertex(vector.Scale(vector.Add(m.Positions[a], m.Positions[b]), 0.5))
+		for _, s := range e.shared(a, b) {
+			//Triangle s runs p q o, becoming p c o and c q o
+			p, q := a, b
+			if (e.corner(s, b)+1)%3 == e.corner(s, a) {
+				p, q = b, a
+			}
+			o := e.third(s, p, q)
+			e.replace(s, e.corner(s, q), c)
+			e.add(c, q, o)
+		}
+		t--
+	}
+	e.finish()
+}
+
+//collapseShort collapses edges shorter than min to their midpoint, or onto the
+//boundary vertex, unless the collapse creates edges longer than max
+func (m *Indexed) collapseShort(min float32, max float32) {
+	e := newEditor(m)
+	for t := 0; t < len(e.removed); t++ {
+		for k := 0; k < 3 && !e.removed[t]; k++ {
+			u, v := m.Indices[t*3+k], m.Indices[t*3+(k+1)%3]
+			if vector.Dist(m.Positions[u], m.Positions[v]) >= min {
+				continue
+			}
+			bu, bv := e.boundary(u), e.boundary(v)
+			if bu && bv {
+				continue
+			}
+			if bv {
+				u, v = v, u
+			}
+			p := vector.Scale(vector.Add(m.Positions[u], m.Positions[v]), 0.5)
+			if bu || bv {
+				p = vector.Vec{m.Positions[u][0], m.Positions[u][1], m.Positions[u][2]}
+			}
+			long := false
+			for _, w := range []int{u, v} {
+				for n := range e.ring(w) {
+					long = long || vector.Dist(p, m.Positions[n]) > max
+				}
+			}
+			if !long && e.canCollapse(u, v, p) {
+				e.collapse(u, v, p)
+			}
+		}
+	}
+	e.finish()
+}
+
+//flipValence flips interior edges when it brings the valences of the four
+//vertices involved closer to six, or four on the boundary
+func (m *Indexed) flipValence() {
+	e := newEditor(m)
+	deviation := func(v int, change int) int {
+		target := 6
+		if e.boundary(v) {
+			target = 4
+		}
+		d := len(e.ring(v)) + change - target
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	for t := 0; t < len(e.removed); t++ {
+		for k := 0; k < 3; k++ {
+			a, b, c := m.Indices[t*3+k], m.Indices[t*3+(k+1)%3], m.Indices[t*3+(k+2)%3]
+			shared := e.shared(a, b)
+			if len(shared) != 2 {
+				continue
+			}
+			u := shared[0]
+			if u == t {
+				u = shared[1]
+			}
+			//The neighbor must run b a d
+			d := e.third(u, a, b)
+			if (e.corner(u, b)+1)%3 != e.corner(u, a) || e.ring(c)[d] > 0 {
+				continue
+			}
+			before := deviation(a, 0) + deviation(b, 0) + deviation(c, 0) + deviation(d, 0)
+			after := deviation(a, -1) + deviation(b, -1) + deviation(c, 1) + deviation(d, 1)
+			if after >= before {
+				continue
+			}
+			nt, nu := vector.Norm(e.normal(t, -1, nil)), vector.Norm(e.normal(u, -1, nil))
+			if vector.Dot(nt, nu) < REMESH_FEATURE {
+				continue
+			}
+			pa, pb, pc, pd := m.Positions[a], m.Positions[b], m.Positions[c], m.Positions[d]
+			average := vector.Norm(vector.Add(nt, nu))
+			first := vector.Cross(vector.Sub(pa, pc), vector.Sub(pd, pc))
+			second := vector.Cross(vector.Sub(pb, pd), vector.Sub(pc, pd))
+			if vector.Dot(vector.Norm(first), average) < EDIT_NORMAL || vector.Dot(vector.Norm(second), average) < EDIT_NORMAL {
+				continue
+			}
+			//a b c and b a d become c a d and d b c
+			e.replace(t, e.corner(t, b), d)
+			e.replace(u, e.corner(u, a), c)
+			break
+		}
+	}
+	e.finish()
+}
+
+//relaxTangential moves the free vertices towards the mean of their neighbors
+//within the tangent plane of the vertex normal
+func (m *Indexed) relaxTangential() {
+	normals := m.VertexNormals()
+	neighbors, fixed := m.umbrella()
+	positions := make([]vector.Vec, len(m.Positions))
+	for v, p := range m.Positions {
+		positions[v] = p
+		if fixed[v] || len(neighbors[v]) == 0 {
+			continue
+		}
+		mean := vector.Vec{0, 0, 0}
+		for _, w := range neighbors[v] {
+			mean = vector.Add(mean, m.Positions[w])
+		}
+		move := vector.Sub(vector.Scale(mean, 1/float32(len(neighbors[v]))), p)
+		move = vector.Sub(move, vector.Scale(normals[v], vector.Dot(move, normals[v])))
+		positions[v] = vector.Add(p, move)
+	}
+	m.Positions = positions
+	m.Normals = nil
+}
@@ -0,0 +1,96 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Taubin Smoothing Factors, a pass band near 1/lambda + 1/mu = 0.11
+const SMOOTH_LAMBDA = 0.5
+const SMOOTH_MU = -0.53
+
+//umbrella returns the neighbors of each vertex, listed once per half edge, and
+//the vertices held fixed on open or non manifold edges and pinched fans
+func (m *Indexed) umbrella() ([][]int, []bool) {
+	if len(m.Twins) != len(m.Indices) {
+		m.Connect()
+	}
+	neighbors := make([][]int, len(m.Positions))
+	fixed := make([]bool, len(m.Positions))
+	for h, twin := range m.Twins {
+		a, b := m.Edge(h)
+		neighbors[a] = append(neighbors[a], b)
+		neighbors[b] = append(neighbors[b], a)
+		if twin < 0 {
+			fixed[a], fixed[b] = true, true
+		}
+	}
+	for v, f := range m.fans() {
+		fixed[v] = fixed[v] || f > 1
+	}
+	return neighbors, fixed
+}
+
+//relax moves the free vertices by factor times the umbrella Laplacian, the
+//mean of the neighbors less the position
+func (m *Indexed) relax(neighbors [][]int, fixed []bool, factor float32) {
+	positions := make([]vector.Vec, len(m.Positions))
+	for v, p := range m.Positions {
+		positions[v] = p
+		if fixed[v] || len(neighbors[v]) == 0 {
+			continue
+		}
+		mean := vector.Vec{0, 0, 0}
+		for _, w := range neighbors[v] {
+			mean = vector.Add(mean, m.Positions[w])
+		}
+		mean = vector.Scale(mean, 1/float32(len(neighbors[v])))
+		positions[v] = vector.Add(p, vector.Scale(vector.Sub(mean, p), factor))
+	}
+	m.Positions = positions
+}
+
+//Laplacian smooths the mesh with iterations of the umbrella operator scaled by
+//lambda. Closed surfaces shrink towards their centroid
+func (m *Indexed) Laplacian(iterations int, lambda float32) {
+	neighbors, fixed := m.umbrella()
+	for i := 0; i < iterations; i++ {
+		m.relax(neighbors, fixed, lambda)
+	}
+	if m.Normals != nil {
+		m.VertexNormals()
+	}
+}
+
+//Taubin smooths the mesh without shrinkage by alternating a shrinking lambda
+//step with an inflating negative mu step, a low pass filter removing the high
+//frequency noise of reconstructed surfaces. Boundary vertices stay fixed
+func (m *Indexed) Taubin(iterations int, lambda float32, mu float32) {
+	neighbors, fixed := m.umbrella()
+	for i := 0; i < iterations; i++ {
+		m.relax(neighbors, fixed, lambda)
+		m.relax(neighbors, fixed, mu)
+	}
+	if m.Normals != nil {
+		m.VertexNormals()
+	}
+}
+
+//ScaleVolume scales a closed outward wound mesh about its vertex centroid to
+//enclose volume, restoring the volume lost to smoothing or decimation
+func (m *Indexed) ScaleVolume(volume float32) {
+	current := m.Volume()
+	if current <= 0 || volume <= 0 || len(m.Positions) == 0 {
+		return
+	}
+	center := vector.Vec{0, 0, 0}
+	for _, p := range m.Positions {
+		center = vector.Add(center, p)
+	}
+	center = vector.Scale(center, 1/float32(len(m.Positions)))
+	s := float32(math.Cbrt(float64(volume / current)))
+	for v, p := range m.Positions {
+		m.Positions[v] = vector.Add(center, vector.Scale(vector.Sub(p, center), s))
+	}
+}
@@ -0,0 +1,77 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//STL Binary Layout
+const STL_HEADER = 80
+const STL_FACET = 50
+
+//ReadSTL imports an ASCII or binary STL mesh. Files are read as binary when the
+//size matches the facet count of the header, since binary exports often start
+//their header with "solid" as well. Facet normals are recomputed from winding
+func ReadSTL(r io.Reader, opts *ImportOptions) (Mesh, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Mesh{}, err
+	}
+	var positions []float32
+	if len(data) >= STL_HEADER+4 {
+		count := int(binary.LittleEndian.Uint32(data[STL_HEADER:]))
+		if len(data) == STL_HEADER+4+count*STL_FACET {
+			positions = make([]float32, 0, count*9)
+			for f := 0; f < count; f++ {
+				//Skip the 12 byte normal, read three vertices and skip attributes
+				at := STL_HEADER + 4 + f*STL_FACET + 12
+				for k := 0; k < 9; k++ {
+					positions = append(positions, math.Float32frombits(binary.LittleEndian.Uint32(data[at+k*4:])))
+				}
+			}
+		}
+	}
+	if positions == nil {
+		if positions, err = readASCIISTL(data); err != nil {
+			return Mesh{}, err
+		}
+	}
+	indices := make([]int, len(positions)/3)
+	for i := range indices {
+		indices[i] = i
+	}
+	return build(positions, indices, opts)
+}
+
+func readASCIISTL(data []byte) ([]float32, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return nil, fmt.Errorf("STL is neither ASCII nor a binary file of matching size")
+	}
+	positions := []float32{}
+	for n, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "vertex" {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("STL line %d: vertex needs three coordinates", n+1)
+		}
+		for _, f := range fields[1:4] {
+			x, err := strconv.ParseFloat(f, 32)
+			if err != nil {
+				return nil, fmt.Errorf("STL line %d: %v", n+1, err)
+			}
+			positions = append(positions, float32(x))
+		}
+	}
+	if len(positions)%9 != 0 {
+		return nil, fmt.Errorf("STL facets must have three vertices")
+	}
+	return positions, nil
+}
@@ -1,187 +1,559 @@
 package geom
 
-import "github.com/andewx/dieselfluid/math/vector"
+import (
+	"container/heap"
+	"math"
+	"math/bits"
+	"sort"
 
-//Octal Tree Structure/Object acts as a classifier for points and point sets
-//Whereby point representation in space is assigned a containing volume code by
-//the encoded pseudo-octet. We don't actually compress here in this libary to the
-//bit wise encoding but with depths usually small depths this shouldn't be an issue
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Morton is a bit packed octal cell key. Each level appends the x, y, z octant
+//bits below a leading sentinel bit, so the root is 1, the parent of a key is
+//key >> 3 and the depth follows from the bit length
+type Morton uint64
+
+//Deepest level a Morton key can encode in 64 bits
+const MORTON_MAX_DEPTH = 21
+
+//Octal Tree Defaults
+const OCTAL_MAX_DEPTH = 6
+const OCTAL_LEAF_SIZE = 8
+
+//Depth returns the tree level of the key, 0 for the root
+func (k Morton) Depth() int {
+	return (bits.Len64(uint64(k)) - 1) / 3
+}
+
+//Parent returns the key of the containing cell, the root is its own parent
+func (k Morton) Parent() Morton {
+	if k <= 1 {
+		return k
+	}
+	return k >> 3
+}
+
+//Child returns the key of an octant, octant bits ordered x, y, z
+func (k Morton) Child(octant int) Morton {
+	return k<<3 | Morton(octant&7)
+}
+
+//Ancestor returns the containing key at a shallower depth
+func (k Morton) Ancestor(depth int) Morton {
+	if d := k.Depth(); depth < d {
+		return k >> uint(3*(d-depth))
+	}
+	return k
+}
+
+//Coord returns the integer cell coordinates of the key at its depth
+func (k Morton) Coord() [3]int {
+	code := uint64(k) &^ (1 << uint(3*k.Depth()))
+	return [3]int{int(compact3(code >> 2)), int(compact3(code >> 1)), int(compact3(code))}
+}
+
+//MortonKey returns the key of the cell with integer coordinates c at depth
+func MortonKey(c [3]int, depth int) Morton {
+	return Morton(1<<uint(3*depth) | split3(uint64(c[0]))<<2 | split3(uint64(c[1]))<<1 | split3(uint64(c[2])))
+}
+
+//split3 spreads the low 21 bits of x to every third bit
+func split3(x uint64) uint64 {
+	x &= 0x1fffff
+	x = (x | x<<32) & 0x1f00000000ffff
+	x = (x | x<<16) & 0x1f0000ff0000ff
+	x = (x | x<<8) & 0x100f00f00f00f00f
+	x = (x | x<<4) & 0x10c30c30c30c30c3
+	x = (x | x<<2) & 0x1249249249249249
+	return x
+}
+
+//compact3 gathers every third bit of x, the inverse of split3
+func compact3(x uint64) uint64 {
+	x &= 0x1249249249249249
+	x = (x ^ x>>2) & 0x10c30c30c30c30c3
+	x = (x ^ x>>4) & 0x100f00f00f00f00f
+	x = (x ^ x>>8) & 0x1f0000ff0000ff
+	x = (x ^ x>>16) & 0x1f00000000ffff
+	x = (x ^ x>>32) & 0x1fffff
+	return x
+}
+
+type octalPoint struct {
+	position [3]float32
+	code     Morton //Key at MORTON_MAX_DEPTH
+	leaf     Morton
+	slot     int //Index within the leaf list
+}
+
+//Octal Tree is an adaptive octree over identified points. Leaves split into
+//octants once they hold more than LeafSize points until MaxDepth, so dense
+//clusters refine while sparse regions stay coarse. Map holds the point ids of
+//every leaf and Nodes the child occupancy mask of every internal cell
 type OctalTree struct {
-	Bounds   [3]float32 //Width, Height, Depth, (X,Y,Z)
-	Origin   [3]float32 //Initial Original of Octal
+	Bounds   [3]float32 //Half extents of the root cell (X,Y,Z)
+	Origin   [3]float32 //Center of the root cell
 	MaxDepth int        //Octal Tree Depth
-	Map      map[string][]int
+	LeafSize int        //Points held by a leaf before it splits
+	Map      map[Morton][]int
+	Nodes    map[Morton]uint8
+	points   map[int]*octalPoint
 }
 
+//InitOctalTree creates a tree centered on the origin spanning [-w,w]x[-h,h]x[-d,d]
 func InitOctalTree(w float32, h float32, d float32) *OctalTree {
-	return &OctalTree{[3]float32{w, h, d}, [3]float32{0, 0, 0}, 6, make(map[string][]int, 10)}
-}
-
-//Octet Lazy Encoding - No Bit Manipulation for Space Concerns
-//Use uint8 encoding for space partitioning sequence and depth is determined
-//By the sequence length
-func (p OctalTree) GetCentroid(encoding []uint8) [3]float32 {
-	current_centroid := p.Origin
-	current_bounds := p.Bounds
-	for i := 0; i < len(encoding); i += 3 {
-		xb := (float32(encoding[i]) - 0.5)
-		yb := (float32(encoding[i+1]) - 0.5)
-		zb := (float32(encoding[i+2]) - 0.5)
-		current_centroid[0] = current_centroid[0] + (xb * current_bounds[0])
-		current_centroid[1] = current_centroid[1] + (yb * current_bounds[1])
-		current_centroid[2] = current_centroid[2] + (zb * current_bounds[2])
-		current_bounds[0] = current_bounds[0] / 2
-		current_bounds[1] = current_bounds[1] / 2
-		current_bounds[2] = current_bounds[2] / 2
-	}
-	return current_centroid
-}
-
-func (p OctalTree) GetParent(encoding []uint8) string {
-	if len(encoding) >= 3 {
-		return string(encoding[0 : len(encoding)-3])
-	} else {
-		return string(encoding[:])
+	return NewOctalTree([3]float32{0, 0, 0}, [3]float32{w, h, d}, OCTAL_MAX_DEPTH, OCTAL_LEAF_SIZE)
+}
+
+//NewOctalTree creates a tree with the root cell center, half extents, maximum
+//depth and leaf capacity
+func NewOctalTree(origin [3]float32, half [3]float32, maxDepth int, leafSize int) *OctalTree {
+	if maxDepth > MORTON_MAX_DEPTH {
+		maxDepth = MORTON_MAX_DEPTH
+	}
+	if leafSize < 1 {
+		leafSize = 1
+	}
+	return &OctalTree{half, origin, maxDepth, leafSize, make(map[Morton][]int, 16), make(map[Morton]uint8, 16), make(map[int]*octalPoint, 16)}
+}
+
+//Len returns the number of points in the tree
+func (p *OctalTree) Len() int {
+	return len(p.points)
+}
+
+//Contains checks if a position lies inside the root cell
+func (p *OctalTree) Contains(point []float32) bool {
+	for a := 0; a < 3; a++ {
+		if math.Abs(float64(point[a]-p.Origin[a])) > float64(p.Bounds[a]) {
+			return false
+		}
 	}
+	return true
 }
 
-//TODO Add Sibling Nodes
-func (p OctalTree) GetNeighbors(encoding []uint8) []int {
-	encode_str := string(encoding[:])
-	enc_par := p.GetParent(encoding)
-	myNeighbors := append(p.Map[encode_str][:], p.Map[enc_par][:]...)
-	return myNeighbors
+//Position returns the stored position of a point
+func (p *OctalTree) Position(id int) ([3]float32, bool) {
+	if e, ok := p.points[id]; ok {
+		return e.position, true
+	}
+	return [3]float32{}, false
 }
 
-//inserts
-func (p OctalTree) InsertPoint(point vector.Vec, unique_id int, desired_depth int) {
+//EncodePoint returns the key of the cell containing point at the tree depth.
+//Coarser keys are taken with Ancestor
+func (p *OctalTree) EncodePoint(point []float32) Morton {
+	return p.encode(point, p.MaxDepth)
+}
 
-	encoding := p.EncodePoint(point, desired_depth)
-	str := string(encoding[:])
-	p.Map[str] = append(p.Map[str][:], unique_id)
+//encode returns the key of the cell containing point at depth. Points outside
+//the root are clamped to its border cells
+func (p *OctalTree) encode(point []float32, depth int) Morton {
+	if depth > MORTON_MAX_DEPTH {
+		depth = MORTON_MAX_DEPTH
+	}
+	n := float64(uint64(1) << MORTON_MAX_DEPTH)
+	var c [3]int
+	for a := 0; a < 3; a++ {
+		x := math.Floor(float64(point[a]-p.Origin[a]+p.Bounds[a]) / float64(2*p.Bounds[a]) * n)
+		c[a] = int(math.Max(0, math.Min(n-1, x)))
+	}
+	return MortonKey(c, MORTON_MAX_DEPTH).Ancestor(depth)
 }
 
-func (p OctalTree) RemovePoint(encoding string, unique_id int) {
-	index := 0
-	found := false
-	for !found && index < len(p.Map[encoding]) {
-		if p.Map[encoding][index] == unique_id {
-			found = true
-		} else {
-			index++
+//CellBounds returns the minimum and maximum corner of a cell
+func (p *OctalTree) CellBounds(k Morton) ([3]float32, [3]float32) {
+	d, c := k.Depth(), k.Coord()
+	var min, max [3]float32
+	for a := 0; a < 3; a++ {
+		size := 2 * p.Bounds[a] / float32(uint64(1)<<uint(d))
+		min[a] = p.Origin[a] - p.Bounds[a] + size*float32(c[a])
+		max[a] = min[a] + size
+	}
+	return min, max
+}
+
+//GetCentroid returns the center of a cell
+func (p *OctalTree) GetCentroid(k Morton) [3]float32 {
+	min, max := p.CellBounds(k)
+	return [3]float32{(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2}
+}
+
+//GetParent returns the key of the containing cell
+func (p *OctalTree) GetParent(k Morton) Morton {
+	return k.Parent()
+}
+
+//IsLeaf checks if a key is a leaf cell holding points
+func (p *OctalTree) IsLeaf(k Morton) bool {
+	_, ok := p.Map[k]
+	return ok
+}
+
+//Leaf returns the leaf cell containing point, or the deepest existing cell on
+//its path when that region holds no points
+func (p *OctalTree) Leaf(point []float32) Morton {
+	return p.descend(p.encode(point, MORTON_MAX_DEPTH))
+}
+
+//descend walks from the root along code while the cells are internal
+func (p *OctalTree) descend(code Morton) Morton {
+	k := Morton(1)
+	for d := 1; d <= MORTON_MAX_DEPTH; d++ {
+		mask, internal := p.Nodes[k]
+		if !internal {
+			break
+		}
+		child := code.Ancestor(d)
+		if mask&(1<<uint(child&7)) == 0 {
+			break
 		}
+		k = child
 	}
-	if index < len(p.Map[encoding])-1 {
-		p.Map[encoding] = append(p.Map[encoding][0:index], p.Map[encoding][index+1:]...)
-	} else {
-		p.Map[encoding] = append(p.Map[encoding][0:index])
-	}
-}
-
-/*Encodes a single point into a octal encoding with max depth*/
-func (p OctalTree) EncodePoint(point vector.Vec, desired_depth int) []uint8 {
-	encoding := make([]uint8, p.MaxDepth*3)
-	current_centroid := p.Origin
-	current_bounds := p.Bounds
-	for i := 0; i < p.MaxDepth*3; i += 3 {
-		if point[0] > current_centroid[0] {
-			encoding[i] = uint8(1)
-			current_centroid[0] = current_centroid[0] + (0.5 * current_bounds[0])
-		} else {
-			current_centroid[0] = current_centroid[0] + (-0.5 * current_bounds[0])
-		}
-		if point[1] > current_centroid[1] {
-			encoding[i+1] = uint8(1)
-			current_centroid[1] = current_centroid[1] + (0.5 * current_bounds[1])
-		} else {
-			current_centroid[1] = current_centroid[1] + (-0.5 * current_bounds[1])
-		}
-		if point[2] > current_centroid[2] {
-			encoding[i+2] = uint8(1)
-			current_centroid[2] = current_centroid[2] + (0.5 * current_bounds[2])
-		} else {
-			current_centroid[2] = current_centroid[2] + (-0.5 * current_bounds[2])
-		}
-
-		current_bounds[0] = current_bounds[0] / 2
-		current_bounds[1] = current_bounds[1] / 2
-		current_bounds[2] = current_bounds[2] / 2
-	}
-	return encoding
-}
-
-/* Generalizes the encoding of a sequential point mesh grouping into a single
-octal encoding*/
-func (p OctalTree) EncodePointGroup(group []vector.Vec, depth_encode int) []uint8 {
-	encodes := make([][]uint8, len(group))
-	max_group_encode := make([]uint8, p.MaxDepth*3)
-	for i := 0; i < len(group); i++ {
-		encodes[i] = p.EncodePoint(group[i], depth_encode)
-	}
-	depth := 0
-	similar := true
-	for similar && depth < p.MaxDepth*3 {
-		first := encodes[0][depth]
-		second := encodes[0][depth+1]
-		third := encodes[0][depth+2]
-		for j := 0; j < len(group); j++ {
-			if first != encodes[j][depth] {
-				similar = false
-			}
-			if second != encodes[j][depth+1] {
-				similar = false
-			}
-			if third != encodes[j][depth+2] {
-				similar = false
-			}
+	return k
+}
+
+//InsertPoint adds or moves a point. The root grows to contain points outside it
+func (p *OctalTree) InsertPoint(point []float32, id int) {
+	if _, ok := p.points[id]; ok {
+		p.RemovePoint(id)
+	}
+	position := [3]float32{point[0], point[1], point[2]}
+	if !p.Contains(point) {
+		p.refit(&position)
+	}
+	e := &octalPoint{position: position, code: p.encode(point, MORTON_MAX_DEPTH)}
+	p.points[id] = e
+	p.insert(id, e)
+}
+
+func (p *OctalTree) insert(id int, e *octalPoint) {
+	k := Morton(1)
+	for d := 1; ; d++ {
+		if _, internal := p.Nodes[k]; !internal {
+			break
 		}
-		//We always encode at least the first group of non-similarity according to the first points grouping
-		//If we want to get the point group encodings actual centroid we decode to depth - 1
-		max_group_encode[depth] = first
-		max_group_encode[depth+1] = second
-		max_group_encode[depth+2] = third
-		if similar {
-			depth += 3
+		child := e.code.Ancestor(d)
+		p.Nodes[k] |= 1 << uint(child&7)
+		k = child
+	}
+	e.leaf, e.slot = k, len(p.Map[k])
+	p.Map[k] = append(p.Map[k], id)
+	if len(p.Map[k]) > p.LeafSize && k.Depth() < p.MaxDepth {
+		ids := p.Map[k]
+		delete(p.Map, k)
+		p.Nodes[k] = 0
+		for _, i := range ids {
+			p.insert(i, p.points[i])
 		}
 	}
+}
 
-	r_encode := make([]uint8, depth)
-	for i := 0; i < depth; i++ {
-		r_encode[i] = max_group_encode[i]
+//RemovePoint deletes a point in constant time, pruning cells left empty
+func (p *OctalTree) RemovePoint(id int) bool {
+	e, ok := p.points[id]
+	if !ok {
+		return false
+	}
+	delete(p.points, id)
+	ids := p.Map[e.leaf]
+	if last := ids[len(ids)-1]; last != id {
+		ids[e.slot] = last
+		p.points[last].slot = e.slot
+	}
+	ids = ids[:len(ids)-1]
+	if len(ids) > 0 {
+		p.Map[e.leaf] = ids
+		return true
+	}
+	delete(p.Map, e.leaf)
+	for k := e.leaf; k > 1; {
+		parent := k.Parent()
+		p.Nodes[parent] &^= 1 << uint(k&7)
+		if p.Nodes[parent] != 0 {
+			break
+		}
+		delete(p.Nodes, parent)
+		k = parent
 	}
+	return true
+}
 
-	return r_encode
+//UpdatePoint moves a point, relinking it only when it leaves its leaf cell
+func (p *OctalTree) UpdatePoint(point []float32, id int) {
+	e, ok := p.points[id]
+	if !ok || !p.Contains(point) {
+		p.InsertPoint(point, id)
+		return
+	}
+	code := p.encode(point, MORTON_MAX_DEPTH)
+	if code.Ancestor(e.leaf.Depth()) != e.leaf {
+		p.InsertPoint(point, id)
+		return
+	}
+	e.position, e.code = [3]float32{point[0], point[1], point[2]}, code
 }
 
-//Returns tree depth similarity between two encodings
-func (p OctalTree) DepthSimilarity(a []uint8, b []uint8) int {
-	depth := 0
-	similar := true
+//Build replaces the tree contents with positions given as packed xyz triples
+//with ids by index. The root is fitted to the point bounds
+func (p *OctalTree) Build(positions []float32) {
+	p.Map = make(map[Morton][]int, len(positions)/3/p.LeafSize+1)
+	p.Nodes = make(map[Morton]uint8, len(positions)/3/p.LeafSize+1)
+	p.points = make(map[int]*octalPoint, len(positions)/3)
+	for i := 0; i+2 < len(positions); i += 3 {
+		p.points[i/3] = &octalPoint{position: [3]float32{positions[i], positions[i+1], positions[i+2]}}
+	}
+	p.refit(nil)
+}
 
-	min := len(b)
-	if len(a) < min {
-		min = len(a)
+//refit fits the root around all points and the extra position and relinks them
+func (p *OctalTree) refit(extra *[3]float32) {
+	min := [3]float32{float32(math.Inf(1)), float32(math.Inf(1)), float32(math.Inf(1))}
+	max := [3]float32{float32(math.Inf(-1)), float32(math.Inf(-1)), float32(math.Inf(-1))}
+	grow := func(x [3]float32) {
+		for a := 0; a < 3; a++ {
+			min[a] = float32(math.Min(float64(min[a]), float64(x[a])))
+			max[a] = float32(math.Max(float64(max[a]), float64(x[a])))
+		}
+	}
+	for _, e := range p.points {
+		grow(e.position)
+	}
+	if extra != nil {
+		grow(*extra)
+	}
+	if len(p.points) == 0 && extra == nil {
+		return
+	}
+	//Cubic root with a margin so boundary points encode inside
+	half := float32(0)
+	for a := 0; a < 3; a++ {
+		half = float32(math.Max(float64(half), float64(max[a]-min[a])/2))
+	}
+	half = half*1.01 + 1e-6
+	for a := 0; a < 3; a++ {
+		p.Origin[a] = (min[a] + max[a]) / 2
+		p.Bounds[a] = half
 	}
 
-	for similar && depth < min {
-		first := a[depth]
-		second := a[depth+1]
-		third := a[depth+2]
+	ids := make([]int, 0, len(p.points))
+	for id, e := range p.points {
+		e.code = p.encode(e.position[:], MORTON_MAX_DEPTH)
+		ids = append(ids, id)
+	}
+	//Insert in Morton order so leaves fill coherently
+	sort.Slice(ids, func(a int, b int) bool {
+		return p.points[ids[a]].code < p.points[ids[b]].code || (p.points[ids[a]].code == p.points[ids[b]].code && ids[a] < ids[b])
+	})
+	p.Map = make(map[Morton][]int, len(ids)/p.LeafSize+1)
+	p.Nodes = make(map[Morton]uint8, len(ids)/p.LeafSize+1)
+	for _, id := range ids {
+		p.insert(id, p.points[id])
+	}
+}
 
-		if first != b[depth] {
-			similar = false
+//Leaves returns the leaf cells within or containing the cell k in key order
+func (p *OctalTree) Leaves(k Morton) []Morton {
+	leaves := []Morton{}
+	for a := k; ; a = a.Parent() {
+		if p.IsLeaf(a) {
+			return append(leaves, a)
+		}
+		if a <= 1 {
+			break
 		}
-		if second != b[depth+1] {
-			similar = false
+	}
+	stack := []Morton{k}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.IsLeaf(n) {
+			leaves = append(leaves, n)
+			continue
 		}
-		if third != b[depth+2] {
-			similar = false
+		mask := p.Nodes[n]
+		for o := 7; o >= 0; o-- {
+			if mask&(1<<uint(o)) != 0 {
+				stack = append(stack, n.Child(o))
+			}
 		}
+	}
+	return leaves
+}
+
+//Siblings returns the existing cells sharing the parent of k
+func (p *OctalTree) Siblings(k Morton) []Morton {
+	siblings := []Morton{}
+	if k <= 1 {
+		return siblings
+	}
+	mask := p.Nodes[k.Parent()]
+	for o := 0; o < 8; o++ {
+		if s := k.Parent().Child(o); s != k && mask&(1<<uint(o)) != 0 {
+			siblings = append(siblings, s)
+		}
+	}
+	return siblings
+}
 
-		if similar {
-			depth += 3
+//Adjacent returns the leaf cells overlapping the 26 same size cells around k,
+//coarser leaves containing a neighbor cell or finer leaves within it
+func (p *OctalTree) Adjacent(k Morton) []Morton {
+	d, c := k.Depth(), k.Coord()
+	n := 1 << uint(d)
+	seen := make(map[Morton]bool)
+	adjacent := []Morton{}
+	for i := -1; i <= 1; i++ {
+		for j := -1; j <= 1; j++ {
+			for l := -1; l <= 1; l++ {
+				nc := [3]int{c[0] + i, c[1] + j, c[2] + l}
+				if (i == 0 && j == 0 && l == 0) || nc[0] < 0 || nc[1] < 0 || nc[2] < 0 || nc[0] >= n || nc[1] >= n || nc[2] >= n {
+					continue
+				}
+				for _, leaf := range p.Leaves(MortonKey(nc, d)) {
+					if !seen[leaf] && leaf.Ancestor(d) != k && k.Ancestor(leaf.Depth()) != leaf {
+						seen[leaf] = true
+						adjacent = append(adjacent, leaf)
+					}
+				}
+			}
 		}
+	}
+	sort.Slice(adjacent, func(a int, b int) bool { return adjacent[a] < adjacent[b] })
+	return adjacent
+}
+
+//GetNeighbors returns the points of the cell k and of its adjacent leaves
+func (p *OctalTree) GetNeighbors(k Morton) []int {
+	neighbors := []int{}
+	for _, leaf := range append(p.Leaves(k), p.Adjacent(k)...) {
+		neighbors = append(neighbors, p.Map[leaf]...)
+	}
+	return neighbors
+}
 
+//distance2 returns the squared distance from point to the cell box
+func (p *OctalTree) distance2(k Morton, point []float32) float64 {
+	min, max := p.CellBounds(k)
+	d := 0.0
+	for a := 0; a < 3; a++ {
+		if x := float64(min[a] - point[a]); x > 0 {
+			d += x * x
+		} else if x := float64(point[a] - max[a]); x > 0 {
+			d += x * x
+		}
+	}
+	return d
+}
+
+func distance2(a [3]float32, b []float32) float64 {
+	d := 0.0
+	for k := 0; k < 3; k++ {
+		x := float64(a[k] - b[k])
+		d += x * x
+	}
+	return d
+}
+
+//Radius returns the points within radius r of point
+func (p *OctalTree) Radius(point []float32, r float32) []int {
+	found := []int{}
+	r2 := float64(r) * float64(r)
+	stack := []Morton{1}
+	for len(stack) > 0 {
+		k := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if p.distance2(k, point) > r2 {
+			continue
+		}
+		if ids, ok := p.Map[k]; ok {
+			for _, id := range ids {
+				if distance2(p.points[id].position, point) <= r2 {
+					found = append(found, id)
+				}
+			}
+			continue
+		}
+		mask := p.Nodes[k]
+		for o := 0; o < 8; o++ {
+			if mask&(1<<uint(o)) != 0 {
+				stack = append(stack, k.Child(o))
+			}
+		}
+	}
+	return found
+}
+
+//octalItem is a cell or point in the nearest neighbor queue
+type octalItem struct {
+	dist2 float64
+	key   Morton
+	id    int //Point id, -1 for cells
+}
+
+type octalQueue []octalItem
+
+func (q octalQueue) Len() int { return len(q) }
+func (q octalQueue) Less(a int, b int) bool {
+	if q[a].dist2 != q[b].dist2 {
+		return q[a].dist2 < q[b].dist2
+	}
+	return q[a].id < q[b].id
+}
+func (q octalQueue) Swap(a int, b int)        { q[a], q[b] = q[b], q[a] }
+func (q *octalQueue) Push(x interface{})      { *q = append(*q, x.(octalItem)) }
+func (q *octalQueue) Pop() (item interface{}) { item, *q = (*q)[len(*q)-1], (*q)[:len(*q)-1]; return }
+
+//Nearest returns the k points nearest to point ordered by distance, visiting
+//cells best first by their box distance
+func (p *OctalTree) Nearest(point []float32, k int) []int {
+	nearest := make([]int, 0, k)
+	q := &octalQueue{{p.distance2(1, point), 1, -1}}
+	for q.Len() > 0 && len(nearest) < k {
+		item := heap.Pop(q).(octalItem)
+		if item.id >= 0 {
+			nearest = append(nearest, item.id)
+			continue
+		}
+		if ids, ok := p.Map[item.key]; ok {
+			for _, id := range ids {
+				heap.Push(q, octalItem{distance2(p.points[id].position, point), item.key, id})
+			}
+			continue
+		}
+		mask := p.Nodes[item.key]
+		for o := 0; o < 8; o++ {
+			if mask&(1<<uint(o)) != 0 {
+				child := item.key.Child(o)
+				heap.Push(q, octalItem{p.distance2(child, point), child, -1})
+			}
+		}
+	}
+	return nearest
+}
+
+//EncodePointGroup returns the deepest cell down to depth containing every point
+func (p *OctalTree) EncodePointGroup(group []vector.Vec, depth int) Morton {
+	if len(group) == 0 {
+		return 1
+	}
+	k := p.encode(group[0], depth)
+	for _, point := range group[1:] {
+		k = k.Ancestor(p.DepthSimilarity(k, p.encode(point, depth)))
+	}
+	return k
+}
+
+//DepthSimilarity returns the depth of the deepest cell containing both keys
+func (p *OctalTree) DepthSimilarity(a Morton, b Morton) int {
+	if a.Depth() > b.Depth() {
+		a = a.Ancestor(b.Depth())
+	} else {
+		b = b.Ancestor(a.Depth())
+	}
+	for a != b {
+		a, b = a.Parent(), b.Parent()
 	}
-	return depth / 3
+	return a.Depth()
 }
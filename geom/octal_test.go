@@ -1,51 +1,149 @@
 package geom
 
-import "testing"
+import (
+	"math/rand"
+	"sort"
+	"testing"
 
-//import "fmt"
-import "github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/math/vector"
+)
 
 func TestOctal(t *testing.T) {
 	oct := InitOctalTree(100.0, 100.0, 100.0)
 	pointA := vector.Vec{2.5, 1.56, 2.61}
 	pointB := vector.Vec{3.61, 5.10, 2.43}
-	/*
-		pointC := vector.Vec{2.55, 3.55, 4.62}
-		group := make([]vector.Vec, 3)
-		group[0] = pointA
-		group[1] = pointB
-		group[2] = pointC
-	*/
-	encA := oct.EncodePoint(pointA, 4)
-	encB := oct.EncodePoint(pointB, 4)
-	//	enc_group := oct.EncodePointGroup(group)
+	encA := oct.EncodePoint(pointA)
+	encB := oct.EncodePoint(pointB)
 
 	abSim := oct.DepthSimilarity(encA, encB)
-	//	enc_group_depth := len(enc_group) / 3
-	//	fmt.Printf("Group Depth %d\n", enc_group_depth)
-	//	fmt.Printf("Point A, Point B Octal Depth Similarity:%d\nPointA Encoding[", abSim)
-	/*
-		for i := 0; i < len(encA); i++ {
-			d := uint32(encA[i])
-			if i%3 == 0 {
-				fmt.Printf(" ")
+	if abSim != 5 {
+		t.Errorf("Points A & B Depth Similarity not 5\n")
+	}
+	if encA.Depth() != 6 || encA.Ancestor(5) != encB.Ancestor(5) || MortonKey(encA.Coord(), 6) != encA {
+		t.Errorf("Morton key %b depth %d coordinates %v\n", encA, encA.Depth(), encA.Coord())
+	}
+	if c := oct.GetCentroid(encA); vector.Dist(vector.Vec{c[0], c[1], c[2]}, pointA) > 200.0/64 {
+		t.Errorf("Cell centroid %v far from %v\n", c, pointA)
+	}
+}
+
+//clusters returns points packed into a dense ball and spread through a sparse box
+func clusters(n int) []float32 {
+	rng := rand.New(rand.NewSource(3))
+	positions := make([]float32, 0, n*3)
+	for i := 0; i < n; i++ {
+		if i%4 == 0 {
+			positions = append(positions, rng.Float32()*10-5, rng.Float32()*10-5, rng.Float32()*10-5)
+		} else {
+			positions = append(positions, 1+rng.Float32()*0.1, 2+rng.Float32()*0.1, rng.Float32()*0.1)
+		}
+	}
+	return positions
+}
+
+func brute(positions []float32, ids []int, point []float32) []float64 {
+	d := make([]float64, len(ids))
+	for n, id := range ids {
+		d[n] = distance2([3]float32{positions[id*3], positions[id*3+1], positions[id*3+2]}, point)
+	}
+	sort.Float64s(d)
+	return d
+}
+
+func TestOctalQueries(t *testing.T) {
+	positions := clusters(2000)
+	all := make([]int, len(positions)/3)
+	for i := range all {
+		all[i] = i
+	}
+	oct := NewOctalTree([3]float32{0, 0, 0}, [3]float32{1, 1, 1}, 12, 8)
+	oct.Build(positions)
+	if oct.Len() != len(all) || !oct.Contains([]float32{-5, -5, -5}) {
+		t.Fatalf("Build holds %d points in root %v %v\n", oct.Len(), oct.Origin, oct.Bounds)
+	}
+
+	rng := rand.New(rand.NewSource(5))
+	for q := 0; q < 50; q++ {
+		point := []float32{rng.Float32()*10 - 5, rng.Float32()*10 - 5, rng.Float32()*10 - 5}
+		if q%2 == 0 {
+			point = []float32{1.05, 2.05, 0.05}
+		}
+		r := 0.02 + rng.Float32()*2
+		found := oct.Radius(point, r)
+		expected := 0
+		for _, d := range brute(positions, all, point) {
+			if d <= float64(r)*float64(r) {
+				expected++
 			}
-			fmt.Printf("%d", d)
 		}
-	*/
-	//	fmt.Printf("]\nPoint B Encoding[")
-	/*
-		for i := 0; i < len(encB); i++ {
-			d := uint32(encB[i])
-			if i%3 == 0 {
-				fmt.Printf(" ")
+		if len(found) != expected {
+			t.Fatalf("Radius %f query found %d of %d points\n", r, len(found), expected)
+		}
+		nearest := oct.Nearest(point, 10)
+		exact := brute(positions, all, point)[:10]
+		got := brute(positions, nearest, point)
+		for n := range exact {
+			if got[n] != exact[n] {
+				t.Fatalf("Nearest %d distance %f expected %f\n", n, got[n], exact[n])
 			}
-			fmt.Printf("%d", d)
 		}
-		fmt.Printf("]\n")
-	*/
-	if abSim != 5 {
-		t.Errorf("Points A & B Depth Similarity not 5\n")
+	}
+
+	//Dense clusters refine deeper than the sparse region
+	dense, sparse := oct.Leaf([]float32{1.05, 2.05, 0.05}), oct.Leaf([]float32{-4, -4, 4})
+	if dense.Depth() <= sparse.Depth()+2 {
+		t.Errorf("Dense leaf depth %d sparse leaf depth %d\n", dense.Depth(), sparse.Depth())
+	}
+	for _, leaf := range oct.Adjacent(dense) {
+		if leaf.Ancestor(dense.Depth()) == dense || !oct.IsLeaf(leaf) {
+			t.Fatalf("Adjacent cell %b of %b\n", leaf, dense)
+		}
+	}
+	neighbors := oct.GetNeighbors(dense)
+	if len(neighbors) <= len(oct.Map[dense]) || len(oct.Siblings(dense)) == 0 {
+		t.Errorf("Leaf neighbors %d siblings %d\n", len(neighbors), len(oct.Siblings(dense)))
+	}
+
+	//Moving and removing points keeps queries exact
+	for id := 0; id < len(all); id += 3 {
+		for a := 0; a < 3; a++ {
+			positions[id*3+a] += rng.Float32()*0.2 - 0.1
+		}
+		oct.UpdatePoint(positions[id*3:id*3+3], id)
+	}
+	remaining := []int{}
+	for id := range all {
+		if id%5 == 0 {
+			oct.RemovePoint(id)
+		} else {
+			remaining = append(remaining, id)
+		}
+	}
+	count := 0
+	for _, ids := range oct.Map {
+		count += len(ids)
+	}
+	if oct.Len() != len(remaining) || count != len(remaining) {
+		t.Fatalf("Tree holds %d points in leaves %d expected %d\n", oct.Len(), count, len(remaining))
+	}
+	point := []float32{1.05, 2.05, 0.05}
+	exact := brute(positions, remaining, point)
+	if got := brute(positions, oct.Nearest(point, 20), point); got[19] != exact[19] {
+		t.Errorf("Nearest after updates %f expected %f\n", got[19], exact[19])
+	}
+	found := oct.Radius(point, 0.5)
+	expected := 0
+	for _, d := range exact {
+		if d <= 0.25 {
+			expected++
+		}
+	}
+	if len(found) != expected {
+		t.Errorf("Radius after updates found %d of %d\n", len(found), expected)
+	}
+	oct.InsertPoint([]float32{50, 0, 0}, -1)
+	if !oct.Contains([]float32{50, 0, 0}) || len(oct.Radius([]float32{50, 0, 0}, 0.1)) != 1 {
+		t.Errorf("Root did not grow to an outside point\n")
 	}
 }
 
@@ -53,8 +151,8 @@ func BenchmarkDepth(b *testing.B) {
 	oct := InitOctalTree(100.0, 100.0, 100.0)
 	pointA := vector.Vec{2.5, 1.56, 2.61}
 	pointB := vector.Vec{3.61, 5.10, 2.43}
-	encA := oct.EncodePoint(pointA, 4)
-	encB := oct.EncodePoint(pointB, 4)
+	encA := oct.EncodePoint(pointA)
+	encB := oct.EncodePoint(pointB)
 
 	for i := 0; i < b.N; i++ {
 		oct.DepthSimilarity(encA, encB)
@@ -1,6 +1,8 @@
 package geom
 
 import (
+	"math"
+
 	"github.com/andewx/dieselfluid/math/vector"
 	"github.com/andewx/dieselfluid/render/transform"
 )
@@ -19,3 +21,56 @@ type Sphere struct {
 type Intersection struct {
 	T []float32
 }
+
+//NewRay creates a world space ray from an origin along a normalized direction
+func NewRay(origin vector.Vec, direction vector.Vec) Ray {
+	o := vector.Vec{origin[0], origin[1], origin[2]}
+	d := vector.Norm(vector.Vec{direction[0], direction[1], direction[2]})
+	return Ray{Ray: &d, Origin: &o}
+}
+
+//At returns the point at distance t along the ray
+func (r *Ray) At(t float32) vector.Vec {
+	return vector.Add(*r.Origin, vector.Scale(*r.Ray, t))
+}
+
+//Center returns the translation of the sphere transform, the origin without one
+func (s *Sphere) Center() vector.Vec {
+	if s.Transform == nil || len(s.Transform.Matrix) < 16 {
+		return vector.Vec{0, 0, 0}
+	}
+	return vector.Vec{s.Transform.Matrix[12], s.Transform.Matrix[13], s.Transform.Matrix[14]}
+}
+
+//Intersect returns the ray distances of the sphere surface crossings in front of
+//the ray origin in increasing order
+func (s *Sphere) Intersect(r Ray) Intersection {
+	oc := vector.Sub(*r.Origin, s.Center())
+	b := vector.Dot(oc, *r.Ray)
+	c := vector.Dot(oc, oc) - s.Radius*s.Radius
+	disc := b*b - c
+	hit := Intersection{}
+	if disc < 0 {
+		return hit
+	}
+	root := float32(math.Sqrt(float64(disc)))
+	for _, t := range []float32{-b - root, -b + root} {
+		if t >= 0 {
+			hit.T = append(hit.T, t)
+		}
+	}
+	return hit
+}
+
+//Support returns the farthest point of the sphere along d
+func (s *Sphere) Support(d vector.Vec) vector.Vec {
+	return vector.Add(s.Center(), vector.Scale(vector.Norm(d), s.Radius))
+}
+
+//Nearest returns the closest crossing distance
+func (i *Intersection) Nearest() (float32, bool) {
+	if len(i.T) == 0 {
+		return 0, false
+	}
+	return i.T[0], true
+}
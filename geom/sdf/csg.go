@@ -0,0 +1,202 @@
+package sdf
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/transform"
+)
+
+//CSG Operations
+const CSG_UNION = 0
+const CSG_INTERSECTION = 1
+const CSG_DIFFERENCE = 2
+
+//CSG combines two shapes, A minus B for differences. A positive Blend rounds
+//the seam with the polynomial smooth minimum of Quilez over that distance
+type CSG struct {
+	Op    int
+	A     Shape
+	B     Shape
+	Blend float32
+}
+
+//Empty is the shape without an inside, with inverted bounds
+type Empty struct{}
+
+func (e Empty) Distance(p vector.Vec) float32 {
+	return float32(math.Inf(1))
+}
+
+func (e Empty) Bounds() ([3]float32, [3]float32) {
+	min, max := infinite()
+	return max, min
+}
+
+//Union returns the union of the shapes, Empty without shapes
+func Union(shapes ...Shape) Shape {
+	if len(shapes) == 0 {
+		return Empty{}
+	}
+	u := shapes[0]
+	for _, s := range shapes[1:] {
+		u = &CSG{CSG_UNION, u, s, 0}
+	}
+	return u
+}
+
+//Intersection returns the intersection of the shapes, Empty without shapes
+func Intersection(shapes ...Shape) Shape {
+	if len(shapes) == 0 {
+		return Empty{}
+	}
+	u := shapes[0]
+	for _, s := range shapes[1:] {
+		u = &CSG{CSG_INTERSECTION, u, s, 0}
+	}
+	return u
+}
+
+//Difference returns a with b carved out
+func Difference(a Shape, b Shape) Shape {
+	return &CSG{CSG_DIFFERENCE, a, b, 0}
+}
+
+//SmoothUnion returns the union of a and b blended over distance k
+func SmoothUnion(a Shape, b Shape, k float32) Shape {
+	return &CSG{CSG_UNION, a, b, k}
+}
+
+//SmoothIntersection returns the intersection of a and b blended over distance k
+func SmoothIntersection(a Shape, b Shape, k float32) Shape {
+	return &CSG{CSG_INTERSECTION, a, b, k}
+}
+
+//SmoothDifference returns a with b carved out blended over distance k
+func SmoothDifference(a Shape, b Shape, k float32) Shape {
+	return &CSG{CSG_DIFFERENCE, a, b, k}
+}
+
+//smoothMin is the polynomial smooth minimum, below min(a, b) by at most k/4
+func smoothMin(a float32, b float32, k float32) float32 {
+	if k <= 0 {
+		return min32(a, b)
+	}
+	h := min32(max32(0.5+0.5*(b-a)/k, 0), 1)
+	return b + (a-b)*h - k*h*(1-h)
+}
+
+func (c *CSG) Distance(p vector.Vec) float32 {
+	a, b := c.A.Distance(p), c.B.Distance(p)
+	switch c.Op {
+	case CSG_INTERSECTION:
+		return -smoothMin(-a, -b, c.Blend)
+	case CSG_DIFFERENCE:
+		return -smoothMin(-a, b, c.Blend)
+	}
+	return smoothMin(a, b, c.Blend)
+}
+
+func (c *CSG) Bounds() ([3]float32, [3]float32) {
+	amin, amax := c.A.Bounds()
+	bmin, bmax := c.B.Bounds()
+	switch c.Op {
+	case CSG_DIFFERENCE:
+		return amin, amax
+	case CSG_INTERSECTION:
+		for a := 0; a < 3; a++ {
+			amin[a], amax[a] = max32(amin[a], bmin[a]), min32(amax[a], bmax[a])
+		}
+		return amin, amax
+	}
+	//The smooth union bulges out by at most a quarter of the blend
+	for a := 0; a < 3; a++ {
+		amin[a] = min32(amin[a], bmin[a]) - c.Blend/4
+		amax[a] = max32(amax[a], bmax[a]) + c.Blend/4
+	}
+	return amin, amax
+}
+
+//Transformed places a shape in the scene with a transform, world points being
+//the upper 3x3 of the matrix applied as with CrossVec plus the translation in
+//Matrix[12:15] as set by Translate. Distances are scaled by the smallest axis
+//scale to remain a lower bound under non uniform scaling
+type Transformed struct {
+	Shape       Shape
+	Transform   *transform.Transform
+	inverse     [9]float64
+	translation [3]float64
+	scale       float32
+}
+
+//NewTransformed places the shape with the transform, inverted once so later
+//changes to the transform need a new placement
+func NewTransformed(s Shape, t *transform.Transform) *Transformed {
+	m := t.Matrix
+	r := [9]float64{}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i*3+j] = float64(m[i*4+j])
+		}
+	}
+	det := r[0]*(r[4]*r[8]-r[5]*r[7]) - r[1]*(r[3]*r[8]-r[5]*r[6]) + r[2]*(r[3]*r[7]-r[4]*r[6])
+	inv := [9]float64{
+		(r[4]*r[8] - r[5]*r[7]) / det, (r[2]*r[7] - r[1]*r[8]) / det, (r[1]*r[5] - r[2]*r[4]) / det,
+		(r[5]*r[6] - r[3]*r[8]) / det, (r[0]*r[8] - r[2]*r[6]) / det, (r[2]*r[3] - r[0]*r[5]) / det,
+		(r[3]*r[7] - r[4]*r[6]) / det, (r[1]*r[6] - r[0]*r[7]) / det, (r[0]*r[4] - r[1]*r[3]) / det,
+	}
+	scale := math.Inf(1)
+	for j := 0; j < 3; j++ {
+		scale = math.Min(scale, math.Sqrt(r[j]*r[j]+r[3+j]*r[3+j]+r[6+j]*r[6+j]))
+	}
+	return &Transformed{s, t, inv, [3]float64{float64(m[12]), float64(m[13]), float64(m[14])}, float32(scale)}
+}
+
+//Translate places the shape at offset
+func Translate(s Shape, offset vector.Vec) *Transformed {
+	t := transform.Transform{Matrix: matrix.Mat4(1.0)}
+	t.Matrix[12], t.Matrix[13], t.Matrix[14] = offset[0], offset[1], offset[2]
+	return NewTransformed(s, &t)
+}
+
+//Local returns the shape space position of the world point p
+func (t *Transformed) Local(p vector.Vec) vector.Vec {
+	d := [3]float64{float64(p[0]) - t.translation[0], float64(p[1]) - t.translation[1], float64(p[2]) - t.translation[2]}
+	l := vector.Vec{0, 0, 0}
+	for i := 0; i < 3; i++ {
+		l[i] = float32(t.inverse[i*3]*d[0] + t.inverse[i*3+1]*d[1] + t.inverse[i*3+2]*d[2])
+	}
+	return l
+}
+
+func (t *Transformed) Distance(p vector.Vec) float32 {
+	return t.Shape.Distance(t.Local(p)) * t.scale
+}
+
+//Bounds returns the box around the transformed corners of the shape bounds
+func (t *Transformed) Bounds() ([3]float32, [3]float32) {
+	lo, hi := t.Shape.Bounds()
+	if isEmpty(lo, hi) {
+		return lo, hi
+	}
+	if !Bounded(t.Shape) {
+		return infinite()
+	}
+	m := t.Transform.Matrix
+	inf := float32(math.Inf(1))
+	min, max := [3]float32{inf, inf, inf}, [3]float32{-inf, -inf, -inf}
+	for c := 0; c < 8; c++ {
+		corner := [3]float32{lo[0], lo[1], lo[2]}
+		for a := 0; a < 3; a++ {
+			if c>>uint(a)&1 == 1 {
+				corner[a] = hi[a]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			w := m[i*4]*corner[0] + m[i*4+1]*corner[1] + m[i*4+2]*corner[2] + m[12+i]
+			min[i], max[i] = min32(min[i], w), max32(max[i], w)
+		}
+	}
+	return min, max
+}
@@ -0,0 +1,238 @@
+package sdf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Primitive distances follow Quilez, each centered on the origin with the y axis
+//up and placed in the scene by Transformed
+
+//Sphere of the given radius
+type Sphere struct {
+	Radius float32
+}
+
+//Box with half extents Half
+type Box struct {
+	Half vector.Vec
+}
+
+//RoundBox is a box with half extents Half whose edges are rounded by Radius
+type RoundBox struct {
+	Half   vector.Vec
+	Radius float32
+}
+
+//Cylinder along the y axis of the given radius and half height
+type Cylinder struct {
+	Radius float32
+	Half   float32
+}
+
+//Capsule is the set of points within Radius of the segment A B
+type Capsule struct {
+	A      vector.Vec
+	B      vector.Vec
+	Radius float32
+}
+
+//Torus in the xz plane with the tube of radius Minor around the circle of radius Major
+type Torus struct {
+	Major float32
+	Minor float32
+}
+
+//Plane is the half space n.p < Offset below the plane with unit normal n
+type Plane struct {
+	Normal vector.Vec
+	Offset float32
+}
+
+//Heightfield is the region below a bilinear height surface y = h(x, z) sampled
+//on a regular grid from Origin, with samples in rows along x. Heights are clamped
+//beyond the grid so the region is unbounded except above. A grid without a full
+//row of samples or a positive spacing is the flat surface y = 0
+type Heightfield struct {
+	Origin  [2]float32
+	Spacing float32
+	Columns int
+	Heights []float32
+}
+
+//NewHeightfield validates the grid of heights in rows of columns samples
+func NewHeightfield(origin [2]float32, spacing float32, columns int, heights []float32) (*Heightfield, error) {
+	if spacing <= 0 {
+		return nil, fmt.Errorf("Heightfield spacing must be positive, got %f", spacing)
+	}
+	if columns <= 0 || len(heights) == 0 || len(heights)%columns != 0 {
+		return nil, fmt.Errorf("Heightfield of %d heights is not a grid of %d columns", len(heights), columns)
+	}
+	return &Heightfield{origin, spacing, columns, heights}, nil
+}
+
+func length2(x float32, y float32) float32 {
+	return float32(math.Sqrt(float64(x*x + y*y)))
+}
+
+func max32(a float32, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min32(a float32, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs32(a float32) float32 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func (s *Sphere) Distance(p vector.Vec) float32 {
+	return vector.Mag(p) - s.Radius
+}
+
+func (s *Sphere) Bounds() ([3]float32, [3]float32) {
+	r := s.Radius
+	return [3]float32{-r, -r, -r}, [3]float32{r, r, r}
+}
+
+//boxDistance is the exact distance to the box of half extents b
+func boxDistance(p vector.Vec, b vector.Vec) float32 {
+	q := [3]float32{abs32(p[0]) - b[0], abs32(p[1]) - b[1], abs32(p[2]) - b[2]}
+	outside := vector.Mag(vector.Vec{max32(q[0], 0), max32(q[1], 0), max32(q[2], 0)})
+	return outside + min32(max32(q[0], max32(q[1], q[2])), 0)
+}
+
+func (s *Box) Distance(p vector.Vec) float32 {
+	return boxDistance(p, s.Half)
+}
+
+func (s *Box) Bounds() ([3]float32, [3]float32) {
+	h := s.Half
+	return [3]float32{-h[0], -h[1], -h[2]}, [3]float32{h[0], h[1], h[2]}
+}
+
+func (s *RoundBox) Distance(p vector.Vec) float32 {
+	r := s.Radius
+	return boxDistance(p, vector.Vec{s.Half[0] - r, s.Half[1] - r, s.Half[2] - r}) - r
+}
+
+func (s *RoundBox) Bounds() ([3]float32, [3]float32) {
+	h := s.Half
+	return [3]float32{-h[0], -h[1], -h[2]}, [3]float32{h[0], h[1], h[2]}
+}
+
+func (s *Cylinder) Distance(p vector.Vec) float32 {
+	dr := length2(p[0], p[2]) - s.Radius
+	dy := abs32(p[1]) - s.Half
+	return min32(max32(dr, dy), 0) + length2(max32(dr, 0), max32(dy, 0))
+}
+
+func (s *Cylinder) Bounds() ([3]float32, [3]float32) {
+	r, h := s.Radius, s.Half
+	return [3]float32{-r, -h, -r}, [3]float32{r, h, r}
+}
+
+func (s *Capsule) Distance(p vector.Vec) float32 {
+	ab := vector.Sub(s.B, s.A)
+	ap := vector.Sub(p, s.A)
+	t := float32(0)
+	if l := vector.Dot(ab, ab); l > 0 {
+		t = min32(max32(vector.Dot(ap, ab)/l, 0), 1)
+	}
+	return vector.Dist(ap, vector.Scale(ab, t)) - s.Radius
+}
+
+func (s *Capsule) Bounds() ([3]float32, [3]float32) {
+	min, max := [3]float32{}, [3]float32{}
+	for a := 0; a < 3; a++ {
+		min[a] = min32(s.A[a], s.B[a]) - s.Radius
+		max[a] = max32(s.A[a], s.B[a]) + s.Radius
+	}
+	return min, max
+}
+
+func (s *Torus) Distance(p vector.Vec) float32 {
+	return length2(length2(p[0], p[2])-s.Major, p[1]) - s.Minor
+}
+
+func (s *Torus) Bounds() ([3]float32, [3]float32) {
+	r := s.Major + s.Minor
+	return [3]float32{-r, -s.Minor, -r}, [3]float32{r, s.Minor, r}
+}
+
+func (s *Plane) Distance(p vector.Vec) float32 {
+	return vector.Dot(p, vector.Norm(s.Normal)) - s.Offset
+}
+
+func (s *Plane) Bounds() ([3]float32, [3]float32) {
+	return infinite()
+}
+
+//flat reports whether the heightfield lacks a valid grid
+func (s *Heightfield) flat() bool {
+	return s.Spacing <= 0 || s.Columns <= 0 || len(s.Heights) < s.Columns
+}
+
+//sample returns the clamped height sample at column i and row j
+func (s *Heightfield) sample(i int, j int) float32 {
+	rows := len(s.Heights) / s.Columns
+	if i < 0 {
+		i = 0
+	} else if i >= s.Columns {
+		i = s.Columns - 1
+	}
+	if j < 0 {
+		j = 0
+	} else if j >= rows {
+		j = rows - 1
+	}
+	return s.Heights[j*s.Columns+i]
+}
+
+//Height returns the bilinear height at x z with its slopes along x and z
+func (s *Heightfield) Height(x float32, z float32) (float32, float32, float32) {
+	if s.flat() {
+		return 0, 0, 0
+	}
+	u := (x - s.Origin[0]) / s.Spacing
+	v := (z - s.Origin[1]) / s.Spacing
+	i, j := int(math.Floor(float64(u))), int(math.Floor(float64(v)))
+	fu, fv := u-float32(i), v-float32(j)
+	h00, h10, h01, h11 := s.sample(i, j), s.sample(i+1, j), s.sample(i, j+1), s.sample(i+1, j+1)
+	h := (h00*(1-fu)+h10*fu)*(1-fv) + (h01*(1-fu)+h11*fu)*fv
+	dx := ((h10-h00)*(1-fv) + (h11-h01)*fv) / s.Spacing
+	dz := ((h01-h00)*(1-fu) + (h11-h10)*fu) / s.Spacing
+	return h, dx, dz
+}
+
+//Distance is the vertical height difference scaled by the local slope, a first
+//order estimate of the distance to the surface
+func (s *Heightfield) Distance(p vector.Vec) float32 {
+	h, dx, dz := s.Height(p[0], p[2])
+	return (p[1] - h) / float32(math.Sqrt(float64(1+dx*dx+dz*dz)))
+}
+
+func (s *Heightfield) Bounds() ([3]float32, [3]float32) {
+	min, max := infinite()
+	top := float32(math.Inf(-1))
+	for _, h := range s.Heights {
+		top = max32(top, h)
+	}
+	if s.flat() {
+		top = 0
+	}
+	max[1] = top
+	return min, max
+}
@@ -0,0 +1,159 @@
+//Signed distance shapes for authoring simulation setups. Analytic primitives are
+//combined with CSG operations and placed with transforms, negative distances
+//lying inside. Shapes serve as colliders and fill volumes through polygonized
+//meshes and as emitter and kill regions through their inside test
+package sdf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//POLYGONIZE_SAMPLES limits the distance samples of a polygonization lattice
+const POLYGONIZE_SAMPLES = 1 << 24
+
+//Shape is a signed distance field, exact or a lower bound on the distance
+//outside, with an axis aligned bounding box of its inside. Unbounded shapes
+//report infinite bounds
+type Shape interface {
+	Distance(p vector.Vec) float32
+	Bounds() ([3]float32, [3]float32)
+}
+
+//Inside reports whether p lies inside the shape
+func Inside(s Shape, p vector.Vec) bool {
+	return s.Distance(p) < 0
+}
+
+//Normal returns the unit gradient of the distance at p by central differences
+//with step eps, the outward surface normal near the surface
+func Normal(s Shape, p vector.Vec, eps float32) vector.Vec {
+	n := vector.Vec{0, 0, 0}
+	for a := 0; a < 3; a++ {
+		hi, lo := vector.Vec{p[0], p[1], p[2]}, vector.Vec{p[0], p[1], p[2]}
+		hi[a] += eps
+		lo[a] -= eps
+		n[a] = s.Distance(hi) - s.Distance(lo)
+	}
+	return vector.Norm(n)
+}
+
+//Bounded reports whether the shape bounds are finite
+func Bounded(s Shape) bool {
+	min, max := s.Bounds()
+	for a := 0; a < 3; a++ {
+		if math.IsInf(float64(min[a]), 0) || math.IsInf(float64(max[a]), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+//isEmpty reports whether the bounds enclose nothing
+func isEmpty(min [3]float32, max [3]float32) bool {
+	return max[0] < min[0] || max[1] < min[1] || max[2] < min[2]
+}
+
+func infinite() ([3]float32, [3]float32) {
+	inf := float32(math.Inf(1))
+	return [3]float32{-inf, -inf, -inf}, [3]float32{inf, inf, inf}
+}
+
+//Cube corners ordered around the main diagonal 0 6 and the six tetrahedra
+//sharing it, which split neighboring cube faces along the same diagonals
+var cubeCorners = [8][3]int{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}, {0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}}
+var cubeTetrahedra = [6][4]int{{0, 6, 1, 2}, {0, 6, 2, 3}, {0, 6, 3, 7}, {0, 6, 7, 4}, {0, 6, 4, 5}, {0, 6, 5, 1}}
+
+//Polygonize extracts the zero surface of a bounded shape as a closed outward
+//wound indexed mesh by marching tetrahedra on a lattice of spacing dx. Edge
+//crossings are shared between cells and placed by linear interpolation
+func Polygonize(s Shape, dx float32) (*mesh.Indexed, error) {
+	min, max := s.Bounds()
+	if isEmpty(min, max) {
+		return nil, fmt.Errorf("Cannot polygonize an empty shape")
+	}
+	if !Bounded(s) {
+		return nil, fmt.Errorf("Cannot polygonize an unbounded shape")
+	}
+	if dx <= 0 {
+		return nil, fmt.Errorf("Lattice spacing must be positive, got %f", dx)
+	}
+	var n [3]int
+	samples := float64(1)
+	for a := 0; a < 3; a++ {
+		min[a] -= dx
+		n[a] = int(math.Ceil(float64((max[a]+dx-min[a])/dx))) + 1
+		samples *= float64(n[a])
+	}
+	if samples > POLYGONIZE_SAMPLES {
+		return nil, fmt.Errorf("Polygonizing at spacing %f needs %g samples, more than %d", dx, samples, POLYGONIZE_SAMPLES)
+	}
+	id := func(i int, j int, k int) int {
+		return (k*n[1]+j)*n[0] + i
+	}
+	point := func(v int) vector.Vec {
+		i, j, k := v%n[0], v/n[0]%n[1], v/(n[0]*n[1])
+		return vector.Vec{min[0] + float32(i)*dx, min[1] + float32(j)*dx, min[2] + float32(k)*dx}
+	}
+	values := make([]float32, n[0]*n[1]*n[2])
+	for v := range values {
+		values[v] = s.Distance(point(v))
+	}
+
+	positions := []vector.Vec{}
+	indices := []int{}
+	crossings := map[[2]int]int{}
+	crossing := func(a int, b int) int {
+		if a > b {
+			a, b = b, a
+		}
+		if c, ok := crossings[[2]int{a, b}]; ok {
+			return c
+		}
+		t := values[a] / (values[a] - values[b])
+		pa, pb := point(a), point(b)
+		positions = append(positions, vector.Add(pa, vector.Scale(vector.Sub(pb, pa), t)))
+		crossings[[2]int{a, b}] = len(positions) - 1
+		return len(positions) - 1
+	}
+	for k := 0; k+1 < n[2]; k++ {
+		for j := 0; j+1 < n[1]; j++ {
+			for i := 0; i+1 < n[0]; i++ {
+				var corner [8]int
+				for c, o := range cubeCorners {
+					corner[c] = id(i+o[0], j+o[1], k+o[2])
+				}
+				for _, tet := range cubeTetrahedra {
+					in, out := []int{}, []int{}
+					for _, c := range tet {
+						if values[corner[c]] < 0 {
+							in = append(in, corner[c])
+						} else {
+							out = append(out, corner[c])
+						}
+					}
+					switch len(in) {
+					case 1:
+						indices = append(indices, crossing(in[0], out[0]), crossing(in[0], out[1]), crossing(in[0], out[2]))
+					case 3:
+						indices = append(indices, crossing(out[0], in[0]), crossing(out[0], in[1]), crossing(out[0], in[2]))
+					case 2:
+						ac, ad := crossing(in[0], out[0]), crossing(in[0], out[1])
+						bd, bc := crossing(in[1], out[1]), crossing(in[1], out[0])
+						indices = append(indices, ac, ad, bd, ac, bd, bc)
+					}
+				}
+			}
+		}
+	}
+
+	m := mesh.NewIndexed(positions, indices)
+	m.Weld(dx * 1e-4)
+	if _, err := m.Orient(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
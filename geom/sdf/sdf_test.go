@@ -0,0 +1,121 @@
+package sdf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/render/transform"
+)
+
+func TestPrimitives(t *testing.T) {
+	cases := []struct {
+		name     string
+		shape    Shape
+		p        vector.Vec
+		distance float32
+	}{
+		{"sphere", &Sphere{1}, vector.Vec{0, 2, 0}, 1},
+		{"box face", &Box{vector.Vec{1, 2, 3}}, vector.Vec{3, 0, 0}, 2},
+		{"box corner", &Box{vector.Vec{1, 1, 1}}, vector.Vec{2, 2, 1}, float32(math.Sqrt2)},
+		{"box inside", &Box{vector.Vec{1, 2, 3}}, vector.Vec{0.5, 0, 0}, -0.5},
+		{"round box corner", &RoundBox{vector.Vec{1, 1, 1}, 0.25}, vector.Vec{2, 2, 2}, float32(math.Sqrt(3)*1.25 - 0.25)},
+		{"cylinder side", &Cylinder{1, 2}, vector.Vec{0, 1, 3}, 2},
+		{"cylinder cap", &Cylinder{1, 2}, vector.Vec{0.5, -3, 0}, 1},
+		{"capsule", &Capsule{vector.Vec{0, 0, 0}, vector.Vec{0, 2, 0}, 0.5}, vector.Vec{0, 3, 0}, 0.5},
+		{"torus", &Torus{2, 0.5}, vector.Vec{0, 0, 2}, -0.5},
+		{"plane", &Plane{vector.Vec{0, 2, 0}, 1}, vector.Vec{5, 3, 5}, 2},
+		{"heightfield", &Heightfield{[2]float32{0, 0}, 1, 2, []float32{1, 1, 1, 1}}, vector.Vec{0.5, 3, 7}, 2},
+	}
+	for _, c := range cases {
+		if d := c.shape.Distance(c.p); math.Abs(float64(d-c.distance)) > 1e-5 {
+			t.Errorf("%s distance %f expected %f\n", c.name, d, c.distance)
+		}
+	}
+	if _, err := NewHeightfield([2]float32{0, 0}, 1, 0, []float32{1}); err == nil || (&Heightfield{}).Distance(vector.Vec{0, 2, 0}) != 2 {
+		t.Errorf("Heightfield without columns\n")
+	}
+	slope := &Heightfield{[2]float32{0, 0}, 1, 2, []float32{0, 1, 0, 1}}
+	if h, dx, _ := slope.Height(0.25, 0.5); h != 0.25 || dx != 1 || Inside(slope, vector.Vec{0.5, 0.6, 0.5}) || !Inside(slope, vector.Vec{0.5, 0.4, 0.5}) || Bounded(slope) {
+		t.Errorf("Heightfield height %f slope %f\n", h, dx)
+	}
+	if n := Normal(&Sphere{1}, vector.Vec{0, 0, 1.5}, 1e-3); math.Abs(float64(n[2]-1)) > 1e-4 {
+		t.Errorf("Sphere normal %v\n", n)
+	}
+}
+
+func TestCSG(t *testing.T) {
+	box := &Box{vector.Vec{1, 1, 1}}
+	ball := Translate(&Sphere{0.5}, vector.Vec{1, 0, 0})
+	carved := Difference(box, ball)
+	if Inside(carved, vector.Vec{0.9, 0, 0}) || !Inside(carved, vector.Vec{-0.9, 0, 0}) || !Inside(Union(box, ball), vector.Vec{1.4, 0, 0}) || Inside(Intersection(box, ball), vector.Vec{0, 0, 0}) {
+		t.Errorf("CSG inside tests\n")
+	}
+	//Smooth blends fill the seam between two spheres
+	a, b := Translate(&Sphere{1}, vector.Vec{-1.2, 0, 0}), Translate(&Sphere{1}, vector.Vec{1.2, 0, 0})
+	seam := vector.Vec{0, 0.5, 0}
+	if Inside(Union(a, b), seam) || !Inside(SmoothUnion(a, b, 1.5), seam) {
+		t.Errorf("Smooth union seam\n")
+	}
+	if d, e := SmoothIntersection(box, ball, 0.2).Distance(vector.Vec{0.75, 0, 0}), Intersection(box, ball).Distance(vector.Vec{0.75, 0, 0}); d < e {
+		t.Errorf("Smooth intersection %f below the intersection %f\n", d, e)
+	}
+	if min, max := carved.Bounds(); min != [3]float32{-1, -1, -1} || max != [3]float32{1, 1, 1} {
+		t.Errorf("Difference bounds %v %v\n", min, max)
+	}
+	if min, max := Intersection(box, ball).Bounds(); min[0] != 0.5 || max[0] != 1 || max[1] != 0.5 {
+		t.Errorf("Intersection bounds %v %v\n", min, max)
+	}
+
+	//A scaled and rotated cylinder lying along x
+	tr := &transform.Transform{Matrix: matrix.Mat4(1.0)}
+	tr.Rotate(vector.Vec{0, 0, 1}, math.Pi/2)
+	tr.Matrix[12] = 5
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			tr.Matrix[i*4+j] *= 2
+		}
+	}
+	lying := NewTransformed(&Cylinder{0.5, 1}, tr)
+	if !Inside(lying, vector.Vec{6.9, 0, 0}) || Inside(lying, vector.Vec{5, 1.1, 0}) || math.Abs(float64(lying.Distance(vector.Vec{5, 0, 3})-2)) > 1e-4 {
+		t.Errorf("Transformed cylinder distance %f\n", lying.Distance(vector.Vec{5, 0, 3}))
+	}
+	if min, max := lying.Bounds(); math.Abs(float64(min[0]-3)) > 1e-5 || math.Abs(float64(max[0]-7)) > 1e-5 || math.Abs(float64(max[2]-1)) > 1e-5 {
+		t.Errorf("Transformed bounds %v %v\n", min, max)
+	}
+}
+
+func TestPolygonize(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		shape  Shape
+		volume float64
+	}{
+		{"sphere", &Sphere{1}, 4 * math.Pi / 3},
+		{"torus", &Torus{1, 0.4}, 2 * math.Pi * math.Pi * 0.16},
+		{"carved box", Difference(&Box{vector.Vec{1, 1, 1}}, &Cylinder{0.5, 2}), 8 - 2*math.Pi*0.25},
+	} {
+		m, err := Polygonize(c.shape, 0.05)
+		if err != nil {
+			t.Fatalf("%s: %v\n", c.name, err)
+		}
+		if v := float64(m.Volume()); !m.IsWatertight() || math.Abs(v-c.volume) > 0.02*c.volume {
+			t.Errorf("%s: volume %f expected %f watertight %v\n", c.name, v, c.volume, m.IsWatertight())
+		}
+		for _, p := range m.Positions {
+			if d := c.shape.Distance(p); math.Abs(float64(d)) > 0.03 {
+				t.Fatalf("%s: vertex %v off the surface by %f\n", c.name, p, d)
+			}
+		}
+	}
+	if _, err := Polygonize(&Plane{vector.Vec{0, 1, 0}, 0}, 0.1); err == nil {
+		t.Errorf("Unbounded plane polygonized\n")
+	}
+	if _, err := Polygonize(&Sphere{100}, 0.01); err == nil {
+		t.Errorf("Oversized lattice polygonized\n")
+	}
+	if _, err := Polygonize(Translate(Union(), vector.Vec{1, 0, 0}), 0.1); err == nil || Inside(Intersection(), vector.Vec{0, 0, 0}) {
+		t.Errorf("Empty shape polygonized\n")
+	}
+}
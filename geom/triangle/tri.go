@@ -1,6 +1,8 @@
 package triangle
 
 import (
+	"math"
+
 	"github.com/andewx/dieselfluid/math/vector"
 )
 
@@ -33,46 +35,138 @@ func (t *Triangle) Collision(P vector.Vec) (vector.Vec, bool) {
 
 }
 
-//Barycentric Focused Collision Test (returns Normal, Coords, CollisionPoint, Collision Bool)
+//Barycentric Focused Collision Test sweeps a particle of radius r from P along
+//V over dt against the triangle (returns Contact Normal, Coords, Contact Point,
+//Collision Bool). The contact point is the particle center at first contact and
+//the coords are those of the touched triangle point, n is kept for callers that
+//have no contact
 func (t *Triangle) BarycentricCollision(P vector.Vec, V vector.Vec, n vector.Vec, dt float64, r float32) (vector.Vec, vector.Vec, vector.Vec, bool) {
-
-	if vector.Mag(V) == 0 {
+	P1 := vector.Add(P, vector.Scale(V, float32(dt)))
+	s, normal, collision := t.Sweep(P, P1, r)
+	if !collision {
 		return n, vector.Vec{}, vector.Vec{}, false
 	}
+	contact := vector.Add(P, vector.Scale(vector.Sub(P1, P), s))
+	coord, _ := t.Barycentric(vector.Sub(contact, vector.Scale(normal, r)))
+	return normal, coord, contact, true
+}
 
-	t0 := *t.Verts[0]
-	//Take Care of Normal
-	v0 := vector.Sub(t0, P)
+//Sweep returns the earliest fraction s in [0,1] of the motion from P0 to P1 at
+//which a sphere of radius r touches the triangle, testing the face plane, the
+//edge cylinders and the vertex spheres, with the unit contact normal pointing
+//toward the sphere. Only approaching contacts are reported so a sphere resting
+//on or sliding along the triangle passes
+func (t *Triangle) Sweep(P0 vector.Vec, P1 vector.Vec, r float32) (float32, vector.Vec, bool) {
+	a, b, c := vec64(*t.Verts[0]), vec64(*t.Verts[1]), vec64(*t.Verts[2])
+	p0 := vec64(P0)
+	d := sub64(vec64(P1), p0)
+	rr := float64(r)
+	best, normal := math.Inf(1), [3]float64{}
+
+	//Face plane offset toward the starting side
+	n := cross64(sub64(b, a), sub64(c, a))
+	if area := math.Sqrt(dot64(n, n)); area > 0 {
+		n = scale64(n, 1/area)
+		dist := dot64(sub64(p0, a), n)
+		side := 1.0
+		if dist < 0 || (dist == 0 && dot64(d, n) > 0) {
+			side = -1
+		}
+		n = scale64(n, side)
+		dist *= side
+		if dn := dot64(d, n); dn < 0 {
+			s := math.Max((dist-rr)/-dn, 0)
+			if s <= 1 {
+				q := sub64(add64(p0, scale64(d, s)), scale64(n, math.Min(dist, rr)))
+				if inside64(a, b, c, q) {
+					best, normal = s, n
+				}
+			}
+		}
+	}
 
-	nDotRay := vector.Dot(n, V)
+	//Edge cylinders and vertex spheres
+	verts := [3][3]float64{a, b, c}
+	for k := 0; k < 3; k++ {
+		e0, e1 := verts[k], verts[(k+1)%3]
+		e := sub64(e1, e0)
+		ee := dot64(e, e)
+		if ee == 0 {
+			continue
+		}
+		m := sub64(p0, e0)
+		mp := sub64(m, scale64(e, dot64(m, e)/ee))
+		dp := sub64(d, scale64(e, dot64(d, e)/ee))
+		if s, ok := sweepRoot(dot64(dp, dp), 2*dot64(mp, dp), dot64(mp, mp)-rr*rr); ok && s < best {
+			x := add64(m, scale64(d, s))
+			if u := dot64(x, e) / ee; u >= 0 && u <= 1 {
+				best, normal = s, sub64(x, scale64(e, u))
+			}
+		}
+	}
+	for _, v := range verts {
+		m := sub64(p0, v)
+		if s, ok := sweepRoot(dot64(d, d), 2*dot64(m, d), dot64(m, m)-rr*rr); ok && s < best {
+			best, normal = s, add64(m, scale64(d, s))
+		}
+	}
 
-	//Point Plane Distance Projected onto the Velocity
-	if nDotRay == 0 {
-		nDotRay = 0.0001
+	if math.IsInf(best, 1) {
+		return 0, vector.Vec{}, false
+	}
+	l := math.Sqrt(dot64(normal, normal))
+	if l == 0 {
+		return 0, vector.Vec{}, false
 	}
+	return float32(best), vector.Vec{float32(normal[0] / l), float32(normal[1] / l), float32(normal[2] / l)}, true
+}
 
-	d := vector.Dot(v0, n)
-	k := (d) / (nDotRay)                    //Project distance to velocity Vector
-	p0 := vector.Add(P, vector.Scale(V, k)) //Projection to the plane
-	dist := vector.Mag(vector.Sub(P, p0))
-
-	//Check the P2 is crossed current plane
-	//- TODO SEE IF PROJECTED VELOCITY IS A BARYCENTRIC COLLISION
-	//	p1 := vector.Add(P, vector.Scale(V, dt)) //Actual projected vector
-	//	p10 := vector.Sub(p1, p0)
-	//dotp10 := vector.Dot(n, p10)
-	//	dist2 := vector.Mag(p10)
-
-	//Point Crossed the plane in a time step. We don't care about the actual collision point
-	//This needs to be scaled with velocity or time step needs to be decreased (dotp10 > 0 && dv0 < 0) ||
-	if dist <= r {
-		coord, collision := t.Barycentric(P)
-		P = vector.Add(P, vector.Scale(V, -1.0*float32(dt)))
-		return n, coord, P, collision
-	} else {
-		return n, vector.Vec{}, vector.Vec{}, false
+//sweepRoot returns the first root in [0,1] of A s^2 + B s + C approached from
+//outside, an already overlapping start counting at s 0 when moving inward
+func sweepRoot(A float64, B float64, C float64) (float64, bool) {
+	if C <= 0 {
+		return 0, B < 0
+	}
+	disc := B*B - 4*A*C
+	if A == 0 || disc < 0 || B >= 0 {
+		return 0, false
 	}
+	s := (-B - math.Sqrt(disc)) / (2 * A)
+	return s, s <= 1
+}
+
+func inside64(a [3]float64, b [3]float64, c [3]float64, p [3]float64) bool {
+	v0, v1, v2 := sub64(b, a), sub64(c, a), sub64(p, a)
+	d00, d01, d11 := dot64(v0, v0), dot64(v0, v1), dot64(v1, v1)
+	d20, d21 := dot64(v2, v0), dot64(v2, v1)
+	denom := d00*d11 - d01*d01
+	u := (d11*d20 - d01*d21) / denom
+	v := (d00*d21 - d01*d20) / denom
+	return u >= 0 && v >= 0 && u+v <= 1
+}
+
+func vec64(v vector.Vec) [3]float64 {
+	return [3]float64{float64(v[0]), float64(v[1]), float64(v[2])}
+}
+
+func add64(a [3]float64, b [3]float64) [3]float64 {
+	return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func sub64(a [3]float64, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func scale64(a [3]float64, k float64) [3]float64 {
+	return [3]float64{a[0] * k, a[1] * k, a[2] * k}
+}
+
+func dot64(a [3]float64, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
 
+func cross64(a [3]float64, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
 }
 
 //Project XY, XZ, YZ - Plane must be
@@ -0,0 +1,278 @@
+package gltf
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/quaternion"
+)
+
+//Animation Sampler Interpolation Modes
+const INTERPOLATION_LINEAR = "LINEAR"
+const INTERPOLATION_STEP = "STEP"
+const INTERPOLATION_CUBICSPLINE = "CUBICSPLINE"
+
+//Animation Channel Target Paths
+const PATH_TRANSLATION = "translation"
+const PATH_ROTATION = "rotation"
+const PATH_SCALE = "scale"
+
+//index decodes a JSON number held in an untyped schema field
+func index(v interface{}) int {
+	switch x := v.(type) {
+	case float64:
+		return int(x)
+	case int:
+		return x
+	case GlTFId:
+		return int(x)
+	}
+	return -1
+}
+
+//SamplerIndex returns the index of the channel sampler in its animation
+func (c *AnimationChannel) SamplerIndex() int {
+	return index(c.Sampler)
+}
+
+//TargetNode returns the node index the channel animates or -1
+func (c *AnimationChannel) TargetNode() int {
+	switch t := c.Target.(type) {
+	case map[string]interface{}:
+		if n, ok := t["node"]; ok {
+			return index(n)
+		}
+	case *AnimationChannelTarget:
+		return index(t.Node)
+	}
+	return -1
+}
+
+//TargetPath returns the animated TRS property name of the channel
+func (c *AnimationChannel) TargetPath() string {
+	switch t := c.Target.(type) {
+	case map[string]interface{}:
+		if p, ok := t["path"].(string); ok {
+			return p
+		}
+	case *AnimationChannelTarget:
+		if p, ok := t.Path.(string); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+func (s *AnimationSampler) InputIndex() int {
+	return index(s.Input)
+}
+
+func (s *AnimationSampler) OutputIndex() int {
+	return index(s.Output)
+}
+
+//Mode returns the interpolation mode, LINEAR when unspecified
+func (s *AnimationSampler) Mode() string {
+	if mode, ok := s.Interpolation.(string); ok && mode != "" {
+		return mode
+	}
+	return INTERPOLATION_LINEAR
+}
+
+//Keyframes holds decoded sampler data. Output holds Components values per key,
+//or in-tangent, value and out-tangent triples for cubic spline samplers.
+//Rotation keyframes are unit quaternions (x, y, z, w)
+type Keyframes struct {
+	Input         []float32
+	Output        []float32
+	Components    int
+	Interpolation string
+	Rotation      bool
+}
+
+//value returns the keyframe value k, skipping cubic spline tangents
+func (k *Keyframes) value(i int) []float32 {
+	c := k.Components
+	if k.Interpolation == INTERPOLATION_CUBICSPLINE {
+		return k.Output[(3*i+1)*c : (3*i+2)*c]
+	}
+	return k.Output[i*c : (i+1)*c]
+}
+
+//Sample evaluates the keyframes at time t, holding the end values outside the
+//keyframe range
+func (k *Keyframes) Sample(t float32) []float32 {
+	n := len(k.Input)
+	out := make([]float32, k.Components)
+	if n == 0 {
+		return out
+	}
+	if t <= k.Input[0] {
+		copy(out, k.value(0))
+		return out
+	}
+	if t >= k.Input[n-1] {
+		copy(out, k.value(n-1))
+		return out
+	}
+	i := 0
+	for i < n-2 && k.Input[i+1] <= t {
+		i++
+	}
+	dt := k.Input[i+1] - k.Input[i]
+	s := (t - k.Input[i]) / dt
+	a, b := k.value(i), k.value(i+1)
+
+	switch k.Interpolation {
+	case INTERPOLATION_STEP:
+		copy(out, a)
+	case INTERPOLATION_CUBICSPLINE:
+		c := k.Components
+		outTangent := k.Output[(3*i+2)*c : (3*i+3)*c]
+		inTangent := k.Output[(3*(i+1))*c : (3*(i+1)+1)*c]
+		s2, s3 := s*s, s*s*s
+		for j := 0; j < c; j++ {
+			out[j] = (2*s3-3*s2+1)*a[j] + dt*(s3-2*s2+s)*outTangent[j] + (-2*s3+3*s2)*b[j] + dt*(s3-s2)*inTangent[j]
+		}
+		if k.Rotation {
+			normalize(out)
+		}
+	default:
+		if k.Rotation {
+			qa := quaternion.NewQ(float64(a[3]), float64(a[0]), float64(a[1]), float64(a[2]))
+			qb := quaternion.NewQ(float64(b[3]), float64(b[0]), float64(b[1]), float64(b[2]))
+			q := quaternion.Slerp(qa, qb, float64(s))
+			out[0], out[1], out[2], out[3] = float32(q.X), float32(q.Y), float32(q.Z), float32(q.W)
+		} else {
+			for j := range out {
+				out[j] = a[j]*(1-s) + b[j]*s
+			}
+		}
+	}
+	return out
+}
+
+//Duration returns the time of the last keyframe
+func (k *Keyframes) Duration() float32 {
+	if len(k.Input) == 0 {
+		return 0
+	}
+	return k.Input[len(k.Input)-1]
+}
+
+func normalize(q []float32) {
+	l := float32(0)
+	for _, x := range q {
+		l += x * x
+	}
+	if l > 0 {
+		inv := float32(1 / math.Sqrt(float64(l)))
+		for j := range q {
+			q[j] *= inv
+		}
+	}
+}
+
+//NodeAnimation animates the local translation, rotation and scale of a node.
+//Properties without keyframes keep the node rest values. The world transform
+//composes the transforms of the Parent chain
+type NodeAnimation struct {
+	Translation *Keyframes
+	Rotation    *Keyframes
+	Scale       *Keyframes
+	Base        [10]float32    //Rest translation, rotation (x, y, z, w) and scale
+	Matrix      matrix.Mat     //Row major rest transform of matrix nodes, nil for TRS nodes
+	Parent      *NodeAnimation //Transform of the parent node, nil for root nodes
+}
+
+//NewNodeAnimation creates an unanimated transform with the node rest TRS or
+//the rest matrix of nodes given by a matrix
+func NewNodeAnimation(node *Node) *NodeAnimation {
+	a := NodeAnimation{}
+	a.Base = [10]float32{0, 0, 0, 0, 0, 0, 1, 1, 1, 1}
+	if node != nil {
+		copy(a.Base[0:3], node.Translation)
+		copy(a.Base[3:7], node.Rotation)
+		copy(a.Base[7:10], node.Scale)
+		if len(node.Matrix) == 16 {
+			a.Matrix = matrix.Mat4(0.0)
+			for i := 0; i < 4; i++ {
+				for j := 0; j < 4; j++ {
+					a.Matrix[i*4+j] = float32(node.Matrix[j*4+i])
+				}
+			}
+		}
+	}
+	return &a
+}
+
+//Set binds keyframes to the TRS property named by path
+func (a *NodeAnimation) Set(path string, k *Keyframes) {
+	switch path {
+	case PATH_TRANSLATION:
+		a.Translation = k
+	case PATH_ROTATION:
+		k.Rotation = true
+		a.Rotation = k
+	case PATH_SCALE:
+		a.Scale = k
+	}
+}
+
+//TRS returns the translation, rotation quaternion (x, y, z, w) and scale at t
+func (a *NodeAnimation) TRS(t float32) ([]float32, []float32, []float32) {
+	trans, rot, scale := a.Base[0:3], a.Base[3:7], a.Base[7:10]
+	if a.Translation != nil {
+		trans = a.Translation.Sample(t)
+	}
+	if a.Rotation != nil {
+		rot = a.Rotation.Sample(t)
+	}
+	if a.Scale != nil {
+		scale = a.Scale.Sample(t)
+	}
+	return trans, rot, scale
+}
+
+//Transform returns the row major 4x4 world transform of the node at time t, the
+//local transforms of the parent chain applied from the root down
+func (a *NodeAnimation) Transform(t float32) matrix.Mat {
+	m := a.Local(t)
+	for p := a.Parent; p != nil; p = p.Parent {
+		m = matrix.MulM(p.Local(t), m)
+	}
+	return m
+}
+
+//Local returns the row major 4x4 local transform T R S of the node at time t
+func (a *NodeAnimation) Local(t float32) matrix.Mat {
+	if a.Matrix != nil && a.Translation == nil && a.Rotation == nil && a.Scale == nil {
+		return append(matrix.Mat{}, a.Matrix...)
+	}
+	trans, rot, scale := a.TRS(t)
+	q := quaternion.NewQ(float64(rot[3]), float64(rot[0]), float64(rot[1]), float64(rot[2]))
+	r := q.RotMat()
+	m := matrix.Mat4(0.0)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			m[i*4+j] = float32(r[i][j]) * scale[j]
+		}
+		m[i*4+3] = trans[i]
+	}
+	m[15] = 1
+	return m
+}
+
+//Duration returns the length of the longest animated property of the node and
+//its parents
+func (a *NodeAnimation) Duration() float32 {
+	d := float32(0)
+	for p := a; p != nil; p = p.Parent {
+		for _, k := range []*Keyframes{p.Translation, p.Rotation, p.Scale} {
+			if k != nil && k.Duration() > d {
+				d = k.Duration()
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,57 @@
+package gltf
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a float32, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-4
+}
+
+func TestKeyframes(t *testing.T) {
+	linear := Keyframes{Input: []float32{0, 1, 2}, Output: []float32{0, 2, 6}, Components: 1, Interpolation: INTERPOLATION_LINEAR}
+	if !near(linear.Sample(0.5)[0], 1) || !near(linear.Sample(1.5)[0], 4) || !near(linear.Sample(3)[0], 6) {
+		t.Errorf("Linear keyframes %v %v %v\n", linear.Sample(0.5), linear.Sample(1.5), linear.Sample(3))
+	}
+	step := linear
+	step.Interpolation = INTERPOLATION_STEP
+	if !near(step.Sample(1.9)[0], 2) {
+		t.Errorf("Step keyframes expected 2 got %v\n", step.Sample(1.9))
+	}
+	//Zero tangents reduce the Hermite spline to smoothstep
+	cubic := Keyframes{Input: []float32{0, 1}, Output: []float32{0, 0, 0, 0, 1, 0}, Components: 1, Interpolation: INTERPOLATION_CUBICSPLINE}
+	if !near(cubic.Sample(0.25)[0], 0.15625) {
+		t.Errorf("Cubic spline expected 0.15625 got %v\n", cubic.Sample(0.25))
+	}
+}
+
+func TestNodeAnimation(t *testing.T) {
+	s := float32(math.Sqrt(0.5))
+	anim := NewNodeAnimation(&Node{})
+	anim.Set(PATH_TRANSLATION, &Keyframes{Input: []float32{0, 1}, Output: []float32{0, 0, 0, 2, 0, 0}, Components: 3})
+	anim.Set(PATH_ROTATION, &Keyframes{Input: []float32{0, 1}, Output: []float32{0, 0, 0, 1, 0, s, 0, s}, Components: 4})
+	m := anim.Transform(0.5)
+	//Half of a 90 degree turn about y maps x to (cos 45, 0, -sin 45)
+	if !near(m[3], 1) || !near(m[0], s) || !near(m[8], -s) {
+		t.Errorf("Node transform at t=0.5\n%v\n", m)
+	}
+	if anim.Duration() != 1 {
+		t.Errorf("Animation duration expected 1 got %f\n", anim.Duration())
+	}
+}
+
+func TestNodeHierarchy(t *testing.T) {
+	//A parent matrix node turned 90 degrees about z, column major, at x 1
+	parent := NewNodeAnimation(&Node{Matrix: []float64{0, 1, 0, 0, -1, 0, 0, 0, 0, 0, 1, 0, 1, 0, 0, 1}})
+	child := NewNodeAnimation(&Node{})
+	child.Set(PATH_TRANSLATION, &Keyframes{Input: []float32{0, 2}, Output: []float32{0, 0, 0, 2, 0, 0}, Components: 3})
+	child.Parent = parent
+	m := child.Transform(1)
+	if !near(m[3], 1) || !near(m[7], 1) || !near(m[11], 0) || !near(m[4], 1) {
+		t.Errorf("Child world transform\n%v\n", m)
+	}
+	if l := child.Local(1); !near(l[3], 1) || !near(l[7], 0) || child.Duration() != 2 {
+		t.Errorf("Child local transform\n%v\n", l)
+	}
+}
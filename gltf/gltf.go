@@ -435,8 +435,8 @@ type MeshPrimitive struct {
 	Extensions string         `json:"extensions,omitempty"`
 	Extras     interface{}    `json:"extras,omitempty"`
 
-	// The index of the accessor that contains the indices.
-	Indices int `json:"indices,omitempty"`
+	// The index of the accessor that contains the indices, nil for non-indexed geometry.
+	Indices *int `json:"indices,omitempty"`
 
 	// The index of the material to apply to this primitive when rendering.
 	Material int `json:"material,omitempty"`
@@ -444,8 +444,8 @@ type MeshPrimitive struct {
 	// Name of material key
 	Name string `json:"name,omitempty"`
 
-	// The type of primitives to render.
-	Mode int `json:"mode,omitempty"`
+	// The type of primitives to render, nil for the default triangles.
+	Mode *int `json:"mode,omitempty"`
 
 	// An array of Morph Targets, each  Morph Target is a dictionary mapping attributes (only `POSITION`, `NORMAL`, and `TANGENT` supported) to their deviations in the Morph Target.
 	Targets []map[string]int `json:"targets,omitempty"`
@@ -2774,16 +2774,18 @@ func (strct *MeshPrimitive) MarshalJSON() ([]byte, error) {
 	}
 	comma = true
 	// Marshal the "indices" field
-	if comma {
-		buf.WriteString(",")
-	}
-	buf.WriteString("\"indices\": ")
-	if tmp, err := json.Marshal(strct.Indices); err != nil {
-		return nil, err
-	} else {
-		buf.Write(tmp)
+	if strct.Indices != nil {
+		if comma {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\"indices\": ")
+		if tmp, err := json.Marshal(strct.Indices); err != nil {
+			return nil, err
+		} else {
+			buf.Write(tmp)
+		}
+		comma = true
 	}
-	comma = true
 	// Marshal the "material" field
 	if comma {
 		buf.WriteString(",")
@@ -2796,16 +2798,18 @@ func (strct *MeshPrimitive) MarshalJSON() ([]byte, error) {
 	}
 	comma = true
 	// Marshal the "mode" field
-	if comma {
-		buf.WriteString(",")
-	}
-	buf.WriteString("\"mode\": ")
-	if tmp, err := json.Marshal(strct.Mode); err != nil {
-		return nil, err
-	} else {
-		buf.Write(tmp)
+	if strct.Mode != nil {
+		if comma {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\"mode\": ")
+		if tmp, err := json.Marshal(strct.Mode); err != nil {
+			return nil, err
+		} else {
+			buf.Write(tmp)
+		}
+		comma = true
 	}
-	comma = true
 	// Marshal the "targets" field
 	if comma {
 		buf.WriteString(",")
@@ -1,6 +1,10 @@
 package kernel
 
-import V "github.com/andewx/dieselfluid/math/vector"
+import (
+	"math"
+
+	V "github.com/andewx/dieselfluid/math/vector"
+)
 
 type Kernel interface {
 	F(x float32) float32
@@ -11,3 +15,16 @@ type Kernel interface {
 	Grad(x float32, dir V.Vec) V.Vec
 	W0() float32
 }
+
+//Integral returns the volume integral of the kernel over its support, 1 for a
+//normalized kernel
+func Integral(k Kernel) float32 {
+	const steps = 256
+	h := float64(k.H0())
+	sum := 0.0
+	for i := 0; i < steps; i++ {
+		r := (float64(i) + 0.5) * h / steps
+		sum += 4 * math.Pi * r * r * float64(k.F(float32(r))) * h / steps
+	}
+	return float32(sum)
+}
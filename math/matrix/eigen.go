@@ -0,0 +1,75 @@
+package matrix
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//SymEigen3 diagonalizes a symmetric 3x3 Mat with cyclic Jacobi rotations. The
+//eigenvalues are returned in descending order with the matching unit
+//eigenvectors as the columns of the returned Mat so that M = R diag(values) R^T.
+//For symmetric positive semi definite matrices such as covariances this is also
+//the singular value decomposition
+func (m Mat) SymEigen3() (vector.Vec, Mat) {
+	var a, v [3][3]float64
+	for i := 0; i < MAT3; i++ {
+		v[i][i] = 1
+		for j := 0; j < MAT3; j++ {
+			a[i][j] = float64(m[Map(i, j, MAT3)]+m[Map(j, i, MAT3)]) / 2
+		}
+	}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		diag := a[0][0]*a[0][0] + a[1][1]*a[1][1] + a[2][2]*a[2][2]
+		if off <= 1e-30*diag || off == 0 {
+			break
+		}
+		for p := 0; p < MAT3-1; p++ {
+			for q := p + 1; q < MAT3; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				//Rotation angle zeroing a[p][q], t = tan(theta) of the smaller root
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < MAT3; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p], a[k][q] = c*akp-s*akq, s*akp+c*akq
+				}
+				for k := 0; k < MAT3; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k], a[q][k] = c*apk-s*aqk, s*apk+c*aqk
+				}
+				for k := 0; k < MAT3; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p], v[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+				}
+			}
+		}
+	}
+
+	order := []int{0, 1, 2}
+	for i := 0; i < MAT3; i++ {
+		for j := i + 1; j < MAT3; j++ {
+			if a[order[j]][order[j]] > a[order[i]][order[i]] {
+				order[i], order[j] = order[j], order[i]
+			}
+		}
+	}
+	values := vector.Vec3()
+	vectors := Mat3(0.0)
+	for col, k := range order {
+		values[col] = float32(a[k][k])
+		for row := 0; row < MAT3; row++ {
+			vectors[Map(row, col, MAT3)] = float32(v[row][k])
+		}
+	}
+	return values, vectors
+}
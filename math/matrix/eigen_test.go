@@ -0,0 +1,29 @@
+package matrix
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSymEigen3(t *testing.T) {
+	m := Mat{4, 1, 2, 1, 3, 0, 2, 0, 5}
+	values, vectors := m.SymEigen3()
+	if values[0] < values[1] || values[1] < values[2] {
+		t.Errorf("Eigenvalues not descending %v\n", values)
+	}
+	//Reconstruct M = R diag R^T
+	for i := 0; i < MAT3; i++ {
+		for j := 0; j < MAT3; j++ {
+			sum := float32(0)
+			for k := 0; k < MAT3; k++ {
+				sum += vectors[Map(i, k, MAT3)] * values[k] * vectors[Map(j, k, MAT3)]
+			}
+			if math.Abs(float64(sum-m[Map(i, j, MAT3)])) > 1e-4 {
+				t.Fatalf("R diag R^T [%d,%d] = %f expected %f\n", i, j, sum, m[Map(i, j, MAT3)])
+			}
+		}
+	}
+	if math.Abs(float64(values[0]+values[1]+values[2]-12)) > 1e-4 {
+		t.Errorf("Eigenvalue trace %v\n", values)
+	}
+}
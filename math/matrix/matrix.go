@@ -410,7 +410,8 @@ func (m Mat) Inv3() Mat {
 			minor[indx][2] = m[d2]
 			minor[indx][3] = m[d3]
 
-			inv[indx] = minor[indx].Det2() / det
+			//Adjugate is the transposed cofactor matrix
+			inv[Map(j, i, MAT3)] = minor[indx].Det2() / det
 		}
 	}
 	return inv
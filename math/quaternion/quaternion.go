@@ -140,3 +140,27 @@ func (qin Quaternion) RotMat() [3][3]float64 {
 	m[2][1] = 2 * (q.W*q.X + q.Z*q.Y)
 	return m
 }
+
+// Dot returns the 4D dot product of two quaternions
+func Dot(a, b Quaternion) float64 {
+	return a.W*b.W + a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+// Slerp spherically interpolates between unit quaternions a and b along the
+// shortest arc, falling back to normalized linear interpolation for nearly
+// parallel inputs
+func Slerp(a, b Quaternion, t float64) Quaternion {
+	d := Dot(a, b)
+	if d < 0 {
+		b = b.Neg()
+		d = -d
+	}
+	if d > 0.9995 {
+		return Sum(Prod(Scalar(1-t), a), Prod(Scalar(t), b)).Unit()
+	}
+	theta := math.Acos(d)
+	s := math.Sin(theta)
+	wa := math.Sin((1-t)*theta) / s
+	wb := math.Sin(t*theta) / s
+	return Sum(Prod(Scalar(wa), a), Prod(Scalar(wb), b))
+}
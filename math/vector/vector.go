@@ -165,7 +165,7 @@ func LogVecError(vec Vec, op string) Vec {
 //Add sums each element index against the other vector a stores the result
 //in a new vector @DELEGATES
 func Add(a Vec, b Vec) Vec {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return Vec{0, 0, 0}
 	}
 	if len(a) != len(b) {
@@ -181,7 +181,7 @@ func Add(a Vec, b Vec) Vec {
 //Add sums each element index against the other vector a stores the result
 //in a new vector @DELEGATES
 func (a Vec) Add(b Vec) Vec {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return Vec{0, 0, 0}
 	}
 	if len(a) != len(b) {
@@ -197,7 +197,7 @@ func (a Vec) Add(b Vec) Vec {
 //Sub subtracts the vector b minus a utilizing the same add functions
 //above. @NONMUTATE
 func Sub(b Vec, a Vec) Vec {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return Vec{0, 0, 0}
 	}
 	if len(a) != len(b) {
@@ -209,7 +209,7 @@ func Sub(b Vec, a Vec) Vec {
 //Sub subtracts the vector b minus a utilizing the same add functions
 //above. @NONMUTATE
 func (b Vec) Sub(a Vec) Vec {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return Vec{0, 0, 0}
 	}
 	if len(a) != len(b) {
@@ -219,7 +219,7 @@ func (b Vec) Sub(a Vec) Vec {
 }
 
 func (b Vec) Mul(a Vec) Vec {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return Vec{0, 0, 0}
 	}
 	if len(a) != len(b) {
@@ -266,7 +266,7 @@ func (a Vec) Scale(k float32) Vec {
 //Dot computes the dot product as in a.Dot(b) where the resulting value should return
 //a scalar value representative of the ||a|||b||Cos() theta angle between two non-parallel vectors
 func Dot(a Vec, b Vec) float32 {
-	if len(a) == 0 || len(a) == 0 {
+	if len(a) == 0 || len(b) == 0 {
 		return 0
 	}
 	if len(a) != len(b) {
@@ -0,0 +1,234 @@
+//Point cloud analysis of fluid particle positions. Normals are estimated from the
+//principal components of the k nearest neighbors and consistently oriented by
+//propagation along a minimum spanning tree of the neighbor graph, Hoppe et al.
+//1992. Particles are classified into interior, surface and isolated particles
+//and linked into connected droplets. The attributes are exported for rendering
+//and secondary particle generation
+package cloud
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/sampler/cell"
+)
+
+//Particle Class Enums
+const CLOUD_INTERIOR = 0
+const CLOUD_SURFACE = 1
+const CLOUD_ISOLATED = 2
+
+//Params holds the neighborhood and classification thresholds
+type Params struct {
+	Radius    float32 //Neighbor search and droplet linking radius
+	Neighbors int     //Nearest neighbors k for the normal estimate
+	Isolated  int     //Fewer neighbors within the radius than this is isolated
+	Offset    float32 //Centroid offset over the radius above which is surface
+	Sheet     float32 //Surface variation below which a thin sheet is surface
+}
+
+//DefaultParams returns thresholds for particles of spacing dx
+func DefaultParams(dx float32) Params {
+	return Params{Radius: 2 * dx, Neighbors: 16, Isolated: 4, Offset: 0.15, Sheet: 0.05}
+}
+
+//Cloud holds the per particle attributes of an analysis. Normals are flattened
+//x y z triples like the particle positions. Variation is the surface variation
+//l_min/(l_0+l_1+l_2) of the neighbor covariance, zero on planes and a third in
+//isotropic interiors. Curvature is the mean curvature of surface particles,
+//positive where the surface is convex
+type Cloud struct {
+	Params    Params
+	Normals   []float32
+	Variation []float32
+	Curvature []float32
+	Offsets   []float32 //Distance to the weighted neighbor centroid over the radius
+	Class     []int
+	Droplet   []int //Droplet label, 0 being the largest
+	Sizes     []int //Particle count per droplet label
+
+	neighbors [][]int
+	centroids []vector.Vec
+}
+
+//Analyze estimates normals and curvature, classifies the fluid particles of parts
+//and labels their droplets
+func Analyze(parts *model.ParticleArray, p Params) *Cloud {
+	n := parts.N()
+	c := &Cloud{Params: p}
+	c.Normals = make([]float32, n*3)
+	c.Variation = make([]float32, n)
+	c.Curvature = make([]float32, n)
+	c.Offsets = make([]float32, n)
+	c.Class = make([]int, n)
+	c.neighbors = make([][]int, n)
+	c.centroids = make([]vector.Vec, n)
+
+	search := cell.New(parts, p.Radius, nil)
+	count := make([]int, n)
+	for i := 0; i < n; i++ {
+		x := parts.Position(i)
+		near := []int{}
+		dist := map[int]float32{}
+		for _, j := range search.GetSamples(i) {
+			if j >= n || j == i {
+				continue
+			}
+			if d := vector.Dist(parts.Position(j), x); d < p.Radius {
+				near = append(near, j)
+				dist[j] = d
+			}
+		}
+		sort.Slice(near, func(a int, b int) bool {
+			if dist[near[a]] != dist[near[b]] {
+				return dist[near[a]] < dist[near[b]]
+			}
+			return near[a] < near[b]
+		})
+		count[i] = len(near)
+		c.centroids[i] = centroid(parts, x, near, dist, p.Radius)
+		if len(near) > 0 {
+			c.Offsets[i] = vector.Dist(x, c.centroids[i]) / p.Radius
+		}
+		if len(near) > p.Neighbors {
+			near = near[:p.Neighbors]
+		}
+		c.neighbors[i] = near
+		normal, variation := fitPlane(parts, i, near)
+		copy(c.Normals[i*3:], normal)
+		c.Variation[i] = variation
+	}
+
+	for i := 0; i < n; i++ {
+		switch {
+		case count[i] < p.Isolated:
+			c.Class[i] = CLOUD_ISOLATED
+		case c.Offsets[i] > p.Offset || c.Variation[i] < p.Sheet:
+			c.Class[i] = CLOUD_SURFACE
+		default:
+			c.Class[i] = CLOUD_INTERIOR
+		}
+	}
+	c.orient(parts)
+	c.curvature(parts)
+	c.label(parts, search)
+	return c
+}
+
+//centroid returns the neighbor centroid weighted by 1-(r/R)^3, x without neighbors
+func centroid(parts *model.ParticleArray, x []float32, near []int, dist map[int]float32, radius float32) vector.Vec {
+	sum := float64(0)
+	mean := [3]float64{}
+	for _, j := range near {
+		r := float64(dist[j] / radius)
+		w := 1 - r*r*r
+		xj := parts.Position(j)
+		for a := 0; a < 3; a++ {
+			mean[a] += w * float64(xj[a])
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return vector.Vec{x[0], x[1], x[2]}
+	}
+	return vector.Vec{float32(mean[0] / sum), float32(mean[1] / sum), float32(mean[2] / sum)}
+}
+
+//fitPlane returns the unoriented normal of particle i, the least principal axis
+//of the covariance of i and its neighbors, and the surface variation
+func fitPlane(parts *model.ParticleArray, i int, near []int) (vector.Vec, float32) {
+	if len(near) < 2 {
+		return vector.Vec{0, 0, 0}, 0
+	}
+	points := append([]int{i}, near...)
+	mean := [3]float64{}
+	for _, j := range points {
+		xj := parts.Position(j)
+		for a := 0; a < 3; a++ {
+			mean[a] += float64(xj[a]) / float64(len(points))
+		}
+	}
+	cov := matrix.Mat3(0.0)
+	for _, j := range points {
+		xj := parts.Position(j)
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				cov[a*3+b] += float32((float64(xj[a]) - mean[a]) * (float64(xj[b]) - mean[b]) / float64(len(points)))
+			}
+		}
+	}
+	values, vectors := cov.SymEigen3()
+	normal := vector.Vec{vectors[2], vectors[5], vectors[8]}
+	sum := values[0] + values[1] + values[2]
+	if sum <= 0 {
+		return normal, 0
+	}
+	return normal, float32(math.Max(float64(values[2]), 0)) / sum
+}
+
+//Normal returns the unit normal of particle i
+func (c *Cloud) Normal(i int) vector.Vec {
+	return vector.Vec{c.Normals[i*3], c.Normals[i*3+1], c.Normals[i*3+2]}
+}
+
+//Neighbors returns the nearest neighbors of particle i used for its normal
+func (c *Cloud) Neighbors(i int) []int {
+	return c.neighbors[i]
+}
+
+//Count returns the number of particles of a class
+func (c *Cloud) Count(class int) int {
+	count := 0
+	for _, k := range c.Class {
+		if k == class {
+			count++
+		}
+	}
+	return count
+}
+
+//Surface returns the indices of the surface particles
+func (c *Cloud) Surface() []int {
+	surface := []int{}
+	for i, k := range c.Class {
+		if k == CLOUD_SURFACE {
+			surface = append(surface, i)
+		}
+	}
+	return surface
+}
+
+//Droplets returns the number of connected droplets
+func (c *Cloud) Droplets() int {
+	return len(c.Sizes)
+}
+
+//curvature estimates the mean curvature of surface particles from the oriented
+//normal and their surface neighbors, H = -2/m sum n.(x_j-x_i)/|x_j-x_i|^2,
+//which is 1/R on a sphere of radius R
+func (c *Cloud) curvature(parts *model.ParticleArray) {
+	for i := range c.Curvature {
+		if c.Class[i] != CLOUD_SURFACE {
+			continue
+		}
+		n := c.Normal(i)
+		x := vector.Vec(parts.Position(i)[:3])
+		sum, m := float32(0), 0
+		for _, j := range c.neighbors[i] {
+			if c.Class[j] != CLOUD_SURFACE {
+				continue
+			}
+			d := vector.Sub(vector.Vec(parts.Position(j)[:3]), x)
+			if l := vector.Dot(d, d); l > 0 {
+				sum += vector.Dot(n, d) / l
+				m++
+			}
+		}
+		if m > 2 {
+			c.Curvature[i] = -2 * sum / float32(m)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cloud
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+func cloud(positions [][3]float32, h float32) *model.ParticleArray {
+	parts := model.NewParticleArray(len(positions), 0, h, 1000, 0.125)
+	for i, p := range positions {
+		parts.Set(i, model.Particle{Position: p})
+	}
+	return &parts
+}
+
+func TestSphereNormals(t *testing.T) {
+	//Fibonacci points on the unit sphere
+	n := 2000
+	points := make([][3]float32, n)
+	golden := math.Pi * (3 - math.Sqrt(5))
+	for i := range points {
+		y := 1 - 2*(float64(i)+0.5)/float64(n)
+		r := math.Sqrt(1 - y*y)
+		points[i] = [3]float32{float32(r * math.Cos(golden*float64(i))), float32(y), float32(r * math.Sin(golden*float64(i)))}
+	}
+	parts := cloud(points, 0.1)
+	c := Analyze(parts, DefaultParams(0.1))
+	if c.Count(CLOUD_SURFACE) != n || c.Droplets() != 1 {
+		t.Fatalf("Sphere shell %d surface particles %d droplets\n", c.Count(CLOUD_SURFACE), c.Droplets())
+	}
+	mean := float32(0)
+	for i := 0; i < n; i++ {
+		if d := vector.Dot(c.Normal(i), vector.Vec(parts.Position(i))); d < 0.99 {
+			t.Fatalf("Normal %v at %v not outward\n", c.Normal(i), parts.Position(i))
+		}
+		mean += c.Curvature[i] / float32(n)
+	}
+	if math.Abs(float64(mean-1)) > 0.1 {
+		t.Errorf("Unit sphere mean curvature %f\n", mean)
+	}
+}
+
+func TestDroplets(t *testing.T) {
+	dx := float32(0.1)
+	points := model.Lattice([3]float32{0, 0, 0}, [3]int{10, 10, 10}, dx)
+	points = append(points, model.Lattice([3]float32{3, 0, 0}, [3]int{3, 3, 3}, dx)...)
+	points = append(points, model.Lattice([3]float32{0, 3, 0}, [3]int{3, 3, 3}, dx)...)
+	points = append(points, [3]float32{-2, 0, 0})
+	parts := cloud(points, dx)
+	c := Analyze(parts, DefaultParams(dx))
+
+	if c.Droplets() != 4 || c.Sizes[0] != 1000 || c.Sizes[1] != 27 || c.Sizes[3] != 1 || c.Droplet[1000] == c.Droplet[1027] || c.Droplet[0] != 0 {
+		t.Fatalf("Droplets %d sizes %v\n", c.Droplets(), c.Sizes)
+	}
+	if c.Class[len(points)-1] != CLOUD_ISOLATED {
+		t.Errorf("Lone particle class %d\n", c.Class[len(points)-1])
+	}
+	//Only the outer layer of the block is surface
+	for i := 0; i < 1000; i++ {
+		x, y, z := i/100, i/10%10, i%10
+		outer := x == 0 || x == 9 || y == 0 || y == 9 || z == 0 || z == 9
+		if outer != (c.Class[i] == CLOUD_SURFACE) {
+			t.Fatalf("Block particle %d %d %d class %d offset %f variation %f\n", x, y, z, c.Class[i], c.Offsets[i], c.Variation[i])
+		}
+		if x > 1 && x < 8 && z > 1 && z < 8 && y == 9 && c.Normal(i)[1] < 0.99 {
+			t.Errorf("Top face normal %v\n", c.Normal(i))
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "cloud.ply")
+	if err := c.ExportPLY(filename, parts); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filename); err != nil || info.Size() < int64(33*len(points)) {
+		t.Errorf("Exported point cloud %v\n", err)
+	}
+}
@@ -0,0 +1,48 @@
+package cloud
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/andewx/dieselfluid/model"
+)
+
+//ExportPLY writes the fluid particles of parts as a binary little endian PLY
+//point cloud with position, normal, mean curvature, the CLOUD class enum and the
+//droplet label of each particle
+func (c *Cloud) ExportPLY(filename string, parts *model.ParticleArray) error {
+	if parts.N() != len(c.Class) {
+		return fmt.Errorf("Cloud of %d particles exported with %d particles", len(c.Class), parts.N())
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "ply\nformat binary_little_endian 1.0\ncomment dieselfluid point cloud attributes\n")
+	fmt.Fprintf(w, "element vertex %d\n", parts.N())
+	fmt.Fprintf(w, "property float x\nproperty float y\nproperty float z\n")
+	fmt.Fprintf(w, "property float nx\nproperty float ny\nproperty float nz\n")
+	fmt.Fprintf(w, "property float curvature\nproperty uchar class\nproperty int droplet\nend_header\n")
+
+	buf := make([]byte, 33)
+	for i := 0; i < parts.N(); i++ {
+		pos := parts.Position(i)
+		for k := 0; k < 3; k++ {
+			binary.LittleEndian.PutUint32(buf[k*4:], math.Float32bits(pos[k]))
+			binary.LittleEndian.PutUint32(buf[12+k*4:], math.Float32bits(c.Normals[i*3+k]))
+		}
+		binary.LittleEndian.PutUint32(buf[24:], math.Float32bits(c.Curvature[i]))
+		buf[28] = byte(c.Class[i])
+		binary.LittleEndian.PutUint32(buf[29:], uint32(int32(c.Droplet[i])))
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
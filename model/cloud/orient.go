@@ -0,0 +1,157 @@
+package cloud
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/sampler/cell"
+)
+
+//orientEdge is a neighbor graph edge in the spanning tree queue weighted by
+//1-|n_i.n_j| so orientations propagate between nearly parallel normals first
+type orientEdge struct {
+	cost float32
+	from int
+	to   int
+}
+
+type orientQueue []orientEdge
+
+func (q orientQueue) Len() int { return len(q) }
+func (q orientQueue) Less(a int, b int) bool {
+	if q[a].cost != q[b].cost {
+		return q[a].cost < q[b].cost
+	}
+	return q[a].to < q[b].to
+}
+func (q orientQueue) Swap(a int, b int)        { q[a], q[b] = q[b], q[a] }
+func (q *orientQueue) Push(x interface{})      { *q = append(*q, x.(orientEdge)) }
+func (q *orientQueue) Pop() (item interface{}) { item, *q = (*q)[len(*q)-1], (*q)[:len(*q)-1]; return }
+
+//outward flips the normal of i to point away from its neighbor centroid and
+//reports whether the centroid offset decided the orientation
+func (c *Cloud) outward(parts *model.ParticleArray, i int) bool {
+	offset := vector.Sub(vector.Vec(parts.Position(i)[:3]), c.centroids[i])
+	d := vector.Dot(c.Normal(i), offset)
+	if d < 0 {
+		c.flip(i)
+	}
+	return d != 0
+}
+
+func (c *Cloud) flip(i int) {
+	for a := 0; a < 3; a++ {
+		c.Normals[i*3+a] = -c.Normals[i*3+a]
+	}
+}
+
+//orient makes the surface normals consistent. Each component of the surface
+//neighbor graph is seeded at its particle with the largest centroid offset,
+//oriented away from the centroid, and the orientation is propagated along a
+//minimum spanning tree by Prim's algorithm. Interior and isolated particles
+//point away from their neighbor centroid
+func (c *Cloud) orient(parts *model.ParticleArray) {
+	adjacent := make([][]int, len(c.Class))
+	seeds := []int{}
+	for i, class := range c.Class {
+		if class != CLOUD_SURFACE {
+			c.outward(parts, i)
+			continue
+		}
+		seeds = append(seeds, i)
+		for _, j := range c.neighbors[i] {
+			if c.Class[j] == CLOUD_SURFACE {
+				adjacent[i] = append(adjacent[i], j)
+				adjacent[j] = append(adjacent[j], i)
+			}
+		}
+	}
+	sort.SliceStable(seeds, func(a int, b int) bool {
+		return c.Offsets[seeds[a]] > c.Offsets[seeds[b]]
+	})
+
+	visited := make([]bool, len(c.Class))
+	q := &orientQueue{}
+	visit := func(i int) {
+		visited[i] = true
+		n := c.Normal(i)
+		for _, j := range adjacent[i] {
+			if !visited[j] {
+				d := vector.Dot(n, c.Normal(j))
+				if d < 0 {
+					d = -d
+				}
+				heap.Push(q, orientEdge{1 - d, i, j})
+			}
+		}
+	}
+	for _, seed := range seeds {
+		if visited[seed] {
+			continue
+		}
+		c.outward(parts, seed)
+		visit(seed)
+		for q.Len() > 0 {
+			e := heap.Pop(q).(orientEdge)
+			if visited[e.to] {
+				continue
+			}
+			if vector.Dot(c.Normal(e.from), c.Normal(e.to)) < 0 {
+				c.flip(e.to)
+			}
+			visit(e.to)
+		}
+	}
+}
+
+//label links particles closer than the radius into droplets with a union find
+//and numbers the droplets by decreasing size
+func (c *Cloud) label(parts *model.ParticleArray, search *cell.CellSampler) {
+	n := len(c.Class)
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := 0; i < n; i++ {
+		x := parts.Position(i)
+		for _, j := range search.GetSamples(i) {
+			if j < n && j != i && vector.Dist(parts.Position(j), x) < c.Params.Radius {
+				if a, b := find(i), find(j); a != b {
+					parent[a] = b
+				}
+			}
+		}
+	}
+
+	size := map[int]int{}
+	roots := []int{}
+	for i := 0; i < n; i++ {
+		r := find(i)
+		if size[r] == 0 {
+			roots = append(roots, r)
+		}
+		size[r]++
+	}
+	sort.SliceStable(roots, func(a int, b int) bool {
+		return size[roots[a]] > size[roots[b]]
+	})
+	labels := map[int]int{}
+	c.Sizes = make([]int, len(roots))
+	for l, r := range roots {
+		labels[r] = l
+		c.Sizes[l] = size[r]
+	}
+	c.Droplet = make([]int, n)
+	for i := 0; i < n; i++ {
+		c.Droplet[i] = labels[find(i)]
+	}
+}
@@ -0,0 +1,149 @@
+//Continuum mechanics on the SPH field. Particles whose phase maps to a Material
+//carry a Cauchy stress tensor which is integrated from the SPH velocity gradient
+//and applied through the symmetric stress divergence. Phases without a material
+//are fluid and contribute their equation of state pressure to neighbor sums
+package continuum
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model/field"
+)
+
+//Model selects materials by particle phase and holds the Monaghan artificial
+//stress parameters used to suppress the tensile instability of SPH solids
+type Model struct {
+	Materials map[int]Material
+	Epsilon   float32 //Artificial stress coefficient, 0 disables
+	Exponent  float32 //Exponent n on the kernel ratio W(r)/W(dp)
+	Spacing   float32 //Initial particle spacing dp
+}
+
+//New creates a model with the artificial stress parameters of Gray, Monaghan
+//and Swift 2001 (epsilon 0.3, n 4) for the given particle spacing
+func New(spacing float32) *Model {
+	return &Model{make(map[int]Material, 4), 0.3, 4.0, spacing}
+}
+
+//Add assigns material to particle phase
+func (m *Model) Add(phase int, material Material) {
+	m.Materials[phase] = material
+}
+
+//Material returns the material for phase or nil for fluid phases
+func (m *Model) Material(phase int) Material {
+	if mat, ok := m.Materials[phase]; ok && mat.Type() != MATERIAL_FLUID {
+		return mat
+	}
+	return nil
+}
+
+//Handles reports whether particle i is governed by a continuum material
+func (m *Model) Handles(f *field.SPHField, i int) bool {
+	return i < f.Particles.N() && m.Material(f.Particles.Phase(i)) != nil
+}
+
+//UpdateStress integrates the stress of every material particle over dt
+func (m *Model) UpdateStress(f *field.SPHField, dt float32) {
+	parts := f.Particles
+	d0 := f.D0()
+	next := make([]matrix.Mat, parts.N())
+	for i := 0; i < parts.N(); i++ {
+		mat := m.Material(parts.Phase(i))
+		if mat == nil {
+			continue
+		}
+		particle := parts.Get(i)
+		ratio := float32(1.0)
+		if d0 > 0 && particle.Density > 0 {
+			ratio = particle.Density / d0
+		}
+		next[i] = mat.Update(matrix.Mat(parts.Stress(i)), f.VelocityGradient(i), ratio, particle.Press, dt)
+	}
+	for i := range next {
+		if next[i] != nil {
+			parts.SetStress(i, next[i])
+		}
+	}
+}
+
+//stressAt returns the full stress of particle j, fluid and boundary particles
+//contribute an isotropic pressure stress
+func (m *Model) stressAt(f *field.SPHField, j int) matrix.Mat {
+	if m.Handles(f, j) {
+		return matrix.Mat(f.Particles.Stress(j))
+	}
+	stress := matrix.Mat3(0.0)
+	p := f.Particles.Get(j).Press
+	stress[0], stress[4], stress[8] = -p, -p, -p
+	return stress
+}
+
+//artificial returns the Monaghan artificial stress R = Q diag(r) Q^T where the
+//principal stresses of the diagonalization stress = Q diag(s) Q^T map to
+//r = -eps*s/rho^2 when tensile and to 0 otherwise
+func (m *Model) artificial(stress matrix.Mat, density float32) matrix.Mat {
+	r := matrix.Mat3(0.0)
+	if m.Epsilon == 0 || density == 0 {
+		return r
+	}
+	values, vectors := stress.SymEigen3()
+	for k := 0; k < 3; k++ {
+		if values[k] <= 0 {
+			continue
+		}
+		rk := -m.Epsilon * values[k] / (density * density)
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				r[a*3+b] += rk * vectors[a*3+k] * vectors[b*3+k]
+			}
+		}
+	}
+	return r
+}
+
+//Forces adds the stress divergence force m_i sum_j m_j (s_i/rho_i^2 + s_j/rho_j^2 + f^n R_ij) grad W_ij
+//to every material particle
+func (m *Model) Forces(f *field.SPHField) {
+	parts := f.Particles
+	kern := f.Kernel()
+	wdp := kern.F(m.Spacing)
+	for i := 0; i < parts.N(); i++ {
+		if !m.Handles(f, i) {
+			continue
+		}
+		particle := parts.Get(i)
+		si := m.stressAt(f, i)
+		ri := m.artificial(si, particle.Density)
+		rho2i := particle.Density * particle.Density
+		force := vector.Vec{0, 0, 0}
+		for _, j := range f.Neighbors(i) {
+			particle_j := parts.Get(j)
+			sj := m.stressAt(f, j)
+			rj := matrix.Mat3(0.0)
+			if m.Handles(f, j) {
+				rj = m.artificial(sj, particle_j.Density)
+			}
+			rho2j := particle_j.Density * particle_j.Density
+			dir := f.Separation(particle.Position[:], particle_j.Position[:])
+			dist := vector.Mag(dir)
+			gradW := kern.Grad(dist, vector.Norm(dir))
+			fn := float32(0.0)
+			if wdp > 0 {
+				fn = float32(math.Pow(float64(kern.F(dist)/wdp), float64(m.Exponent)))
+			}
+			mj := parts.ParticleMass(j)
+			for a := 0; a < 3; a++ {
+				sum := float32(0.0)
+				for b := 0; b < 3; b++ {
+					sum += (si[a*3+b]/rho2i + sj[a*3+b]/rho2j + fn*(ri[a*3+b]+rj[a*3+b])) * gradW[b]
+				}
+				force[a] += mj * sum
+			}
+		}
+		particle.AddForce(vector.CastFixed(vector.Scale(force, parts.ParticleMass(i))))
+		parts.Set(i, particle)
+	}
+}
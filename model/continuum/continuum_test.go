@@ -0,0 +1,97 @@
+package continuum
+
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+)
+
+//Simple shear velocity gradient du/dy = rate
+func shear(rate float32) matrix.Mat {
+	L := matrix.Mat3(0.0)
+	L[1] = rate
+	return L
+}
+
+func TestElasticShear(t *testing.T) {
+	mat := LinearElastic{Shear: 100.0, Bulk: 1000.0}
+	stress := mat.Update(matrix.Mat3(0.0), shear(2.0), 1.0, 0.0, 0.01)
+	//ds_xy = 2G D_xy dt = 2 * 100 * 1 * 0.01
+	if math.Abs(float64(stress[1]-2.0)) > 1e-4 || math.Abs(float64(stress[3]-2.0)) > 1e-4 {
+		t.Errorf("Elastic shear stress expected 2.0 got %f %f\n", stress[1], stress[3])
+	}
+	compressed := mat.Update(matrix.Mat3(0.0), matrix.Mat3(0.0), 1.01, 0.0, 0.01)
+	if compressed[0] >= 0 || compressed[0] != compressed[4] {
+		t.Errorf("Compression should produce isotropic pressure got %v\n", compressed)
+	}
+}
+
+func TestDruckerPragerReturnMap(t *testing.T) {
+	mat := DruckerPrager{Shear: 1000.0, Bulk: 5000.0, Friction: 0.5, Cohesion: 0.0}
+	stress := matrix.Mat3(0.0)
+	for step := 0; step < 100; step++ {
+		stress = mat.Update(stress, shear(10.0), 1.001, 0.0, 0.01)
+	}
+	tan := math.Tan(0.5)
+	alpha := float32(tan / math.Sqrt(9+12*tan*tan))
+	i1 := stress[0] + stress[4] + stress[8]
+	sqrtJ2 := float32(math.Sqrt(float64(SecondInvariant(Deviator(stress)))))
+	if sqrtJ2 > -alpha*i1+1e-3 {
+		t.Errorf("Stress outside the yield cone sqrt(J2) %f limit %f\n", sqrtJ2, -alpha*i1)
+	}
+
+	//Cohesionless sand carries no tension
+	tension := mat.Update(matrix.Mat3(0.0), shear(10.0), 0.9, 0.0, 0.01)
+	if tension[0]+tension[4]+tension[8] > 1e-5 {
+		t.Errorf("Granular material in tension %v\n", tension)
+	}
+}
+
+func TestViscoplasticYield(t *testing.T) {
+	mat := Viscoplastic{Yield: 5.0, Viscosity: 0.1, Regularization: 1000.0}
+	fast := mat.Update(matrix.Mat3(0.0), shear(100.0), 1.0, 0.0, 0.01)
+	//tau = eta*rate + yield for a well developed flow
+	if math.Abs(float64(fast[1]-15.0)) > 0.1 {
+		t.Errorf("Bingham shear stress expected 15.0 got %f\n", fast[1])
+	}
+	slow := mat.Update(matrix.Mat3(0.0), shear(0.0001), 1.0, 0.0, 0.01)
+	if slow[1] > 5.0 {
+		t.Errorf("Stress below yield exceeded the yield stress %f\n", slow[1])
+	}
+}
+
+func TestArtificialPrincipal(t *testing.T) {
+	m := New(0.1)
+	//Uniaxial tension T along (1,1,0)/sqrt(2) has the off diagonal components T/2
+	tension := matrix.Mat3(0.0)
+	tension[0], tension[1], tension[3], tension[4] = 5.0, 5.0, 5.0, 5.0
+	r := m.artificial(tension, 2.0)
+	expect := -m.Epsilon * 5.0 / 4.0
+	for _, k := range []int{0, 1, 3, 4} {
+		if math.Abs(float64(r[k]-expect)) > 1e-4 {
+			t.Errorf("Artificial stress expected %f at %d got %v\n", expect, k, r)
+		}
+	}
+	if math.Abs(float64(r[2]))+math.Abs(float64(r[5]))+math.Abs(float64(r[8])) > 1e-5 {
+		t.Errorf("Artificial stress outside the tension plane %v\n", r)
+	}
+
+	//Pure shear has principal stresses +5 and -5, only the tensile one is corrected
+	shear := matrix.Mat3(0.0)
+	shear[1], shear[3] = 5.0, 5.0
+	r = m.artificial(shear, 2.0)
+	for _, k := range []int{0, 1, 3, 4} {
+		if math.Abs(float64(r[k]-expect/2)) > 1e-4 {
+			t.Errorf("Shear artificial stress expected %f at %d got %v\n", expect/2, k, r)
+		}
+	}
+
+	compression := matrix.Mat3(0.0)
+	compression[0], compression[4], compression[8] = -5.0, -5.0, -5.0
+	for k, v := range m.artificial(compression, 2.0) {
+		if v != 0 {
+			t.Errorf("Compression should carry no artificial stress at %d got %f\n", k, v)
+		}
+	}
+}
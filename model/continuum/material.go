@@ -0,0 +1,162 @@
+package continuum
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+)
+
+//Material Type Enums
+const MATERIAL_FLUID = 0
+const MATERIAL_ELASTIC = 1
+const MATERIAL_GRANULAR = 2
+const MATERIAL_VISCOPLASTIC = 3
+
+//Material integrates a particle Cauchy stress (tension positive, row major) over
+//a time step given the velocity gradient L, the density ratio rho/rho0 and the
+//equation of state pressure of the particle
+type Material interface {
+	Type() int
+	Update(stress matrix.Mat, L matrix.Mat, ratio float32, pressure float32, dt float32) matrix.Mat
+}
+
+//LinearElastic hypoelastic solid with Jaumann stress rate
+type LinearElastic struct {
+	Shear float32 //Shear modulus G
+	Bulk  float32 //Bulk modulus K
+}
+
+//DruckerPrager elastic perfectly plastic granular model with a tension cut off
+//following Bui et al. 2008
+type DruckerPrager struct {
+	Shear    float32 //Shear modulus G
+	Bulk     float32 //Bulk modulus K
+	Friction float32 //Internal friction angle (radians)
+	Cohesion float32 //Cohesion c
+}
+
+//Viscoplastic regularized Bingham (Papanastasiou) material which flows like a
+//viscous fluid above the yield stress and stiffens below it
+type Viscoplastic struct {
+	Yield          float32 //Yield stress
+	Viscosity      float32 //Plastic viscosity
+	Regularization float32 //Papanastasiou exponent m
+}
+
+func (m LinearElastic) Type() int { return MATERIAL_ELASTIC }
+func (m DruckerPrager) Type() int { return MATERIAL_GRANULAR }
+func (m Viscoplastic) Type() int  { return MATERIAL_VISCOPLASTIC }
+
+//Update integrates the deviatoric stress with the Jaumann rate and sets the
+//isotropic part from the bulk modulus and compression
+func (m LinearElastic) Update(stress matrix.Mat, L matrix.Mat, ratio float32, pressure float32, dt float32) matrix.Mat {
+	dev := jaumann(Deviator(stress), L, m.Shear, dt)
+	return isotropic(dev, m.Bulk*(ratio-1))
+}
+
+//Update performs an elastic predictor followed by a return map onto the Drucker
+//Prager cone. Tensile states beyond the apex are cut off at the apex
+func (m DruckerPrager) Update(stress matrix.Mat, L matrix.Mat, ratio float32, pressure float32, dt float32) matrix.Mat {
+	dev := jaumann(Deviator(stress), L, m.Shear, dt)
+	p := m.Bulk * (ratio - 1)
+	tan := float64(math.Tan(float64(m.Friction)))
+	denom := float32(math.Sqrt(9 + 12*tan*tan))
+	alpha := float32(tan) / denom
+	k := 3 * m.Cohesion / denom
+
+	//Tension cracking treatment - I1 may not exceed the cone apex
+	i1 := -3 * p
+	if alpha > 0 && i1 > k/alpha {
+		i1 = k / alpha
+		p = -i1 / 3
+	}
+
+	j2 := SecondInvariant(dev)
+	limit := k - alpha*i1
+	if sqrtJ2 := float32(math.Sqrt(float64(j2))); sqrtJ2 > limit && sqrtJ2 > 0 {
+		dev = dev.Mul(limit / sqrtJ2)
+	}
+	return isotropic(dev, p)
+}
+
+//Update evaluates the regularized Bingham viscous stress from the strain rate and
+//takes the isotropic part from the equation of state pressure
+func (m Viscoplastic) Update(stress matrix.Mat, L matrix.Mat, ratio float32, pressure float32, dt float32) matrix.Mat {
+	D := Deviator(StrainRate(L))
+	rate := float32(math.Sqrt(float64(2 * Contract(D, D))))
+	eta := m.Viscosity
+	if rate > 0 {
+		eta += m.Yield * (1 - float32(math.Exp(float64(-m.Regularization*rate)))) / rate
+	} else {
+		eta += m.Yield * m.Regularization
+	}
+	return isotropic(D.Mul(2*eta), pressure)
+}
+
+//StrainRate returns the symmetric part of the velocity gradient
+func StrainRate(L matrix.Mat) matrix.Mat {
+	D := matrix.Mat3(0.0)
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			D[a*3+b] = 0.5 * (L[a*3+b] + L[b*3+a])
+		}
+	}
+	return D
+}
+
+//Spin returns the antisymmetric part of the velocity gradient
+func Spin(L matrix.Mat) matrix.Mat {
+	W := matrix.Mat3(0.0)
+	for a := 0; a < 3; a++ {
+		for b := 0; b < 3; b++ {
+			W[a*3+b] = 0.5 * (L[a*3+b] - L[b*3+a])
+		}
+	}
+	return W
+}
+
+//Deviator removes the isotropic part of a tensor
+func Deviator(m matrix.Mat) matrix.Mat {
+	dev := m.Copy()
+	tr := (m[0] + m[4] + m[8]) / 3
+	dev[0] -= tr
+	dev[4] -= tr
+	dev[8] -= tr
+	return dev
+}
+
+//Contract returns the double contraction A:B
+func Contract(a matrix.Mat, b matrix.Mat) float32 {
+	sum := float32(0.0)
+	for i := 0; i < 9; i++ {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+//SecondInvariant returns J2 = s:s/2 of a deviatoric tensor
+func SecondInvariant(dev matrix.Mat) float32 {
+	return 0.5 * Contract(dev, dev)
+}
+
+//jaumann advances the deviatoric stress s by ds/dt = 2G D' + s W^T + W s
+func jaumann(s matrix.Mat, L matrix.Mat, shear float32, dt float32) matrix.Mat {
+	D := Deviator(StrainRate(L))
+	W := Spin(L)
+	rot := matrix.MulM(s, W.Transpose())
+	rot2 := matrix.MulM(W, s)
+	next := matrix.Mat3(0.0)
+	for i := 0; i < 9; i++ {
+		next[i] = s[i] + dt*(2*shear*D[i]+rot[i]+rot2[i])
+	}
+	return next
+}
+
+//isotropic combines a deviatoric stress with a pressure into a Cauchy stress
+func isotropic(dev matrix.Mat, pressure float32) matrix.Mat {
+	stress := dev.Copy()
+	stress[0] -= pressure
+	stress[4] -= pressure
+	stress[8] -= pressure
+	return stress
+}
@@ -0,0 +1,234 @@
+//Conservation and stability diagnostics for SPH runs. A Monitor computes a
+//Record of the global fluid state after each step, writes it as a CSV row or a
+//JSON line and reports a BlowUp when the state turns non finite or the kinetic
+//energy spikes so runs can stop early with a clear report
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Output Format Enums
+const FORMAT_CSV = 0
+const FORMAT_JSON = 1
+
+//Record holds the global fluid state after a step. Energies and momenta are
+//summed over the fluid particles, boundary particles only enter the minimum
+//distance and the neighbor counts. Without neighbors the minimum distance is the
+//kernel length
+type Record struct {
+	Step             int        `json:"step"`
+	Time             float32    `json:"time"`
+	Dt               float32    `json:"dt"`
+	Particles        int        `json:"particles"`
+	Mass             float64    `json:"mass"`
+	Momentum         [3]float64 `json:"momentum"`
+	AngularMomentum  [3]float64 `json:"angular_momentum"`
+	Kinetic          float64    `json:"kinetic"`
+	Potential        float64    `json:"potential"`
+	MaxVelocity      float32    `json:"max_velocity"`
+	DensityErrorMean float64    `json:"density_error_mean"`
+	DensityErrorMax  float64    `json:"density_error_max"`
+	MinDistance      float32    `json:"min_distance"`
+	Neighbors        []int      `json:"neighbors"`  //Histogram of fluid neighbor counts
+	NonFinite        int        `json:"non_finite"` //Particles with NaN or Inf state
+}
+
+//Energy returns the total kinetic and gravitational potential energy
+func (r *Record) Energy() float64 {
+	return r.Kinetic + r.Potential
+}
+
+//Compute evaluates the diagnostics of the current particle state. Neighbor counts
+//are bucketed into bins of width bin, at least one, with the last bucket
+//collecting the overflow. Potential energy is measured against the origin along
+//the active gravity
+func Compute(core *sph.SPH, step int, bin int, bins int) Record {
+	if bin < 1 {
+		bin = 1
+	}
+	parts := core.Particles()
+	fld := core.Field()
+	n := parts.N()
+	d0 := float64(parts.D0())
+	g := core.Gravity()
+	r := Record{Step: step, Time: core.Elapsed(), Dt: core.Time(), Particles: n}
+	r.Neighbors = make([]int, bins)
+	r.MinDistance = float32(math.Inf(1))
+
+	for i := 0; i < n; i++ {
+		pos := parts.Position(i)
+		vel := parts.Velocity(i)
+		rho := parts.Density(i)
+		if !finite(pos) || !finite(vel) || !finite([]float32{rho}) {
+			r.NonFinite++
+			continue
+		}
+		m := float64(parts.ParticleMass(i))
+		r.Mass += m
+		mom := vector.Cross(pos, vel)
+		v2 := float64(0)
+		for k := 0; k < 3; k++ {
+			r.Momentum[k] += m * float64(vel[k])
+			r.AngularMomentum[k] += m * float64(mom[k])
+			v2 += float64(vel[k]) * float64(vel[k])
+			r.Potential -= m * float64(g[k]) * float64(pos[k])
+		}
+		r.Kinetic += 0.5 * m * v2
+		if speed := float32(math.Sqrt(v2)); speed > r.MaxVelocity {
+			r.MaxVelocity = speed
+		}
+		err := math.Abs(float64(rho)-d0) / d0
+		r.DensityErrorMean += err
+		if err > r.DensityErrorMax {
+			r.DensityErrorMax = err
+		}
+
+		neighbors := fld.Neighbors(i)
+		for _, j := range neighbors {
+			if dist := vector.Mag(fld.Separation(pos, parts.Position(j))); dist < r.MinDistance {
+				r.MinDistance = dist
+			}
+		}
+		if bins > 0 {
+			b := len(neighbors) / bin
+			if b >= bins {
+				b = bins - 1
+			}
+			r.Neighbors[b]++
+		}
+	}
+	if math.IsInf(float64(r.MinDistance), 1) {
+		r.MinDistance = fld.GetKernelLength()
+	}
+	if valid := n - r.NonFinite; valid > 0 {
+		r.DensityErrorMean /= float64(valid)
+	}
+	return r
+}
+
+func finite(x []float32) bool {
+	for _, v := range x {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return false
+		}
+	}
+	return true
+}
+
+//BlowUp reports the step at which a run became unstable with the offending
+//record and the last healthy record
+type BlowUp struct {
+	Reason string
+	Record Record
+	Last   Record
+}
+
+func (b *BlowUp) Error() string {
+	return fmt.Sprintf("Simulation blow up at step %d time %.6f: %s\n"+
+		"  kinetic %.6g (previous %.6g) max velocity %.6g (previous %.6g)\n"+
+		"  density error mean %.4g max %.4g min distance %.6g non finite %d",
+		b.Record.Step, b.Record.Time, b.Reason,
+		b.Record.Kinetic, b.Last.Kinetic, b.Record.MaxVelocity, b.Last.MaxVelocity,
+		b.Record.DensityErrorMean, b.Record.DensityErrorMax, b.Record.MinDistance, b.Record.NonFinite)
+}
+
+//Monitor computes, writes and checks a record per step. A kinetic energy above
+//SpikeFactor times the previous value, offset by the potential energy scale
+//M |g| H0 so a fluid starting at rest does not trigger, is reported as a spike
+type Monitor struct {
+	Output      io.Writer //Record sink - nil disables output
+	Format      int
+	NeighborBin int //Width of a neighbor histogram bin
+	Bins        int //Number of neighbor histogram bins
+	SpikeFactor float64
+	MaxDensity  float64 //Maximum density error before blow up - 0 disables
+
+	step   int
+	last   *Record
+	header bool
+}
+
+//NewMonitor returns a monitor writing records to w in the given format
+func NewMonitor(w io.Writer, format int) *Monitor {
+	return &Monitor{Output: w, Format: format, NeighborBin: 8, Bins: 12, SpikeFactor: 10}
+}
+
+//Last returns the last recorded state or nil before the first record
+func (m *Monitor) Last() *Record {
+	return m.last
+}
+
+//Record computes and writes the diagnostics of the current step and returns a
+//*BlowUp error when the run has become unstable
+func (m *Monitor) Record(core *sph.SPH) (Record, error) {
+	rec := Compute(core, m.step, m.NeighborBin, m.Bins)
+	m.step++
+	reason := m.check(core, &rec)
+	err := m.write(&rec)
+	if reason != "" {
+		last := Record{}
+		if m.last != nil {
+			last = *m.last
+		}
+		return rec, &BlowUp{reason, rec, last}
+	}
+	m.last = &rec
+	return rec, err
+}
+
+func (m *Monitor) check(core *sph.SPH, rec *Record) string {
+	if rec.NonFinite > 0 || math.IsNaN(rec.Energy()) || math.IsInf(rec.Energy(), 0) {
+		return fmt.Sprintf("%d particles with non finite state", rec.NonFinite)
+	}
+	if m.MaxDensity > 0 && rec.DensityErrorMax > m.MaxDensity {
+		return fmt.Sprintf("density error %.4g exceeds %.4g", rec.DensityErrorMax, m.MaxDensity)
+	}
+	if m.last != nil && m.SpikeFactor > 0 {
+		scale := rec.Mass * float64(vector.Mag(core.Gravity())) * float64(core.Field().GetKernelLength())
+		if rec.Kinetic > m.SpikeFactor*(m.last.Kinetic+scale) {
+			return fmt.Sprintf("kinetic energy spike %.6g from %.6g", rec.Kinetic, m.last.Kinetic)
+		}
+	}
+	return ""
+}
+
+func (m *Monitor) write(rec *Record) error {
+	if m.Output == nil {
+		return nil
+	}
+	if m.Format == FORMAT_JSON {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = m.Output.Write(append(line, '\n'))
+		return err
+	}
+	if !m.header {
+		m.header = true
+		header := "step,time,dt,particles,mass,px,py,pz,lx,ly,lz,kinetic,potential,energy," +
+			"max_velocity,density_error_mean,density_error_max,min_distance,non_finite,neighbors\n"
+		if _, err := io.WriteString(m.Output, header); err != nil {
+			return err
+		}
+	}
+	hist := make([]string, len(rec.Neighbors))
+	for i, c := range rec.Neighbors {
+		hist[i] = fmt.Sprint(c)
+	}
+	_, err := fmt.Fprintf(m.Output, "%d,%g,%g,%d,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g,%g,%d,%s\n",
+		rec.Step, rec.Time, rec.Dt, rec.Particles, rec.Mass,
+		rec.Momentum[0], rec.Momentum[1], rec.Momentum[2],
+		rec.AngularMomentum[0], rec.AngularMomentum[1], rec.AngularMomentum[2],
+		rec.Kinetic, rec.Potential, rec.Energy(), rec.MaxVelocity,
+		rec.DensityErrorMean, rec.DensityErrorMax, rec.MinDistance, rec.NonFinite, strings.Join(hist, ";"))
+	return err
+}
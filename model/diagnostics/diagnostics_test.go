@@ -0,0 +1,90 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//A cubic block of particles translating with velocity vel
+func block(vel [3]float32) sph.SPH {
+	const dx = float32(0.05)
+	const side = 4
+	parts := model.NewLatticeArray(model.Lattice([3]float32{0, 0, 0}, [3]int{side, side, side}, dx), dx)
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		particle.Velocity = vel
+		parts.Set(i, particle)
+	}
+	core := sph.New(&parts, 2*dx)
+	core.NN()
+	return core
+}
+
+func TestRecord(t *testing.T) {
+	core := block([3]float32{2, 0, 0})
+	rec := Compute(&core, 0, 8, 12)
+	mass := float64(core.Particles().TotalMass())
+	if math.Abs(rec.Mass-mass) > 1e-6*mass || math.Abs(rec.Momentum[0]-2*mass) > 1e-5*mass {
+		t.Errorf("Mass %f momentum %v expected %f\n", rec.Mass, rec.Momentum, mass)
+	}
+	if math.Abs(rec.Kinetic-2*mass) > 1e-5*mass || rec.DensityErrorMax > 1e-6 {
+		t.Errorf("Kinetic energy %f density error %f\n", rec.Kinetic, rec.DensityErrorMax)
+	}
+	if math.Abs(float64(rec.MinDistance)-0.05) > 1e-5 {
+		t.Errorf("Minimum distance expected 0.05 got %f\n", rec.MinDistance)
+	}
+	total := 0
+	for _, c := range rec.Neighbors {
+		total += c
+	}
+	if total != rec.Particles {
+		t.Errorf("Neighbor histogram counts %d of %d particles\n", total, rec.Particles)
+	}
+	//A monitor literal without a bin width counts single neighbors
+	if zero, err := (&Monitor{Bins: 4}).Record(&core); err != nil || zero.Neighbors[3] != rec.Particles {
+		t.Errorf("Monitor without bin width %v %v\n", zero.Neighbors, err)
+	}
+
+	var buf bytes.Buffer
+	monitor := NewMonitor(&buf, FORMAT_JSON)
+	monitor.Record(&core)
+	decoded := Record{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Particles != rec.Particles {
+		t.Errorf("JSON record %s: %v\n", buf.String(), err)
+	}
+	buf.Reset()
+	monitor = NewMonitor(&buf, FORMAT_CSV)
+	monitor.Record(&core)
+	monitor.Record(&core)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || len(strings.Split(lines[0], ",")) != len(strings.Split(lines[2], ",")) {
+		t.Errorf("CSV records\n%s\n", buf.String())
+	}
+}
+
+func TestBlowUp(t *testing.T) {
+	core := block([3]float32{0, 0, 0})
+	monitor := NewMonitor(nil, FORMAT_CSV)
+	if _, err := monitor.Record(&core); err != nil {
+		t.Errorf("Fluid at rest reported %v\n", err)
+	}
+	parts := core.Particles()
+	particle := parts.Get(3)
+	particle.Velocity = [3]float32{50, 0, 0}
+	parts.Set(3, particle)
+	if _, err := monitor.Record(&core); err == nil {
+		t.Errorf("Kinetic energy spike not detected\n")
+	}
+	particle.Velocity = [3]float32{float32(math.NaN()), 0, 0}
+	parts.Set(3, particle)
+	_, err := monitor.Record(&core)
+	if blowup, ok := err.(*BlowUp); !ok || blowup.Record.NonFinite != 1 {
+		t.Errorf("Non finite particle not detected %v\n", err)
+	}
+}
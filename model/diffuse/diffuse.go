@@ -0,0 +1,291 @@
+//Diffuse secondary particles following Ihmsen et al. 2012, Unified Spray, Foam
+//and Bubbles for Particle-Based Fluids. Diffuse particles are generated from the
+//trapped air potential, wave crest curvature and kinetic energy of the SPH fluid
+//and do not feed back into the fluid. They are classified by the number of fluid
+//neighbors into spray, foam and bubbles which each advect differently
+package diffuse
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model/field"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Diffuse Type Enums, 0 marks fluid particles in exports
+const DIFFUSE_FLUID = 0
+const DIFFUSE_SPRAY = 1
+const DIFFUSE_FOAM = 2
+const DIFFUSE_BUBBLE = 3
+
+type Particle struct {
+	Position [3]float32
+	Velocity [3]float32
+	Lifetime float32 //Remaining foam lifetime in seconds
+	Type     int
+}
+
+//Params holds the generation thresholds and the diffuse particle dynamics. Each
+//potential is clamped to its [Min, Max] range and mapped to [0,1]
+type Params struct {
+	TrappedAirMin   float32
+	TrappedAirMax   float32
+	WaveCrestMin    float32
+	WaveCrestMax    float32
+	EnergyMin       float32
+	EnergyMax       float32
+	TrappedAirRate  float32 //Diffuse particles per second at full trapped air potential
+	WaveCrestRate   float32 //Diffuse particles per second at full wave crest potential
+	SprayNeighbors  int     //Fewer fluid neighbors than this is spray
+	BubbleNeighbors int     //More fluid neighbors than this is a bubble
+	Buoyancy        float32 //Bubble buoyancy k_b relative to gravity
+	Drag            float32 //Bubble drag k_d toward the fluid velocity
+	Lifetime        float32 //Foam lifetime in seconds
+	MaxParticles    int     //Diffuse particle budget, 0 is unbounded
+}
+
+//DefaultParams returns the thresholds suggested by Ihmsen et al. 2012
+func DefaultParams() Params {
+	return Params{
+		TrappedAirMin:   5,
+		TrappedAirMax:   20,
+		WaveCrestMin:    2,
+		WaveCrestMax:    8,
+		EnergyMin:       5,
+		EnergyMax:       50,
+		TrappedAirRate:  2000,
+		WaveCrestRate:   2000,
+		SprayNeighbors:  6,
+		BubbleNeighbors: 20,
+		Buoyancy:        2.0,
+		Drag:            0.5,
+		Lifetime:        2.0,
+		MaxParticles:    0,
+	}
+}
+
+type System struct {
+	Params    Params
+	Particles []Particle
+	rng       *rand.Rand
+}
+
+func New(params Params, seed int64) *System {
+	return &System{params, make([]Particle, 0, 1024), rand.New(rand.NewSource(seed))}
+}
+
+//clamp maps x to [0,1] over the range [min, max]
+func clamp(x float32, min float32, max float32) float32 {
+	if max <= min {
+		return 0
+	}
+	return float32(math.Min(float64(x), float64(max))-math.Min(float64(x), float64(min))) / (max - min)
+}
+
+//Potentials holds the unclamped generation potentials of a fluid particle
+type Potentials struct {
+	TrappedAir float32
+	WaveCrest  float32
+	Energy     float32
+}
+
+//Potentials computes the trapped air, wave crest and kinetic energy potentials
+//of every fluid particle in the SPH system. Neighbor lists must be current
+func (s *System) Potentials(core *sph.SPH) []Potentials {
+	f := core.Field()
+	parts := f.Particles
+	n := parts.N()
+	h := f.GetKernelLength()
+	normals := surfaceNormals(f)
+	pots := make([]Potentials, n)
+	for i := 0; i < n; i++ {
+		particle := parts.Get(i)
+		vi := vector.Vec(particle.Velocity[:])
+		ni := normals[i]
+		crest := vector.Mag(ni) > 0 && vector.Dot(vector.Norm(vi), ni) >= 0.6
+		for _, j := range f.Neighbors(i) {
+			if j >= n {
+				continue
+			}
+			particle_j := parts.Get(j)
+			xji := f.Separation(particle.Position[:], particle_j.Position[:])
+			dist := vector.Mag(xji)
+			weight := 1 - dist/h
+			vij := vector.Sub(vi, particle_j.Velocity[:])
+			if vector.Mag(vij) > 0 && dist > 0 {
+				//x_ij = -x_ji so approaching particles weigh 1 - cos(pi)
+				pots[i].TrappedAir += vector.Mag(vij) * (1 + vector.Dot(vector.Norm(vij), vector.Norm(xji))) * weight
+			}
+			if crest && dist > 0 && vector.Dot(vector.Norm(xji), ni) < 0 && vector.Mag(normals[j]) > 0 {
+				pots[i].WaveCrest += (1 - vector.Dot(ni, normals[j])) * weight
+			}
+		}
+		pots[i].Energy = 0.5 * parts.ParticleMass(i) * vector.Dot(vi, vi)
+	}
+	return pots
+}
+
+//surfaceNormals returns the unit outward normal -sum_j V_j grad W_ij of fluid
+//particles near the free surface. Interior particles where the color gradient
+//is small relative to the kernel support get a zero normal
+func surfaceNormals(f *field.SPHField) []vector.Vec {
+	parts := f.Particles
+	kern := f.Kernel()
+	h := f.GetKernelLength()
+	normals := make([]vector.Vec, parts.N())
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		grad := vector.Vec{0, 0, 0}
+		for _, j := range f.Neighbors(i) {
+			particle_j := parts.Get(j)
+			if particle_j.Density == 0 {
+				continue
+			}
+			dir := f.Separation(particle.Position[:], particle_j.Position[:])
+			vol := parts.ParticleMass(j) / particle_j.Density
+			grad = vector.Add(grad, vector.Scale(kern.Grad(vector.Mag(dir), vector.Norm(dir)), -vol))
+		}
+		normals[i] = vector.Vec{0, 0, 0}
+		if vector.Mag(grad)*h > 0.5 {
+			normals[i] = vector.Norm(grad)
+		}
+	}
+	return normals
+}
+
+//Update advects the existing diffuse particles through the current fluid state,
+//removes dissolved foam and emits new diffuse particles. Call after each SPH step
+//so the neighbor sampler is current. Returns the generated and removed counts
+func (s *System) Update(core *sph.SPH) (int, int) {
+	removed := s.advect(core)
+	generated := s.generate(core)
+	return generated, removed
+}
+
+func (s *System) generate(core *sph.SPH) int {
+	f := core.Field()
+	parts := f.Particles
+	dt := core.Time()
+	h := f.GetKernelLength()
+	pots := s.Potentials(core)
+	count := 0
+	for i := range pots {
+		energy := clamp(pots[i].Energy, s.Params.EnergyMin, s.Params.EnergyMax)
+		rate := s.Params.TrappedAirRate*clamp(pots[i].TrappedAir, s.Params.TrappedAirMin, s.Params.TrappedAirMax) +
+			s.Params.WaveCrestRate*clamp(pots[i].WaveCrest, s.Params.WaveCrestMin, s.Params.WaveCrestMax)
+		emit := int(energy*rate*dt + s.rng.Float32())
+		if emit == 0 {
+			continue
+		}
+		particle := parts.Get(i)
+		v := vector.Vec(particle.Velocity[:])
+		axis := vector.Norm(v)
+		e1, e2 := basis(axis)
+		for k := 0; k < emit; k++ {
+			if s.Params.MaxParticles > 0 && len(s.Particles) >= s.Params.MaxParticles {
+				return count
+			}
+			//Uniform sample in the cylinder swept by the fluid particle over dt
+			r := 0.5 * h * float32(math.Sqrt(float64(s.rng.Float32())))
+			theta := 2 * math.Pi * s.rng.Float64()
+			offset := vector.Add(vector.Scale(e1, r*float32(math.Cos(theta))), vector.Scale(e2, r*float32(math.Sin(theta))))
+			along := vector.Scale(axis, s.rng.Float32()*vector.Mag(v)*dt)
+			d := Particle{Type: DIFFUSE_SPRAY, Lifetime: s.Params.Lifetime}
+			d.Position = vector.CastFixed(vector.Add(vector.Add(particle.Position[:], offset), along))
+			d.Velocity = vector.CastFixed(vector.Add(v, offset))
+			s.Particles = append(s.Particles, d)
+			count++
+		}
+	}
+	return count
+}
+
+//basis returns two unit vectors orthogonal to the unit vector axis
+func basis(axis vector.Vec) (vector.Vec, vector.Vec) {
+	ref := vector.Vec{1, 0, 0}
+	if math.Abs(float64(axis[0])) > 0.9 {
+		ref = vector.Vec{0, 1, 0}
+	}
+	e1 := vector.Norm(vector.Cross(axis, ref))
+	e2 := vector.Cross(axis, e1)
+	return e1, e2
+}
+
+//advect classifies each diffuse particle by its fluid neighbor count. Spray is
+//ballistic, foam moves with the averaged fluid velocity and dissolves over its
+//lifetime, bubbles rise against gravity and are dragged toward the fluid
+func (s *System) advect(core *sph.SPH) int {
+	f := core.Field()
+	parts := f.Particles
+	kern := f.Kernel()
+	h := f.GetKernelLength()
+	dt := core.Time()
+	domain := core.Domain()
+	gravity := core.Gravity()
+	kept := s.Particles[:0]
+	for _, d := range s.Particles {
+		neighbors := 0
+		weight := float32(0.0)
+		fluid := vector.Vec{0, 0, 0}
+		for _, j := range f.Neighborhood().GetSamplesFromPosition(d.Position[:]) {
+			if j >= parts.N() {
+				continue
+			}
+			dist := vector.Mag(f.Separation(d.Position[:], parts.Position(j)))
+			if dist >= h {
+				continue
+			}
+			neighbors++
+			w := kern.F(dist)
+			weight += w
+			fluid = vector.Add(fluid, vector.Scale(parts.Velocity(j), w))
+		}
+		if weight > 0 {
+			fluid = vector.Scale(fluid, 1/weight)
+		}
+
+		v := vector.Vec(d.Velocity[:])
+		switch {
+		case neighbors < s.Params.SprayNeighbors:
+			d.Type = DIFFUSE_SPRAY
+			v = vector.Add(v, vector.Scale(gravity, dt))
+		case neighbors > s.Params.BubbleNeighbors:
+			d.Type = DIFFUSE_BUBBLE
+			v = vector.Add(v, vector.Scale(gravity, -s.Params.Buoyancy*dt))
+			v = vector.Add(v, vector.Scale(vector.Sub(fluid, v), s.Params.Drag))
+		default:
+			d.Type = DIFFUSE_FOAM
+			v = fluid
+			d.Lifetime -= dt
+		}
+		d.Velocity = vector.CastFixed(v)
+		pos := vector.Add(d.Position[:], vector.Scale(v, dt))
+		if domain != nil {
+			domain.Wrap(pos)
+			if !domain.Contains(pos) {
+				continue
+			}
+		}
+		d.Position = vector.CastFixed(pos)
+		if d.Lifetime <= 0 {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	removed := len(s.Particles) - len(kept)
+	s.Particles = kept
+	return removed
+}
+
+//Count returns the number of diffuse particles of type t
+func (s *System) Count(t int) int {
+	count := 0
+	for _, d := range s.Particles {
+		if d.Type == t {
+			count++
+		}
+	}
+	return count
+}
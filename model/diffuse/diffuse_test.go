@@ -0,0 +1,57 @@
+package diffuse
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Two fluid blocks with the given velocity along x moving toward each other
+func collision(speed float32) sph.SPH {
+	const dx = float32(0.05)
+	const side = 6
+	left := model.Lattice([3]float32{-(side - 0.5) * dx, 0, 0}, [3]int{side, side, side}, dx)
+	right := model.Lattice([3]float32{0.5 * dx, 0, 0}, [3]int{side, side, side}, dx)
+	parts := model.NewLatticeArray(append(left, right...), dx)
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		particle.Velocity = [3]float32{speed, 0, 0}
+		if particle.Position[0] > 0 {
+			particle.Velocity[0] = -speed
+		}
+		parts.Set(i, particle)
+	}
+	return sph.New(&parts, 2*dx)
+}
+
+func TestGeneration(t *testing.T) {
+	calm := collision(0)
+	s := New(DefaultParams(), 1)
+	if generated, _ := s.Update(&calm); generated != 0 {
+		t.Errorf("Fluid at rest generated %d diffuse particles\n", generated)
+	}
+
+	splash := collision(12)
+	if generated, _ := s.Update(&splash); generated == 0 {
+		t.Errorf("Colliding fluid generated no diffuse particles\n")
+	}
+
+	//An isolated particle is spray and falls ballistically
+	s.Particles = []Particle{{Position: [3]float32{10, 10, 10}, Lifetime: 1}}
+	s.Update(&calm)
+	if s.Particles[0].Type != DIFFUSE_SPRAY || s.Particles[0].Velocity[1] >= 0 {
+		t.Errorf("Isolated diffuse particle should fall as spray %v\n", s.Particles[0])
+	}
+
+	file := filepath.Join(t.TempDir(), "diffuse.ply")
+	if err := s.ExportPLY(file, calm.Particles()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.Size() < int64(25*(calm.Particles().N()+len(s.Particles))) {
+		t.Errorf("PLY export is truncated\n")
+	}
+}
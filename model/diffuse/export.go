@@ -0,0 +1,55 @@
+package diffuse
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/andewx/dieselfluid/model"
+)
+
+//ExportPLY writes the fluid particles of parts followed by the diffuse particles
+//as a binary little endian PLY point cloud with position, velocity and a type
+//property using the DIFFUSE type enums
+func (s *System) ExportPLY(filename string, parts *model.ParticleArray) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	n := 0
+	if parts != nil {
+		n = parts.N()
+	}
+	fmt.Fprintf(w, "ply\nformat binary_little_endian 1.0\ncomment dieselfluid diffuse particles\n")
+	fmt.Fprintf(w, "element vertex %d\n", n+len(s.Particles))
+	fmt.Fprintf(w, "property float x\nproperty float y\nproperty float z\n")
+	fmt.Fprintf(w, "property float vx\nproperty float vy\nproperty float vz\n")
+	fmt.Fprintf(w, "property uchar type\nend_header\n")
+
+	buf := make([]byte, 25)
+	put := func(pos []float32, vel []float32, t int) error {
+		for k := 0; k < 3; k++ {
+			binary.LittleEndian.PutUint32(buf[k*4:], math.Float32bits(pos[k]))
+			binary.LittleEndian.PutUint32(buf[12+k*4:], math.Float32bits(vel[k]))
+		}
+		buf[24] = byte(t)
+		_, err := w.Write(buf)
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := put(parts.Position(i), parts.Velocity(i), DIFFUSE_FLUID); err != nil {
+			return err
+		}
+	}
+	for _, d := range s.Particles {
+		if err := put(d.Position[:], d.Velocity[:], d.Type); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
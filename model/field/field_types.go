@@ -1,6 +1,8 @@
 package field
 
 import (
+	"math"
+
 	"github.com/andewx/dieselfluid/model"
 )
 
@@ -17,7 +19,7 @@ type TensorField interface {
 //DENSITY
 
 type DensityField struct {
-	Ref model.ParticleArray
+	Ref *model.ParticleArray
 }
 
 func (p DensityField) Value(i int) float32 {
@@ -33,12 +35,13 @@ func (p DensityField) Set(x float32, i int) {
 //PRESSURE
 
 type PressureField struct {
-	Ref model.ParticleArray
+	Ref *model.ParticleArray
 }
 
+//Value returns the stored particle pressure, see SPH PressureAll() for the
+//equation of state evaluation
 func (p PressureField) Value(i int) float32 {
-	particle := p.Ref.Get(i)
-	return particle.Pressure(model.FLUID_DENSITY, 0.0)
+	return p.Ref.Get(i).Press
 }
 
 func (p PressureField) Set(x float32, i int) {
@@ -47,10 +50,24 @@ func (p PressureField) Set(x float32, i int) {
 	p.Ref.Set(i, particle)
 }
 
+//TEMPERATURE
+
+type TemperatureField struct {
+	Ref *model.ParticleArray
+}
+
+func (p TemperatureField) Value(i int) float32 {
+	return p.Ref.Temperature(i)
+}
+
+func (p TemperatureField) Set(x float32, i int) {
+	p.Ref.SetTemperature(i, x)
+}
+
 //FORCE
 
 type ForceField struct {
-	Ref model.ParticleArray
+	Ref *model.ParticleArray
 }
 
 func (p ForceField) Value(i int) []float32 {
@@ -67,7 +84,7 @@ func (p ForceField) Set(x []float32, i int) {
 }
 
 type VelocityField struct {
-	Ref model.ParticleArray
+	Ref *model.ParticleArray
 }
 
 func (p VelocityField) Value(i int) []float32 {
@@ -111,3 +128,42 @@ func (p Vector3Field) Set(x []float32, i int) {
 	p.Values[i][1] = x[1]
 	p.Values[i][2] = x[2]
 }
+
+//SpeedField is the particle velocity magnitude
+type SpeedField struct {
+	Ref *model.ParticleArray
+}
+
+func (p SpeedField) Value(i int) float32 {
+	v := p.Ref.Velocity(i)
+	return float32(math.Sqrt(float64(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])))
+}
+
+func (p SpeedField) Set(x float32, i int) {}
+
+//FluidField is 1 on fluid particles and 0 on boundary particles so that its
+//interpolant is the fluid volume fraction
+type FluidField struct {
+	Ref *model.ParticleArray
+}
+
+func (p FluidField) Value(i int) float32 {
+	if i < p.Ref.N() {
+		return 1
+	}
+	return 0
+}
+
+func (p FluidField) Set(x float32, i int) {}
+
+//ConstantField has the same value on every particle. Its interpolant with a
+//value of 1 is the kernel sum used for Shepard normalization
+type ConstantField struct {
+	X float32
+}
+
+func (p ConstantField) Value(i int) float32 {
+	return p.X
+}
+
+func (p ConstantField) Set(x float32, i int) {}
@@ -4,8 +4,10 @@ import (
 	"github.com/andewx/dieselfluid/geom/grid"
 	"github.com/andewx/dieselfluid/geom/mesh"
 	"github.com/andewx/dieselfluid/kernel"
+	"github.com/andewx/dieselfluid/math/matrix"
 	"github.com/andewx/dieselfluid/math/vector"
 	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/sampler"
 	"github.com/andewx/dieselfluid/sampler/lsh"
 )
 
@@ -15,24 +17,29 @@ const SAMPLES = 150
 type SPHField struct {
 	kern          kernel.Kernel
 	smplr         *lsh.HashSampler
-	Particles     model.ParticleArray
+	nbrs          sampler.Neighborhood //Neighbor candidate queries - defaults to smplr
+	domain        *grid.Domain         //Periodic separation domain - nil for free space
+	Particles     *model.ParticleArray
 	fields        map[string]Field
 	tensor_fields map[string]TensorField
 	densities     DensityField
 	pressures     PressureField
+	temperatures  TemperatureField
 	velocities    VelocityField
 	forces        ForceField
 	divergence    Field
 	vort          Field
 }
 
-func InitSPH(parts model.ParticleArray, ref *lsh.HashSampler, kern kernel.Kernel, basis int) SPHField {
+func InitSPH(parts *model.ParticleArray, ref *lsh.HashSampler, kern kernel.Kernel, basis int) SPHField {
 	mySPH := SPHField{}
 	mySPH.kern = kern
 	mySPH.smplr = ref
+	mySPH.nbrs = ref
 	mySPH.Particles = parts
 	mySPH.densities = DensityField{mySPH.Particles}
 	mySPH.pressures = PressureField{mySPH.Particles}
+	mySPH.temperatures = TemperatureField{mySPH.Particles}
 	mySPH.velocities = VelocityField{mySPH.Particles}
 	mySPH.forces = ForceField{mySPH.Particles}
 	mySPH.divergence = ScalarField{make([]float32, basis)}
@@ -41,6 +48,7 @@ func InitSPH(parts model.ParticleArray, ref *lsh.HashSampler, kern kernel.Kernel
 	mySPH.tensor_fields = make(map[string]TensorField, 10)
 	mySPH.fields["density"] = mySPH.densities
 	mySPH.fields["pressure"] = mySPH.pressures
+	mySPH.fields["temperature"] = mySPH.temperatures
 	mySPH.fields["divergence"] = mySPH.divergence
 	mySPH.fields["vorticity"] = mySPH.vort
 	mySPH.tensor_fields["velocity"] = mySPH.velocities
@@ -107,45 +115,106 @@ func (p *SPHField) AlignWithGrid(mGrid grid.Grid) {
 	}
 }
 
+//Resize grows the particle indexed scalar fields after the particle count
+//has changed so that every live particle has a slot
+func (p *SPHField) Resize() {
+	n := p.Particles.N()
+	for name, f := range p.fields {
+		scalar, ok := f.(ScalarField)
+		if ok && len(scalar.Values) < n {
+			values := make([]float32, n)
+			copy(values, scalar.Values)
+			p.fields[name] = ScalarField{values}
+		}
+	}
+	p.divergence = p.fields["divergence"]
+	p.vort = p.fields["vorticity"]
+}
+
 func (p *SPHField) GetTensorFields() map[string]TensorField {
 	return p.tensor_fields
 }
 
 //Sampler Nearest Neighbors Update
 func (p *SPHField) NN() {
-	p.smplr.UpdateSampler()
+	p.nbrs.UpdateSampler()
+}
+
+//SetNeighborhood replaces the neighbor candidate sampler used by the kernel sums
+func (p *SPHField) SetNeighborhood(n sampler.Neighborhood) {
+	p.nbrs = n
+}
+
+func (p *SPHField) Neighborhood() sampler.Neighborhood {
+	return p.nbrs
+}
+
+//SetDomain sets the domain used for periodic particle separations
+func (p *SPHField) SetDomain(d *grid.Domain) {
+	p.domain = d
+}
+
+func (p *SPHField) Domain() *grid.Domain {
+	return p.domain
+}
+
+//Separation returns the vector b - a between two positions, measured to the
+//nearest periodic image when a periodic domain is set
+func (p *SPHField) Separation(a []float32, b []float32) vector.Vec {
+	if p.domain != nil {
+		return p.domain.Separation(a, b)
+	}
+	return vector.Sub(b, a)
 }
 
 func (p *SPHField) GetSampler() *lsh.HashSampler {
 	return p.smplr
 }
 
-//nterpolates a scalar field given a position giving a continuous field
+//Neighbors returns the unique sampled particle indices within the kernel radius
+//of particle i, excluding i itself
+func (p *SPHField) Neighbors(i int) []int {
+	samples := p.nbrs.GetSamples(i)
+	h := p.kern.H0()
+	pos := p.Particles.Position(i)
+	seen := make(map[int]bool, len(samples))
+	list := make([]int, 0, len(samples))
+	for _, j := range samples {
+		if j == i || seen[j] || j >= p.Particles.Total() {
+			continue
+		}
+		seen[j] = true
+		if vector.Mag(p.Separation(pos, p.Particles.Position(j))) < h {
+			list = append(list, j)
+		}
+	}
+	return list
+}
+
+//Interpolate interpolates a scalar field at a position giving a continuous field
 func (p *SPHField) Interpolate(position []float32, field Field) float32 {
-	sampleList := p.smplr.GetSamplesFromPosition(position)
+	sampleList := p.nbrs.GetSamplesFromPosition(position)
 	sum := float32(0.0)
-	mass := p.Mass()
 	for i := 0; i < len(sampleList); i++ {
 		part := p.Particles.Get(sampleList[i])
-		dist := vector.Dist(position, part.Position[:])
-		weight := mass / part.Density * p.kern.F(dist)
+		dist := vector.Mag(p.Separation(position, part.Position[:]))
+		weight := p.Particles.ParticleMass(sampleList[i]) / part.Density * p.kern.F(dist)
 		sum += weight * field.Value(sampleList[i])
 	}
 	return sum
 }
 
 func (p *SPHField) DensityF(pos vector.Vec, positions []float32) float32 {
-	sampleList := p.smplr.GetSamplesFromPosition(pos)
+	sampleList := p.nbrs.GetSamplesFromPosition(pos)
 	density := p.kern.W0()
-	mass := p.Field().Mass()
 
 	for j := 0; j < len(sampleList); j++ {
 		pIndex := sampleList[j]
 		if pIndex < p.Particles.Total() {
 
 			particle_j := p.Particles.Get(pIndex)
-			dist := vector.Dist(pos, particle_j.Position[:]) //Change to dist
-			density += mass * p.kern.F(dist)
+			dist := vector.Mag(p.Separation(pos, particle_j.Position[:])) //Change to dist
+			density += p.Particles.ParticleMass(pIndex) * p.kern.F(dist)
 		}
 	}
 	return density
@@ -153,18 +222,17 @@ func (p *SPHField) DensityF(pos vector.Vec, positions []float32) float32 {
 
 //Density -- Computes density field for SPH Field - Boundary particle contribute to infinite density
 func (p *SPHField) Density(i int) {
-	sampleList := p.smplr.GetSamples(i)
+	sampleList := p.nbrs.GetSamples(i)
 	density := float32(0)
 	particle := p.Particles.Get(i)
 	lenSample := len(sampleList)
-	mass := p.Field().Mass()
 	for j := 0; j < lenSample; j++ {
 		pIndex := sampleList[j]
 		if i != pIndex && pIndex < p.Particles.Total() {
 
 			particle_j := p.Particles.Get(pIndex)
-			dist := vector.Dist(particle.Position[:], particle_j.Position[:]) //Change to dist
-			density += mass * p.kern.F(dist)
+			dist := vector.Mag(p.Separation(particle.Position[:], particle_j.Position[:])) //Change to dist
+			density += p.Particles.ParticleMass(pIndex) * p.kern.F(dist)
 		}
 	}
 	particle.Density = density
@@ -174,9 +242,8 @@ func (p *SPHField) Density(i int) {
 //Computes gradient vector at particle i given a scalar field
 func (p *SPHField) Gradient(i int, field Field) []float32 {
 
-	samples := p.smplr.GetSamples(i)
+	samples := p.nbrs.GetSamples(i)
 	F := float32(0.0)
-	mass := p.Particles.Mass()
 	accumGrad := vector.Vec{0, 0, 0}
 	particle := p.Particles.Get(i)
 	dens := particle.Density
@@ -186,16 +253,16 @@ func (p *SPHField) Gradient(i int, field Field) []float32 {
 		if jIndex != i {
 			particle_j := p.Particles.Get(jIndex)
 			jDensity := particle_j.Density
-			dir := vector.Sub(particle_j.Position[:], particle.Position[:])
+			dir := p.Separation(particle.Position[:], particle_j.Position[:])
 			dist := vector.Mag(dir)
 			dir = vector.Norm(dir)
 			grad := p.kern.Grad(float32(dist), dir)
 			F = (field.Value(i) / (dens * dens)) + field.Value(samples[j])/(jDensity*jDensity)
-			accumGrad = vector.Add(accumGrad, vector.Scale(grad, F))
+			accumGrad = vector.Add(accumGrad, vector.Scale(grad, F*p.Particles.ParticleMass(jIndex)))
 		}
 	}
 
-	return vector.Scale(accumGrad, dens*mass)
+	return vector.Scale(accumGrad, dens)
 
 }
 
@@ -203,9 +270,8 @@ func (p *SPHField) Gradient(i int, field Field) []float32 {
 func (p *SPHField) Div(i int, field TensorField) float32 {
 
 	particle := p.Particles.Get(i)
-	samples := p.smplr.GetSamples(i)
+	samples := p.nbrs.GetSamples(i)
 	div := float32(0.0)
-	mass := p.Mass()
 
 	//For all particle neighbors -- Non Symmetric
 	for j := 0; j < len(samples); j++ {
@@ -213,11 +279,11 @@ func (p *SPHField) Div(i int, field TensorField) float32 {
 		if jIndex != i {
 			particle_j := p.Particles.Get(jIndex)
 			jDensity := particle_j.Density
-			dir := vector.Sub(particle_j.Position[:], particle.Position[:])
+			dir := p.Separation(particle.Position[:], particle_j.Position[:])
 			dist := vector.Mag(dir)
 			dir = vector.Norm(dir) //Normalize
 			grad := p.kern.Grad(dist, dir)
-			scaleVec := vector.Scale(field.Value(samples[j]), mass/jDensity)
+			scaleVec := vector.Scale(field.Value(samples[j]), p.Particles.ParticleMass(jIndex)/jDensity)
 			div += vector.Dot(scaleVec, grad)
 		}
 	} //End J
@@ -226,12 +292,32 @@ func (p *SPHField) Div(i int, field TensorField) float32 {
 
 }
 
+//VelocityGradient computes the row major velocity gradient tensor L = dv_a/dx_b
+//at particle i from the neighbor velocity differences
+func (p *SPHField) VelocityGradient(i int) matrix.Mat {
+	particle := p.Particles.Get(i)
+	grad := matrix.Mat3(0.0)
+	for _, j := range p.Neighbors(i) {
+		particle_j := p.Particles.Get(j)
+		dir := p.Separation(particle.Position[:], particle_j.Position[:])
+		dist := vector.Mag(dir)
+		gradW := p.kern.Grad(dist, vector.Norm(dir))
+		dv := vector.Sub(particle_j.Velocity[:], particle.Velocity[:])
+		vol := p.Particles.ParticleMass(j) / particle_j.Density
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				grad[a*3+b] += vol * dv[a] * gradW[b]
+			}
+		}
+	}
+	return grad
+}
+
 //Computes a laplacian value at the particle i for the given scalar field
 func (p *SPHField) Laplacian(i int, field Field) float32 {
 
 	particle := p.Particles.Get(i)
-	samples := p.smplr.GetSamples(i)
-	m := p.Mass()
+	samples := p.nbrs.GetSamples(i)
 	sum := float32(0.0)
 	//Conduct inner loop
 	for j := 0; j < len(samples); j++ {
@@ -240,8 +326,8 @@ func (p *SPHField) Laplacian(i int, field Field) float32 {
 		particle_j := p.Particles.Get(jIndex)
 		if jIndex != i {
 			jDensity := particle_j.Density
-			dist := vector.Dist(particle.Position[:], particle_j.Position[:])
-			sum += m * ((field.Value(samples[j]) - field.Value(i)) / jDensity) * p.kern.O2D(dist)
+			dist := vector.Mag(p.Separation(particle.Position[:], particle_j.Position[:]))
+			sum += p.Particles.ParticleMass(jIndex) * ((field.Value(samples[j]) - field.Value(i)) / jDensity) * p.kern.O2D(dist)
 		}
 	}
 	return sum
@@ -251,8 +337,7 @@ func (p *SPHField) Laplacian(i int, field Field) float32 {
 func (p *SPHField) LaplacianForce(i int, field TensorField) []float32 {
 
 	particle := p.Particles.Get(i)
-	samples := p.smplr.GetSamples(i)
-	m := p.Mass()
+	samples := p.nbrs.GetSamples(i)
 	force := vector.Vec{0, 0, 0}
 	//Conduct inner loop
 	for j := 0; j < len(samples); j++ {
@@ -261,8 +346,8 @@ func (p *SPHField) LaplacianForce(i int, field TensorField) []float32 {
 			particle_j := p.Particles.Get(jIndex)
 			jDensity := particle_j.Density
 			v := vector.Scale(vector.Sub(particle_j.Velocity[:], particle.Velocity[:]), 1/jDensity)
-			dist := vector.Dist(particle.Position[:], particle_j.Position[:])
-			force = force.Add(v.Scale(p.kern.O2D(dist))).Scale(m)
+			dist := vector.Mag(p.Separation(particle.Position[:], particle_j.Position[:]))
+			force = force.Add(v.Scale(p.kern.O2D(dist) * p.Particles.ParticleMass(jIndex)))
 		}
 	}
 	return force
@@ -272,9 +357,8 @@ func (p *SPHField) LaplacianForce(i int, field TensorField) []float32 {
 func (p *SPHField) Curl(i int, field TensorField) []float32 {
 
 	particle := p.Particles.Get(i)
-	samples := p.smplr.GetSamples(i)
+	samples := p.nbrs.GetSamples(i)
 	curl_vec := vector.Vec{0, 0, 0}
-	mass := p.Mass()
 
 	//For all particle neighbors
 	for j := 0; j < len(samples); j++ {
@@ -282,11 +366,11 @@ func (p *SPHField) Curl(i int, field TensorField) []float32 {
 		if jIndex != i {
 			particle_j := p.Particles.Get(jIndex)
 			jDensity := particle_j.Density
-			dir := vector.Sub(particle_j.Position[:], particle.Position[:])
+			dir := p.Separation(particle.Position[:], particle_j.Position[:])
 			dist := vector.Mag(dir)
 			dir = vector.Norm(dir) //Normalize
 			grad := p.kern.Grad(dist, dir)
-			scaleVec := vector.Scale(field.Value(samples[j]), mass/jDensity)
+			scaleVec := vector.Scale(field.Value(samples[j]), p.Particles.ParticleMass(jIndex)/jDensity)
 			curl_vec = vector.Add(curl_vec, vector.Cross(scaleVec, grad))
 		}
 	} //End J
@@ -0,0 +1,164 @@
+//Volume fill seeding fluid particles inside closed meshes. The mesh bounds are
+//voxelized at the particle spacing with one ray per lattice column, the sorted
+//surface crossings giving the inside intervals by parity, or with generalized
+//winding numbers for meshes with holes, and particles are placed at the inside
+//cell centers on a lattice or jittered pattern. Implicit shapes are filled by
+//the sign of their distance
+package fill
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/andewx/dieselfluid/geom/bvh"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/sdf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Seeding Patterns
+const PATTERN_LATTICE = 0
+const PATTERN_JITTER = 1
+
+//Inside Tests, winding numbers requiring consistently wound triangles
+const TEST_PARITY = 0
+const TEST_WINDING = 1
+
+//Rest density of water
+const DENSITY = 1000.0
+
+type Params struct {
+	Spacing  float32 //Particle spacing, half the kernel support
+	Pattern  int
+	Jitter   float32 //Jitter amplitude as a fraction of the spacing
+	Test     int
+	Velocity vector.Vec //Initial particle velocity
+	Density  float32    //Rest density giving the particle mass Density Spacing^3
+	Seed     int64
+}
+
+//DefaultParams returns lattice seeding at half the kernel support h with parity
+//inside tests, at rest with the density of water
+func DefaultParams(h float32) Params {
+	return Params{h / 2, PATTERN_LATTICE, 0.25, TEST_PARITY, vector.Vec{0, 0, 0}, DENSITY, 1}
+}
+
+//Positions returns the seeded particle positions inside the mesh
+func Positions(m *mesh.Mesh, p Params) []float32 {
+	tree := bvh.FromMesh(m)
+	min, max := tree.Bounds()
+	dx := p.Spacing
+	inside := func(x vector.Vec) bool {
+		if p.Test == TEST_WINDING {
+			return tree.Winding(x) > 0.5
+		}
+		return tree.Inside(x)
+	}
+	center := func(c [3]int) vector.Vec {
+		return vector.Vec{min[0] + (float32(c[0])+0.5)*dx, min[1] + (float32(c[1])+0.5)*dx, min[2] + (float32(c[2])+0.5)*dx}
+	}
+
+	//Parity tests share the column voxelizer, the other tests probe every center
+	var cells [][3]int
+	if p.Test == TEST_PARITY {
+		_, cells = tree.Voxelize(dx)
+	} else {
+		var n [3]int
+		for a := 0; a < 3; a++ {
+			n[a] = int(math.Ceil(float64((max[a] - min[a]) / dx)))
+		}
+		for i := 0; i < n[0]; i++ {
+			for j := 0; j < n[1]; j++ {
+				for k := 0; k < n[2]; k++ {
+					if inside(center([3]int{i, j, k})) {
+						cells = append(cells, [3]int{i, j, k})
+					}
+				}
+			}
+		}
+	}
+
+	rng := rand.New(rand.NewSource(p.Seed))
+	positions := []float32{}
+	for _, c := range cells {
+		pos := center(c)
+		if p.Pattern == PATTERN_JITTER && p.Jitter > 0 {
+			jittered := vector.Vec{0, 0, 0}
+			for a := 0; a < 3; a++ {
+				jittered[a] = pos[a] + (rng.Float32()-0.5)*p.Jitter*dx
+			}
+			if inside(jittered) {
+				pos = jittered
+			}
+		}
+		positions = append(positions, pos[0], pos[1], pos[2])
+	}
+	return positions
+}
+
+//ShapePositions returns the seeded particle positions inside a bounded implicit
+//shape, the parity and winding tests being replaced by the distance sign
+func ShapePositions(s sdf.Shape, p Params) ([]float32, error) {
+	if !sdf.Bounded(s) {
+		return nil, fmt.Errorf("Cannot fill an unbounded shape")
+	}
+	min, max := s.Bounds()
+	dx := p.Spacing
+	var n [3]int
+	for a := 0; a < 3; a++ {
+		n[a] = int(math.Ceil(float64((max[a] - min[a]) / dx)))
+	}
+	rng := rand.New(rand.NewSource(p.Seed))
+	positions := []float32{}
+	for i := 0; i < n[0]; i++ {
+		for j := 0; j < n[1]; j++ {
+			for k := 0; k < n[2]; k++ {
+				pos := vector.Vec{min[0] + (float32(i)+0.5)*dx, min[1] + (float32(j)+0.5)*dx, min[2] + (float32(k)+0.5)*dx}
+				if !sdf.Inside(s, pos) {
+					continue
+				}
+				if p.Pattern == PATTERN_JITTER && p.Jitter > 0 {
+					jittered := vector.Vec{0, 0, 0}
+					for a := 0; a < 3; a++ {
+						jittered[a] = pos[a] + (rng.Float32()-0.5)*p.Jitter*dx
+					}
+					if sdf.Inside(s, jittered) {
+						pos = jittered
+					}
+				}
+				positions = append(positions, pos[0], pos[1], pos[2])
+			}
+		}
+	}
+	return positions, nil
+}
+
+//Fill seeds a particle array with the fluid filling the mesh. Particles have
+//mass Density Spacing^3, the rest density and the initial velocity of p
+func Fill(m *mesh.Mesh, h float32, p Params) model.ParticleArray {
+	return particles(Positions(m, p), h, p)
+}
+
+//FillShape seeds a particle array with the fluid filling a bounded implicit shape
+func FillShape(s sdf.Shape, h float32, p Params) (model.ParticleArray, error) {
+	positions, err := ShapePositions(s, p)
+	if err != nil {
+		return model.ParticleArray{}, err
+	}
+	return particles(positions, h, p), nil
+}
+
+func particles(positions []float32, h float32, p Params) model.ParticleArray {
+	count := len(positions) / 3
+	dx := p.Spacing
+	parts := model.NewParticleArray(count, 0, h, 1/(dx*dx*dx), p.Density*dx*dx*dx)
+	for i := 0; i < count; i++ {
+		particle := model.Particle{Density: parts.D0()}
+		copy(particle.Position[:], positions[i*3:i*3+3])
+		copy(particle.Velocity[:], p.Velocity)
+		parts.Set(i, particle)
+	}
+	return parts
+}
@@ -0,0 +1,95 @@
+package fill
+
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom/bvh"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/sdf"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//sphere returns a closed outward wound latitude longitude sphere
+func sphere(r float32, slices int, stacks int) mesh.Mesh {
+	point := func(i int, j int) vector.Vec {
+		theta := math.Pi * float64(j) / float64(stacks)
+		phi := 2 * math.Pi * float64(i) / float64(slices)
+		return vector.Vec{r * float32(math.Sin(theta)*math.Cos(phi)), r * float32(math.Cos(theta)), r * float32(math.Sin(theta)*math.Sin(phi))}
+	}
+	vertices := []vector.Vec{}
+	for j := 0; j < stacks; j++ {
+		for i := 0; i < slices; i++ {
+			a, b, c, d := point(i, j), point(i+1, j), point(i+1, j+1), point(i, j+1)
+			if j > 0 {
+				vertices = append(vertices, a, b, d)
+			}
+			if j < stacks-1 {
+				vertices = append(vertices, b, c, d)
+			}
+		}
+	}
+	return mesh.InitMesh(vertices, vector.Vec{0, 0, 0})
+}
+
+func TestFill(t *testing.T) {
+	h := float32(0.1)
+	box := mesh.Box(1, 0.5, 0.5, vector.Vec{0.2, 0, 0})
+	p := DefaultParams(h)
+	p.Velocity = vector.Vec{1, 0, -2}
+	parts := Fill(&box, h, p)
+	if parts.N() != 20*10*10 || parts.Total() != parts.N() {
+		t.Fatalf("Box filled with %d particles\n", parts.N())
+	}
+	if v := parts.Velocity(7); v[0] != 1 || v[2] != -2 || math.Abs(float64(parts.D0()-DENSITY)) > 1e-2 || math.Abs(float64(parts.TotalMass()-DENSITY*0.25)) > 1e-2 {
+		t.Errorf("Velocity %v rest density %f mass %f\n", v, parts.D0(), parts.TotalMass())
+	}
+
+	//The lattice samples the sphere volume at one particle per spacing cube
+	ball := sphere(0.5, 48, 24)
+	tree := bvh.FromMesh(&ball)
+	positions := Positions(&ball, p)
+	expected := 4 * math.Pi / 3 * 0.125 / float64(p.Spacing*p.Spacing*p.Spacing)
+	if n := float64(len(positions) / 3); math.Abs(n-expected) > 0.05*expected {
+		t.Errorf("Sphere filled with %f particles expected %f\n", n, expected)
+	}
+	p.Pattern = PATTERN_JITTER
+	jittered := Positions(&ball, p)
+	moved := 0
+	for i := 0; i < len(jittered); i += 3 {
+		x := vector.Vec(jittered[i : i+3])
+		if !tree.Inside(x) {
+			t.Fatalf("Jittered particle %v outside\n", x)
+		}
+		if vector.Dist(x, positions[i:i+3]) > 0 {
+			moved++
+		}
+	}
+	if len(jittered) != len(positions) || moved < len(positions)/6 {
+		t.Errorf("Jittered %d of %d particles\n", moved, len(jittered)/3)
+	}
+
+	//Winding numbers still fill a sphere with a missing cap
+	open := mesh.InitMesh(ball.Vertexes[48*3:], vector.Vec{0, 0, 0})
+	p = DefaultParams(h)
+	p.Test = TEST_WINDING
+	if n := len(Positions(&open, p)); n < len(positions)*9/10 || n > len(positions) {
+		t.Errorf("Open sphere filled with %d of %d particles\n", n/3, len(positions)/3)
+	}
+
+	//Implicit shapes fill by their distance sign
+	hollow := sdf.Difference(&sdf.Box{Half: vector.Vec{0.5, 0.5, 0.5}}, &sdf.Sphere{Radius: 0.3})
+	shaped, err := FillShape(hollow, h, p)
+	expected = (1 - 4*math.Pi/3*0.027) / float64(p.Spacing*p.Spacing*p.Spacing)
+	if err != nil || math.Abs(float64(shaped.N())-expected) > 0.02*expected || shaped.D0() != parts.D0() {
+		t.Errorf("Hollow box filled with %d particles expected %f error %v\n", shaped.N(), expected, err)
+	}
+	for i := 0; i < shaped.N(); i++ {
+		if sdf.Inside(&sdf.Sphere{Radius: 0.3}, shaped.Position(i)) {
+			t.Fatalf("Particle %v inside the carved sphere\n", shaped.Position(i))
+		}
+	}
+	if _, err := FillShape(&sdf.Plane{Normal: vector.Vec{0, 1, 0}}, h, p); err == nil {
+		t.Errorf("Unbounded shape filled\n")
+	}
+}
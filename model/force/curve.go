@@ -0,0 +1,75 @@
+package force
+
+import "github.com/andewx/dieselfluid/math/vector"
+
+//Curve is an animatable scalar parameter given by keyframes at ascending times.
+//Values are linearly interpolated and held constant outside the key range
+type Curve struct {
+	Times  []float32
+	Values []float32
+}
+
+//VecCurve is an animatable vector parameter
+type VecCurve struct {
+	Times  []float32
+	Values []vector.Vec
+}
+
+func Constant(v float32) Curve {
+	return Curve{[]float32{0}, []float32{v}}
+}
+
+func ConstantVec(v vector.Vec) VecCurve {
+	return VecCurve{[]float32{0}, []vector.Vec{v}}
+}
+
+//Key adds a keyframe, keys must be added in ascending time order
+func (c *Curve) Key(t float32, v float32) {
+	c.Times = append(c.Times, t)
+	c.Values = append(c.Values, v)
+}
+
+func (c *VecCurve) Key(t float32, v vector.Vec) {
+	c.Times = append(c.Times, t)
+	c.Values = append(c.Values, v)
+}
+
+//segment returns the key index and interpolation weight for time t
+func segment(times []float32, t float32) (int, float32) {
+	n := len(times)
+	if n < 2 || t <= times[0] {
+		return 0, 0
+	}
+	if t >= times[n-1] {
+		return n - 1, 0
+	}
+	k := 0
+	for k < n-2 && times[k+1] <= t {
+		k++
+	}
+	return k, (t - times[k]) / (times[k+1] - times[k])
+}
+
+//At evaluates the curve at time t
+func (c Curve) At(t float32) float32 {
+	if len(c.Values) == 0 {
+		return 0
+	}
+	k, w := segment(c.Times, t)
+	if w == 0 {
+		return c.Values[k]
+	}
+	return c.Values[k]*(1-w) + c.Values[k+1]*w
+}
+
+//At evaluates the vector curve at time t
+func (c VecCurve) At(t float32) vector.Vec {
+	if len(c.Values) == 0 {
+		return vector.Vec{0, 0, 0}
+	}
+	k, w := segment(c.Times, t)
+	if w == 0 {
+		return vector.Vec{c.Values[k][0], c.Values[k][1], c.Values[k][2]}
+	}
+	return vector.Add(vector.Scale(c.Values[k], 1-w), vector.Scale(c.Values[k+1], w))
+}
@@ -0,0 +1,184 @@
+//External force fields for steering particle simulations. Fields are evaluated
+//per particle each step from their position, velocity and mass at the simulation
+//time so every parameter may be animated with keyframed curves. Each field is
+//weighted by a spatial falloff around the falloff center
+package force
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Falloff Shape Enums
+const FALLOFF_NONE = 0
+const FALLOFF_LINEAR = 1
+const FALLOFF_SMOOTH = 2
+const FALLOFF_INVERSE_SQUARE = 3
+
+//Field returns the force on a particle at time t
+type Field interface {
+	Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec
+}
+
+//Falloff weights a field by the distance from Center. Linear and smooth shapes
+//fade to zero at Radius, inverse square is full strength inside Radius
+type Falloff struct {
+	Shape  int
+	Center VecCurve
+	Radius Curve
+}
+
+//Uniform returns a falloff with full weight everywhere
+func Uniform() Falloff {
+	return Falloff{FALLOFF_NONE, ConstantVec(vector.Vec{0, 0, 0}), Constant(1)}
+}
+
+//Weight returns the falloff weight in [0,1] at pos and time t
+func (f Falloff) Weight(pos vector.Vec, t float32) float32 {
+	if f.Shape == FALLOFF_NONE {
+		return 1
+	}
+	r := f.Radius.At(t)
+	if r <= 0 {
+		return 0
+	}
+	d := vector.Mag(vector.Sub(pos, f.Center.At(t)))
+	x := d / r
+	switch f.Shape {
+	case FALLOFF_LINEAR:
+		return float32(math.Max(0, float64(1-x)))
+	case FALLOFF_SMOOTH:
+		if x >= 1 {
+			return 0
+		}
+		q := 1 - x*x
+		return q * q * q
+	case FALLOFF_INVERSE_SQUARE:
+		if x <= 1 {
+			return 1
+		}
+		return 1 / (x * x)
+	}
+	return 1
+}
+
+//Gravity applies a uniform acceleration
+type Gravity struct {
+	Acceleration VecCurve
+	Falloff      Falloff
+}
+
+//Wind pulls particle velocities toward the wind velocity, F = c m (w - v)
+type Wind struct {
+	Velocity    VecCurve
+	Coefficient Curve
+	Falloff     Falloff
+}
+
+//Attractor accelerates particles toward the falloff center, negative strength repels
+type Attractor struct {
+	Strength Curve
+	Falloff  Falloff
+}
+
+//Vortex accelerates particles tangentially around an axis through the falloff center
+type Vortex struct {
+	Axis     VecCurve
+	Strength Curve
+	Falloff  Falloff
+}
+
+//Drag applies linear velocity damping, F = -c m v
+type Drag struct {
+	Coefficient Curve
+	Falloff     Falloff
+}
+
+//Turbulence applies divergence free curl noise acceleration. The noise pattern
+//scrolls through space at Speed so the turbulence evolves over time
+type Turbulence struct {
+	Amplitude Curve
+	Frequency Curve
+	Speed     VecCurve
+	Falloff   Falloff
+	noise     *Noise
+}
+
+func NewGravity(g vector.Vec) *Gravity {
+	return &Gravity{ConstantVec(g), Uniform()}
+}
+
+func NewWind(velocity vector.Vec, coefficient float32) *Wind {
+	return &Wind{ConstantVec(velocity), Constant(coefficient), Uniform()}
+}
+
+func NewAttractor(center vector.Vec, strength float32, radius float32) *Attractor {
+	return &Attractor{Constant(strength), Falloff{FALLOFF_SMOOTH, ConstantVec(center), Constant(radius)}}
+}
+
+func NewVortex(center vector.Vec, axis vector.Vec, strength float32, radius float32) *Vortex {
+	return &Vortex{ConstantVec(axis), Constant(strength), Falloff{FALLOFF_SMOOTH, ConstantVec(center), Constant(radius)}}
+}
+
+func NewDrag(coefficient float32) *Drag {
+	return &Drag{Constant(coefficient), Uniform()}
+}
+
+func NewTurbulence(amplitude float32, frequency float32, seed int64) *Turbulence {
+	return &Turbulence{Constant(amplitude), Constant(frequency), ConstantVec(vector.Vec{0, 0, 0}), Uniform(), NewNoise(seed)}
+}
+
+func (f *Gravity) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	return vector.Scale(f.Acceleration.At(t), mass*f.Falloff.Weight(pos, t))
+}
+
+func (f *Wind) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	rel := vector.Sub(f.Velocity.At(t), vel)
+	return vector.Scale(rel, f.Coefficient.At(t)*mass*f.Falloff.Weight(pos, t))
+}
+
+func (f *Attractor) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	dir := vector.Norm(vector.Sub(f.Falloff.Center.At(t), pos))
+	return vector.Scale(dir, f.Strength.At(t)*mass*f.Falloff.Weight(pos, t))
+}
+
+func (f *Vortex) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	axis := vector.Norm(f.Axis.At(t))
+	tangent := vector.Norm(vector.Cross(axis, vector.Sub(pos, f.Falloff.Center.At(t))))
+	return vector.Scale(tangent, f.Strength.At(t)*mass*f.Falloff.Weight(pos, t))
+}
+
+func (f *Drag) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	return vector.Scale(vel, -f.Coefficient.At(t)*mass*f.Falloff.Weight(pos, t))
+}
+
+func (f *Turbulence) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	if f.noise == nil {
+		f.noise = NewNoise(0)
+	}
+	freq := f.Frequency.At(t)
+	sample := vector.Sub(vector.Scale(pos, freq), vector.Scale(f.Speed.At(t), t*freq))
+	return vector.Scale(f.noise.Curl(sample), f.Amplitude.At(t)*mass*f.Falloff.Weight(pos, t))
+}
+
+//Sum evaluates all fields on a particle at time t
+func Sum(fields []Field, pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	total := vector.Vec{0, 0, 0}
+	for _, f := range fields {
+		total = vector.Add(total, f.Force(pos, vel, mass, t))
+	}
+	return total
+}
+
+//GravityOf returns the total uniform gravity acceleration of the gravity fields
+//in the set at time t, evaluated at the falloff centers
+func GravityOf(fields []Field, t float32) vector.Vec {
+	g := vector.Vec{0, 0, 0}
+	for _, f := range fields {
+		if grav, ok := f.(*Gravity); ok {
+			g = vector.Add(g, vector.Scale(grav.Acceleration.At(t), grav.Falloff.Weight(grav.Falloff.Center.At(t), t)))
+		}
+	}
+	return g
+}
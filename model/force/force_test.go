@@ -0,0 +1,143 @@
+package force
+
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+func TestCurve(t *testing.T) {
+	c := Constant(1)
+	c.Key(2, 3)
+	if c.At(-1) != 1 || c.At(1) != 2 || c.At(5) != 3 {
+		t.Errorf("Curve interpolation %f %f %f\n", c.At(-1), c.At(1), c.At(5))
+	}
+}
+
+func TestFields(t *testing.T) {
+	center := vector.Vec{1, 0, 0}
+	attractor := NewAttractor(center, 2, 1)
+	f := attractor.Force(vector.Vec{0.5, 0, 0}, vector.Vec{0, 0, 0}, 1, 0)
+	if f[0] <= 0 || f[1] != 0 || f[2] != 0 {
+		t.Errorf("Attractor should pull toward the center %v\n", f)
+	}
+	if f := attractor.Force(vector.Vec{3, 0, 0}, vector.Vec{0, 0, 0}, 1, 0); vector.Mag(f) != 0 {
+		t.Errorf("Attractor outside its falloff radius %v\n", f)
+	}
+
+	vortex := NewVortex(vector.Vec{0, 0, 0}, vector.Vec{0, 1, 0}, 1, 2)
+	pos := vector.Vec{1, 0, 0}
+	if f := vortex.Force(pos, vector.Vec{0, 0, 0}, 1, 0); math.Abs(float64(vector.Dot(f, pos))) > 1e-6 || vector.Mag(f) == 0 {
+		t.Errorf("Vortex force should be tangential %v\n", f)
+	}
+
+	drag := NewDrag(0.5)
+	if f := drag.Force(pos, vector.Vec{2, 0, 0}, 2, 0); f[0] != -2 {
+		t.Errorf("Drag force expected -2 got %v\n", f)
+	}
+
+	g := NewGravity(vector.Vec{0, -9.81, 0})
+	g.Acceleration.Key(1, vector.Vec{0, 0, 0})
+	if f := g.Force(pos, vector.Vec{0, 0, 0}, 1, 0.5); math.Abs(float64(f[1]+4.905)) > 1e-4 {
+		t.Errorf("Animated gravity expected -4.905 got %v\n", f)
+	}
+}
+
+//Curl noise is divergence free
+func TestCurlNoise(t *testing.T) {
+	n := NewNoise(7)
+	const e = 1e-2
+	for _, p := range []vector.Vec{{0.3, 0.7, 1.1}, {2.5, -1.2, 0.4}, {-3.3, 4.1, 2.2}} {
+		div := float32(0)
+		for k := 0; k < 3; k++ {
+			a, b := vector.Vec{p[0], p[1], p[2]}, vector.Vec{p[0], p[1], p[2]}
+			a[k] -= e
+			b[k] += e
+			div += (n.Curl(b)[k] - n.Curl(a)[k]) / (2 * e)
+		}
+		if mag := vector.Mag(n.Curl(p)); mag == 0 || math.Abs(float64(div)) > 0.01*float64(mag) {
+			t.Errorf("Curl noise divergence %f at %v\n", div, p)
+		}
+	}
+}
+
+func TestFalloff(t *testing.T) {
+	//Weights at distances 1, 2 and 4 from the center for a radius of 2
+	center := vector.Vec{1, 0, 0}
+	points := []vector.Vec{{2, 0, 0}, {1, 2, 0}, {1, 0, -4}}
+	shapes := map[int][3]float32{
+		FALLOFF_NONE:           {1, 1, 1},
+		FALLOFF_LINEAR:         {0.5, 0, 0},
+		FALLOFF_SMOOTH:         {0.421875, 0, 0},
+		FALLOFF_INVERSE_SQUARE: {1, 1, 0.25},
+	}
+	for shape, weights := range shapes {
+		g := NewGravity(vector.Vec{0, -9.81, 0})
+		g.Falloff = Falloff{shape, ConstantVec(center), Constant(2)}
+		for k, p := range points {
+			if w := g.Falloff.Weight(p, 0); math.Abs(float64(w-weights[k])) > 1e-6 {
+				t.Errorf("Falloff %d weight at %v expected %f got %f\n", shape, p, weights[k], w)
+			}
+			if f := vector.Mag(g.Force(p, vector.Vec{0, 0, 0}, 2, 0)); math.Abs(float64(f-19.62*weights[k])) > 1e-4 {
+				t.Errorf("Falloff %d force at %v expected %f got %f\n", shape, p, 19.62*weights[k], f)
+			}
+		}
+		if shape != FALLOFF_NONE {
+			g.Falloff.Radius = Constant(0)
+			if w := g.Falloff.Weight(center, 0); w != 0 {
+				t.Errorf("Falloff %d with zero radius weight %f\n", shape, w)
+			}
+		}
+	}
+}
+
+func TestWind(t *testing.T) {
+	wind := NewWind(vector.Vec{2, 0, 0}, 0.5)
+	//F = c m (w - v)
+	if f := wind.Force(vector.Vec{0, 0, 0}, vector.Vec{0, 1, 0}, 2, 0); vector.Mag(vector.Sub(f, vector.Vec{2, -1, 0})) > 1e-6 {
+		t.Errorf("Wind force expected [2 -1 0] got %v\n", f)
+	}
+	if f := wind.Force(vector.Vec{0, 0, 0}, vector.Vec{2, 0, 0}, 2, 0); vector.Mag(f) != 0 {
+		t.Errorf("Particle moving with the wind pushed %v\n", f)
+	}
+	wind.Velocity.Key(1, vector.Vec{0, 0, 0})
+	if f := wind.Force(vector.Vec{0, 0, 0}, vector.Vec{0, 0, 0}, 2, 0.5); math.Abs(float64(f[0]-1)) > 1e-6 {
+		t.Errorf("Animated wind expected 1 got %v\n", f)
+	}
+	wind.Falloff = Falloff{FALLOFF_LINEAR, ConstantVec(vector.Vec{0, 0, 0}), Constant(1)}
+	if f := wind.Force(vector.Vec{0, 0.5, 0}, vector.Vec{0, 0, 0}, 2, 0); math.Abs(float64(f[0]-1)) > 1e-6 {
+		t.Errorf("Wind at half the falloff radius expected 1 got %v\n", f)
+	}
+	for _, p := range []vector.Vec{{0, 1, 0}, {0, 3, 0}} {
+		if f := wind.Force(p, vector.Vec{0, 0, 0}, 2, 0); vector.Mag(f) != 0 {
+			t.Errorf("Wind at %v beyond its falloff %v\n", p, f)
+		}
+	}
+}
+
+func TestTurbulence(t *testing.T) {
+	turbulence := NewTurbulence(3, 2, 7)
+	noise := NewNoise(7)
+	pos := vector.Vec{0.3, -0.4, 1.2}
+	//Curl noise of the scaled position times amplitude and mass
+	expect := vector.Scale(noise.Curl(vector.Scale(pos, 2)), 3*0.5)
+	f := turbulence.Force(pos, vector.Vec{0, 0, 0}, 0.5, 0)
+	if vector.Mag(expect) == 0 || vector.Mag(vector.Sub(f, expect)) > 1e-5 {
+		t.Errorf("Turbulence force expected %v got %v\n", expect, f)
+	}
+	//The pattern scrolls with the speed
+	turbulence.Speed = ConstantVec(vector.Vec{1, 0, 0})
+	moved := turbulence.Force(vector.Add(pos, vector.Vec{0.25, 0, 0}), vector.Vec{0, 0, 0}, 0.5, 0.25)
+	if vector.Mag(vector.Sub(moved, expect)) > 1e-5 {
+		t.Errorf("Scrolled turbulence expected %v got %v\n", expect, moved)
+	}
+	turbulence.Falloff = Falloff{FALLOFF_SMOOTH, ConstantVec(vector.Vec{0, 0, 0}), Constant(1)}
+	if f := turbulence.Force(vector.Vec{2, 0, 0}, vector.Vec{0, 0, 0}, 0.5, 0); vector.Mag(f) != 0 {
+		t.Errorf("Turbulence beyond its falloff %v\n", f)
+	}
+	turbulence.Amplitude = Constant(0)
+	if f := turbulence.Force(pos, vector.Vec{0, 0, 0}, 0.5, 0); vector.Mag(f) != 0 {
+		t.Errorf("Turbulence without amplitude %v\n", f)
+	}
+}
@@ -0,0 +1,94 @@
+package force
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Noise is seeded 3D Perlin gradient noise
+type Noise struct {
+	perm [512]int
+}
+
+func NewNoise(seed int64) *Noise {
+	n := Noise{}
+	p := rand.New(rand.NewSource(seed)).Perm(256)
+	for i := 0; i < 512; i++ {
+		n.perm[i] = p[i&255]
+	}
+	return &n
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t float64, a float64, b float64) float64 {
+	return a + t*(b-a)
+}
+
+//grad dots the hashed cube edge gradient with the offset
+func grad(hash int, x float64, y float64, z float64) float64 {
+	h := hash & 15
+	u, v := y, z
+	if h < 8 {
+		u = x
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+//At returns the noise value in [-1,1] at x, y, z
+func (n *Noise) At(x float64, y float64, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	X, Y, Z := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+	p := n.perm
+	A, B := p[X]+Y, p[X+1]+Y
+	AA, AB, BA, BB := p[A]+Z, p[A+1]+Z, p[B]+Z, p[B+1]+Z
+	return lerp(w,
+		lerp(v, lerp(u, grad(p[AA], x, y, z), grad(p[BA], x-1, y, z)),
+			lerp(u, grad(p[AB], x, y-1, z), grad(p[BB], x-1, y-1, z))),
+		lerp(v, lerp(u, grad(p[AA+1], x, y, z-1), grad(p[BA+1], x-1, y, z-1)),
+			lerp(u, grad(p[AB+1], x, y-1, z-1), grad(p[BB+1], x-1, y-1, z-1))))
+}
+
+//potential returns a vector noise potential from three decorrelated samples
+func (n *Noise) potential(x float64, y float64, z float64) [3]float64 {
+	return [3]float64{
+		n.At(x, y, z),
+		n.At(y+31.416, z-47.853, x+12.793),
+		n.At(z-233.145, x-113.408, y+185.31),
+	}
+}
+
+//Curl returns the curl of the vector noise potential at pos. The result is a
+//divergence free field
+func (n *Noise) Curl(pos vector.Vec) vector.Vec {
+	const e = 1e-3
+	x, y, z := float64(pos[0]), float64(pos[1]), float64(pos[2])
+	dx0, dx1 := n.potential(x-e, y, z), n.potential(x+e, y, z)
+	dy0, dy1 := n.potential(x, y-e, z), n.potential(x, y+e, z)
+	dz0, dz1 := n.potential(x, y, z-e), n.potential(x, y, z+e)
+	d := func(a [3]float64, b [3]float64, k int) float64 {
+		return (b[k] - a[k]) / (2 * e)
+	}
+	return vector.Vec{
+		float32(d(dy0, dy1, 2) - d(dz0, dz1, 1)),
+		float32(d(dz0, dz1, 0) - d(dx0, dx1, 2)),
+		float32(d(dx0, dx1, 1) - d(dy0, dy1, 0)),
+	}
+}
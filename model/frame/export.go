@@ -0,0 +1,72 @@
+package frame
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Export Space Enums
+const SPACE_TANK = 0
+const SPACE_WORLD = 1
+
+//Positions returns the fluid particle positions at time t in the given space
+func (f *Frame) Positions(parts *model.ParticleArray, t float32, space int) []float32 {
+	out := make([]float32, parts.N()*3)
+	for i := 0; i < parts.N(); i++ {
+		pos := vector.Vec(parts.Position(i))
+		if space == SPACE_WORLD {
+			pos = f.ToWorld(pos, t)
+		}
+		copy(out[i*3:], pos[:3])
+	}
+	return out
+}
+
+//Velocities returns the fluid particle velocities at time t in the given space.
+//World velocities include the frame motion
+func (f *Frame) Velocities(parts *model.ParticleArray, t float32, space int) []float32 {
+	out := make([]float32, parts.N()*3)
+	for i := 0; i < parts.N(); i++ {
+		vel := vector.Vec(parts.Velocity(i))
+		if space == SPACE_WORLD {
+			vel = f.VelocityToWorld(parts.Position(i), vel, t)
+		}
+		copy(out[i*3:], vel[:3])
+	}
+	return out
+}
+
+//ExportPLY writes the fluid particles at time t as a binary little endian PLY
+//point cloud with position and velocity in the tank or world space
+func (f *Frame) ExportPLY(filename string, parts *model.ParticleArray, t float32, space int) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pos := f.Positions(parts, t, space)
+	vel := f.Velocities(parts, t, space)
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "ply\nformat binary_little_endian 1.0\ncomment dieselfluid time %f space %d\n", t, space)
+	fmt.Fprintf(w, "element vertex %d\n", parts.N())
+	fmt.Fprintf(w, "property float x\nproperty float y\nproperty float z\n")
+	fmt.Fprintf(w, "property float vx\nproperty float vy\nproperty float vz\nend_header\n")
+	buf := make([]byte, 24)
+	for i := 0; i < parts.N(); i++ {
+		for k := 0; k < 3; k++ {
+			binary.LittleEndian.PutUint32(buf[k*4:], math.Float32bits(pos[i*3+k]))
+			binary.LittleEndian.PutUint32(buf[12+k*4:], math.Float32bits(vel[i*3+k]))
+		}
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
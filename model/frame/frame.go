@@ -0,0 +1,185 @@
+//Non-inertial reference frames. A simulation may run in the frame of a moving
+//container so only the relative fluid motion is resolved. The frame motion maps
+//tank coordinates to the world, x_w = R(t) x + T(t), and the fictitious forces
+//-m (R^T T'' + w' x r + 2 w x v + w x (w x r)) are applied as a force field with
+//the angular velocity w expressed in the tank frame
+package frame
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Motion supplies a row major 4x4 rigid transform from the tank frame to the world
+type Motion interface {
+	Transform(t float32) matrix.Mat
+}
+
+//Frame is a non-inertial tank frame. Gravity is the world gravity acceleration
+//which the frame rotates into tank coordinates, zero disables it
+type Frame struct {
+	Motion  Motion
+	Gravity vector.Vec
+	Epsilon float32 //Time step for differentiating the motion
+
+	cached bool
+	at     float32
+	state  State
+}
+
+//State holds the frame kinematics at a time in tank coordinates
+type State struct {
+	Rotation     matrix.Mat //Tank to world rotation R
+	Translation  vector.Vec //World position of the tank origin T
+	Velocity     vector.Vec //World velocity of the tank origin T'
+	Acceleration vector.Vec //Tank frame acceleration of the origin R^T T''
+	Omega        vector.Vec //Tank frame angular velocity w
+	Alpha        vector.Vec //Tank frame angular acceleration w'
+}
+
+func New(motion Motion, gravity vector.Vec) *Frame {
+	return &Frame{Motion: motion, Gravity: gravity, Epsilon: 1e-2}
+}
+
+func split(m matrix.Mat) (matrix.Mat, vector.Vec) {
+	r := matrix.Mat3(0.0)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i*3+j] = m[i*4+j]
+		}
+	}
+	return r, vector.Vec{m[3], m[7], m[11]}
+}
+
+//derivative differentiates the 4x4 motion transform at t with five point stencils
+//returning the first and second derivatives of the transform entries
+func (f *Frame) derivative(t float32) ([16]float64, [16]float64) {
+	e := float64(f.Epsilon)
+	var m [5]matrix.Mat
+	for k := -2; k <= 2; k++ {
+		m[k+2] = f.Motion.Transform(t + float32(k)*f.Epsilon)
+	}
+	var d1, d2 [16]float64
+	for i := 0; i < 16; i++ {
+		a, b, c, d, g := float64(m[0][i]), float64(m[1][i]), float64(m[2][i]), float64(m[3][i]), float64(m[4][i])
+		d1[i] = (a - 8*b + 8*d - g) / (12 * e)
+		d2[i] = (-a + 16*b - 30*c + 16*d - g) / (12 * e * e)
+	}
+	return d1, d2
+}
+
+//omega returns the tank frame angular velocity and its derivative from
+//[w]x = R^T R' and [w']x = R^T R'' - [w]x [w]x
+func (f *Frame) omega(t float32) (vector.Vec, vector.Vec) {
+	r, _ := split(f.Motion.Transform(t))
+	d1, d2 := f.derivative(t)
+	var w, a [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				w[i*3+j] += float64(r[k*3+i]) * d1[k*4+j]
+				a[i*3+j] += float64(r[k*3+i]) * d2[k*4+j]
+			}
+		}
+	}
+	//Average the antisymmetric pairs of the skew matrix
+	skew := func(m [9]float64) vector.Vec {
+		return vector.Vec{float32(0.5 * (m[7] - m[5])), float32(0.5 * (m[2] - m[6])), float32(0.5 * (m[3] - m[1]))}
+	}
+	omega := skew(w)
+	//R^T R'' = [w']x + [w]x[w]x where the second term is symmetric
+	return omega, skew(a)
+}
+
+//At returns the frame kinematics at time t
+func (f *Frame) At(t float32) State {
+	if f.cached && f.at == t {
+		return f.state
+	}
+	s := State{}
+	s.Rotation, s.Translation = split(f.Motion.Transform(t))
+	d1, d2 := f.derivative(t)
+	s.Velocity = vector.Vec{float32(d1[3]), float32(d1[7]), float32(d1[11])}
+	accel := vector.Vec{float32(d2[3]), float32(d2[7]), float32(d2[11])}
+	s.Acceleration = s.Rotation.Transpose().CrossVec(accel)
+	s.Omega, s.Alpha = f.omega(t)
+	f.cached, f.at, f.state = true, t, s
+	return s
+}
+
+//Force returns the fictitious forces and the tank frame gravity on a particle at
+//tank position pos with tank relative velocity vel, implementing force.Field
+func (f *Frame) Force(pos vector.Vec, vel vector.Vec, mass float32, t float32) vector.Vec {
+	s := f.At(t)
+	a := vector.Vec{s.Acceleration[0], s.Acceleration[1], s.Acceleration[2]}
+	a = vector.Add(a, vector.Cross(s.Alpha, pos))
+	a = vector.Add(a, vector.Scale(vector.Cross(s.Omega, vel), 2))
+	a = vector.Add(a, vector.Cross(s.Omega, vector.Cross(s.Omega, pos)))
+	force := vector.Scale(a, -mass)
+	if vector.Mag(f.Gravity) > 0 {
+		force = vector.Add(force, vector.Scale(s.Rotation.Transpose().CrossVec(f.Gravity), mass))
+	}
+	return force
+}
+
+//ToWorld maps a tank position to the world at time t
+func (f *Frame) ToWorld(pos vector.Vec, t float32) vector.Vec {
+	s := f.At(t)
+	return vector.Add(s.Rotation.CrossVec(pos), s.Translation)
+}
+
+//VelocityToWorld maps a tank relative velocity at tank position pos to the world
+//velocity R (v + w x r) + T'
+func (f *Frame) VelocityToWorld(pos vector.Vec, vel vector.Vec, t float32) vector.Vec {
+	s := f.At(t)
+	return vector.Add(s.Rotation.CrossVec(vector.Add(vel, vector.Cross(s.Omega, pos))), s.Velocity)
+}
+
+//Sway is a harmonic container motion, a translation Amplitude sin(2 pi f t) and
+//a roll of RollAmplitude sin(2 pi f t + Phase) radians about RollAxis
+type Sway struct {
+	Amplitude     vector.Vec
+	RollAxis      vector.Vec
+	RollAmplitude float32
+	Frequency     float32
+	Phase         float32
+}
+
+func (s Sway) Transform(t float32) matrix.Mat {
+	w := 2 * math.Pi * float64(s.Frequency)
+	disp := float32(math.Sin(w * float64(t)))
+	angle := float64(s.RollAmplitude) * math.Sin(w*float64(t)+float64(s.Phase))
+	m := Rotation(s.RollAxis, float32(angle))
+	for k := 0; k < 3; k++ {
+		m[k*4+3] = s.Amplitude[k] * disp
+	}
+	return m
+}
+
+//Rotation returns the 4x4 rotation of angle radians about axis (Rodrigues)
+func Rotation(axis vector.Vec, angle float32) matrix.Mat {
+	m := matrix.Mat4(1.0)
+	if vector.Mag(axis) == 0 || angle == 0 {
+		return m
+	}
+	u := vector.Norm(axis)
+	c := float32(math.Cos(float64(angle)))
+	s := float32(math.Sin(float64(angle)))
+	k := [3][3]float32{{0, -u[2], u[1]}, {u[2], 0, -u[0]}, {-u[1], u[0], 0}}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			kk := float32(0)
+			for l := 0; l < 3; l++ {
+				kk += k[i][l] * k[l][j]
+			}
+			id := float32(0)
+			if i == j {
+				id = 1
+			}
+			m[i*4+j] = id + s*k[i][j] + (1-c)*kk
+		}
+	}
+	return m
+}
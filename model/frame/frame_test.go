@@ -0,0 +1,64 @@
+package frame
+
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//spin rotates the tank about y at a constant rate
+type spin struct {
+	rate float32
+}
+
+func (s spin) Transform(t float32) matrix.Mat {
+	return Rotation(vector.Vec{0, 1, 0}, s.rate*t)
+}
+
+func near(a vector.Vec, b vector.Vec, tol float32) bool {
+	return vector.Mag(vector.Sub(a, b)) <= tol*(1+vector.Mag(b))
+}
+
+func TestSwayAcceleration(t *testing.T) {
+	sway := Sway{Amplitude: vector.Vec{0.1, 0, 0}, Frequency: 0.5}
+	f := New(sway, vector.Vec{0, 0, 0})
+	tm := float32(0.4)
+	w := 2 * math.Pi * 0.5
+	//The tank accelerates as -A w^2 sin(w t) so the fictitious force is its negative
+	expected := vector.Vec{float32(0.1 * w * w * math.Sin(w*float64(tm))), 0, 0}
+	force := f.Force(vector.Vec{0.3, 0.2, 0}, vector.Vec{0, 0, 0}, 1, tm)
+	if !near(force, expected, 1e-3) {
+		t.Errorf("Sway fictitious force expected %v got %v\n", expected, force)
+	}
+}
+
+func TestRotatingFrame(t *testing.T) {
+	rate := float32(2.0)
+	f := New(spin{rate}, vector.Vec{0, -9.81, 0})
+	s := f.At(1)
+	if !near(s.Omega, vector.Vec{0, rate, 0}, 1e-3) || vector.Mag(s.Alpha) > 1e-2 {
+		t.Errorf("Angular velocity %v acceleration %v\n", s.Omega, s.Alpha)
+	}
+	//Centrifugal force points outward with magnitude w^2 r, gravity stays on y
+	force := f.Force(vector.Vec{0.5, 0, 0}, vector.Vec{0, 0, 0}, 1, 1)
+	if !near(force, vector.Vec{rate * rate * 0.5, -9.81, 0}, 1e-3) {
+		t.Errorf("Centrifugal force %v\n", force)
+	}
+	//Coriolis force -2 w x v at the origin
+	force = f.Force(vector.Vec{0, 0, 0}, vector.Vec{1, 0, 0}, 1, 1)
+	if !near(force, vector.Vec{0, -9.81, 2 * rate}, 1e-3) {
+		t.Errorf("Coriolis force %v\n", force)
+	}
+	//A particle at rest in the tank moves with the rotation in the world
+	pos := vector.Vec{0.5, 0, 0}
+	world := f.ToWorld(pos, 1)
+	if math.Abs(float64(vector.Mag(world)-0.5)) > 1e-5 {
+		t.Errorf("World position %v\n", world)
+	}
+	vel := f.VelocityToWorld(pos, vector.Vec{0, 0, 0}, 1)
+	if math.Abs(float64(vector.Mag(vel)-rate*0.5)) > 1e-3 || math.Abs(float64(vector.Dot(vel, world))) > 1e-3 {
+		t.Errorf("World velocity %v\n", vel)
+	}
+}
@@ -0,0 +1,25 @@
+package model
+
+//Lattice returns the n[0] x n[1] x n[2] points of a cubic lattice with spacing dx
+//starting at origin, z varying fastest
+func Lattice(origin [3]float32, n [3]int, dx float32) [][3]float32 {
+	points := make([][3]float32, 0, n[0]*n[1]*n[2])
+	for x := 0; x < n[0]; x++ {
+		for y := 0; y < n[1]; y++ {
+			for z := 0; z < n[2]; z++ {
+				points = append(points, [3]float32{origin[0] + float32(x)*dx, origin[1] + float32(y)*dx, origin[2] + float32(z)*dx})
+			}
+		}
+	}
+	return points
+}
+
+//NewLatticeArray creates an array of water particles at rest on points with the
+//kernel length 2dx of a lattice of spacing dx, each at the reference density
+func NewLatticeArray(points [][3]float32, dx float32) ParticleArray {
+	parts := NewParticleArray(len(points), 0, 2*dx, 1/(dx*dx*dx), 1000*dx*dx*dx)
+	for i, p := range points {
+		parts.Set(i, Particle{Position: p, Density: parts.D0()})
+	}
+	return parts
+}
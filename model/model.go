@@ -57,6 +57,7 @@ type ParticleField interface {
 	Velocity(x int) []float32
 	Force(x int) []float32
 	Mass() float32
+	ParticleMass(x int) float32
 	Set(x int, particle Particle)
 	Get(x int) Particle
 	D0() float32
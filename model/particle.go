@@ -2,11 +2,13 @@ package model
 
 //Fixed Particle structure array
 type Particle struct {
-	Position [3]float32
-	Velocity [3]float32
-	Force    [3]float32
-	Density  float32
-	Press    float32
+	Position    [3]float32
+	Velocity    [3]float32
+	Force       [3]float32
+	Density     float32
+	Press       float32
+	Temperature float32
+	Phase       int
 }
 
 //Add vector b to a. Mutates a
@@ -8,6 +8,12 @@ type ParticleArray struct {
 	densities        []float32
 	forces           []float32
 	pressures        []float32
+	masses           []float32
+	temperatures     []float32
+	phases           []int
+	stresses         []float32
+	boundary_press   []float32
+	boundary_vel     []float32
 	n_particles      int
 	n_boundary       int
 	mass             float32
@@ -22,7 +28,16 @@ func NewParticleArray(n_particles int, n_boundary int, kernel float32, density f
 	parray.densities = make([]float32, (n_particles))
 	parray.forces = make([]float32, (n_particles)*3)
 	parray.pressures = make([]float32, (n_particles))
+	parray.masses = make([]float32, (n_particles))
+	parray.temperatures = make([]float32, (n_particles + n_boundary))
+	parray.phases = make([]int, (n_particles))
+	parray.stresses = make([]float32, (n_particles)*9)
+	parray.boundary_press = make([]float32, (n_boundary))
+	parray.boundary_vel = make([]float32, (n_boundary)*3)
 	parray.mass = mass
+	for i := range parray.masses {
+		parray.masses[i] = mass
+	}
 	parray.ReferenceDensity = density * parray.mass
 	parray.n_particles = n_particles
 	parray.n_boundary = n_boundary
@@ -80,9 +95,40 @@ func (p *ParticleArray) Force(index int) []float32 {
 	}
 	return []float32{p.forces[x], p.forces[x+1], p.forces[x+2]}
 }
+
+//Mass returns the reference particle mass the array was allocated with, see
+//ParticleMass() for the mass carried by an individual particle
 func (p *ParticleArray) Mass() float32 {
 	return p.mass
 }
+
+//ParticleMass returns the mass of particle index, boundary particles carry the
+//reference mass
+func (p *ParticleArray) ParticleMass(index int) float32 {
+	if index >= 0 && index < len(p.masses) {
+		return p.masses[index]
+	}
+	return p.mass
+}
+
+func (p *ParticleArray) SetParticleMass(index int, m float32) {
+	if index >= 0 && index < len(p.masses) {
+		p.masses[index] = m
+	}
+}
+
+func (p *ParticleArray) Masses() []float32 {
+	return p.masses
+}
+
+//TotalMass sums the mass of all fluid particles
+func (p *ParticleArray) TotalMass() float32 {
+	sum := float32(0.0)
+	for i := 0; i < p.n_particles; i++ {
+		sum += p.masses[i]
+	}
+	return sum
+}
 func (p *ParticleArray) Set(index int, particle Particle) {
 	x := index * 3
 	Float3_buffer_set(x, p.positions, &particle.Position)
@@ -90,16 +136,19 @@ func (p *ParticleArray) Set(index int, particle Particle) {
 	Float3_buffer_set(x, p.forces, &particle.Force)
 	p.densities[index] = particle.Density
 	p.pressures[index] = particle.Press
+	p.temperatures[index] = particle.Temperature
+	p.phases[index] = particle.Phase
 }
 func (p *ParticleArray) Get(index int) Particle {
 	x := index * 3
 	particle := Particle{}
 
-	if index > p.n_particles && index < p.Total() {
+	if index >= p.n_particles && index < p.Total() {
 		Float3_set(x, &particle.Position, p.positions)
-		particle.Press = 0
-		particle.Density = 0
-		particle.Velocity = [3]float32{0, 0, 0}
+		particle.Press = p.boundary_press[index-p.n_particles]
+		particle.Density = p.ReferenceDensity
+		particle.Temperature = p.temperatures[index]
+		Float3_set((index-p.n_particles)*3, &particle.Velocity, p.boundary_vel)
 		particle.Force = [3]float32{0, 0, 0}
 		return particle
 	}
@@ -110,6 +159,8 @@ func (p *ParticleArray) Get(index int) Particle {
 		Float3_set(x, &particle.Force, p.forces)
 		particle.Density = p.densities[index]
 		particle.Press = p.pressures[index]
+		particle.Temperature = p.temperatures[index]
+		particle.Phase = p.phases[index]
 		return particle
 	}
 
@@ -123,10 +174,72 @@ func (p *ParticleArray) D0() float32 {
 func (p *ParticleArray) AddBoundaryParticles(positions []float32) []float32 {
 	p.n_boundary += len(positions) / 3
 	p.positions = append(p.positions, positions...)
+	p.temperatures = append(p.temperatures, make([]float32, len(positions)/3)...)
+	p.boundary_press = append(p.boundary_press, make([]float32, len(positions)/3)...)
+	p.boundary_vel = append(p.boundary_vel, make([]float32, len(positions))...)
 	return p.positions
 
 }
 
+//SetBoundaryPressure sets the extrapolated wall pressure of boundary particle index
+func (p *ParticleArray) SetBoundaryPressure(index int, press float32) {
+	b := index - p.n_particles
+	if b >= 0 && b < len(p.boundary_press) {
+		p.boundary_press[b] = press
+	}
+}
+
+//SetBoundary moves boundary particle index and sets its wall velocity
+func (p *ParticleArray) SetBoundary(index int, position [3]float32, velocity [3]float32) {
+	b := index - p.n_particles
+	if b >= 0 && b < p.n_boundary {
+		Float3_buffer_set(index*3, p.positions, &position)
+		Float3_buffer_set(b*3, p.boundary_vel, &velocity)
+	}
+}
+
+func (p *ParticleArray) Temperature(index int) float32 {
+	return p.temperatures[index]
+}
+
+//SetTemperature sets the temperature of any particle including boundary particles
+func (p *ParticleArray) SetTemperature(index int, t float32) {
+	p.temperatures[index] = t
+}
+
+func (p *ParticleArray) Temperatures() []float32 {
+	return p.temperatures
+}
+
+func (p *ParticleArray) Phase(index int) int {
+	return p.phases[index]
+}
+
+func (p *ParticleArray) SetPhase(index int, phase int) {
+	p.phases[index] = phase
+}
+
+//Stress returns a copy of the row major 3x3 Cauchy stress tensor of particle index
+func (p *ParticleArray) Stress(index int) []float32 {
+	x := index * 9
+	stress := make([]float32, 9)
+	if x >= 0 && x+9 <= len(p.stresses) {
+		copy(stress, p.stresses[x:x+9])
+	}
+	return stress
+}
+
+func (p *ParticleArray) SetStress(index int, stress []float32) {
+	x := index * 9
+	if x >= 0 && x+9 <= len(p.stresses) {
+		copy(p.stresses[x:x+9], stress)
+	}
+}
+
+func (p *ParticleArray) Stresses() []float32 {
+	return p.stresses
+}
+
 func (p *ParticleArray) N() int {
 	return p.n_particles
 }
@@ -134,3 +247,53 @@ func (p *ParticleArray) N() int {
 func (p *ParticleArray) Total() int {
 	return p.n_particles + p.n_boundary
 }
+
+//Insert appends a fluid particle with mass m ahead of the boundary particles and
+//returns its index. Boundary particle positions are shifted up by one slot
+func (p *ParticleArray) Insert(particle Particle, m float32) int {
+	index := p.n_particles
+	x := index * 3
+	positions := make([]float32, len(p.positions)+3)
+	copy(positions, p.positions[:x])
+	copy(positions[x+3:], p.positions[x:])
+	p.positions = positions
+	temperatures := make([]float32, len(p.temperatures)+1)
+	copy(temperatures, p.temperatures[:index])
+	copy(temperatures[index+1:], p.temperatures[index:])
+	p.temperatures = temperatures
+	p.phases = append(p.phases, 0)
+	p.stresses = append(p.stresses, make([]float32, 9)...)
+	p.velocities = append(p.velocities, 0, 0, 0)
+	p.forces = append(p.forces, 0, 0, 0)
+	p.densities = append(p.densities, 0)
+	p.pressures = append(p.pressures, 0)
+	p.masses = append(p.masses, m)
+	p.n_particles++
+	p.Set(index, particle)
+	return index
+}
+
+//Remove deletes fluid particle index by moving the last fluid particle into its
+//slot. Indexes held for the last fluid particle are invalidated
+func (p *ParticleArray) Remove(index int) {
+	if index < 0 || index >= p.n_particles {
+		return
+	}
+	last := p.n_particles - 1
+	if index != last {
+		p.Set(index, p.Get(last))
+		p.masses[index] = p.masses[last]
+		p.SetStress(index, p.Stress(last))
+	}
+	x := last * 3
+	p.positions = append(p.positions[:x], p.positions[x+3:]...)
+	p.temperatures = append(p.temperatures[:last], p.temperatures[last+1:]...)
+	p.phases = p.phases[:last]
+	p.stresses = p.stresses[:last*9]
+	p.velocities = p.velocities[:x]
+	p.forces = p.forces[:x]
+	p.densities = p.densities[:last]
+	p.pressures = p.pressures[:last]
+	p.masses = p.masses[:last]
+	p.n_particles--
+}
@@ -106,6 +106,10 @@ func (p ParticleStructField) Mass() float32 {
 	return p.mass
 }
 
+func (p ParticleStructField) ParticleMass(x int) float32 {
+	return p.mass
+}
+
 func (p ParticleStructField) Set(x int, particle Particle) {
 	p.Particles[x] = particle
 }
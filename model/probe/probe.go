@@ -0,0 +1,224 @@
+//Virtual probes and sensors. Probes sample the SPH fields at user placed points,
+//lines and planes by Shepard normalized kernel interpolation and force sensors
+//integrate the fluid pressure on a range of boundary particles. A Recorder
+//samples its sensors at a fixed cadence and writes time series as CSV rows or
+//JSON lines for comparison with laboratory gauges
+package probe
+
+import (
+	"strconv"
+
+	"github.com/andewx/dieselfluid/kernel"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model/field"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Probe Quantity Enums
+const QUANTITY_DENSITY = 0
+const QUANTITY_PRESSURE = 1
+const QUANTITY_SPEED = 2
+const QUANTITY_HEIGHT = 3
+
+//WET_FRACTION is the fluid volume fraction above which a sample point is wet
+const WET_FRACTION = 0.5
+
+var quantityNames = []string{"density", "pressure", "speed", "height"}
+
+//QuantityName returns the output name of a probe quantity
+func QuantityName(quantity int) string {
+	if quantity < 0 || quantity >= len(quantityNames) {
+		return "unknown"
+	}
+	return quantityNames[quantity]
+}
+
+//Probe samples quantities at a set of points. Density, pressure and speed are
+//reported per point. Height is a single value, the distance from the first point
+//to the last point where the fluid fraction crosses WET_FRACTION, so a line probe
+//from the floor upward acts as a wave gauge
+type Probe struct {
+	Name       string
+	Points     []vector.Vec
+	Quantities []int
+}
+
+//NewPoint creates a probe at a single position
+func NewPoint(name string, position vector.Vec, quantities ...int) *Probe {
+	return &Probe{name, []vector.Vec{copyVec(position)}, quantities}
+}
+
+//NewLine creates a probe of samples points evenly spaced from start to end
+func NewLine(name string, start vector.Vec, end vector.Vec, samples int, quantities ...int) *Probe {
+	points := make([]vector.Vec, samples)
+	for k := 0; k < samples; k++ {
+		u := float32(0)
+		if samples > 1 {
+			u = float32(k) / float32(samples-1)
+		}
+		points[k] = vector.Add(start, vector.Scale(vector.Sub(end, start), u))
+	}
+	return &Probe{name, points, quantities}
+}
+
+//NewPlane creates a probe of nu by nv points spanning the parallelogram origin +
+//s u + t v for s, t in [0,1]. Points are ordered with u varying fastest
+func NewPlane(name string, origin vector.Vec, u vector.Vec, v vector.Vec, nu int, nv int, quantities ...int) *Probe {
+	points := make([]vector.Vec, 0, nu*nv)
+	for j := 0; j < nv; j++ {
+		for i := 0; i < nu; i++ {
+			s, t := float32(0), float32(0)
+			if nu > 1 {
+				s = float32(i) / float32(nu-1)
+			}
+			if nv > 1 {
+				t = float32(j) / float32(nv-1)
+			}
+			points = append(points, vector.Add(origin, vector.Add(vector.Scale(u, s), vector.Scale(v, t))))
+		}
+	}
+	return &Probe{name, points, quantities}
+}
+
+func copyVec(v vector.Vec) vector.Vec {
+	return vector.Vec{v[0], v[1], v[2]}
+}
+
+//Interpolate returns the Shepard normalized interpolant of f at pos, zero where
+//no particle lies within the kernel support
+func Interpolate(fld *field.SPHField, pos vector.Vec, f field.Field) float32 {
+	norm := fld.Interpolate(pos, field.ConstantField{X: 1})
+	if norm <= 0 {
+		return 0
+	}
+	return fld.Interpolate(pos, f) / norm
+}
+
+//Fraction returns the fluid volume fraction at pos, the fluid kernel sum relative
+//to the kernel integral so that kernels without unit normalization read 1 in bulk
+func Fraction(fld *field.SPHField, pos vector.Vec) float32 {
+	return fld.Interpolate(pos, field.FluidField{Ref: fld.Particles}) / kernel.Integral(fld.Kernel())
+}
+
+//Sample evaluates the probe quantities in order, see Probe for the layout. Dry
+//points report zero density, pressure and speed
+func (p *Probe) Sample(core *sph.SPH) []float32 {
+	fld := core.Field()
+	parts := fld.Particles
+	values := make([]float32, 0, len(p.Points)*len(p.Quantities))
+	wet := make([]bool, len(p.Points))
+	fraction := make([]float32, len(p.Points))
+	for k, pos := range p.Points {
+		fraction[k] = Fraction(fld, pos)
+		wet[k] = fraction[k] >= WET_FRACTION
+	}
+	for _, q := range p.Quantities {
+		if q == QUANTITY_HEIGHT {
+			values = append(values, p.height(fraction))
+			continue
+		}
+		var f field.Field
+		switch q {
+		case QUANTITY_DENSITY:
+			f = field.DensityField{Ref: parts}
+		case QUANTITY_PRESSURE:
+			f = field.PressureField{Ref: parts}
+		default:
+			f = field.SpeedField{Ref: parts}
+		}
+		for k, pos := range p.Points {
+			value := float32(0)
+			if wet[k] {
+				value = Interpolate(fld, pos, f)
+			}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+//height returns the distance from the first point to the last wet point, moved
+//to the linearly interpolated crossing of the wet fraction with the next point
+func (p *Probe) height(fraction []float32) float32 {
+	for k := len(p.Points) - 1; k >= 0; k-- {
+		if fraction[k] < WET_FRACTION {
+			continue
+		}
+		pos := p.Points[k]
+		if k+1 < len(p.Points) {
+			u := (fraction[k] - WET_FRACTION) / (fraction[k] - fraction[k+1])
+			pos = vector.Add(pos, vector.Scale(vector.Sub(p.Points[k+1], pos), u))
+		}
+		return vector.Mag(vector.Sub(pos, p.Points[0]))
+	}
+	return 0
+}
+
+//Columns returns the output column names of the probe values
+func (p *Probe) Columns() []string {
+	columns := make([]string, 0, len(p.Points)*len(p.Quantities))
+	for _, q := range p.Quantities {
+		if q == QUANTITY_HEIGHT || len(p.Points) == 1 {
+			columns = append(columns, p.Name+"."+QuantityName(q))
+			continue
+		}
+		for k := range p.Points {
+			columns = append(columns, p.Name+"."+QuantityName(q)+"."+strconv.Itoa(k))
+		}
+	}
+	return columns
+}
+
+//ForceSensor integrates the fluid pressure force on a collider given by a range
+//of boundary particles. First is the offset from the first boundary particle as
+//used by kinematic colliders. The torque is taken about Center
+type ForceSensor struct {
+	Name   string
+	First  int
+	Count  int
+	Center vector.Vec
+}
+
+func NewForceSensor(name string, first int, count int) *ForceSensor {
+	return &ForceSensor{name, first, count, vector.Vec{0, 0, 0}}
+}
+
+//Sample returns the force and the torque on the collider as the reaction of the
+//symmetric pressure force the fluid particles receive from the boundary particles,
+//using the neighbor lists and pressures of the last solver step
+func (s *ForceSensor) Sample(core *sph.SPH) (vector.Vec, vector.Vec) {
+	fld := core.Field()
+	parts := fld.Particles
+	kern := fld.Kernel()
+	n := parts.N()
+	force, torque := vector.Vec{0, 0, 0}, vector.Vec{0, 0, 0}
+	for b := n + s.First; b < n+s.First+s.Count && b < parts.Total(); b++ {
+		boundary := parts.Get(b)
+		pb := boundary.Press / (boundary.Density * boundary.Density)
+		fb := vector.Vec{0, 0, 0}
+		for _, i := range fld.Neighbors(b) {
+			if i >= n {
+				continue
+			}
+			particle := parts.Get(i)
+			pi := particle.Press / (particle.Density * particle.Density)
+			dir := fld.Separation(boundary.Position[:], particle.Position[:])
+			grad := kern.Grad(vector.Mag(dir), vector.Norm(dir))
+			fb = vector.Add(fb, vector.Scale(grad, -parts.ParticleMass(i)*(pb+pi)))
+		}
+		fb = vector.Scale(fb, parts.ParticleMass(b))
+		force = vector.Add(force, fb)
+		torque = vector.Add(torque, vector.Cross(vector.Sub(boundary.Position[:], s.Center), fb))
+	}
+	return force, torque
+}
+
+//Columns returns the output column names of the force sensor values
+func (s *ForceSensor) Columns() []string {
+	names := []string{"fx", "fy", "fz", "tx", "ty", "tz"}
+	columns := make([]string, len(names))
+	for k, name := range names {
+		columns[k] = s.Name + "." + name
+	}
+	return columns
+}
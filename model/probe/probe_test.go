@@ -0,0 +1,81 @@
+package probe
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+const dx = float32(0.05)
+const side = 8
+
+//A compressed block of fluid moving along x resting on a floor of three layers
+func tank() sph.SPH {
+	parts := model.NewLatticeArray(model.Lattice([3]float32{0.5 * dx, 0.5 * dx, 0.5 * dx}, [3]int{side, side, side}, dx), dx)
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		particle.Velocity = [3]float32{1, 0, 0}
+		particle.Density = 1010
+		parts.Set(i, particle)
+	}
+	floor := make([]float32, 0)
+	for _, p := range model.Lattice([3]float32{0.5 * dx, -2.5 * dx, 0.5 * dx}, [3]int{side, 3, side}, dx) {
+		floor = append(floor, p[:]...)
+	}
+	parts.AddBoundaryParticles(floor)
+	core := sph.New(&parts, 2*dx)
+	core.SetEquationOfState(10, 7)
+	core.ClearForceFields()
+	return core
+}
+
+func TestProbes(t *testing.T) {
+	core := tank()
+	mid := float32(side) * dx / 2
+	point := NewPoint("point", vector.Vec{mid, mid, mid}, QUANTITY_DENSITY, QUANTITY_SPEED)
+	gauge := NewLine("gauge", vector.Vec{mid, 0, mid}, vector.Vec{mid, 1, mid}, 101, QUANTITY_HEIGHT)
+	floor := NewForceSensor("floor", 0, side*side*3)
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf, FORMAT_CSV, 0.1)
+	recorder.AddProbe(point)
+	recorder.AddProbe(gauge)
+	recorder.AddForceSensor(floor)
+	//Neighbors and pressures as left by a solver step
+	core.NN()
+	core.PressureAll()
+	core.BoundaryPressureAll()
+	if sampled, err := recorder.Update(&core); !sampled || err != nil {
+		t.Fatalf("Recorder did not sample at time 0: %v\n", err)
+	}
+	if sampled, _ := recorder.Update(&core); sampled {
+		t.Errorf("Recorder sampled twice within the interval\n")
+	}
+
+	values := point.Sample(&core)
+	if math.Abs(float64(values[0]-1010)) > 1 || math.Abs(float64(values[1]-1)) > 1e-3 {
+		t.Errorf("Point probe density and speed %v\n", values)
+	}
+	height := float64(gauge.Sample(&core)[0])
+	if math.Abs(height-float64(side*dx)) > float64(dx) {
+		t.Errorf("Wave gauge expected height %f got %f\n", side*dx, height)
+	}
+
+	//The floor carries the block pressure over its area
+	press := float64(core.Particles().Get(0).Press)
+	area := float64(side*dx) * float64(side*dx)
+	force, _ := floor.Sample(&core)
+	if force[1] >= 0 || math.Abs(-float64(force[1])/(press*area)-1) > 0.25 {
+		t.Errorf("Floor force %v expected %f\n", force, -press*area)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || len(strings.Split(lines[0], ",")) != 1+2+1+6 || len(strings.Split(lines[1], ",")) != 10 {
+		t.Errorf("CSV records\n%s\n", buf.String())
+	}
+}
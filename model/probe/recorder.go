@@ -0,0 +1,117 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Output Format Enums
+const FORMAT_CSV = 0
+const FORMAT_JSON = 1
+
+//Record holds the sensor values at a sample time keyed by probe or sensor name
+type Record struct {
+	Time   float32              `json:"time"`
+	Probes map[string][]float32 `json:"probes"`
+	Forces map[string][]float32 `json:"forces"` //Force followed by torque
+}
+
+//Recorder samples its probes and force sensors every Interval seconds of
+//simulation time and writes each record to Output
+type Recorder struct {
+	Probes   []*Probe
+	Forces   []*ForceSensor
+	Interval float32
+	Output   io.Writer //Record sink - nil disables output
+	Format   int
+
+	next   float32
+	header bool
+}
+
+func NewRecorder(w io.Writer, format int, interval float32) *Recorder {
+	return &Recorder{Output: w, Format: format, Interval: interval}
+}
+
+func (r *Recorder) AddProbe(p *Probe) {
+	r.Probes = append(r.Probes, p)
+}
+
+func (r *Recorder) AddForceSensor(s *ForceSensor) {
+	r.Forces = append(r.Forces, s)
+}
+
+//Update samples the sensors when the simulation clock has reached the next
+//sample time. It returns whether a record was taken
+func (r *Recorder) Update(core *sph.SPH) (bool, error) {
+	if core.Elapsed() < r.next {
+		return false, nil
+	}
+	for r.next <= core.Elapsed() {
+		r.next += r.Interval
+		if r.Interval <= 0 {
+			break
+		}
+	}
+	_, err := r.Sample(core)
+	return true, err
+}
+
+//Sample records all sensors now. It must be called after a solver step since
+//it reads the neighbor lists and pressures stored by that step, the solver
+//state is not modified
+func (r *Recorder) Sample(core *sph.SPH) (Record, error) {
+	rec := Record{core.Elapsed(), make(map[string][]float32), make(map[string][]float32)}
+	for _, p := range r.Probes {
+		rec.Probes[p.Name] = p.Sample(core)
+	}
+	for _, s := range r.Forces {
+		force, torque := s.Sample(core)
+		rec.Forces[s.Name] = []float32{force[0], force[1], force[2], torque[0], torque[1], torque[2]}
+	}
+	return rec, r.write(&rec)
+}
+
+func (r *Recorder) write(rec *Record) error {
+	if r.Output == nil {
+		return nil
+	}
+	if r.Format == FORMAT_JSON {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = r.Output.Write(append(line, '\n'))
+		return err
+	}
+	if !r.header {
+		r.header = true
+		columns := []string{"time"}
+		for _, p := range r.Probes {
+			columns = append(columns, p.Columns()...)
+		}
+		for _, s := range r.Forces {
+			columns = append(columns, s.Columns()...)
+		}
+		if _, err := io.WriteString(r.Output, strings.Join(columns, ",")+"\n"); err != nil {
+			return err
+		}
+	}
+	row := []string{fmt.Sprint(rec.Time)}
+	for _, p := range r.Probes {
+		for _, v := range rec.Probes[p.Name] {
+			row = append(row, fmt.Sprint(v))
+		}
+	}
+	for _, s := range r.Forces {
+		for _, v := range rec.Forces[s.Name] {
+			row = append(row, fmt.Sprint(v))
+		}
+	}
+	_, err := io.WriteString(r.Output, strings.Join(row, ",")+"\n")
+	return err
+}
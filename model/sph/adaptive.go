@@ -0,0 +1,159 @@
+package sph
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Split child offsets - regular tetrahedron whose vertices sum to zero so that
+//the children share the parent center of mass
+var tetrahedron = [4][3]float32{
+	{1, 1, 1},
+	{1, -1, -1},
+	{-1, 1, -1},
+	{-1, -1, 1},
+}
+
+//Adaptivity configures adaptive particle resolution. Particles near the free
+//surface or a collider (boundary particle neighbor) are split into four lighter
+//children while refined interior particles are merged back pairwise. Mass bounds
+//are ratios of the reference particle mass
+type Adaptivity struct {
+	MinMass          float32 //Smallest mass ratio a split may produce
+	MaxMass          float32 //Largest mass ratio a merge may produce
+	SurfaceNeighbors int     //Particles with fewer neighbors are free surface particles
+	Spread           float32 //Child offset as a fraction of the parent particle spacing
+}
+
+//DefaultAdaptivity allows two levels of refinement and merges back up to the
+//reference mass
+func DefaultAdaptivity() Adaptivity {
+	return Adaptivity{MinMass: 1.0 / 16.0, MaxMass: 1.0, SurfaceNeighbors: 20, Spread: 0.25}
+}
+
+//Classify marks fluid particles for refinement. Returns per particle flags where
+//true denotes a free surface or collider adjacent particle
+func (p *SPH) Classify(cfg Adaptivity) []bool {
+	n := p.field.Particles.N()
+	refine := make([]bool, n)
+	for i := 0; i < n; i++ {
+		neighbors := p.field.Neighbors(i)
+		if len(neighbors) < cfg.SurfaceNeighbors {
+			refine[i] = true
+			continue
+		}
+		for _, j := range neighbors {
+			if j >= n {
+				refine[i] = true
+				break
+			}
+		}
+	}
+	return refine
+}
+
+//Adapt splits refinement particles and merges interior particles lighter than the
+//reference mass. Total mass and linear momentum are conserved exactly. Returns the
+//number of split and merged particles. The sampler is rebuilt when the particle
+//count changes
+func (p *SPH) Adapt(cfg Adaptivity) (int, int) {
+	parts := p.field.Particles
+	n := parts.N()
+	m0 := parts.Mass()
+	d0 := parts.D0()
+	refine := p.Classify(cfg)
+	used := make([]bool, n)
+	removals := make([]int, 0)
+	children := make([]model.Particle, 0)
+	childMass := make([]float32, 0)
+	splits := 0
+
+	for i := 0; i < n; i++ {
+		mi := parts.ParticleMass(i)
+
+		//Split into tetrahedral children
+		if refine[i] {
+			child := mi / float32(len(tetrahedron))
+			if child < cfg.MinMass*m0 {
+				continue
+			}
+			spacing := float32(math.Cbrt(float64(mi / d0)))
+			offset := cfg.Spread * spacing / float32(math.Sqrt(3))
+			parent := parts.Get(i)
+			for c := 1; c < len(tetrahedron); c++ {
+				particle := parent
+				for k := 0; k < 3; k++ {
+					particle.Position[k] += tetrahedron[c][k] * offset
+				}
+				children = append(children, particle)
+				childMass = append(childMass, child)
+			}
+			for k := 0; k < 3; k++ {
+				parent.Position[k] += tetrahedron[0][k] * offset
+			}
+			parts.Set(i, parent)
+			parts.SetParticleMass(i, child)
+			used[i] = true
+			splits++
+			continue
+		}
+
+		//Merge with the nearest light interior neighbor
+		if used[i] || mi >= m0 {
+			continue
+		}
+		pos := parts.Position(i)
+		nearest := -1
+		nearestDist := float32(math.MaxFloat32)
+		for _, j := range p.field.Neighbors(i) {
+			if j >= n || used[j] || refine[j] || j == i {
+				continue
+			}
+			mj := parts.ParticleMass(j)
+			if mj >= m0 || mi+mj > cfg.MaxMass*m0 {
+				continue
+			}
+			if d := vector.Mag(p.field.Separation(pos, parts.Position(j))); d < nearestDist {
+				nearest = j
+				nearestDist = d
+			}
+		}
+		if nearest < 0 {
+			continue
+		}
+		a := parts.Get(i)
+		b := parts.Get(nearest)
+		mj := parts.ParticleMass(nearest)
+		m := mi + mj
+		for k := 0; k < 3; k++ {
+			a.Position[k] = (mi*a.Position[k] + mj*b.Position[k]) / m
+			a.Velocity[k] = (mi*a.Velocity[k] + mj*b.Velocity[k]) / m
+			a.Force[k] += b.Force[k]
+		}
+		parts.Set(i, a)
+		parts.SetParticleMass(i, m)
+		used[i] = true
+		used[nearest] = true
+		removals = append(removals, nearest)
+	}
+
+	//Remove merged particles from the highest index so swaps never move a
+	//particle still pending removal
+	sort.Sort(sort.Reverse(sort.IntSlice(removals)))
+	for _, j := range removals {
+		parts.Remove(j)
+	}
+	for c := range children {
+		parts.Insert(children[c], childMass[c])
+	}
+
+	if splits > 0 || len(removals) > 0 {
+		p.particles = parts.N()
+		p.field.Resize()
+		p.NN()
+	}
+	return splits, len(removals)
+}
@@ -0,0 +1,268 @@
+package sph
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/geom/sdf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/sampler/cell"
+)
+
+//Inflow is a buffer zone where fluid particles are held at a prescribed velocity
+//and pressure. New particle layers are emitted from the upstream face of the zone
+//each time the flow has advanced by one particle spacing. An optional Shape
+//limits the zone to its inside and layers are emitted over its cross section
+//where the flow enters it
+type Inflow struct {
+	Min      vector.Vec
+	Max      vector.Vec
+	Velocity vector.Vec
+	Pressure float32
+	Spacing  float32
+	Budget   int //Maximum fluid particle count, 0 is unbounded
+	Shape    sdf.Shape
+	travel   float32 //Flow distance since the last emitted layer
+	columns  []inflowColumn
+	section  int //Flow axis and direction of the cached shape columns, 0 for none
+}
+
+//Outflow is a zone where fluid particles leave the simulation. An optional
+//Shape limits the zone to its inside, making a kill region
+type Outflow struct {
+	Min   vector.Vec
+	Max   vector.Vec
+	Shape sdf.Shape
+}
+
+//shapeBox returns the bounds of a bounded shape as zone corners
+func shapeBox(s sdf.Shape) (vector.Vec, vector.Vec) {
+	min, max := s.Bounds()
+	return vector.Cast(min), vector.Cast(max)
+}
+
+//NewShapeInflow creates an emitter filling the inside of a bounded shape with
+//layers moving at velocity
+func NewShapeInflow(s sdf.Shape, velocity vector.Vec, pressure float32, spacing float32) *Inflow {
+	min, max := shapeBox(s)
+	return &Inflow{Min: min, Max: max, Velocity: velocity, Pressure: pressure, Spacing: spacing, Shape: s}
+}
+
+//NewShapeOutflow creates a kill region removing the particles inside a bounded shape
+func NewShapeOutflow(s sdf.Shape) *Outflow {
+	min, max := shapeBox(s)
+	return &Outflow{min, max, s}
+}
+
+func inZone(pos []float32, min vector.Vec, max vector.Vec, shape sdf.Shape) bool {
+	for k := 0; k < 3; k++ {
+		if pos[k] < min[k] || pos[k] > max[k] {
+			return false
+		}
+	}
+	return shape == nil || sdf.Inside(shape, pos)
+}
+
+//SetDomain sets the simulation domain. Neighbor queries switch to a cell list
+//sampler which wraps across periodic axes, and particle separations use the
+//nearest periodic image
+func (p *SPH) SetDomain(d *grid.Domain) {
+	p.domain = d
+	p.field.SetDomain(d)
+	p.field.SetNeighborhood(cell.New(p.field.Particles, p.field.GetKernelLength(), d))
+}
+
+func (p *SPH) Domain() *grid.Domain {
+	return p.domain
+}
+
+func (p *SPH) AddInflow(in *Inflow) {
+	p.inflows = append(p.inflows, in)
+}
+
+func (p *SPH) AddOutflow(out *Outflow) {
+	p.outflows = append(p.outflows, out)
+}
+
+//inflowPressure overrides the equation of state pressure inside inflow zones
+func (p *SPH) inflowPressure() {
+	if len(p.inflows) == 0 {
+		return
+	}
+	parts := p.field.Particles
+	for i := 0; i < parts.N(); i++ {
+		pos := parts.Position(i)
+		for _, in := range p.inflows {
+			if inZone(pos, in.Min, in.Max, in.Shape) {
+				particle := parts.Get(i)
+				particle.Press = in.Pressure
+				parts.Set(i, particle)
+				break
+			}
+		}
+	}
+}
+
+//BoundaryAll applies the domain boundaries after a position update. Positions on
+//periodic axes are wrapped, particles inside outflow zones are removed, inflow
+//buffer particles are reset to the prescribed velocity and new inflow layers are
+//emitted. Returns the number of emitted and removed particles
+func (p *SPH) BoundaryAll() (int, int) {
+	parts := p.field.Particles
+	n := parts.N()
+
+	if p.domain != nil {
+		for i := 0; i < n; i++ {
+			particle := parts.Get(i)
+			pos := vector.Cast(particle.Position)
+			if p.domain.Wrap(pos) {
+				particle.Position = vector.CastFixed(pos)
+				parts.Set(i, particle)
+			}
+		}
+	}
+
+	removals := make([]int, 0)
+	for i := 0; i < n && len(p.outflows) > 0; i++ {
+		pos := parts.Position(i)
+		for _, out := range p.outflows {
+			if inZone(pos, out.Min, out.Max, out.Shape) {
+				removals = append(removals, i)
+				break
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(removals)))
+	for _, i := range removals {
+		parts.Remove(i)
+	}
+
+	emitted := 0
+	for _, in := range p.inflows {
+		for i := 0; i < parts.N(); i++ {
+			if inZone(parts.Position(i), in.Min, in.Max, in.Shape) {
+				particle := parts.Get(i)
+				particle.Velocity = vector.CastFixed(in.Velocity)
+				parts.Set(i, particle)
+			}
+		}
+		in.travel += vector.Mag(in.Velocity) * p.time
+		for in.Spacing > 0 && in.travel >= in.Spacing {
+			in.travel -= in.Spacing
+			emitted += p.emitLayer(in)
+		}
+	}
+
+	if emitted > 0 || len(removals) > 0 {
+		p.particles = parts.N()
+		p.field.Resize()
+		p.NN()
+	}
+	return emitted, len(removals)
+}
+
+//emitLayer seeds a lattice of particles on the upstream face of the inflow zone
+//offset downstream by the distance the flow travelled past the last layer. Shape
+//inflows seed the lattice columns crossing the shape where they enter it
+func (p *SPH) emitLayer(in *Inflow) int {
+	axis := 0
+	for k := 1; k < 3; k++ {
+		if math.Abs(float64(in.Velocity[k])) > math.Abs(float64(in.Velocity[axis])) {
+			axis = k
+		}
+	}
+	sign := float32(1)
+	if in.Velocity[axis] < 0 {
+		sign = -1
+	}
+	u, v := (axis+1)%3, (axis+2)%3
+	columns := in.columns
+	if in.Shape == nil || in.section != sectionKey(axis, sign) {
+		columns = in.crossSection(axis, sign)
+	}
+	parts := p.field.Particles
+	count := 0
+	for _, c := range columns {
+		if in.Budget > 0 && parts.N() >= in.Budget {
+			return count
+		}
+		particle := model.Particle{}
+		particle.Position[axis] = c.entry + sign*in.travel
+		particle.Position[u] = c.a
+		particle.Position[v] = c.b
+		particle.Velocity = vector.CastFixed(in.Velocity)
+		particle.Density = parts.D0()
+		particle.Press = in.Pressure
+		if p.thermal != nil {
+			particle.Temperature = p.thermal.Ambient
+		}
+		parts.Insert(particle, parts.Mass())
+		count++
+	}
+	return count
+}
+
+//sectionKey identifies a flow axis and direction, never 0
+func sectionKey(axis int, sign float32) int {
+	if sign < 0 {
+		return axis*2 + 2
+	}
+	return axis*2 + 1
+}
+
+//inflowColumn is a lattice column of the inflow zone along the flow axis with
+//the axis coordinate where the flow enters the zone
+type inflowColumn struct {
+	a     float32
+	b     float32
+	entry float32
+}
+
+//crossSection returns the lattice columns of the upstream face. Columns of shape
+//inflows are kept where they cross the shape, entering at its upstream surface
+//found by marching and bisection, and are cached for the flow direction
+func (in *Inflow) crossSection(axis int, sign float32) []inflowColumn {
+	u, v := (axis+1)%3, (axis+2)%3
+	face := in.Min[axis]
+	if sign < 0 {
+		face = in.Max[axis]
+	}
+	length := in.Max[axis] - in.Min[axis]
+	step := in.Spacing / 4
+	columns := []inflowColumn{}
+	for a := in.Min[u] + in.Spacing/2; a <= in.Max[u]; a += in.Spacing {
+		for b := in.Min[v] + in.Spacing/2; b <= in.Max[v]; b += in.Spacing {
+			if in.Shape == nil {
+				columns = append(columns, inflowColumn{a, b, face})
+				continue
+			}
+			pos := [3]float32{}
+			pos[u], pos[v] = a, b
+			inside := func(d float32) bool {
+				pos[axis] = face + sign*d
+				return sdf.Inside(in.Shape, pos[:])
+			}
+			for d := float32(0); d <= length; d += step {
+				if !inside(d) {
+					continue
+				}
+				lo, hi := float32(math.Max(float64(d-step), 0)), d
+				for k := 0; k < 8 && d > 0; k++ {
+					if mid := (lo + hi) / 2; inside(mid) {
+						hi = mid
+					} else {
+						lo = mid
+					}
+				}
+				columns = append(columns, inflowColumn{a, b, face + sign*hi})
+				break
+			}
+		}
+	}
+	if in.Shape != nil {
+		in.columns, in.section = columns, sectionKey(axis, sign)
+	}
+	return columns
+}
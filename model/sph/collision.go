@@ -0,0 +1,126 @@
+package sph
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/bvh"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/sdf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Collision Response Defaults
+const (
+	COLLISION_RESTITUTION = 0.0
+	COLLISION_FRICTION    = 0.0
+	COLLISION_ITERATIONS  = 4
+	COLLISION_SKIN        = 1e-3 //Separation kept from the contact as a fraction of the radius
+)
+
+//Collider is a static obstacle resolved by swept sphere collision detection
+//during the position update, so that particles cannot tunnel through thin walls
+//at high velocity. Restitution is the fraction of the approaching normal speed
+//returned on contact and Friction the Coulomb coefficient limiting the
+//tangential speed loss to Friction times the normal impulse
+type Collider struct {
+	Mesh        *bvh.BVH
+	Restitution float32
+	Friction    float32
+	Radius      float32 //Particle contact radius
+}
+
+//AddCollider adds a static collider mesh in the simulation frame. Particles
+//collide as spheres of a quarter of the kernel support, half the usual spacing
+func (p *SPH) AddCollider(m *mesh.Mesh, restitution float32, friction float32) *Collider {
+	c := Collider{bvh.FromMesh(m), restitution, friction, p.field.GetKernelLength() / 4}
+	p.colliders = append(p.colliders, &c)
+	return &c
+}
+
+//AddShapeCollider adds a static collider from a bounded implicit shape
+//polygonized at the lattice spacing dx. The lattice spans the shape bounds so
+//the spacing should resolve the shape features rather than the kernel support
+func (p *SPH) AddShapeCollider(s sdf.Shape, dx float32, restitution float32, friction float32) (*Collider, error) {
+	surface, err := sdf.Polygonize(s, dx)
+	if err != nil {
+		return nil, err
+	}
+	m := surface.Mesh()
+	return p.AddCollider(&m, restitution, friction), nil
+}
+
+func (p *SPH) Colliders() []*Collider {
+	return p.colliders
+}
+
+//collide resolves the motion of a particle from start to its integrated position
+//against the colliders. At each contact the particle is placed at the contact,
+//its velocity reflected with restitution and friction and the rest of the step
+//swept again with the new velocity. Particles left within the contact radius of
+//a surface are then projected out along the closest point direction
+func (p *SPH) collide(particle *model.Particle, start [3]float32, dt float32) {
+	if len(p.colliders) == 0 {
+		return
+	}
+	x0 := vector.Vec{start[0], start[1], start[2]}
+	x1 := vector.Cast(particle.Position)
+	v := vector.Cast(particle.Velocity)
+	remaining := dt
+
+	for iter := 0; iter < COLLISION_ITERATIONS; iter++ {
+		var first *Collider
+		contact := bvh.Contact{T: 2}
+		for _, c := range p.colliders {
+			if hit, ok := c.Mesh.Sweep(x0, x1, c.Radius); ok && hit.T < contact.T {
+				first, contact = c, hit
+			}
+		}
+		if first == nil {
+			break
+		}
+		n := contact.Normal
+		v = first.respond(v, n)
+		remaining *= 1 - contact.T
+		x0 = vector.Add(contact.Point, vector.Scale(n, first.Radius*COLLISION_SKIN))
+		x1 = vector.Add(x0, vector.Scale(v, remaining))
+		if iter == COLLISION_ITERATIONS-1 {
+			x1 = x0
+		}
+	}
+
+	for _, c := range p.colliders {
+		closest, ok := c.Mesh.ClosestPoint(x1, c.Radius)
+		if !ok {
+			continue
+		}
+		n := vector.Sub(x1, closest.Point)
+		if closest.Distance > 0 {
+			n = vector.Scale(n, 1/closest.Distance)
+		} else {
+			n = c.Mesh.Normal(closest.Triangle)
+		}
+		x1 = vector.Add(closest.Point, vector.Scale(n, c.Radius*(1+COLLISION_SKIN)))
+		if vn := vector.Dot(v, n); vn < 0 {
+			v = c.respond(v, n)
+		}
+	}
+	particle.Position = vector.CastFixed(x1)
+	particle.Velocity = vector.CastFixed(v)
+}
+
+//respond returns the velocity after a contact with unit normal n. The normal
+//component is reversed and scaled by the restitution while the tangential
+//component loses up to the friction coefficient times the normal impulse
+func (c *Collider) respond(v vector.Vec, n vector.Vec) vector.Vec {
+	vn := vector.Dot(v, n)
+	if vn >= 0 {
+		return v
+	}
+	tangent := vector.Sub(v, vector.Scale(n, vn))
+	if speed := vector.Mag(tangent); speed > 0 {
+		impulse := -vn * (1 + c.Restitution)
+		tangent = vector.Scale(tangent, float32(math.Max(0, float64(1-c.Friction*impulse/speed))))
+	}
+	return vector.Add(tangent, vector.Scale(n, -c.Restitution*vn))
+}
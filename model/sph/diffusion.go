@@ -0,0 +1,172 @@
+package sph
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Density Diffusion Enums
+const DIFFUSION_NONE = 0
+const DIFFUSION_MOLTENI = 1
+const DIFFUSION_ANTUONO = 2
+
+//SetDensityDiffusion evolves density with the continuity equation and adds the
+//delta-SPH diffusion term delta h c0 sum_j psi_ij . grad W_ij V_j. Molteni and
+//Colagrossi 2009 use psi_ij = 2 (rho_j - rho_i) x_ji/|x_ji|^2, Antuono et al. 2010
+//subtract the renormalized density gradient so hydrostatic states are preserved.
+//The diffusion uses the sound speed set with SetEquationOfState
+func (p *SPH) SetDensityDiffusion(mode int, delta float32) {
+	p.diffusion = mode
+	p.deltaCoef = delta
+}
+
+func (p *SPH) DensityDiffusion() int {
+	return p.diffusion
+}
+
+//SetArtificialPressure enables the Monaghan 2000 artificial pressure in the
+//pressure force. Particle pairs in tension are pushed apart by eps |P|/rho^2
+//weighted by (W(r)/W(spacing))^n. An eps of 0 disables the term
+func (p *SPH) SetArtificialPressure(eps float32, n float32, spacing float32) {
+	p.apEpsilon = eps
+	p.apN = n
+	p.apSpacing = spacing
+}
+
+//artificialPressure returns R_ij f^n for the pressure terms pi = P_i/rho_i^2 and
+//pj = P_j/rho_j^2 at pair distance dist
+func (p *SPH) artificialPressure(pi float32, pj float32, dist float32) float32 {
+	if p.apEpsilon == 0 {
+		return 0
+	}
+	r := float32(0.0)
+	if pi < 0 {
+		r += p.apEpsilon * -pi
+	}
+	if pj < 0 {
+		r += p.apEpsilon * -pj
+	}
+	if pi > 0 && pj > 0 {
+		r = 0.01 * (pi + pj)
+	}
+	kern := p.field.Kernel()
+	wdp := kern.F(p.apSpacing)
+	if wdp == 0 {
+		return 0
+	}
+	return r * float32(math.Pow(float64(kern.F(dist)/wdp), float64(p.apN)))
+}
+
+//ContinuityAll computes the density rate of every fluid particle from the
+//continuity equation drho_i/dt = sum_j m_j (v_i - v_j) . grad W_ij plus the
+//density diffusion term. Densities are advanced by Update
+func (p *SPH) ContinuityAll() {
+	parts := p.field.Particles
+	kern := p.field.Kernel()
+	n := p.particles
+	p.drho = make([]float32, n)
+
+	var grads []vector.Vec
+	if p.diffusion == DIFFUSION_ANTUONO {
+		grads = p.renormalizedDensityGradients()
+	}
+
+	dh := p.deltaCoef * p.field.GetKernelLength() * p.sos
+	for i := 0; i < n; i++ {
+		particle := parts.Get(i)
+		rate := float32(0.0)
+		for _, j := range p.field.Neighbors(i) {
+			particle_j := parts.Get(j)
+			dir := p.field.Separation(particle.Position[:], particle_j.Position[:])
+			dist := vector.Mag(dir)
+			grad := kern.Grad(dist, vector.Norm(dir))
+			dv := vector.Sub(particle.Velocity[:], particle_j.Velocity[:])
+			mj := parts.ParticleMass(j)
+			rate += mj * vector.Dot(dv, grad)
+
+			if p.diffusion == DIFFUSION_NONE || j >= n || dist == 0 || particle_j.Density == 0 {
+				continue
+			}
+			diff := particle_j.Density - particle.Density
+			if grads != nil {
+				diff -= 0.5 * vector.Dot(vector.Add(grads[i], grads[j]), dir)
+			}
+			psi := vector.Scale(dir, 2*diff/(dist*dist))
+			rate += dh * vector.Dot(psi, grad) * mj / particle_j.Density
+		}
+		p.drho[i] = rate
+	}
+}
+
+//renormalizedDensityGradients returns L_i sum_j (rho_j - rho_i) grad W_ij V_j
+//with the kernel gradient correction L_i = (sum_j V_j grad W_ij (x) x_ji)^-1
+//taken over fluid neighbors
+func (p *SPH) renormalizedDensityGradients() []vector.Vec {
+	parts := p.field.Particles
+	kern := p.field.Kernel()
+	n := p.particles
+	grads := make([]vector.Vec, n)
+	for i := 0; i < n; i++ {
+		particle := parts.Get(i)
+		M := matrix.Mat3(0.0)
+		sum := vector.Vec{0, 0, 0}
+		for _, j := range p.field.Neighbors(i) {
+			if j >= n {
+				continue
+			}
+			particle_j := parts.Get(j)
+			if particle_j.Density == 0 {
+				continue
+			}
+			dir := p.field.Separation(particle.Position[:], particle_j.Position[:])
+			dist := vector.Mag(dir)
+			grad := kern.Grad(dist, vector.Norm(dir))
+			vol := parts.ParticleMass(j) / particle_j.Density
+			for a := 0; a < 3; a++ {
+				for b := 0; b < 3; b++ {
+					M[a*3+b] += vol * grad[a] * dir[b]
+				}
+			}
+			sum = vector.Add(sum, vector.Scale(grad, (particle_j.Density-particle.Density)*vol))
+		}
+		if det := M.Det3(); math.Abs(float64(det)) > 1e-6 {
+			sum = M.Inv3().CrossVec(sum)
+		}
+		grads[i] = sum
+	}
+	return grads
+}
+
+//BoundaryPressureAll extrapolates fluid pressure onto the boundary particles
+//following Adami et al. 2012, P_b = (sum_f P_f W_bf + g . sum_f rho_f x_bf W_bf) / sum_f W_bf.
+//Negative wall pressures are clamped to zero so the fluid does not stick to walls
+func (p *SPH) BoundaryPressureAll() {
+	parts := p.field.Particles
+	kern := p.field.Kernel()
+	n := p.particles
+	gravity := p.Gravity()
+	for b := n; b < parts.Total(); b++ {
+		pos := parts.Position(b)
+		weight := float32(0.0)
+		press := float32(0.0)
+		for _, f := range p.field.Neighbors(b) {
+			if f >= n {
+				continue
+			}
+			fluid := parts.Get(f)
+			rel := p.field.Separation(fluid.Position[:], pos)
+			w := kern.F(vector.Mag(rel))
+			weight += w
+			press += (fluid.Press + fluid.Density*vector.Dot(gravity, rel)) * w
+		}
+		if weight > 0 {
+			press /= weight
+		}
+		if press < 0 {
+			press = 0
+		}
+		parts.SetBoundaryPressure(b, press)
+	}
+}
@@ -3,20 +3,27 @@ package sph
 import (
 	"fmt"
 	"log"
+	"math"
 
-	"github.com/andewx/dieselfluid/geom"
 	"github.com/andewx/dieselfluid/geom/grid"
 	"github.com/andewx/dieselfluid/geom/mesh"
 	"github.com/andewx/dieselfluid/kernel"
 	"github.com/andewx/dieselfluid/math/vector"
 	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/continuum"
 	"github.com/andewx/dieselfluid/model/field"
+	"github.com/andewx/dieselfluid/model/force"
+	"github.com/andewx/dieselfluid/model/frame"
+	"github.com/andewx/dieselfluid/sampler/cell"
 	"github.com/andewx/dieselfluid/sampler/lsh"
 )
 
 const (
 	VISCOSITY_WATER = 1.3059
 	CACHE_L         = 0.8
+	GRAVITY         = -9.81
+	CFL_NUMBER      = 0.25
+	CFL_FORCE       = 0.25
 )
 
 //SPH Standard SPH Particle System - Implements SPHSystem Interface
@@ -24,12 +31,32 @@ type SPH struct {
 	time       float32 //Time Step
 	maxVel     float32 //Max Vel - Courant Condition
 	maxF       float32
-	field      field.SPHField  //SPH Field Methods
-	colliders  []geom.Collider //List of collidables
-	particles  int             //Number Particles
-	cache_life float32         //Cache Extinction Coefficient
-	mu         float32         //viscosity coefficient
-	delta      float32         //pcisph delta computation
+	maxAcc     float32          //Max acceleration - force condition
+	field      field.SPHField   //SPH Field Methods
+	colliders  []*Collider      //Swept collision obstacles
+	particles  int              //Number Particles
+	cache_life float32          //Cache Extinction Coefficient
+	mu         float32          //viscosity coefficient
+	delta      float32          //pcisph delta computation
+	thermal    *Thermal         //Heat transport model - nil disables
+	continuum  *continuum.Model //Solid and granular materials - nil disables
+	domain     *grid.Domain     //Periodic domain - nil for free space
+	inflows    []*Inflow        //Open inflow buffer zones
+	outflows   []*Outflow       //Open outflow zones
+	sos        float32          //Speed of sound - 0 uses the legacy Tait EOS
+	gamma      float32          //EOS stiffness exponent
+	diffusion  int              //Density diffusion mode
+	deltaCoef  float32          //Density diffusion coefficient
+	drho       []float32        //Continuity density rates
+	apEpsilon  float32          //Artificial pressure coefficient - 0 disables
+	apN        float32          //Artificial pressure exponent
+	apSpacing  float32          //Artificial pressure reference spacing
+	forces     []force.Field    //External force fields evaluated each step
+	clock      float32          //Elapsed simulation time
+	kinematics []*Kinematic     //Animated colliders
+	frame      *frame.Frame     //Non-inertial container frame - nil for the world frame
+	world      []force.Field    //World gravity fields replaced by the frame
+	noSlip     bool             //Ghost wall velocities in the viscous force
 }
 
 /*
@@ -62,16 +89,19 @@ func Init(scl float32, origin vector.Vec, colliders []*mesh.Mesh, n3 int, pci bo
 	//Instantiates and allocates the fielded particle lists which includes the collider implicit particle fields
 	particles := model.NewParticleArray(num, 0, h, ref_density, mass)
 	sampler := lsh.Allocate(num, 255, 8, &particles)
-	core.field = field.InitSPH(particles, sampler, kern, num)
+	core.field = field.InitSPH(&particles, sampler, kern, num)
 	core.particles = num
 	core.cache_life = CACHE_L
 
 	core.mu = VISCOSITY_WATER
 	//	core.field.BoundaryParticles(colliders)
+	for _, collider := range colliders {
+		core.AddCollider(collider, COLLISION_RESTITUTION, COLLISION_FRICTION)
+	}
 	core.field.AlignWithGrid(grid)
 	sampler.UpdateSampler()
 	core.DensityAll()
-	core.ExternalAll([]float32{0, -9.81 * mass, 0})
+	core.AddForceField(force.NewGravity(vector.Vec{0, GRAVITY, 0}))
 	core.ViscousAll()
 	core.CFL()
 
@@ -87,8 +117,26 @@ func Init(scl float32, origin vector.Vec, colliders []*mesh.Mesh, n3 int, pci bo
 	return core
 }
 
+//New creates an SPH system over a prepared particle array, for instance a scene
+//with boundary particles already appended. Neighbor queries use a cell list
+//sampler with cells of the kernel support h and a default gravity force field
+func New(particles *model.ParticleArray, h float32) SPH {
+	core := SPH{}
+	num := particles.N()
+	sampler := lsh.Allocate(num, 255, 8, particles)
+	core.field = field.InitSPH(particles, sampler, kernel.Build_Kernel(h), num)
+	core.field.SetNeighborhood(cell.New(particles, h, nil))
+	core.particles = num
+	core.cache_life = CACHE_L
+	core.mu = VISCOSITY_WATER
+	core.gamma = 7.0
+	core.AddForceField(force.NewGravity(vector.Vec{0, GRAVITY, 0}))
+	core.CFL()
+	return core
+}
+
 //Get the field particles list, note that boundary particles are appended
-func (p *SPH) Particles() model.ParticleArray {
+func (p *SPH) Particles() *model.ParticleArray {
 	return p.field.Particles
 }
 
@@ -108,11 +156,36 @@ func (p *SPH) N() int {
 }
 
 //CFL Time Step Condition - Ensure the GPU Forumlas match this constraint
+//When a speed of sound is set the step follows dt = C h / (c0 + max|v|) bounded
+//by the force condition C_f sqrt(h / max|a|), max|v| and max|a| being taken over
+//the particles of the last Update
 func (p *SPH) CFL() float32 {
 	p.time = 0.01
+	if p.sos > 0 {
+		h := p.field.GetKernelLength()
+		p.time = CFL_NUMBER * h / (p.sos + p.maxVel)
+		if p.maxAcc > 0 {
+			if dt := CFL_FORCE * float32(math.Sqrt(float64(h/p.maxAcc))); dt < p.time {
+				p.time = dt
+			}
+		}
+	}
 	return p.time
 }
 
+//SetEquationOfState switches the pressure evaluation to the weakly compressible
+//Tait equation P = rho0 c0^2/gamma ((rho/rho0)^gamma - 1) which allows negative
+//pressures, and ties the time step to the speed of sound c0
+func (p *SPH) SetEquationOfState(soundSpeed float32, gamma float32) {
+	p.sos = soundSpeed
+	p.gamma = gamma
+	p.CFL()
+}
+
+func (p *SPH) SoundSpeed() float32 {
+	return p.sos
+}
+
 func (p *SPH) Viscosity() float32 {
 	return p.mu
 }
@@ -125,6 +198,7 @@ func (p *SPH) SetViscosity(x float32) {
 
 //Computes all particle densities
 func (p *SPH) DensityAll() {
+	p.drho = nil
 	for i := 0; i < p.field.Particles.N(); i++ {
 		p.field.Density(i)
 	}
@@ -133,24 +207,101 @@ func (p *SPH) DensityAll() {
 //Iterates over density field and calculates the particle pressures using tait EOS mapping
 func (p *SPH) PressureAll() int {
 	retVal := 0 //SPH VALID
+	d0 := p.field.Particles.D0()
 	for i := 0; i < p.particles; i++ {
 		particle := p.field.Particles.Get(i)
-		particle.Pressure(p.field.Particles.D0(), 0.0)
+		if p.sos > 0 {
+			particle.Press = model.EosGamma(particle.Density, d0*p.sos*p.sos, d0, p.gamma, 0.0)
+		} else {
+			particle.Pressure(d0, 0.0)
+		}
 		p.field.Particles.Set(i, particle)
 	}
+	p.inflowPressure()
 	return retVal
 }
 
 //(N)Applys an artificial viscosity force by calculating the laplacian of the velocity field
 //And maps the force to the particle force fields
 func (p *SPH) ViscousAll() {
+	if p.noSlip {
+		p.noSlipViscous()
+		return
+	}
 	for i := 0; i < p.particles; i++ {
 		particle := p.field.Particles.Get(i)
-		particle.AddForce(vector.CastFixed(vector.Scale(p.field.LaplacianForce(i, p.field.GetTensorFields()["velocity"]), p.mu)))
+		particle.AddForce(vector.CastFixed(vector.Scale(p.field.LaplacianForce(i, p.field.GetTensorFields()["velocity"]), p.viscosityAt(i))))
 		p.field.Particles.Set(i, particle)
 	}
 }
 
+//SetNoSlip enables the no-slip wall condition of Adami et al. 2012 in the
+//viscous force. Boundary particles take the ghost velocity 2 v_w - v_f, v_f
+//being the kernel weighted average of the neighboring fluid velocities, so the
+//velocity vanishes at the wall surface rather than at the wall particles
+func (p *SPH) SetNoSlip(enabled bool) {
+	p.noSlip = enabled
+}
+
+func (p *SPH) NoSlip() bool {
+	return p.noSlip
+}
+
+//ghostVelocities returns the flattened no-slip ghost velocities of the boundary
+//particles. Walls without fluid neighbors keep their own velocity
+func (p *SPH) ghostVelocities() []float32 {
+	parts := p.field.Particles
+	kern := p.field.Kernel()
+	n := p.particles
+	ghost := make([]float32, (parts.Total()-n)*3)
+	for b := n; b < parts.Total(); b++ {
+		wall := parts.Get(b)
+		weight := float32(0.0)
+		mean := vector.Vec{0, 0, 0}
+		for _, f := range p.field.Neighbors(b) {
+			if f >= n {
+				continue
+			}
+			fluid := parts.Get(f)
+			w := kern.F(vector.Mag(p.field.Separation(fluid.Position[:], wall.Position[:])))
+			weight += w
+			mean = mean.Add(vector.Scale(fluid.Velocity[:], w))
+		}
+		for a := 0; a < 3; a++ {
+			ghost[(b-n)*3+a] = wall.Velocity[a]
+			if weight > 0 {
+				ghost[(b-n)*3+a] = 2*wall.Velocity[a] - mean[a]/weight
+			}
+		}
+	}
+	return ghost
+}
+
+//noSlipViscous adds the viscous force with the boundary particles moving at
+//their ghost velocities
+func (p *SPH) noSlipViscous() {
+	parts := p.field.Particles
+	kern := p.field.Kernel()
+	n := p.particles
+	ghost := p.ghostVelocities()
+	for i := 0; i < n; i++ {
+		particle := parts.Get(i)
+		force := vector.Vec{0, 0, 0}
+		for _, j := range p.field.Neighbors(i) {
+			other := parts.Get(j)
+			v := other.Velocity[:]
+			if j >= n {
+				v = ghost[(j-n)*3 : (j-n)*3+3]
+			}
+			dist := vector.Mag(p.field.Separation(particle.Position[:], other.Position[:]))
+			dv := vector.Scale(vector.Sub(v, particle.Velocity[:]), 1/other.Density)
+			force = force.Add(dv.Scale(kern.O2D(dist) * parts.ParticleMass(j)))
+		}
+		particle.AddForce(vector.CastFixed(vector.Scale(force, p.viscosityAt(i))))
+		parts.Set(i, particle)
+	}
+}
+
 //External add an external force to all particles
 func (p *SPH) ExternalAll(force vector.Vec) {
 	for i := 0; i < p.particles; i++ {
@@ -160,40 +311,163 @@ func (p *SPH) ExternalAll(force vector.Vec) {
 	}
 }
 
-//Computes gradient pressure force and adds to the particle
+//AddForceField adds an external force field evaluated by ForceFieldsAll
+func (p *SPH) AddForceField(f force.Field) {
+	p.forces = append(p.forces, f)
+}
+
+func (p *SPH) ForceFields() []force.Field {
+	return p.forces
+}
+
+//ClearForceFields removes all force fields including the default gravity
+func (p *SPH) ClearForceFields() {
+	p.forces = nil
+}
+
+//Gravity returns the gravity acceleration of the active gravity fields. In a
+//container frame this is the frame gravity less the frame acceleration
+func (p *SPH) Gravity() vector.Vec {
+	g := force.GravityOf(p.forces, p.clock)
+	if p.frame != nil {
+		s := p.frame.At(p.clock)
+		g = vector.Add(g, vector.Sub(s.Rotation.Transpose().CrossVec(p.frame.Gravity), s.Acceleration))
+	}
+	return g
+}
+
+//SetFrame runs the simulation in a moving container frame. World gravity fields
+//are replaced by the frame which applies the rotated gravity together with the
+//fictitious forces. Particle positions and velocities are then tank relative.
+//Clearing the frame with nil restores the replaced gravity fields
+func (p *SPH) SetFrame(f *frame.Frame) {
+	fields := []force.Field{}
+	for _, field := range p.forces {
+		if p.frame != nil && field == force.Field(p.frame) {
+			continue
+		}
+		if _, ok := field.(*force.Gravity); ok && f != nil {
+			p.world = append(p.world, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	p.forces = fields
+	p.frame = f
+	if f != nil {
+		p.forces = append(p.forces, f)
+	} else {
+		p.forces = append(p.forces, p.world...)
+		p.world = nil
+	}
+}
+
+func (p *SPH) Frame() *frame.Frame {
+	return p.frame
+}
+
+//Elapsed returns the simulation time advanced by Update
+func (p *SPH) Elapsed() float32 {
+	return p.clock
+}
+
+//ForceFieldsAll evaluates the active force fields at the current simulation time
+//and adds them to the particle forces
+func (p *SPH) ForceFieldsAll() {
+	if len(p.forces) == 0 {
+		return
+	}
+	parts := p.field.Particles
+	for i := 0; i < p.particles; i++ {
+		particle := parts.Get(i)
+		f := force.Sum(p.forces, particle.Position[:], particle.Velocity[:], parts.ParticleMass(i), p.clock)
+		particle.AddForce(vector.CastFixed(f))
+		parts.Set(i, particle)
+	}
+}
+
+//Computes the symmetric pressure gradient force -m_i sum_j m_j (P_i/rho_i^2 + P_j/rho_j^2 + R_ij f^n) grad W_ij
+//and adds it to the particle. R_ij f^n is the Monaghan artificial pressure when enabled
 func (p *SPH) GradientPressureForce() {
 	pressure_field := p.field.GetFields()["pressure"]
+	parts := p.field.Particles
+	kern := p.field.Kernel()
 	for i := 0; i < p.particles; i++ {
-		particle := p.field.Particles.Get(i)
-		gradient := p.field.Gradient(i, pressure_field)
-		particle.AddForce(vector.CastFixed(gradient))
-		p.field.Particles.Set(i, particle)
+		if p.continuum != nil && p.continuum.Handles(&p.field, i) {
+			continue
+		}
+		particle := parts.Get(i)
+		pi := pressure_field.Value(i) / (particle.Density * particle.Density)
+		force := vector.Vec{0, 0, 0}
+		for _, j := range p.field.Neighbors(i) {
+			particle_j := parts.Get(j)
+			pj := pressure_field.Value(j) / (particle_j.Density * particle_j.Density)
+			dir := p.field.Separation(particle.Position[:], particle_j.Position[:])
+			dist := vector.Mag(dir)
+			grad := kern.Grad(dist, vector.Norm(dir))
+			scale := pi + pj + p.artificialPressure(pi, pj, dist)
+			force = vector.Add(force, vector.Scale(grad, -parts.ParticleMass(j)*scale))
+		}
+		particle.AddForce(vector.CastFixed(vector.Scale(force, parts.ParticleMass(i))))
+		parts.Set(i, particle)
 	}
 }
 
-//Update updates all particle positions -- non-blocking mutex locked
+//SetContinuum selects continuum materials by particle phase, phases without a
+//material remain fluid
+func (p *SPH) SetContinuum(m *continuum.Model) {
+	p.continuum = m
+}
+
+func (p *SPH) Continuum() *continuum.Model {
+	return p.continuum
+}
+
+//StressAll integrates material particle stresses and adds the stress divergence
+//force. Material particles are skipped by GradientPressureForce since their
+//stress carries the isotropic pressure. Does nothing without a continuum model
+func (p *SPH) StressAll() {
+	if p.continuum == nil {
+		return
+	}
+	p.continuum.UpdateStress(&p.field, p.time)
+	p.continuum.Forces(&p.field)
+}
+
+//Update updates all particle positions -- non-blocking mutex locked. Forces are
+//cleared for the next step while the pressures of this step are kept for output
 func (p *SPH) Update() {
 
-	m := 1 / p.field.Mass()
 	ts := p.CFL()
+	continuity := len(p.drho) == p.particles
+	p.maxVel, p.maxF, p.maxAcc = 0, 0, 0
 
 	//Calculate Velocities Update Position / Clear Force To Gravity only
 	for i := 0; i < p.particles; i++ {
 		particle := p.field.Particles.Get(i)
-		a := vector.Scale(particle.Force[:], m)
+		mass := p.field.Particles.ParticleMass(i)
+		if continuity {
+			particle.Density += p.drho[i] * ts
+		}
+		a := vector.Scale(particle.Force[:], 1/mass)
 		particle.AddVelocity(vector.CastFixed(vector.Scale(a, float32(ts))))
+		start := particle.Position
 		particle.AddPosition(vector.CastFixed(vector.Scale(particle.Velocity[:], float32(ts))))
+		p.collide(&particle, start, ts)
 		if vector.Mag(particle.Velocity[:]) > p.maxVel {
 			p.maxVel = vector.Mag(particle.Velocity[:])
 		}
 		if vector.Mag(particle.Force[:]) > p.maxF {
 			p.maxF = vector.Mag(particle.Force[:])
 		}
-		particle.Press = 0
-		particle.Force = ([3]float32{0, -9.81 * p.field.Mass(), 0})
+		if vector.Mag(a) > p.maxAcc {
+			p.maxAcc = vector.Mag(a)
+		}
+		particle.Force = ([3]float32{0, 0, 0})
 		p.field.Particles.Set(i, particle)
 
 	}
+	p.clock += ts
 }
 
 func (p *SPH) Time() float32 {
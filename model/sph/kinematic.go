@@ -0,0 +1,66 @@
+package sph
+
+import (
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Motion supplies a row major 4x4 rigid transform at simulation time t, for
+//instance a glTF node animation
+type Motion interface {
+	Transform(t float32) matrix.Mat
+}
+
+//Kinematic is a collider whose boundary particles follow a prescribed motion.
+//First is the offset of the particle range from the first boundary particle and
+//Local holds the particle positions in the collider frame
+type Kinematic struct {
+	First  int
+	Count  int
+	Local  []float32
+	Motion Motion
+}
+
+//AddKinematicCollider samples boundary particles on the collider mesh, given in
+//the collider frame, and binds them to motion. The particles are placed at the
+//transform for the current simulation time
+func (p *SPH) AddKinematicCollider(collider *mesh.Mesh, motion Motion) *Kinematic {
+	parts := p.field.Particles
+	first := parts.Total() - parts.N()
+	local := p.field.BoundaryParticles([]*mesh.Mesh{collider})
+	k := Kinematic{first, parts.Total() - parts.N() - first, append([]float32{}, local...), motion}
+	p.kinematics = append(p.kinematics, &k)
+	p.moveKinematic(&k, 0)
+	p.NN()
+	return &k
+}
+
+func (p *SPH) Kinematics() []*Kinematic {
+	return p.kinematics
+}
+
+//KinematicAll moves the kinematic collider particles to their transforms at the
+//current simulation time. Wall velocities are taken from the displacement over
+//the last step so the fluid feels the collider motion through the continuity,
+//viscosity and wall pressure terms
+func (p *SPH) KinematicAll() {
+	for _, k := range p.kinematics {
+		p.moveKinematic(k, p.time)
+	}
+}
+
+func (p *SPH) moveKinematic(k *Kinematic, dt float32) {
+	parts := p.field.Particles
+	m := k.Motion.Transform(p.clock)
+	for i := 0; i < k.Count; i++ {
+		index := parts.N() + k.First + i
+		local := vector.Vec{k.Local[i*3], k.Local[i*3+1], k.Local[i*3+2], 1}
+		world := m.CrossVec(local)[:3]
+		vel := vector.Vec{0, 0, 0}
+		if dt > 0 {
+			vel = vector.Scale(vector.Sub(world, parts.Position(index)), 1/dt)
+		}
+		parts.SetBoundary(index, vector.CastFixed(world), vector.CastFixed(vel))
+	}
+}
@@ -1,7 +1,18 @@
 package sph
 
-import "testing"
-import "github.com/andewx/dieselfluid/math/vector"
+import (
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/geom/sdf"
+	"github.com/andewx/dieselfluid/gltf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/continuum"
+	"github.com/andewx/dieselfluid/model/frame"
+)
 
 const N = 16
 
@@ -10,3 +21,329 @@ func TestGPUCompile(t *testing.T) {
 	Init(1.0, vector.Vec{}, nil, N, true)
 
 }
+
+func momentum(sph *SPH) (float32, vector.Vec) {
+	parts := sph.Particles()
+	mass := float32(0)
+	mom := vector.Vec{0, 0, 0}
+	for i := 0; i < parts.N(); i++ {
+		m := parts.ParticleMass(i)
+		mass += m
+		mom = vector.Add(mom, vector.Scale(parts.Velocity(i), m))
+	}
+	return mass, mom
+}
+
+func TestAdaptiveConservation(t *testing.T) {
+	sph := Init(1.0, vector.Vec{0, 0, 0}, nil, 8, false)
+	parts := sph.Particles()
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		particle.Velocity = [3]float32{float32(i%3) - 1, 0.5, float32(i % 5)}
+		parts.Set(i, particle)
+	}
+	mass0, mom0 := momentum(&sph)
+
+	//Everything is refined when the surface threshold exceeds any neighborhood
+	cfg := DefaultAdaptivity()
+	cfg.SurfaceNeighbors = 1 << 20
+	splits, _ := sph.Adapt(cfg)
+	if splits == 0 || sph.N() != parts.N() || parts.N() != 512+3*splits {
+		t.Errorf("Expected split particles, got %d splits with %d particles\n", splits, parts.N())
+	}
+	mass1, mom1 := momentum(&sph)
+	if math.Abs(float64(mass1-mass0)) > 1e-2 || vector.Dist(mom0, mom1) > 1e-1 {
+		t.Errorf("Split did not conserve mass %f -> %f or momentum %v -> %v\n", mass0, mass1, mom0, mom1)
+	}
+
+	//Nothing is refined so light particles merge
+	cfg.SurfaceNeighbors = 0
+	_, merges := sph.Adapt(cfg)
+	if merges == 0 {
+		t.Errorf("Expected interior particles to merge\n")
+	}
+	mass2, mom2 := momentum(&sph)
+	if math.Abs(float64(mass2-mass0)) > 1e-2 || vector.Dist(mom0, mom2) > 1e-1 {
+		t.Errorf("Merge did not conserve mass %f -> %f or momentum %v -> %v\n", mass0, mass2, mom0, mom2)
+	}
+}
+
+func TestThermalConduction(t *testing.T) {
+	sph := Init(1.0, vector.Vec{0, 0, 0}, nil, 8, false)
+	thermal := NewThermal(20.0)
+	thermal.Phases[0].Conductivity = 50.0
+	sph.SetThermal(thermal)
+	parts := sph.Particles()
+	hot := 0
+	for i := 0; i < parts.N(); i++ {
+		if parts.Position(i)[1] < 0 {
+			parts.SetTemperature(i, 80.0)
+			hot = i
+		}
+	}
+	force0 := parts.Force(hot)[1]
+	sph.ThermalAll()
+
+	warmed, cooled := 0, 0
+	for i := 0; i < parts.N(); i++ {
+		temp := parts.Temperature(i)
+		if temp > 20.0 && temp < 80.0 {
+			if parts.Position(i)[1] < 0 {
+				cooled++
+			} else {
+				warmed++
+			}
+		}
+	}
+	if warmed == 0 || cooled == 0 {
+		t.Errorf("Heat did not conduct between regions, %d warmed %d cooled\n", warmed, cooled)
+	}
+	if parts.Force(hot)[1] <= force0 {
+		t.Errorf("Hot particle received no buoyancy force %f -> %f\n", force0, parts.Force(hot)[1])
+	}
+
+	//Without gravity there is no buoyancy
+	sph.ClearForceFields()
+	force0 = parts.Force(hot)[1]
+	sph.ThermalAll()
+	if parts.Force(hot)[1] != force0 {
+		t.Errorf("Buoyancy without gravity %f -> %f\n", force0, parts.Force(hot)[1])
+	}
+}
+
+func TestFrameGravity(t *testing.T) {
+	parts := model.NewParticleArray(8, 0, 0.1, 1000, 0.125)
+	sph := New(&parts, 0.1)
+	g := sph.Gravity()
+	tank := frame.New(frame.Sway{Amplitude: vector.Vec{1, 0, 0}, Frequency: 1}, vector.Vec{0, -5, 0})
+	sph.SetFrame(tank)
+	sph.SetFrame(tank)
+	if len(sph.ForceFields()) != 1 || sph.Gravity()[1] != -5 {
+		t.Errorf("Frame fields %d gravity %v\n", len(sph.ForceFields()), sph.Gravity())
+	}
+	sph.SetFrame(nil)
+	if len(sph.ForceFields()) != 1 || vector.Dist(sph.Gravity(), g) != 0 || sph.Frame() != nil {
+		t.Errorf("World gravity %v not restored to %v\n", sph.Gravity(), g)
+	}
+}
+
+func TestContinuumStress(t *testing.T) {
+	//Elastic block with the shear stress s_xy = k y, the last x layer being fluid.
+	//The artificial stress is disabled to compare the bare stress divergence
+	dx, k := float32(0.05), float32(1000)
+	lattice := model.NewParticleArray(1000, 0, 2*dx, 1/(dx*dx*dx), 1000*dx*dx*dx)
+	for i := 0; i < lattice.N(); i++ {
+		x, y, z := i/100, i/10%10, i%10
+		particle := model.Particle{Position: [3]float32{float32(x) * dx, float32(y) * dx, float32(z) * dx}, Density: 1000}
+		if x < 9 {
+			particle.Phase = 1
+		}
+		lattice.Set(i, particle)
+		lattice.SetStress(i, []float32{0, k * particle.Position[1], 0, k * particle.Position[1], 0, 0, 0, 0, 0})
+	}
+	sph := New(&lattice, 2*dx)
+	solid := continuum.New(dx)
+	solid.Epsilon = 0
+	solid.Add(1, continuum.LinearElastic{Shear: 1e6, Bulk: 1e6})
+	sph.SetContinuum(solid)
+	sph.NN()
+	sph.StressAll()
+
+	//Interior accelerations follow the stress divergence div s / rho = (k/rho, 0, 0)
+	parts := sph.Particles()
+	for i := 0; i < parts.N(); i++ {
+		x, y, z := i/100, i/10%10, i%10
+		if x == 9 && vector.Mag(parts.Force(i)) != 0 {
+			t.Fatalf("Fluid particle %d received a stress force %v\n", i, parts.Force(i))
+		}
+		if x < 2 || x > 6 || y < 2 || y > 7 || z < 2 || z > 7 {
+			continue
+		}
+		a := vector.Scale(parts.Force(i), 1/parts.ParticleMass(i))
+		if math.Abs(float64(a[0]-k/1000)) > 0.1*float64(k/1000) || math.Abs(float64(a[1])) > 0.01 || math.Abs(float64(a[2])) > 0.01 {
+			t.Fatalf("Particle %d %d %d stress acceleration %v expected %f\n", x, y, z, a, k/1000)
+		}
+	}
+}
+
+func TestCFL(t *testing.T) {
+	parts := model.NewParticleArray(8, 0, 0.1, 1000, 0.125)
+	sph := New(&parts, 0.1)
+	sph.SetEquationOfState(10, 7)
+	calm := sph.CFL()
+	particle := parts.Get(0)
+	particle.Velocity = [3]float32{90, 0, 0}
+	parts.Set(0, particle)
+	sph.Update()
+	if spike := sph.CFL(); math.Abs(float64(spike-calm/10)) > 1e-6 {
+		t.Errorf("Time step %f at the velocity spike expected %f\n", spike, calm/10)
+	}
+	particle = parts.Get(0)
+	particle.Velocity = [3]float32{0, 0, 0}
+	parts.Set(0, particle)
+	sph.Update()
+	if dt := sph.CFL(); dt != calm {
+		t.Errorf("Time step %f did not recover to %f after the spike\n", dt, calm)
+	}
+}
+
+func TestArtificialPressure(t *testing.T) {
+	//A close pair in tension attracts unless the artificial pressure repels it
+	dx := float32(0.05)
+	pull := func(eps float32) float32 {
+		parts := model.NewParticleArray(2, 0, 2*dx, 1/(dx*dx*dx), 1000*dx*dx*dx)
+		parts.Set(0, model.Particle{Position: [3]float32{0, 0, 0}, Density: 950})
+		parts.Set(1, model.Particle{Position: [3]float32{0.5 * dx, 0, 0}, Density: 950})
+		sph := New(&parts, 2*dx)
+		sph.SetEquationOfState(10, 7)
+		sph.SetArtificialPressure(eps, 4, dx)
+		sph.NN()
+		sph.PressureAll()
+		sph.GradientPressureForce()
+		return parts.Force(0)[0]
+	}
+	if f := pull(0); f <= 0 {
+		t.Errorf("Pair in tension not attracted without artificial pressure %f\n", f)
+	}
+	if f := pull(0.2); f >= 0 {
+		t.Errorf("Artificial pressure did not push the pair apart %f\n", f)
+	}
+}
+
+func TestOpenBoundaries(t *testing.T) {
+	sph := Init(1.0, vector.Vec{0, 0, 0}, nil, 8, false)
+	domain := grid.NewDomain(vector.Vec{-1, -1, -1}, vector.Vec{1, 1, 1})
+	domain.SetPeriodic(true, false, false)
+	sph.SetDomain(domain)
+	parts := sph.Particles()
+
+	particle := parts.Get(0)
+	particle.Position = [3]float32{1.1, 0, 0}
+	parts.Set(0, particle)
+
+	sph.AddOutflow(&Outflow{Min: vector.Vec{-2, 0.5, -2}, Max: vector.Vec{2, 2, 2}})
+	inflow := &Inflow{Min: vector.Vec{-1, -1, -1}, Max: vector.Vec{-0.5, 0.4, 1}, Velocity: vector.Vec{15, 0, 0}, Spacing: 0.1}
+	sph.AddInflow(inflow)
+	n := parts.N()
+	emitted, removed := sph.BoundaryAll()
+
+	if x := parts.Position(0)[0]; x < -1 || x > 1 {
+		t.Errorf("Periodic position not wrapped %f\n", x)
+	}
+	if removed == 0 || emitted != 280 || parts.N() != n-removed+emitted || sph.N() != parts.N() {
+		t.Errorf("Open boundary bookkeeping emitted %d removed %d particles %d -> %d\n", emitted, removed, n, parts.N())
+	}
+	for i := 0; i < parts.N(); i++ {
+		if parts.Position(i)[1] > 0.5 {
+			t.Fatalf("Particle %d left inside the outflow zone\n", i)
+		}
+	}
+}
+
+func TestShapeBoundaries(t *testing.T) {
+	sph := Init(1.0, vector.Vec{0, 0, 0}, nil, 8, false)
+	parts := sph.Particles()
+	center := vector.Vec(parts.Position(0))
+	sph.AddOutflow(NewShapeOutflow(sdf.Translate(&sdf.Sphere{Radius: 0.01}, center)))
+	//A round nozzle emitting along x only inside the ball
+	nozzle := vector.Vec{-3, 0, 0}
+	sph.AddInflow(NewShapeInflow(sdf.Translate(&sdf.Sphere{Radius: 0.3}, nozzle), vector.Vec{15, 0, 0}, 0, 0.1))
+	n := parts.N()
+	emitted, removed := sph.BoundaryAll()
+	//Layers cover the 32 lattice columns of spacing 0.1 crossing the disc of radius 0.3
+	if removed != 1 || emitted == 0 || emitted%32 != 0 || parts.N() != n-1+emitted {
+		t.Fatalf("Shape boundaries emitted %d removed %d\n", emitted, removed)
+	}
+	for i := parts.N() - emitted; i < parts.N(); i++ {
+		if p := parts.Position(i); vector.Dist(p, nozzle) > 0.3 {
+			t.Errorf("Particle %v emitted outside the nozzle\n", p)
+		}
+	}
+
+	collider, err := sph.AddShapeCollider(sdf.Translate(&sdf.Box{Half: vector.Vec{0.5, 0.5, 0.5}}, vector.Vec{5, 0, 0}), 0.1, 0.5, 0)
+	if err != nil || collider == nil || len(sph.Colliders()) != 1 {
+		t.Fatalf("Shape collider %v\n", err)
+	}
+	if _, err := sph.AddShapeCollider(&sdf.Plane{Normal: vector.Vec{0, 1, 0}}, 0.1, 0.5, 0); err == nil {
+		t.Errorf("Unbounded shape collider accepted\n")
+	}
+}
+
+func TestKinematicCollider(t *testing.T) {
+	h := float32(0.1)
+	lattice := model.NewParticleArray(512, 0, h, 1000, 0.125)
+	for i := 0; i < lattice.N(); i++ {
+		lattice.Set(i, model.Particle{Position: [3]float32{float32(i%8) * 0.05, float32(i/8%8) * 0.05, float32(i/64) * 0.05}})
+	}
+	sph := New(&lattice, h)
+	parts := sph.Particles()
+	center := vector.Vec(parts.Position(0))
+	box := mesh.Box(0.5, 0.5, 0.5, center)
+	paddle := gltf.NewNodeAnimation(&gltf.Node{})
+	paddle.Set(gltf.PATH_TRANSLATION, &gltf.Keyframes{Input: []float32{0, 1}, Output: []float32{0, 0, 0, 1, 0, 0}, Components: 3})
+	collider := sph.AddKinematicCollider(&box, paddle)
+	first := parts.N() + collider.First
+	rest := parts.Position(first)
+
+	sph.Update()
+	sph.KinematicAll()
+	moved := parts.Get(first)
+	if math.Abs(float64(moved.Position[0]-rest[0]-sph.Elapsed())) > 1e-4 || math.Abs(float64(moved.Velocity[0]-1)) > 1e-2 {
+		t.Errorf("Kinematic particle expected to follow the paddle, %v -> %v velocity %v\n", rest, moved.Position, moved.Velocity)
+	}
+
+	//The fluid is dragged along with the paddle
+	sph.NN()
+	sph.ViscousAll()
+	if parts.Force(0)[0] <= 0 {
+		t.Errorf("Fluid next to the paddle received no drag %v\n", parts.Force(0))
+	}
+
+	//No-slip ghost velocities 2 v_w - v_f drag the resting fluid harder
+	drag := parts.Force(0)[0]
+	sph.SetNoSlip(true)
+	sph.ViscousAll()
+	if parts.Force(0)[0]-drag <= drag {
+		t.Errorf("No-slip drag %f not above the wall velocity drag %f\n", parts.Force(0)[0]-drag, drag)
+	}
+}
+
+func TestThinWallCollision(t *testing.T) {
+	h := float32(0.1)
+	parts := model.NewParticleArray(64, 0, h, 1000, 0.125)
+	for i := 0; i < parts.N(); i++ {
+		parts.Set(i, model.Particle{Position: [3]float32{-0.05, float32(i%8)*0.05 - 0.2, float32(i/8)*0.05 - 0.2}})
+	}
+	sph := New(&parts, h)
+	sph.ClearForceFields()
+	//A zero thickness wall at x 0 facing the particles
+	wall := mesh.InitMesh([]vector.Vec{{0, -1, -1}, {0, 1, -1}, {0, 1, 1}, {0, 1, 1}, {0, -1, 1}, {0, -1, -1}}, vector.Vec{-1, 0, 0})
+	collider := sph.AddCollider(&wall, 0.5, 0)
+
+	for step := 0; step < 20; step++ {
+		for i := 0; i < parts.N(); i++ {
+			particle := parts.Get(i)
+			particle.Velocity = [3]float32{200, 2, 0}
+			parts.Set(i, particle)
+		}
+		sph.Update()
+		for i := 0; i < parts.N(); i++ {
+			if x := parts.Position(i)[0]; x > -collider.Radius {
+				t.Fatalf("Step %d particle %d tunnelled to x %f\n", step, i, x)
+			}
+		}
+	}
+	if v := parts.Velocity(0); math.Abs(float64(v[0]+100)) > 1e-3 || v[1] != 2 {
+		t.Errorf("Restitution 0.5 velocity %v\n", v)
+	}
+
+	//Coulomb friction stops the sliding when the normal impulse is large enough
+	collider.Restitution, collider.Friction = 0, 0.5
+	particle := parts.Get(0)
+	particle.Velocity = [3]float32{200, 50, 0}
+	parts.Set(0, particle)
+	sph.Update()
+	if v := parts.Velocity(0); vector.Mag(v) > 1e-3 {
+		t.Errorf("Friction velocity %v\n", v)
+	}
+}
@@ -0,0 +1,134 @@
+package sph
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//ThermalPhase holds the thermal material properties of a particle phase
+type ThermalPhase struct {
+	Conductivity   float32 //Thermal conductivity k
+	HeatCapacity   float32 //Specific heat capacity c
+	Expansion      float32 //Boussinesq thermal expansion coefficient
+	ViscosityDecay float32 //Exponential viscosity temperature coefficient, 0 disables
+}
+
+//Heater holds a range of boundary particles at a fixed temperature. First is
+//the offset of the range from the first boundary particle so that the range
+//survives changes in the fluid particle count
+type Heater struct {
+	First       int
+	Count       int
+	Temperature float32
+}
+
+//Thermal configures SPH heat conduction and Boussinesq buoyancy. Phases are
+//indexed by the particle phase, particles with a phase outside the list use
+//the first phase
+type Thermal struct {
+	Phases  []ThermalPhase
+	Ambient float32 //Reference temperature for buoyancy and viscosity
+	Heaters []Heater
+}
+
+//WaterPhase returns thermal properties for water in the simulation units
+func WaterPhase() ThermalPhase {
+	return ThermalPhase{Conductivity: 0.6, HeatCapacity: 4.186, Expansion: 2.1e-4, ViscosityDecay: 0.0}
+}
+
+//NewThermal creates a single phase water thermal model with the given ambient temperature
+func NewThermal(ambient float32) *Thermal {
+	return &Thermal{Phases: []ThermalPhase{WaterPhase()}, Ambient: ambient}
+}
+
+//Phase returns the thermal properties for phase index x
+func (t *Thermal) Phase(x int) ThermalPhase {
+	if x >= 0 && x < len(t.Phases) {
+		return t.Phases[x]
+	}
+	return t.Phases[0]
+}
+
+func (p *SPH) SetThermal(t *Thermal) {
+	p.thermal = t
+	for i := 0; i < p.field.Particles.Total(); i++ {
+		p.field.Particles.SetTemperature(i, t.Ambient)
+	}
+}
+
+func (p *SPH) Thermal() *Thermal {
+	return p.thermal
+}
+
+//AddHeatedCollider samples boundary particles from the collider and holds them at
+//temperature. Requires a thermal model to be set
+func (p *SPH) AddHeatedCollider(collider *mesh.Mesh, temperature float32) {
+	if p.thermal == nil {
+		return
+	}
+	first := p.field.Particles.Total() - p.field.Particles.N()
+	p.field.BoundaryParticles([]*mesh.Mesh{collider})
+	count := p.field.Particles.Total() - p.field.Particles.N() - first
+	p.thermal.Heaters = append(p.thermal.Heaters, Heater{first, count, temperature})
+	p.NN()
+}
+
+//viscosityAt returns the viscosity coefficient of particle i accounting for
+//temperature dependence when a thermal model is set
+func (p *SPH) viscosityAt(i int) float32 {
+	if p.thermal == nil {
+		return p.mu
+	}
+	decay := p.thermal.Phase(p.field.Particles.Phase(i)).ViscosityDecay
+	if decay == 0 {
+		return p.mu
+	}
+	dt := p.field.Particles.Temperature(i) - p.thermal.Ambient
+	return p.mu * float32(math.Exp(float64(-decay*dt)))
+}
+
+//ThermalAll holds heater boundaries at temperature, conducts heat through the
+//temperature field laplacian over one time step and adds the Boussinesq buoyancy
+//force. Does nothing when no thermal model is set
+func (p *SPH) ThermalAll() {
+	if p.thermal == nil {
+		return
+	}
+	parts := p.field.Particles
+	n := parts.N()
+	for _, heater := range p.thermal.Heaters {
+		for b := heater.First; b < heater.First+heater.Count; b++ {
+			if n+b < parts.Total() {
+				parts.SetTemperature(n+b, heater.Temperature)
+			}
+		}
+	}
+
+	//Conduction dT/dt = k/(rho c) Lap(T) evaluated before any update
+	temperature := p.field.GetFields()["temperature"]
+	rates := make([]float32, n)
+	for i := 0; i < n; i++ {
+		phase := p.thermal.Phase(parts.Phase(i))
+		dens := parts.Density(i)
+		if dens <= 0 {
+			dens = parts.D0()
+		}
+		rates[i] = phase.Conductivity / (dens * phase.HeatCapacity) * p.field.Laplacian(i, temperature)
+	}
+
+	//Buoyancy opposes the solver gravity including container frame accelerations
+	gravity := p.Gravity()
+	for i := 0; i < n; i++ {
+		t := parts.Temperature(i) + rates[i]*p.time
+		parts.SetTemperature(i, t)
+
+		//Boussinesq buoyancy F = -m beta (T - T0) g
+		beta := p.thermal.Phase(parts.Phase(i)).Expansion
+		scale := -parts.ParticleMass(i) * beta * (t - p.thermal.Ambient)
+		particle := parts.Get(i)
+		particle.AddForce(vector.CastFixed(vector.Scale(gravity, scale)))
+		parts.Set(i, particle)
+	}
+}
@@ -0,0 +1,156 @@
+package surface
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/matrix"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/sampler/cell"
+)
+
+//Kernel is the anisotropic smoothing kernel of a particle, Yu & Turk 2013. G
+//maps offsets from the smoothed Center into the unit kernel sphere and Extent
+//is the largest world space reach of the kernel ellipsoid
+type Kernel struct {
+	Center vector.Vec
+	G      matrix.Mat
+	Extent float32
+}
+
+//Kernels computes the anisotropic kernel of every fluid particle from the
+//weighted principal components of its neighbors within twice the support. With
+//enough neighbors the kernel shrinks along the weak axes, limited to Stretch
+//times the strongest, and is rescaled to keep the isotropic kernel volume, so
+//kernels flatten at the free surface and along thin sheets. Centers are moved
+//toward the weighted mean of the neighbors within the support by the Smoothing
+//factor, the smaller radius limiting the shrinkage of the liquid volume
+func Kernels(parts *model.ParticleArray, p Params) []Kernel {
+	radius := 2 * p.Support
+	search := cell.New(parts, radius, nil)
+	kernels := make([]Kernel, parts.N())
+	for i := 0; i < parts.N(); i++ {
+		x := parts.Position(i)
+		sum, local := float64(0), float64(0)
+		mean, smooth := [3]float64{}, [3]float64{}
+		neighbors := search.GetSamples(i)
+		weights := make([]float64, len(neighbors))
+		count := 0
+		for n, j := range neighbors {
+			if j >= parts.N() {
+				continue
+			}
+			r := float64(vector.Dist(parts.Position(j), x)) / float64(radius)
+			if r >= 1 {
+				continue
+			}
+			weights[n] = 1 - r*r*r
+			sum += weights[n]
+			xj := parts.Position(j)
+			for a := 0; a < 3; a++ {
+				mean[a] += weights[n] * float64(xj[a])
+			}
+			if r < 0.5 {
+				w := 1 - 8*r*r*r
+				local += w
+				for a := 0; a < 3; a++ {
+					smooth[a] += w * float64(xj[a])
+				}
+			}
+			count++
+		}
+		for a := 0; a < 3; a++ {
+			mean[a] /= sum
+			smooth[a] /= local
+		}
+
+		cov := matrix.Mat3(0.0)
+		for n, j := range neighbors {
+			if weights[n] == 0 {
+				continue
+			}
+			xj := parts.Position(j)
+			for a := 0; a < 3; a++ {
+				for b := 0; b < 3; b++ {
+					cov[a*3+b] += float32(weights[n] * (float64(xj[a]) - mean[a]) * (float64(xj[b]) - mean[b]) / sum)
+				}
+			}
+		}
+
+		sigma := vector.Vec{1, 1, 1}
+		axes := matrix.Mat3(1.0)
+		if count > p.Neighbors {
+			values, vectors := cov.SymEigen3()
+			if values[0] > 0 {
+				axes = vectors
+				sigma[0] = values[0]
+				for a := 1; a < 3; a++ {
+					sigma[a] = float32(math.Max(float64(values[a]), float64(values[0]/p.Stretch)))
+				}
+			}
+		}
+		//Singular values of the covariance are squared lengths
+		scale := float32(math.Cbrt(float64(sigma[0] * sigma[1] * sigma[2])))
+		extent := float32(0)
+		for a := 0; a < 3; a++ {
+			sigma[a] = float32(math.Sqrt(float64(sigma[a] / scale)))
+			extent = float32(math.Max(float64(extent), float64(sigma[a])))
+		}
+
+		g := matrix.Mat3(0.0)
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				for k := 0; k < 3; k++ {
+					g[a*3+b] += axes[a*3+k] * axes[b*3+k] / (sigma[k] * p.Support)
+				}
+			}
+		}
+		center := vector.Vec{0, 0, 0}
+		for a := 0; a < 3; a++ {
+			center[a] = (1-p.Smoothing)*x[a] + p.Smoothing*float32(smooth[a])
+		}
+		kernels[i] = Kernel{center, g, extent * p.Support}
+	}
+	return kernels
+}
+
+//sampleAnisotropic builds the color field of the anisotropic kernels, the sum of
+//particle volumes times det(G) P(|G r|) with P the normalized (1-s^2)^3 kernel
+func sampleAnisotropic(parts *model.ParticleArray, p Params) *Field {
+	kernels := Kernels(parts, p)
+	pad := p.Spacing
+	for _, k := range kernels {
+		pad = float32(math.Max(float64(pad), float64(k.Extent+p.Spacing)))
+	}
+	min, max := Bounds(parts, pad)
+	f := NewField(min, max, p.Spacing, p.Iso)
+	norm := float32(315 / (64 * math.Pi))
+	r := vector.Vec{0, 0, 0}
+	for q, k := range kernels {
+		rho := parts.Density(q)
+		if rho <= 0 {
+			rho = parts.D0()
+		}
+		volume := parts.ParticleMass(q) / rho * k.G.Det3() * norm
+		lo, hi := f.Range(k.Center, k.Extent)
+		for i := lo[0]; i <= hi[0]; i++ {
+			for j := lo[1]; j <= hi[1]; j++ {
+				for l := lo[2]; l <= hi[2]; l++ {
+					r[0] = f.Min[0] + f.Spacing*float32(i) - k.Center[0]
+					r[1] = f.Min[1] + f.Spacing*float32(j) - k.Center[1]
+					r[2] = f.Min[2] + f.Spacing*float32(l) - k.Center[2]
+					s2 := float32(0)
+					for a := 0; a < 3; a++ {
+						s := k.G[a*3]*r[0] + k.G[a*3+1]*r[1] + k.G[a*3+2]*r[2]
+						s2 += s * s
+					}
+					if s2 < 1 {
+						w := 1 - s2
+						f.Values[f.Index(i, j, l)] -= volume * w * w * w
+					}
+				}
+			}
+		}
+	}
+	return f
+}
@@ -0,0 +1,182 @@
+package surface
+
+import (
+	"github.com/andewx/dieselfluid/geom/mesh"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Cube corner n lies at offset [n&1, n>>1&1, n>>2&1]. Faces list their corners
+//counter clockwise seen from outside the cube
+var cubeFaces = [6][4]int{{0, 4, 6, 2}, {1, 3, 7, 5}, {0, 1, 5, 4}, {2, 6, 7, 3}, {0, 2, 3, 1}, {4, 5, 7, 6}}
+
+//cubeEdges lists the corner pairs of the 12 cube edges, lower corner first
+var cubeEdges [12][2]int
+
+//edgeIndex maps a corner pair to its cube edge
+var edgeIndex [8][8]int
+
+//cubeCases holds the triangles of each inside corner configuration as cube
+//edge triples
+var cubeCases [256][][3]int
+
+func init() {
+	e := 0
+	for a := 0; a < 8; a++ {
+		for _, bit := range []int{1, 2, 4} {
+			if a&bit == 0 {
+				cubeEdges[e] = [2]int{a, a | bit}
+				edgeIndex[a][a|bit], edgeIndex[a|bit][a] = e, e
+				e++
+			}
+		}
+	}
+	for c := 0; c < 256; c++ {
+		cubeCases[c] = triangulate(c)
+	}
+}
+
+//triangulate builds the triangles of a corner configuration from the iso lines
+//on the cube faces. On every face each run of adjacent inside corners is cut off
+//by a segment running from its outgoing to its incoming edge, which separates
+//the inside corners of ambiguous faces. Neighbor cubes see the same face so the
+//surface is watertight, and every crossed edge starts one segment and ends
+//another so the segments close into loops which are fanned into triangles
+//facing away from the inside
+func triangulate(c int) [][3]int {
+	inside := func(n int) bool { return c&(1<<uint(n)) != 0 }
+	next := make(map[int]int)
+	for _, face := range cubeFaces {
+		for k := 0; k < 4; k++ {
+			a, b := face[k], face[(k+1)%4]
+			if !inside(a) || inside(b) {
+				continue
+			}
+			//Walk back over the run of inside corners to its incoming edge
+			m := k
+			for inside(face[(m+3)%4]) {
+				m = (m + 3) % 4
+			}
+			next[edgeIndex[a][b]] = edgeIndex[face[(m+3)%4]][face[m]]
+		}
+	}
+
+	triangles := make([][3]int, 0)
+	visited := make(map[int]bool)
+	for e := 0; e < 12; e++ {
+		if _, ok := next[e]; !ok || visited[e] {
+			continue
+		}
+		loop := []int{}
+		for v := e; !visited[v]; v = next[v] {
+			visited[v] = true
+			loop = append(loop, v)
+		}
+		for v := 1; v < len(loop)-1; v++ {
+			triangles = append(triangles, [3]int{loop[0], loop[v+1], loop[v]})
+		}
+	}
+	return triangles
+}
+
+//Surface is an indexed triangle mesh with one smooth normal per vertex.
+//Triangles are counter clockwise seen from outside the liquid
+type Surface struct {
+	Positions []vector.Vec
+	Normals   []vector.Vec
+	Indices   []int
+}
+
+//Triangles returns the number of triangles
+func (s *Surface) Triangles() int {
+	return len(s.Indices) / 3
+}
+
+//Volume returns the enclosed volume of a closed surface
+func (s *Surface) Volume() float32 {
+	v := float32(0)
+	for t := 0; t < len(s.Indices); t += 3 {
+		a, b, c := s.Positions[s.Indices[t]], s.Positions[s.Indices[t+1]], s.Positions[s.Indices[t+2]]
+		v += vector.Dot(a, vector.Cross(b, c)) / 6
+	}
+	return v
+}
+
+//Indexed returns the surface as an indexed mesh sharing its vertex arrays
+func (s *Surface) Indexed() *mesh.Indexed {
+	m := mesh.Indexed{Positions: s.Positions, Normals: s.Normals, Indices: s.Indices}
+	m.Connect()
+	return &m
+}
+
+//Mesh returns the surface as a triangle list with face normals
+func (s *Surface) Mesh() mesh.Mesh {
+	m := mesh.Mesh{Vertexes: make([]vector.Vec, len(s.Indices)), Normals: make([]vector.Vec, s.Triangles())}
+	for t := 0; t < len(s.Indices); t += 3 {
+		for v := 0; v < 3; v++ {
+			p := s.Positions[s.Indices[t+v]]
+			m.Vertexes[t+v] = vector.Vec{p[0], p[1], p[2]}
+		}
+		ab := vector.Sub(m.Vertexes[t+1], m.Vertexes[t])
+		ac := vector.Sub(m.Vertexes[t+2], m.Vertexes[t])
+		m.Normals[t/3] = vector.Norm(vector.Cross(ab, ac))
+	}
+	return m
+}
+
+//Polygonize extracts the iso level set of the field with marching cubes. Nodes
+//below iso are inside. Vertices are shared between neighbor cells and their
+//normals follow the interpolated field gradient
+func Polygonize(f *Field, iso float32) *Surface {
+	s := &Surface{Positions: []vector.Vec{}, Normals: []vector.Vec{}, Indices: []int{}}
+	vertices := make(map[int]int)
+	vertex := func(i int, j int, k int, e int) int {
+		a, b := cubeEdges[e][0], cubeEdges[e][1]
+		ia, ja, ka := i+a&1, j+a>>1&1, k+a>>2&1
+		ib, jb, kb := i+b&1, j+b>>1&1, k+b>>2&1
+		axis := 0
+		for ; (a^b)>>uint(axis) != 1; axis++ {
+		}
+		key := f.Index(ia, ja, ka)*3 + axis
+		if v, ok := vertices[key]; ok {
+			return v
+		}
+		fa, fb := f.At(ia, ja, ka), f.At(ib, jb, kb)
+		t := float32(0.5)
+		if fb != fa {
+			t = (iso - fa) / (fb - fa)
+		}
+		pa, pb := f.Position(ia, ja, ka), f.Position(ib, jb, kb)
+		ga, gb := f.Gradient(ia, ja, ka), f.Gradient(ib, jb, kb)
+		p, n := vector.Vec{0, 0, 0}, vector.Vec{0, 0, 0}
+		for c := 0; c < 3; c++ {
+			p[c] = pa[c] + t*(pb[c]-pa[c])
+			n[c] = ga[c] + t*(gb[c]-ga[c])
+		}
+		if vector.Mag(n) > 0 {
+			n = vector.Norm(n)
+		}
+		vertices[key] = len(s.Positions)
+		s.Positions = append(s.Positions, p)
+		s.Normals = append(s.Normals, n)
+		return vertices[key]
+	}
+
+	for i := 0; i < f.Dims[0]-1; i++ {
+		for j := 0; j < f.Dims[1]-1; j++ {
+			for k := 0; k < f.Dims[2]-1; k++ {
+				c := 0
+				for n := 0; n < 8; n++ {
+					if f.At(i+n&1, j+n>>1&1, k+n>>2&1) < iso {
+						c |= 1 << uint(n)
+					}
+				}
+				for _, tri := range cubeCases[c] {
+					for _, e := range tri {
+						s.Indices = append(s.Indices, vertex(i, j, k, e))
+					}
+				}
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package surface
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+//Mesh Format Enums
+const FORMAT_OBJ = 0
+const FORMAT_GLTF = 1
+
+//ExportFrame writes the surface of an animation frame to prefix_00042.obj or
+//.gltf and returns the file name
+func (s *Surface) ExportFrame(prefix string, frame int, format int) (string, error) {
+	if format == FORMAT_GLTF {
+		filename := fmt.Sprintf("%s_%05d.gltf", prefix, frame)
+		return filename, s.ExportGLTF(filename, fmt.Sprintf("liquid_%05d", frame))
+	}
+	filename := fmt.Sprintf("%s_%05d.obj", prefix, frame)
+	return filename, s.ExportOBJ(filename)
+}
+
+//ExportOBJ writes the surface as a Wavefront OBJ with vertex normals
+func (s *Surface) ExportOBJ(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "# dieselfluid liquid surface\n")
+	for _, p := range s.Positions {
+		fmt.Fprintf(w, "v %g %g %g\n", p[0], p[1], p[2])
+	}
+	for _, n := range s.Normals {
+		fmt.Fprintf(w, "vn %g %g %g\n", n[0], n[1], n[2])
+	}
+	for t := 0; t < len(s.Indices); t += 3 {
+		a, b, c := s.Indices[t]+1, s.Indices[t+1]+1, s.Indices[t+2]+1
+		fmt.Fprintf(w, "f %d//%d %d//%d %d//%d\n", a, a, b, b, c, c)
+	}
+	return w.Flush()
+}
+
+//ExportGLTF writes the surface as a self contained glTF 2.0 file with the vertex
+//positions, normals and triangle indices embedded as a base64 buffer
+func (s *Surface) ExportGLTF(filename string, name string) error {
+	return s.Indexed().ExportGLTF(filename, name)
+}
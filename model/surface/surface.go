@@ -0,0 +1,212 @@
+//Liquid surface reconstruction from SPH particles. A signed scalar field is
+//sampled around the fluid particles, negative inside the liquid, and polygonized
+//with marching cubes into an indexed triangle mesh with smooth vertex normals
+package surface
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Scalar Field Method Enums
+const METHOD_ZHU_BRIDSON = 0 //Distance to the weighted particle average, Zhu & Bridson 2005
+const METHOD_COLOR = 1       //Iso level of the smoothed fluid color field
+const METHOD_ANISOTROPIC = 2 //Color field of anisotropic kernels, Yu & Turk 2013
+
+//Params configures the scalar field. Radius is the particle radius used by the
+//Zhu Bridson field, Support the smoothing radius of the fields, Spacing the
+//marching cubes cell size and Iso the color field level of the surface. The
+//anisotropic field needs more than Neighbors particles to stretch a kernel up to
+//Stretch, and blends each kernel center toward its neighbor mean by Smoothing
+type Params struct {
+	Method    int
+	Radius    float32
+	Support   float32
+	Spacing   float32
+	Iso       float32
+	Smoothing float32
+	Neighbors int
+	Stretch   float32
+}
+
+//DefaultParams returns a Zhu Bridson field for the SPH kernel length h with
+//particles spaced h/2 apart and cells of half the particle spacing
+func DefaultParams(h float32) Params {
+	return Params{Method: METHOD_ZHU_BRIDSON, Radius: h / 4, Support: h, Spacing: h / 4, Iso: 0.5,
+		Smoothing: 0.5, Neighbors: 25, Stretch: 4}
+}
+
+//Field is a dense scalar grid where node [i,j,k] samples Min + Spacing*[i,j,k]
+type Field struct {
+	Min     vector.Vec
+	Spacing float32
+	Dims    [3]int
+	Values  []float32
+}
+
+//NewField allocates a field covering the box [min,max] with all nodes set to the
+//outside value
+func NewField(min vector.Vec, max vector.Vec, spacing float32, outside float32) *Field {
+	f := &Field{Min: vector.Vec{min[0], min[1], min[2]}, Spacing: spacing}
+	for a := 0; a < 3; a++ {
+		f.Dims[a] = int(math.Ceil(float64((max[a]-min[a])/spacing))) + 1
+	}
+	f.Values = make([]float32, f.Dims[0]*f.Dims[1]*f.Dims[2])
+	for n := range f.Values {
+		f.Values[n] = outside
+	}
+	return f
+}
+
+//Index returns the value index of node [i,j,k] with z varying fastest
+func (f *Field) Index(i int, j int, k int) int {
+	return (i*f.Dims[1]+j)*f.Dims[2] + k
+}
+
+//At returns the value of node [i,j,k]
+func (f *Field) At(i int, j int, k int) float32 {
+	return f.Values[f.Index(i, j, k)]
+}
+
+//Position returns the sample position of node [i,j,k]
+func (f *Field) Position(i int, j int, k int) vector.Vec {
+	return vector.Vec{f.Min[0] + f.Spacing*float32(i), f.Min[1] + f.Spacing*float32(j), f.Min[2] + f.Spacing*float32(k)}
+}
+
+//Gradient returns the central difference gradient at node [i,j,k], one sided
+//on the field border
+func (f *Field) Gradient(i int, j int, k int) vector.Vec {
+	g := vector.Vec{0, 0, 0}
+	ijk := [3]int{i, j, k}
+	for a := 0; a < 3; a++ {
+		lo, hi := ijk, ijk
+		if lo[a] > 0 {
+			lo[a]--
+		}
+		if hi[a] < f.Dims[a]-1 {
+			hi[a]++
+		}
+		if hi[a] > lo[a] {
+			g[a] = (f.At(hi[0], hi[1], hi[2]) - f.At(lo[0], lo[1], lo[2])) / (f.Spacing * float32(hi[a]-lo[a]))
+		}
+	}
+	return g
+}
+
+//Range returns the node index range within radius of x, clamped to the field
+func (f *Field) Range(x []float32, radius float32) ([3]int, [3]int) {
+	var lo, hi [3]int
+	for a := 0; a < 3; a++ {
+		lo[a] = int(math.Ceil(float64((x[a] - radius - f.Min[a]) / f.Spacing)))
+		hi[a] = int(math.Floor(float64((x[a] + radius - f.Min[a]) / f.Spacing)))
+		if lo[a] < 0 {
+			lo[a] = 0
+		}
+		if hi[a] > f.Dims[a]-1 {
+			hi[a] = f.Dims[a] - 1
+		}
+	}
+	return lo, hi
+}
+
+//Bounds returns the fluid particle bounding box grown by pad
+func Bounds(parts *model.ParticleArray, pad float32) (vector.Vec, vector.Vec) {
+	min := vector.Vec{float32(math.Inf(1)), float32(math.Inf(1)), float32(math.Inf(1))}
+	max := vector.Vec{float32(math.Inf(-1)), float32(math.Inf(-1)), float32(math.Inf(-1))}
+	for p := 0; p < parts.N(); p++ {
+		x := parts.Position(p)
+		for a := 0; a < 3; a++ {
+			min[a] = float32(math.Min(float64(min[a]), float64(x[a]-pad)))
+			max[a] = float32(math.Max(float64(max[a]), float64(x[a]+pad)))
+		}
+	}
+	return min, max
+}
+
+//weight is the smooth kernel (1-s^2)^3 of the Zhu Bridson field for s = r/R
+func weight(r2 float32, support float32) float32 {
+	s := 1 - r2/(support*support)
+	if s <= 0 {
+		return 0
+	}
+	return s * s * s
+}
+
+//Sample builds the signed field of the fluid particles. The Zhu Bridson field is
+//|x - xavg| - ravg over kernel weighted particle averages, the color field is
+//Iso minus the normalized kernel sum of the particle volumes
+func Sample(parts *model.ParticleArray, p Params) *Field {
+	if p.Method == METHOD_ANISOTROPIC && parts.N() > 0 {
+		return sampleAnisotropic(parts, p)
+	}
+	pad := p.Support + p.Spacing
+	min, max := Bounds(parts, pad)
+	f := NewField(min, max, p.Spacing, p.Support)
+	if parts.N() == 0 {
+		f.Dims = [3]int{0, 0, 0}
+		f.Values = nil
+		return f
+	}
+	n := len(f.Values)
+	sum := make([]float32, n)
+	var avg []float32
+	if p.Method == METHOD_ZHU_BRIDSON {
+		avg = make([]float32, n*3)
+	}
+	norm := float32(315 / (64 * math.Pi * math.Pow(float64(p.Support), 3)))
+
+	for q := 0; q < parts.N(); q++ {
+		x := parts.Position(q)
+		volume := float32(1)
+		if p.Method == METHOD_COLOR {
+			rho := parts.Density(q)
+			if rho <= 0 {
+				rho = parts.D0()
+			}
+			volume = parts.ParticleMass(q) / rho
+		}
+		lo, hi := f.Range(x, p.Support)
+		for i := lo[0]; i <= hi[0]; i++ {
+			for j := lo[1]; j <= hi[1]; j++ {
+				for k := lo[2]; k <= hi[2]; k++ {
+					dx := f.Min[0] + f.Spacing*float32(i) - x[0]
+					dy := f.Min[1] + f.Spacing*float32(j) - x[1]
+					dz := f.Min[2] + f.Spacing*float32(k) - x[2]
+					w := weight(dx*dx+dy*dy+dz*dz, p.Support)
+					if w == 0 {
+						continue
+					}
+					m := f.Index(i, j, k)
+					sum[m] += volume * w
+					if avg != nil {
+						for a := 0; a < 3; a++ {
+							avg[m*3+a] += w * x[a]
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for m := range f.Values {
+		if p.Method == METHOD_COLOR {
+			f.Values[m] = p.Iso - sum[m]*norm
+			continue
+		}
+		if sum[m] <= 0 {
+			continue
+		}
+		i, j, k := m/(f.Dims[1]*f.Dims[2]), (m/f.Dims[2])%f.Dims[1], m%f.Dims[2]
+		x := f.Position(i, j, k)
+		c := vector.Vec{avg[m*3] / sum[m], avg[m*3+1] / sum[m], avg[m*3+2] / sum[m]}
+		f.Values[m] = vector.Mag(vector.Sub(x, c)) - p.Radius
+	}
+	return f
+}
+
+//Reconstruct samples the particle field and polygonizes its zero level set
+func Reconstruct(parts *model.ParticleArray, p Params) *Surface {
+	return Polygonize(Sample(parts, p), 0)
+}
@@ -0,0 +1,165 @@
+package surface
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/gltf"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+const dx = float32(0.05)
+
+//A ball of fluid particles on a lattice centered at the origin
+func ball(radius float32) model.ParticleArray {
+	positions := make([][3]float32, 0)
+	n := int(radius / dx)
+	corner := -float32(n) * dx
+	for _, p := range model.Lattice([3]float32{corner, corner, corner}, [3]int{2*n + 1, 2*n + 1, 2*n + 1}, dx) {
+		if p[0]*p[0]+p[1]*p[1]+p[2]*p[2] <= radius*radius {
+			positions = append(positions, p)
+		}
+	}
+	return model.NewLatticeArray(positions, dx)
+}
+
+//watertight checks that every directed edge is matched by its reverse
+func watertight(s *Surface) bool {
+	edges := make(map[[2]int]int)
+	for t := 0; t < len(s.Indices); t += 3 {
+		for v := 0; v < 3; v++ {
+			edges[[2]int{s.Indices[t+v], s.Indices[t+(v+1)%3]}]++
+		}
+	}
+	for e, count := range edges {
+		if count != 1 || edges[[2]int{e[1], e[0]}] != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCases(t *testing.T) {
+	//Random fields exercise every cube configuration including ambiguous faces
+	rng := rand.New(rand.NewSource(1))
+	f := NewField(vector.Vec{0, 0, 0}, vector.Vec{1, 1, 1}, 0.125, 1)
+	for i := 1; i < f.Dims[0]-1; i++ {
+		for j := 1; j < f.Dims[1]-1; j++ {
+			for k := 1; k < f.Dims[2]-1; k++ {
+				f.Values[f.Index(i, j, k)] = rng.Float32()*2 - 1
+			}
+		}
+	}
+	s := Polygonize(f, 0)
+	if s.Triangles() == 0 || !watertight(s) {
+		t.Errorf("Random field surface of %d triangles is not watertight\n", s.Triangles())
+	}
+	if len(cubeCases[0]) != 0 || len(cubeCases[255]) != 0 || len(cubeCases[1]) != 1 || len(cubeCases[0x69]) != 4 {
+		t.Errorf("Marching cubes case table\n")
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	radius := float32(0.3)
+	parts := ball(radius)
+	fluid := float64(parts.N()) * float64(dx*dx*dx)
+	for _, method := range []int{METHOD_ZHU_BRIDSON, METHOD_COLOR} {
+		p := DefaultParams(2 * dx)
+		p.Method = method
+		s := Reconstruct(&parts, p)
+		if !watertight(s) {
+			t.Errorf("Method %d surface is not watertight\n", method)
+		}
+		if v := float64(s.Volume()); math.Abs(v/fluid-1) > 0.2 {
+			t.Errorf("Method %d encloses %f expected %f\n", method, v, fluid)
+		}
+		for v, n := range s.Normals {
+			if vector.Dot(n, s.Positions[v]) <= 0 || math.Abs(float64(vector.Mag(n))-1) > 1e-3 {
+				t.Fatalf("Method %d normal %v at %v does not face out\n", method, n, s.Positions[v])
+			}
+		}
+		m := s.Mesh()
+		for i, n := range m.Normals {
+			if vector.Dot(n, m.Vertexes[i*3]) <= 0 {
+				t.Fatalf("Method %d triangle %d faces inward\n", method, i)
+			}
+		}
+	}
+
+	s := Reconstruct(&parts, DefaultParams(2*dx))
+	dir, err := ioutil.TempDir("", "surface")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	obj, err := s.ExportFrame(dir+"/liquid", 7, FORMAT_OBJ)
+	if err != nil || !strings.HasSuffix(obj, "liquid_00007.obj") {
+		t.Fatalf("OBJ export %s %v\n", obj, err)
+	}
+	data, _ := ioutil.ReadFile(obj)
+	if strings.Count(string(data), "\nf ") != s.Triangles() || strings.Count(string(data), "\nvn ") != len(s.Normals) {
+		t.Errorf("OBJ export counts\n")
+	}
+
+	file, err := s.ExportFrame(dir+"/liquid", 7, FORMAT_GLTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadFile(file)
+	doc := gltf.GlTF{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Meshes) != 1 || doc.Accessors[2].Count != len(s.Indices) || doc.Accessors[0].Count != len(s.Positions) {
+		t.Errorf("glTF export meshes %d accessors %v\n", len(doc.Meshes), doc.Accessors)
+	}
+}
+
+//A slab of fluid particles layers thick centered at the origin
+func slab(side int, layers int) model.ParticleArray {
+	x, y := -float32(side-1)/2*dx, -float32(layers-1)/2*dx
+	return model.NewLatticeArray(model.Lattice([3]float32{x, y, x}, [3]int{side, layers, side}, dx), dx)
+}
+
+func TestAnisotropic(t *testing.T) {
+	p := DefaultParams(2 * dx)
+	p.Method = METHOD_ANISOTROPIC
+	parts := ball(0.3)
+	s := Reconstruct(&parts, p)
+	fluid := float64(parts.N()) * float64(dx*dx*dx)
+	if v := float64(s.Volume()); !watertight(s) || math.Abs(v/fluid-1) > 0.2 {
+		t.Errorf("Anisotropic ball encloses %f expected %f\n", v, fluid)
+	}
+
+	//Kernels on a sheet are squashed across it and the surface stays flat
+	layers := 3
+	sheet := slab(16, layers)
+	kernels := Kernels(&sheet, p)
+	center := (8*layers+layers-1)*16 + 8
+	g := kernels[center].G
+	across := vector.Mag(g.CrossVec(vector.Vec{0, 1, 0}))
+	along := vector.Mag(g.CrossVec(vector.Vec{1, 0, 0}))
+	if across < 1.5*along {
+		t.Errorf("Sheet kernel not flattened, across %f along %f\n", across, along)
+	}
+	s = Reconstruct(&sheet, p)
+	top, count, flat := float32(0), 0, float32(0)
+	for v, x := range s.Positions {
+		if x[1] > 0 && math.Abs(float64(x[0])) < 0.2 && math.Abs(float64(x[2])) < 0.2 {
+			top += x[1]
+			flat = float32(math.Max(float64(flat), 1-float64(s.Normals[v][1])))
+			count++
+		}
+	}
+	top /= float32(count)
+	if thickness := 2 * top; count == 0 || thickness > float32(layers+1)*dx || thickness < float32(layers-1)*dx || flat > 0.01 {
+		t.Errorf("Sheet of %d layers reconstructed %f thick with normal deviation %f\n", layers, thickness, flat)
+	}
+}
@@ -0,0 +1,62 @@
+package voxel
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+)
+
+func floatBytes(values []float32) []byte {
+	buf := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+//ExportRaw writes the dense volume as little endian float32 values with x varying
+//fastest and the components of a voxel interleaved. The file carries no header
+func ExportRaw(filename string, vol *Volume) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(floatBytes(vol.Dense()))
+	return err
+}
+
+//ExportVTI writes the volume as VTK XML image data with the field stored as
+//binary point data, base64 encoded together with its byte count header. Inactive
+//voxels hold zero
+func ExportVTI(filename string, vol *Volume, name string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data := floatBytes(vol.Dense())
+	header := make([]byte, 4)
+	binary.LittleEndian.PutUint32(header, uint32(len(data)))
+	extent := fmt.Sprintf("0 %d 0 %d 0 %d", vol.Dims[0]-1, vol.Dims[1]-1, vol.Dims[2]-1)
+
+	w := bufio.NewWriter(file)
+	fmt.Fprintf(w, "<?xml version=\"1.0\"?>\n")
+	fmt.Fprintf(w, "<VTKFile type=\"ImageData\" version=\"1.0\" byte_order=\"LittleEndian\" header_type=\"UInt32\">\n")
+	fmt.Fprintf(w, "  <ImageData WholeExtent=\"%s\" Origin=\"%g %g %g\" Spacing=\"%g %g %g\">\n", extent,
+		vol.Min[0], vol.Min[1], vol.Min[2], vol.Spacing[0], vol.Spacing[1], vol.Spacing[2])
+	fmt.Fprintf(w, "    <Piece Extent=\"%s\">\n", extent)
+	attribute := "Scalars"
+	if vol.Components == 3 {
+		attribute = "Vectors"
+	}
+	fmt.Fprintf(w, "      <PointData %s=\"%s\">\n", attribute, name)
+	fmt.Fprintf(w, "        <DataArray type=\"Float32\" Name=\"%s\" NumberOfComponents=\"%d\" format=\"binary\">\n", name, vol.Components)
+	fmt.Fprintf(w, "          %s\n", base64.StdEncoding.EncodeToString(append(header, data...)))
+	fmt.Fprintf(w, "        </DataArray>\n      </PointData>\n    </Piece>\n  </ImageData>\n</VTKFile>\n")
+	return w.Flush()
+}
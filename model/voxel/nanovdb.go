@@ -0,0 +1,348 @@
+package voxel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+//NanoVDB Layout. Grids follow the uncompressed NanoVDB 32.3 float grid buffer,
+//a 672 byte grid header and 64 byte tree header followed breadth first by the
+//root, its tiles and the 32^3 upper, 16^3 lower and 8^3 leaf nodes. Child
+//references are signed byte offsets from the parent node
+const (
+	NANOVDB_MAGIC   = 0x304244566f6e614e //"NanoVDB0"
+	NANOVDB_VERSION = 32<<21 | 3<<10 | 3
+
+	NANOVDB_GRID_SIZE  = 672
+	NANOVDB_TREE_SIZE  = 64
+	NANOVDB_ROOT_SIZE  = 64
+	NANOVDB_TILE_SIZE  = 32
+	NANOVDB_UPPER_SIZE = 8256 + 8*32*32*32
+	NANOVDB_LOWER_SIZE = 1088 + 8*16*16*16
+	NANOVDB_LEAF_SIZE  = 96 + 4*BLOCK_VOXELS
+
+	NANOVDB_TYPE_FLOAT = 1
+
+	NANOVDB_CLASS_UNKNOWN    = 0
+	NANOVDB_CLASS_FOG_VOLUME = 2
+
+	nanovdbFlags  = 2 | 4 | 32 //Has bbox, has min max, breadth first
+	lowerLog2     = 4
+	upperLog2     = 5
+	lowerTotal    = BLOCK_LOG2 + lowerLog2
+	upperTotal    = lowerTotal + upperLog2
+	metaDataSize  = 176
+	fileHeadSize  = 16
+	maxNameLength = 255
+)
+
+//bbox is an inclusive index space bounding box with the node value range
+type bbox struct {
+	min, max [3]int32
+	lo, hi   float32
+}
+
+func emptyBox() bbox {
+	return bbox{[3]int32{math.MaxInt32, math.MaxInt32, math.MaxInt32}, [3]int32{math.MinInt32, math.MinInt32, math.MinInt32},
+		float32(math.Inf(1)), float32(math.Inf(-1))}
+}
+
+func (b *bbox) expand(o bbox) {
+	for a := 0; a < 3; a++ {
+		if o.min[a] < b.min[a] {
+			b.min[a] = o.min[a]
+		}
+		if o.max[a] > b.max[a] {
+			b.max[a] = o.max[a]
+		}
+	}
+	b.lo = float32(math.Min(float64(b.lo), float64(o.lo)))
+	b.hi = float32(math.Max(float64(b.hi), float64(o.hi)))
+}
+
+//node is an upper or lower internal node with the sorted keys of its children
+type node struct {
+	key      [3]int
+	offset   int
+	children [][3]int
+	box      bbox
+}
+
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) u16(at int, v uint16)  { binary.LittleEndian.PutUint16(w.buf[at:], v) }
+func (w *writer) u32(at int, v uint32)  { binary.LittleEndian.PutUint32(w.buf[at:], v) }
+func (w *writer) u64(at int, v uint64)  { binary.LittleEndian.PutUint64(w.buf[at:], v) }
+func (w *writer) f32(at int, v float32) { w.u32(at, math.Float32bits(v)) }
+func (w *writer) f64(at int, v float64) { w.u64(at, math.Float64bits(v)) }
+
+func (w *writer) box(at int, b bbox) {
+	for a := 0; a < 3; a++ {
+		w.u32(at+a*4, uint32(b.min[a]))
+		w.u32(at+12+a*4, uint32(b.max[a]))
+	}
+}
+
+//stats writes the minimum and maximum, leaving the average and deviation zero
+func (w *writer) stats(at int, b bbox) {
+	if b.lo <= b.hi {
+		w.f32(at, b.lo)
+		w.f32(at+4, b.hi)
+	}
+}
+
+func sortKeys(keys [][3]int) {
+	sort.Slice(keys, func(a int, b int) bool {
+		for c := 0; c < 3; c++ {
+			if keys[a][c] != keys[b][c] {
+				return keys[a][c] < keys[b][c]
+			}
+		}
+		return false
+	})
+}
+
+//group collects child keys under their parent key, the child key shifted right
+//by shift, returning the parents in sorted order
+func group(children [][3]int, shift uint) []*node {
+	parents := make(map[[3]int]*node)
+	for _, c := range children {
+		key := [3]int{c[0] >> shift, c[1] >> shift, c[2] >> shift}
+		p, ok := parents[key]
+		if !ok {
+			p = &node{key: key, box: emptyBox()}
+			parents[key] = p
+		}
+		p.children = append(p.children, c)
+	}
+	keys := make([][3]int, 0, len(parents))
+	for key := range parents {
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+	nodes := make([]*node, len(keys))
+	for i, key := range keys {
+		nodes[i] = parents[key]
+	}
+	return nodes
+}
+
+//NanoVDB returns the volume as a NanoVDB float grid buffer. Only single
+//component volumes are supported, vector fields must be split per component
+func NanoVDB(vol *Volume, name string, class int) ([]byte, error) {
+	if vol.Components != 1 {
+		return nil, fmt.Errorf("NanoVDB export supports scalar volumes, got %d components", vol.Components)
+	}
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	leaves := vol.Keys()
+	lowers := group(leaves, lowerLog2)
+	lowerKeys := make([][3]int, len(lowers))
+	for i, l := range lowers {
+		lowerKeys[i] = l.key
+	}
+	uppers := group(lowerKeys, upperLog2)
+
+	tree := NANOVDB_GRID_SIZE
+	root := tree + NANOVDB_TREE_SIZE
+	upperStart := root + NANOVDB_ROOT_SIZE + NANOVDB_TILE_SIZE*len(uppers)
+	lowerStart := upperStart + NANOVDB_UPPER_SIZE*len(uppers)
+	leafStart := lowerStart + NANOVDB_LOWER_SIZE*len(lowers)
+	size := leafStart + NANOVDB_LEAF_SIZE*len(leaves)
+	w := writer{make([]byte, size)}
+
+	//Leaves
+	leafBoxes := make(map[[3]int]bbox, len(leaves))
+	leafOffsets := make(map[[3]int]int, len(leaves))
+	voxels := uint64(0)
+	for l, key := range leaves {
+		at := leafStart + l*NANOVDB_LEAF_SIZE
+		b := vol.Blocks[key]
+		box := emptyBox()
+		for n := 0; n < BLOCK_VOXELS; n++ {
+			w.f32(at+96+n*4, b.Values[n])
+			if b.Mask[n>>6]&(1<<uint(n&63)) == 0 {
+				continue
+			}
+			voxels++
+			ijk := [3]int32{int32(b.Origin[0] + n>>6), int32(b.Origin[1] + (n>>3)&7), int32(b.Origin[2] + n&7)}
+			box.expand(bbox{ijk, ijk, b.Values[n], b.Values[n]})
+		}
+		for a := 0; a < 3; a++ {
+			w.u32(at+a*4, uint32(box.min[a]))
+			w.buf[at+12+a] = uint8(box.max[a] - box.min[a])
+		}
+		w.buf[at+15] = 2
+		for m, word := range b.Mask {
+			w.u64(at+16+m*8, word)
+		}
+		w.stats(at+80, box)
+		leafBoxes[key], leafOffsets[key] = box, at
+	}
+
+	//Internal nodes index their children x major with z varying fastest
+	internal := func(nodes []*node, start int, size int, log2 uint, mask int, header int, childBox func([3]int) (bbox, int)) {
+		for i, nd := range nodes {
+			nd.offset = start + i*size
+			masks := (1 << (3 * log2)) / 8
+			for _, c := range nd.children {
+				n := (c[0]&mask)<<(2*log2) | (c[1]&mask)<<log2 | c[2]&mask
+				box, offset := childBox(c)
+				nd.box.expand(box)
+				w.buf[nd.offset+32+masks+n>>3] |= 1 << uint(n&7)
+				w.u64(nd.offset+header+n*8, uint64(offset-nd.offset))
+			}
+			w.box(nd.offset, nd.box)
+			w.stats(nd.offset+32+2*masks, nd.box)
+		}
+	}
+	internal(lowers, lowerStart, NANOVDB_LOWER_SIZE, lowerLog2, 1<<lowerLog2-1, 1088, func(c [3]int) (bbox, int) {
+		return leafBoxes[c], leafOffsets[c]
+	})
+	lowerNodes := make(map[[3]int]*node, len(lowers))
+	for _, l := range lowers {
+		lowerNodes[l.key] = l
+	}
+	internal(uppers, upperStart, NANOVDB_UPPER_SIZE, upperLog2, 1<<upperLog2-1, 8256, func(c [3]int) (bbox, int) {
+		return lowerNodes[c].box, lowerNodes[c].offset
+	})
+
+	//Root and its tiles keyed by the upper node origin
+	rootBox := emptyBox()
+	for t, u := range uppers {
+		rootBox.expand(u.box)
+		at := root + NANOVDB_ROOT_SIZE + t*NANOVDB_TILE_SIZE
+		w.u64(at, rootKey(u.key))
+		w.u64(at+8, uint64(u.offset-root))
+	}
+	w.box(root, rootBox)
+	w.u32(root+24, uint32(len(uppers)))
+	w.stats(root+32, rootBox)
+
+	//Tree
+	counts := []int{len(leaves), len(lowers), len(uppers)}
+	starts := []int{leafStart, lowerStart, upperStart}
+	for level := 0; level < 3; level++ {
+		if counts[level] > 0 {
+			w.u64(tree+level*8, uint64(starts[level]-tree))
+		}
+		w.u32(tree+32+level*4, uint32(counts[level]))
+	}
+	w.u64(tree+24, uint64(root-tree))
+	w.u64(tree+56, voxels)
+
+	//Grid with the index to world map scaling by the voxel spacing
+	w.u64(0, NANOVDB_MAGIC)
+	w.u64(8, ^uint64(0))
+	w.u32(16, NANOVDB_VERSION)
+	w.u32(20, nanovdbFlags)
+	w.u32(28, 1)
+	w.u64(32, uint64(size))
+	copy(w.buf[40:40+maxNameLength], name)
+	const mapAt = 296
+	for a := 0; a < 3; a++ {
+		s := float64(vol.Spacing[a])
+		w.f32(mapAt+a*16, float32(s))
+		w.f32(mapAt+36+a*16, float32(1/s))
+		w.f32(mapAt+72+a*4, vol.Min[a])
+		w.f64(mapAt+88+a*32, s)
+		w.f64(mapAt+160+a*32, 1/s)
+		w.f64(mapAt+232+a*8, float64(vol.Min[a]))
+	}
+	w.f32(mapAt+84, 1)
+	w.f64(mapAt+256, 1)
+	world := worldBox(vol, rootBox)
+	for a := 0; a < 6; a++ {
+		w.f64(560+a*8, world[a])
+	}
+	for a := 0; a < 3; a++ {
+		w.f64(608+a*8, float64(vol.Spacing[a]))
+	}
+	w.u32(632, uint32(class))
+	w.u32(636, NANOVDB_TYPE_FLOAT)
+	return w.buf, nil
+}
+
+//worldBox returns the world bounds of the index box with the upper voxel edge
+//included as min x, y, z followed by max x, y, z
+func worldBox(vol *Volume, b bbox) [6]float64 {
+	box := [6]float64{}
+	if b.min[0] > b.max[0] {
+		return box
+	}
+	for a := 0; a < 3; a++ {
+		s, o := float64(vol.Spacing[a]), float64(vol.Min[a])
+		box[a] = float64(b.min[a])*s + o
+		box[3+a] = float64(b.max[a]+1)*s + o
+	}
+	return box
+}
+
+//rootKey packs the origin of an upper node into the 21 bit per axis root table key
+func rootKey(key [3]int) uint64 {
+	k := func(a int) uint64 {
+		return uint64(uint32(key[a]<<upperTotal) >> upperTotal)
+	}
+	return k(2) | k(1)<<21 | k(0)<<42
+}
+
+//nameKey is the NanoVDB string hash of a grid name
+func nameKey(name string) uint64 {
+	hash := uint64(0)
+	for i := 0; i < len(name); i++ {
+		overflow := hash >> (64 - 8)
+		hash *= 67
+		hash += uint64(name[i]) + overflow
+	}
+	return hash
+}
+
+//ExportNanoVDB writes the volume as a single uncompressed grid .nvdb file
+func ExportNanoVDB(filename string, vol *Volume, name string, class int) error {
+	grid, err := NanoVDB(vol, name, class)
+	if err != nil {
+		return err
+	}
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	w := writer{make([]byte, fileHeadSize+metaDataSize+len(name)+1)}
+	w.u64(0, NANOVDB_MAGIC)
+	w.u32(8, NANOVDB_VERSION)
+	w.u16(12, 1)
+
+	at := fileHeadSize
+	w.u64(at, uint64(len(grid)))
+	w.u64(at+8, uint64(len(grid)))
+	w.u64(at+16, nameKey(name))
+	w.u64(at+24, binary.LittleEndian.Uint64(grid[NANOVDB_GRID_SIZE+56:]))
+	w.u32(at+32, NANOVDB_TYPE_FLOAT)
+	w.u32(at+36, uint32(class))
+	copy(w.buf[at+40:at+88], grid[560:608])
+	copy(w.buf[at+88:at+112], grid[NANOVDB_GRID_SIZE+NANOVDB_TREE_SIZE:NANOVDB_GRID_SIZE+NANOVDB_TREE_SIZE+24])
+	copy(w.buf[at+112:at+136], grid[608:632])
+	w.u32(at+136, uint32(len(name)+1))
+	for level := 0; level < 3; level++ {
+		w.u32(at+140+level*4, binary.LittleEndian.Uint32(grid[NANOVDB_GRID_SIZE+32+level*4:]))
+	}
+	w.u32(at+152, 1)
+	w.u32(at+172, NANOVDB_VERSION)
+	copy(w.buf[at+metaDataSize:], name)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(w.buf); err != nil {
+		return err
+	}
+	_, err = file.Write(grid)
+	return err
+}
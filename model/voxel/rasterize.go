@@ -0,0 +1,122 @@
+package voxel
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/kernel"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Rasterized Field Enums
+const FIELD_DENSITY = 0
+const FIELD_VELOCITY = 1
+const FIELD_PRESSURE = 2
+const FIELD_TEMPERATURE = 3
+
+var fieldNames = []string{"density", "velocity", "pressure", "temperature"}
+
+//FieldName returns the export name of a rasterized field
+func FieldName(f int) string {
+	if f < 0 || f >= len(fieldNames) {
+		return "unknown"
+	}
+	return fieldNames[f]
+}
+
+//Components returns the number of values per voxel of a field
+func Components(f int) int {
+	if f == FIELD_VELOCITY {
+		return 3
+	}
+	return 1
+}
+
+//Rasterize resamples a fluid particle field onto the volume, which must have the
+//field's number of components. Every voxel within the kernel support of a fluid
+//particle becomes active. Density is the kernel sum of the particle masses
+//relative to the kernel integral so it fades to zero across the free surface,
+//other fields are Shepard normalized. Pressures are the ones stored by the last
+//solver step
+func Rasterize(core *sph.SPH, f int, vol *Volume) {
+	fld := core.Field()
+	parts := fld.Particles
+	kern := fld.Kernel()
+	h := kern.H0()
+
+	c := vol.Components
+	acc := New(vol.Min, vol.Spacing, vol.Dims, c+1)
+	value := make([]float32, c)
+	for p := 0; p < parts.N(); p++ {
+		particle := parts.Get(p)
+		if particle.Density <= 0 {
+			continue
+		}
+		switch f {
+		case FIELD_DENSITY:
+			value[0] = particle.Density
+		case FIELD_VELOCITY:
+			copy(value, particle.Velocity[:])
+		case FIELD_PRESSURE:
+			value[0] = particle.Press
+		case FIELD_TEMPERATURE:
+			value[0] = particle.Temperature
+		}
+		vj := parts.ParticleMass(p) / particle.Density
+		var lo, hi [3]int
+		for a := 0; a < 3; a++ {
+			lo[a] = int(math.Ceil(float64((particle.Position[a] - h - acc.Min[a]) / acc.Spacing[a])))
+			hi[a] = int(math.Floor(float64((particle.Position[a] + h - acc.Min[a]) / acc.Spacing[a])))
+			if lo[a] < 0 {
+				lo[a] = 0
+			}
+			if hi[a] > acc.Dims[a]-1 {
+				hi[a] = acc.Dims[a] - 1
+			}
+		}
+		for i := lo[0]; i <= hi[0]; i++ {
+			for j := lo[1]; j <= hi[1]; j++ {
+				for k := lo[2]; k <= hi[2]; k++ {
+					dist := vector.Mag(fld.Separation(acc.Position(i, j, k), particle.Position[:]))
+					if dist >= h {
+						continue
+					}
+					w := vj * kern.F(dist)
+					b := acc.Block(i, j, k, true)
+					n := Offset(i, j, k)
+					b.Mask[n>>6] |= 1 << uint(n&63)
+					for a := 0; a < c; a++ {
+						b.Values[n*(c+1)+a] += w * value[a]
+					}
+					b.Values[n*(c+1)+c] += w
+				}
+			}
+		}
+	}
+
+	norm := float32(1.0)
+	if f == FIELD_DENSITY {
+		norm = kernel.Integral(kern)
+	}
+	for key, b := range acc.Blocks {
+		for n := 0; n < BLOCK_VOXELS; n++ {
+			if b.Mask[n>>6]&(1<<uint(n&63)) == 0 {
+				continue
+			}
+			w := b.Values[n*(c+1)+c]
+			if f != FIELD_DENSITY {
+				norm = w
+			}
+			if norm <= 0 {
+				continue
+			}
+			i := key[0]<<BLOCK_LOG2 + n>>(2*BLOCK_LOG2)
+			j := key[1]<<BLOCK_LOG2 + (n>>BLOCK_LOG2)&(BLOCK-1)
+			k := key[2]<<BLOCK_LOG2 + n&(BLOCK-1)
+			for a := 0; a < c; a++ {
+				value[a] = b.Values[n*(c+1)+a] / norm
+			}
+			vol.Set(i, j, k, value)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+//Sparse voxel volumes of SPH fields. Particle fields are rasterized with the SPH
+//kernel onto a regular grid stored as 8^3 voxel blocks so that empty regions use
+//no memory. Volumes export as raw float arrays, VTK image data and NanoVDB grids
+package voxel
+
+import (
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/math/vector"
+)
+
+//Block edge length in voxels as a power of two, matching NanoVDB leaf nodes
+const BLOCK_LOG2 = 3
+const BLOCK = 1 << BLOCK_LOG2
+const BLOCK_VOXELS = BLOCK * BLOCK * BLOCK
+
+//Block holds the voxels of an aligned 8^3 region. Mask flags the active voxels
+//which have received a value, indexed as x major with z varying fastest
+type Block struct {
+	Origin [3]int
+	Values []float32
+	Mask   [BLOCK_VOXELS / 64]uint64
+}
+
+//Volume is a sparse voxel grid of Dims voxels with Components values per voxel.
+//Voxel [i,j,k] samples the position Min + Spacing * [i,j,k]
+type Volume struct {
+	Min        vector.Vec
+	Spacing    vector.Vec
+	Dims       [3]int
+	Components int
+	Blocks     map[[3]int]*Block
+}
+
+func New(min vector.Vec, spacing vector.Vec, dims [3]int, components int) *Volume {
+	return &Volume{vector.Vec{min[0], min[1], min[2]}, vector.Vec{spacing[0], spacing[1], spacing[2]}, dims, components, make(map[[3]int]*Block)}
+}
+
+//FromGrid creates a volume with a voxel at every grid element
+func FromGrid(g grid.Grid, components int) *Volume {
+	return New(g.Min(), g.Step(), g.Dims(), components)
+}
+
+//Position returns the sample position of voxel [i,j,k]
+func (v *Volume) Position(i int, j int, k int) vector.Vec {
+	return vector.Vec{v.Min[0] + v.Spacing[0]*float32(i), v.Min[1] + v.Spacing[1]*float32(j), v.Min[2] + v.Spacing[2]*float32(k)}
+}
+
+//Contains checks if [i,j,k] lies inside the volume dimensions
+func (v *Volume) Contains(i int, j int, k int) bool {
+	return i >= 0 && j >= 0 && k >= 0 && i < v.Dims[0] && j < v.Dims[1] && k < v.Dims[2]
+}
+
+func blockKey(i int, j int, k int) [3]int {
+	return [3]int{i >> BLOCK_LOG2, j >> BLOCK_LOG2, k >> BLOCK_LOG2}
+}
+
+//Offset returns the index of voxel [i,j,k] within its block
+func Offset(i int, j int, k int) int {
+	return (i&(BLOCK-1))<<(2*BLOCK_LOG2) | (j&(BLOCK-1))<<BLOCK_LOG2 | k&(BLOCK-1)
+}
+
+//Block returns the block containing [i,j,k], allocating it when create is set
+func (v *Volume) Block(i int, j int, k int, create bool) *Block {
+	key := blockKey(i, j, k)
+	b, ok := v.Blocks[key]
+	if !ok && create {
+		b = &Block{Origin: [3]int{key[0] << BLOCK_LOG2, key[1] << BLOCK_LOG2, key[2] << BLOCK_LOG2}}
+		b.Values = make([]float32, BLOCK_VOXELS*v.Components)
+		v.Blocks[key] = b
+	}
+	return b
+}
+
+//Value returns the voxel value, zero for inactive voxels
+func (v *Volume) Value(i int, j int, k int) []float32 {
+	value := make([]float32, v.Components)
+	if b := v.Block(i, j, k, false); b != nil {
+		n := Offset(i, j, k) * v.Components
+		copy(value, b.Values[n:n+v.Components])
+	}
+	return value
+}
+
+//Set stores and activates a voxel value, positions outside the volume are ignored
+func (v *Volume) Set(i int, j int, k int, value []float32) {
+	if !v.Contains(i, j, k) {
+		return
+	}
+	b := v.Block(i, j, k, true)
+	n := Offset(i, j, k)
+	copy(b.Values[n*v.Components:(n+1)*v.Components], value)
+	b.Mask[n>>6] |= 1 << uint(n&63)
+}
+
+//Active checks if a voxel holds a value
+func (v *Volume) Active(i int, j int, k int) bool {
+	b := v.Block(i, j, k, false)
+	if b == nil {
+		return false
+	}
+	n := Offset(i, j, k)
+	return b.Mask[n>>6]&(1<<uint(n&63)) != 0
+}
+
+//ActiveVoxels returns the number of voxels holding a value
+func (v *Volume) ActiveVoxels() int {
+	count := 0
+	for _, b := range v.Blocks {
+		for _, word := range b.Mask {
+			for ; word != 0; word &= word - 1 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+//Keys returns the block keys in x, y, z order
+func (v *Volume) Keys() [][3]int {
+	keys := make([][3]int, 0, len(v.Blocks))
+	for key := range v.Blocks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(a int, b int) bool {
+		for c := 0; c < 3; c++ {
+			if keys[a][c] != keys[b][c] {
+				return keys[a][c] < keys[b][c]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+//Dense returns all voxel values with x varying fastest and the components of a
+//voxel interleaved, the layout of raw volumes and VTK image data
+func (v *Volume) Dense() []float32 {
+	nx, ny, c := v.Dims[0], v.Dims[1], v.Components
+	dense := make([]float32, nx*ny*v.Dims[2]*c)
+	for _, b := range v.Blocks {
+		for n := 0; n < BLOCK_VOXELS; n++ {
+			if b.Mask[n>>6]&(1<<uint(n&63)) == 0 {
+				continue
+			}
+			i := b.Origin[0] + n>>(2*BLOCK_LOG2)
+			j := b.Origin[1] + (n>>BLOCK_LOG2)&(BLOCK-1)
+			k := b.Origin[2] + n&(BLOCK-1)
+			copy(dense[((k*ny+j)*nx+i)*c:], b.Values[n*c:(n+1)*c])
+		}
+	}
+	return dense
+}
+
+//Range returns the minimum and maximum active value of a component
+func (v *Volume) Range(component int) (float32, float32) {
+	min, max := float32(math.Inf(1)), float32(math.Inf(-1))
+	for _, b := range v.Blocks {
+		for n := 0; n < BLOCK_VOXELS; n++ {
+			if b.Mask[n>>6]&(1<<uint(n&63)) != 0 {
+				x := b.Values[n*v.Components+component]
+				min = float32(math.Min(float64(min), float64(x)))
+				max = float32(math.Max(float64(max), float64(x)))
+			}
+		}
+	}
+	return min, max
+}
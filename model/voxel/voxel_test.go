@@ -0,0 +1,131 @@
+package voxel
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+const dx = float32(0.05)
+const side = 8
+
+//A block of fluid moving along x
+func block() sph.SPH {
+	parts := model.NewLatticeArray(model.Lattice([3]float32{0.5 * dx, 0.5 * dx, 0.5 * dx}, [3]int{side, side, side}, dx), dx)
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		particle.Velocity = [3]float32{1, 0, 0}
+		parts.Set(i, particle)
+	}
+	core := sph.New(&parts, 2*dx)
+	core.ClearForceFields()
+	return core
+}
+
+//lookup walks a NanoVDB grid buffer from the root to the leaf holding [i,j,k]
+func lookup(grid []byte, i int, j int, k int) (float32, bool) {
+	u64 := func(at int) int { return int(int64(binary.LittleEndian.Uint64(grid[at:]))) }
+	bit := func(at int, n int) bool { return grid[at+n>>3]&(1<<uint(n&7)) != 0 }
+	root := NANOVDB_GRID_SIZE + u64(NANOVDB_GRID_SIZE+24)
+	tiles := int(binary.LittleEndian.Uint32(grid[root+24:]))
+	for t := 0; t < tiles; t++ {
+		tile := root + NANOVDB_ROOT_SIZE + t*NANOVDB_TILE_SIZE
+		if uint64(u64(tile)) != rootKey([3]int{i >> upperTotal, j >> upperTotal, k >> upperTotal}) {
+			continue
+		}
+		upper := root + u64(tile+8)
+		n := (i&4095)>>7<<10 | (j&4095)>>7<<5 | (k&4095)>>7
+		if !bit(upper+32+4096, n) {
+			return 0, false
+		}
+		lower := upper + u64(upper+8256+n*8)
+		n = (i&127)>>3<<8 | (j&127)>>3<<4 | (k&127)>>3
+		if !bit(lower+32+512, n) {
+			return 0, false
+		}
+		leaf := lower + u64(lower+1088+n*8)
+		n = Offset(i, j, k)
+		return math.Float32frombits(binary.LittleEndian.Uint32(grid[leaf+96+n*4:])), bit(leaf+16, n)
+	}
+	return 0, false
+}
+
+func TestRasterize(t *testing.T) {
+	core := block()
+	spacing := dx / 2
+	dims := [3]int{40, 40, 40}
+	min := vector.Vec{-0.5, -0.5, -0.5}
+
+	density := New(min, vector.Vec{spacing, spacing, spacing}, dims, 1)
+	Rasterize(&core, FIELD_DENSITY, density)
+	velocity := New(min, vector.Vec{spacing, spacing, spacing}, dims, 3)
+	Rasterize(&core, FIELD_VELOCITY, velocity)
+
+	//Center of the block lies at 0.2, voxel 28 along each axis
+	c := 28
+	if rho := density.Value(c, c, c)[0]; math.Abs(float64(rho-1000)) > 50 {
+		t.Errorf("Bulk density expected 1000 got %f\n", rho)
+	}
+	if v := velocity.Value(c, c, c); math.Abs(float64(v[0]-1)) > 1e-3 || math.Abs(float64(v[1])) > 1e-3 {
+		t.Errorf("Bulk velocity expected [1 0 0] got %v\n", v)
+	}
+	if density.Active(0, 0, 0) || density.Value(0, 0, 0)[0] != 0 {
+		t.Errorf("Voxel far from the fluid is active\n")
+	}
+	if len(density.Blocks) >= 125 || density.ActiveVoxels() == 0 {
+		t.Errorf("Sparse volume holds %d of 125 blocks\n", len(density.Blocks))
+	}
+
+	dir, err := ioutil.TempDir("", "voxel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	raw := filepath.Join(dir, "velocity.raw")
+	if err := ExportRaw(raw, velocity); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(raw); info == nil || info.Size() != 40*40*40*3*4 {
+		t.Errorf("Raw volume size %v\n", info)
+	}
+
+	vti := filepath.Join(dir, "density.vti")
+	if err := ExportVTI(vti, density, FieldName(FIELD_DENSITY)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(vti); !strings.Contains(string(data), "WholeExtent=\"0 39 0 39 0 39\"") {
+		t.Errorf("VTK image data header\n%s\n", data[:200])
+	}
+
+	if _, err := NanoVDB(velocity, "velocity", NANOVDB_CLASS_UNKNOWN); err == nil {
+		t.Errorf("NanoVDB accepted a vector volume\n")
+	}
+	nvdb := filepath.Join(dir, "density.nvdb")
+	if err := ExportNanoVDB(nvdb, density, "density", NANOVDB_CLASS_FOG_VOLUME); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(nvdb)
+	grid := data[fileHeadSize+metaDataSize+len("density")+1:]
+	if binary.LittleEndian.Uint64(data) != NANOVDB_MAGIC || binary.LittleEndian.Uint64(grid) != NANOVDB_MAGIC ||
+		binary.LittleEndian.Uint64(grid[32:]) != uint64(len(grid)) {
+		t.Fatalf("NanoVDB headers\n")
+	}
+	if voxels := binary.LittleEndian.Uint64(grid[NANOVDB_GRID_SIZE+56:]); voxels != uint64(density.ActiveVoxels()) {
+		t.Errorf("NanoVDB voxel count %d expected %d\n", voxels, density.ActiveVoxels())
+	}
+	for _, ijk := range [][3]int{{c, c, c}, {20, 21, 22}, {0, 0, 0}} {
+		value, active := lookup(grid, ijk[0], ijk[1], ijk[2])
+		if value != density.Value(ijk[0], ijk[1], ijk[2])[0] || active != density.Active(ijk[0], ijk[1], ijk[2]) {
+			t.Errorf("NanoVDB voxel %v holds %f active %v\n", ijk, value, active)
+		}
+	}
+}
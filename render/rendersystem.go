@@ -235,11 +235,11 @@ func (r *RenderSystem) RegisterMesh(meshIndex int, primitiveIndex int, scn *scen
 	meshComp.TransformComponent.Model = matrix.Mat4(1.0)
 	meshComp.TransformComponent.Position = vector.Vec3()
 
-	if primitive.Indices == 0 {
-		return nil, fmt.Errorf("RegisterMesh() - GLTF Format Error: No primitive indices reference. Indices index is 0. GLTF file may not use a indices accessor reference of zero\n")
+	if primitive.Indices == nil {
+		return nil, fmt.Errorf("RegisterMesh() - GLTF Format Error: No primitive indices reference\n")
 	}
 
-	indicesAccessorIdx := primitive.Indices
+	indicesAccessorIdx := *primitive.Indices
 	_, IdxBufferView, _ := scn.GetAccessorBufferView(indicesAccessorIdx)
 	meshComp.IndiceComponent = new(defs.IndiceComponent)
 	meshComp.IndiceComponent.Indices = scn.Buffers[IdxBufferView.Buffer]
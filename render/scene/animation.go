@@ -0,0 +1,167 @@
+package scene
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/andewx/dieselfluid/gltf"
+)
+
+//Accessor Component Types
+const (
+	COMPONENT_BYTE           = 5120
+	COMPONENT_UNSIGNED_BYTE  = 5121
+	COMPONENT_SHORT          = 5122
+	COMPONENT_UNSIGNED_SHORT = 5123
+	COMPONENT_UNSIGNED_INT   = 5125
+	COMPONENT_FLOAT          = 5126
+)
+
+//Components returns the number of components of an accessor type
+func Components(accessorType string) int {
+	switch accessorType {
+	case "SCALAR":
+		return 1
+	case "VEC2":
+		return 2
+	case "VEC3":
+		return 3
+	case "VEC4":
+		return 4
+	case "MAT4":
+		return 16
+	}
+	return 0
+}
+
+//AccessorFloats decodes an accessor into floats. Normalized integer components
+//are mapped to [0,1] or [-1,1] as used by quantized rotation keyframes
+func (scene *Scene) AccessorFloats(accessor_index int) ([]float32, int, error) {
+	acc, view, err := scene.GetAccessorBufferView(accessor_index)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := scene.GetBufferDataIx(acc.BufferView)
+	if err != nil {
+		return nil, 0, err
+	}
+	comps := Components(acc.Type)
+	size := 4
+	switch acc.ComponentType {
+	case COMPONENT_BYTE, COMPONENT_UNSIGNED_BYTE:
+		size = 1
+	case COMPONENT_SHORT, COMPONENT_UNSIGNED_SHORT:
+		size = 2
+	case COMPONENT_FLOAT:
+		size = 4
+	default:
+		return nil, 0, fmt.Errorf("Unsupported accessor component type %d", acc.ComponentType)
+	}
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = comps * size
+	}
+	base := view.ByteOffset + acc.ByteOffset
+	if base+(acc.Count-1)*stride+comps*size > len(data) {
+		return nil, 0, fmt.Errorf("Accessor %d exceeds its buffer", accessor_index)
+	}
+
+	values := make([]float32, acc.Count*comps)
+	for i := 0; i < acc.Count; i++ {
+		for c := 0; c < comps; c++ {
+			at := base + i*stride + c*size
+			var v float32
+			switch acc.ComponentType {
+			case COMPONENT_FLOAT:
+				v = math.Float32frombits(binary.LittleEndian.Uint32(data[at:]))
+			case COMPONENT_BYTE:
+				v = float32(math.Max(float64(int8(data[at]))/127.0, -1))
+			case COMPONENT_UNSIGNED_BYTE:
+				v = float32(data[at]) / 255.0
+			case COMPONENT_SHORT:
+				v = float32(math.Max(float64(int16(binary.LittleEndian.Uint16(data[at:])))/32767.0, -1))
+			case COMPONENT_UNSIGNED_SHORT:
+				v = float32(binary.LittleEndian.Uint16(data[at:])) / 65535.0
+			}
+			values[i*comps+c] = v
+		}
+	}
+	return values, comps, nil
+}
+
+//GetAnimations returns the list of animations in the scene
+func (scene *Scene) GetAnimations() []*gltf.Animation {
+	return scene.Root.Animations
+}
+
+//ParentNode returns the index of the node listing index as a child or -1 for
+//root nodes
+func (scene *Scene) ParentNode(index int) int {
+	for i, node := range scene.GetNodes() {
+		for _, child := range node.Children {
+			if child == index {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+//NodeAnimation collects the translation, rotation and scale channels of an
+//animation targeting node into an evaluable node transform. The transforms of
+//the parent nodes, animated by the same animation, are linked so the node
+//animation evaluates to the world transform
+func (scene *Scene) NodeAnimation(animation_index int, node_index int) (*gltf.NodeAnimation, error) {
+	nodeAnim, err := scene.localAnimation(animation_index, node_index)
+	if err != nil {
+		return nil, err
+	}
+	visited := map[int]bool{node_index: true}
+	child := nodeAnim
+	for parent := scene.ParentNode(node_index); parent >= 0; parent = scene.ParentNode(parent) {
+		if visited[parent] {
+			return nil, fmt.Errorf("Node hierarchy cycle through node %d", parent)
+		}
+		visited[parent] = true
+		if child.Parent, err = scene.localAnimation(animation_index, parent); err != nil {
+			return nil, err
+		}
+		child = child.Parent
+	}
+	return nodeAnim, nil
+}
+
+//localAnimation collects the channels of an animation targeting node
+func (scene *Scene) localAnimation(animation_index int, node_index int) (*gltf.NodeAnimation, error) {
+	anims := scene.GetAnimations()
+	if animation_index < 0 || animation_index >= len(anims) {
+		return nil, fmt.Errorf("Invalid animation index %d", animation_index)
+	}
+	node, err := scene.GetNodeIx(node_index)
+	if err != nil {
+		return nil, err
+	}
+	anim := anims[animation_index]
+	nodeAnim := gltf.NewNodeAnimation(node)
+	for _, channel := range anim.Channels {
+		if channel.TargetNode() != node_index {
+			continue
+		}
+		s := channel.SamplerIndex()
+		if s < 0 || s >= len(anim.Samplers) {
+			return nil, fmt.Errorf("Invalid animation sampler index %d", s)
+		}
+		sampler := anim.Samplers[s]
+		input, _, err := scene.AccessorFloats(sampler.InputIndex())
+		if err != nil {
+			return nil, err
+		}
+		output, comps, err := scene.AccessorFloats(sampler.OutputIndex())
+		if err != nil {
+			return nil, err
+		}
+		nodeAnim.Set(channel.TargetPath(), &gltf.Keyframes{Input: input, Output: output, Components: comps, Interpolation: sampler.Mode()})
+	}
+	return nodeAnim, nil
+}
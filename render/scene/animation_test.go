@@ -0,0 +1,114 @@
+package scene
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/gltf"
+)
+
+//animated builds a two node hierarchy in memory, the child translating from the
+//origin to y = 2 over one second under a root translated to x = 1. Nodes 2 and 3
+//list each other as children
+func animated() *Scene {
+	data := make([]byte, 43)
+	for i, v := range []float32{0, 1, 0, 0, 0, 0, 2, 0} {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	//Normalized unsigned byte pairs padded to a stride of 4 and signed bytes
+	copy(data[32:], []byte{255, 0, 9, 9, 51, 255, 9, 9})
+	copy(data[40:], []byte{0x80, 127, 0})
+	return &Scene{Buffers: [][]byte{data}, Root: &gltf.GlTF{
+		Buffers: []*gltf.Buffer{{ByteLength: len(data)}},
+		BufferViews: []*gltf.BufferView{
+			{Buffer: 0, ByteLength: 32},
+			{Buffer: 0, ByteOffset: 32, ByteLength: 8, ByteStride: 4},
+			{Buffer: 0, ByteOffset: 40, ByteLength: 3},
+		},
+		Accessors: []*gltf.Accessor{
+			{BufferView: 0, ComponentType: COMPONENT_FLOAT, Count: 2, Type: "SCALAR"},
+			{BufferView: 0, ByteOffset: 8, ComponentType: COMPONENT_FLOAT, Count: 2, Type: "VEC3"},
+			{BufferView: 1, ComponentType: COMPONENT_UNSIGNED_BYTE, Count: 2, Type: "VEC2", Normalized: true},
+			{BufferView: 2, ComponentType: COMPONENT_BYTE, Count: 3, Type: "SCALAR", Normalized: true},
+			{BufferView: 0, ComponentType: COMPONENT_UNSIGNED_INT, Count: 2, Type: "SCALAR"},
+			{BufferView: 0, ComponentType: COMPONENT_FLOAT, Count: 11, Type: "SCALAR"},
+		},
+		Nodes: []*gltf.Node{
+			{Children: []int{1}, Translation: []float32{1, 0, 0}},
+			{},
+			{Children: []int{3}},
+			{Children: []int{2}},
+		},
+		Animations: []*gltf.Animation{{
+			Samplers: []*gltf.AnimationSampler{{Input: 0, Output: 1}},
+			Channels: []*gltf.AnimationChannel{
+				{Sampler: 0, Target: &gltf.AnimationChannelTarget{Node: 1, Path: gltf.PATH_TRANSLATION}},
+				{Sampler: 0, Target: &gltf.AnimationChannelTarget{Node: 2, Path: gltf.PATH_TRANSLATION}},
+			},
+		}},
+	}}
+}
+
+func TestAccessorFloats(t *testing.T) {
+	scn := animated()
+	values, comps, err := scn.AccessorFloats(1)
+	if err != nil || comps != 3 || len(values) != 6 || values[4] != 2 {
+		t.Fatalf("Float accessor %v components %d error %v\n", values, comps, err)
+	}
+	//Strided pairs skip the padding bytes
+	values, comps, err = scn.AccessorFloats(2)
+	expect := []float32{1, 0, 0.2, 1}
+	if err != nil || comps != 2 || len(values) != len(expect) {
+		t.Fatalf("Unsigned byte accessor %v components %d error %v\n", values, comps, err)
+	}
+	for i := range expect {
+		if math.Abs(float64(values[i]-expect[i])) > 1e-6 {
+			t.Errorf("Unsigned byte component %d expected %f got %f\n", i, expect[i], values[i])
+		}
+	}
+	//-128 clamps to -1
+	values, _, err = scn.AccessorFloats(3)
+	if err != nil || values[0] != -1 || values[1] != 1 || values[2] != 0 {
+		t.Errorf("Signed byte accessor %v error %v\n", values, err)
+	}
+	if _, _, err := scn.AccessorFloats(4); err == nil {
+		t.Errorf("Unsigned int accessor decoded\n")
+	}
+	if _, _, err := scn.AccessorFloats(5); err == nil {
+		t.Errorf("Accessor exceeding its buffer decoded\n")
+	}
+	if _, _, err := scn.AccessorFloats(6); err == nil {
+		t.Errorf("Missing accessor decoded\n")
+	}
+}
+
+func TestNodeAnimation(t *testing.T) {
+	scn := animated()
+	if scn.ParentNode(1) != 0 || scn.ParentNode(0) != -1 || scn.ParentNode(2) != 3 {
+		t.Errorf("Parents %d %d %d\n", scn.ParentNode(1), scn.ParentNode(0), scn.ParentNode(2))
+	}
+
+	anim, err := scn.NodeAnimation(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.Translation == nil || anim.Parent == nil || anim.Parent.Translation != nil || anim.Duration() != 1 {
+		t.Fatalf("Node animation %+v parent %+v\n", anim, anim.Parent)
+	}
+	//Row major world transform with the root offset along x
+	m := anim.Transform(0.5)
+	if math.Abs(float64(m[3]-1)) > 1e-6 || math.Abs(float64(m[7]-1)) > 1e-6 || m[11] != 0 {
+		t.Errorf("World transform at 0.5 %v\n", m)
+	}
+
+	if _, err := scn.NodeAnimation(0, 2); err == nil {
+		t.Errorf("Node hierarchy cycle accepted\n")
+	}
+	if _, err := scn.NodeAnimation(1, 1); err == nil {
+		t.Errorf("Missing animation accepted\n")
+	}
+	if _, err := scn.NodeAnimation(0, 9); err == nil {
+		t.Errorf("Missing node accepted\n")
+	}
+}
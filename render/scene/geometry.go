@@ -0,0 +1,128 @@
+package scene
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+//Primitive Topology Modes
+const (
+	MODE_POINTS         = 0
+	MODE_TRIANGLES      = 4
+	MODE_TRIANGLE_STRIP = 5
+	MODE_TRIANGLE_FAN   = 6
+)
+
+//AccessorIndices decodes an unsigned integer scalar accessor into indices
+func (scene *Scene) AccessorIndices(accessor_index int) ([]int, error) {
+	acc, view, err := scene.GetAccessorBufferView(accessor_index)
+	if err != nil {
+		return nil, err
+	}
+	if Components(acc.Type) != 1 {
+		return nil, fmt.Errorf("Index accessor %d is not a scalar", accessor_index)
+	}
+	data, err := scene.GetBufferDataIx(acc.BufferView)
+	if err != nil {
+		return nil, err
+	}
+	size := 0
+	switch acc.ComponentType {
+	case COMPONENT_UNSIGNED_BYTE:
+		size = 1
+	case COMPONENT_UNSIGNED_SHORT:
+		size = 2
+	case COMPONENT_UNSIGNED_INT:
+		size = 4
+	default:
+		return nil, fmt.Errorf("Unsupported index component type %d", acc.ComponentType)
+	}
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = size
+	}
+	base := view.ByteOffset + acc.ByteOffset
+	if acc.Count > 0 && base+(acc.Count-1)*stride+size > len(data) {
+		return nil, fmt.Errorf("Accessor %d exceeds its buffer", accessor_index)
+	}
+	indices := make([]int, acc.Count)
+	for i := range indices {
+		at := base + i*stride
+		switch size {
+		case 1:
+			indices[i] = int(data[at])
+		case 2:
+			indices[i] = int(binary.LittleEndian.Uint16(data[at:]))
+		case 4:
+			indices[i] = int(binary.LittleEndian.Uint32(data[at:]))
+		}
+	}
+	return indices, nil
+}
+
+//PrimitiveTriangles returns the positions and triangle list indices of a mesh
+//primitive. Strips and fans are unrolled into triangle lists. A primitive
+//without indices is read as unindexed and one without a mode as triangles
+func (scene *Scene) PrimitiveTriangles(mesh_index int, primitive_index int) ([]float32, []int, error) {
+	prims, err := scene.GetMeshPrimitives(mesh_index)
+	if err != nil {
+		return nil, nil, err
+	}
+	if primitive_index < 0 || primitive_index >= len(prims) {
+		return nil, nil, fmt.Errorf("Invalid primitive index %d", primitive_index)
+	}
+	prim := prims[primitive_index]
+	position, ok := prim.Attributes["POSITION"]
+	if !ok {
+		return nil, nil, fmt.Errorf("Primitive %d has no POSITION attribute", primitive_index)
+	}
+	positions, comps, err := scene.AccessorFloats(position)
+	if err != nil {
+		return nil, nil, err
+	}
+	if comps != 3 {
+		return nil, nil, fmt.Errorf("POSITION accessor %d is not a VEC3", position)
+	}
+
+	var vertices []int
+	if prim.Indices != nil {
+		if vertices, err = scene.AccessorIndices(*prim.Indices); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		vertices = make([]int, len(positions)/3)
+		for i := range vertices {
+			vertices[i] = i
+		}
+	}
+	for _, v := range vertices {
+		if v >= len(positions)/3 {
+			return nil, nil, fmt.Errorf("Primitive %d index %d out of range", primitive_index, v)
+		}
+	}
+
+	mode := MODE_TRIANGLES
+	if prim.Mode != nil {
+		mode = *prim.Mode
+	}
+	indices := []int{}
+	switch mode {
+	case MODE_TRIANGLES:
+		indices = vertices[:len(vertices)/3*3]
+	case MODE_TRIANGLE_STRIP:
+		for i := 2; i < len(vertices); i++ {
+			if i%2 == 0 {
+				indices = append(indices, vertices[i-2], vertices[i-1], vertices[i])
+			} else {
+				indices = append(indices, vertices[i-1], vertices[i-2], vertices[i])
+			}
+		}
+	case MODE_TRIANGLE_FAN:
+		for i := 2; i < len(vertices); i++ {
+			indices = append(indices, vertices[0], vertices[i-1], vertices[i])
+		}
+	default:
+		return nil, nil, fmt.Errorf("Primitive %d mode %d is not a triangle mode", primitive_index, mode)
+	}
+	return positions, indices, nil
+}
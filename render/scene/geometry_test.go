@@ -0,0 +1,79 @@
+package scene
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/andewx/dieselfluid/gltf"
+)
+
+//quad holds two triangles with unsigned byte indices in accessor 0 and the
+//four corner positions in accessor 1
+func quad(primitive string) (*Scene, error) {
+	data := make([]byte, 8+48)
+	copy(data, []byte{0, 1, 2, 2, 1, 3})
+	for i, v := range []float32{0, 0, 0, 1, 0, 0, 0, 1, 0, 1, 1, 0} {
+		binary.LittleEndian.PutUint32(data[8+i*4:], math.Float32bits(v))
+	}
+	prim := gltf.MeshPrimitive{}
+	if err := json.Unmarshal([]byte(primitive), &prim); err != nil {
+		return nil, err
+	}
+	return &Scene{Buffers: [][]byte{data}, Root: &gltf.GlTF{
+		Buffers:     []*gltf.Buffer{{ByteLength: len(data)}},
+		BufferViews: []*gltf.BufferView{{Buffer: 0, ByteLength: 6}, {Buffer: 0, ByteOffset: 8, ByteLength: 48}},
+		Accessors: []*gltf.Accessor{
+			{BufferView: 0, ComponentType: COMPONENT_UNSIGNED_BYTE, Count: 6, Type: "SCALAR"},
+			{BufferView: 1, ComponentType: COMPONENT_FLOAT, Count: 4, Type: "VEC3"},
+		},
+		Meshes: []*gltf.Mesh{{Primitives: []*gltf.MeshPrimitive{&prim}}},
+	}}, nil
+}
+
+func TestPrimitiveTriangles(t *testing.T) {
+	//Index accessor 0 is used rather than read as absent
+	scn, err := quad(`{"attributes": {"POSITION": 1}, "indices": 0}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions, indices, err := scn.PrimitiveTriangles(0, 0)
+	if err != nil || len(positions) != 12 || len(indices) != 6 || indices[3] != 2 || indices[5] != 3 {
+		t.Errorf("Indexed quad %v error %v\n", indices, err)
+	}
+
+	//Without indices the positions are read as a triangle list
+	scn, _ = quad(`{"attributes": {"POSITION": 1}}`)
+	if _, indices, err := scn.PrimitiveTriangles(0, 0); err != nil || len(indices) != 3 || indices[2] != 2 {
+		t.Errorf("Unindexed quad %v error %v\n", indices, err)
+	}
+
+	//An explicit points mode is not a triangle mode
+	scn, _ = quad(`{"attributes": {"POSITION": 1}, "indices": 0, "mode": 0}`)
+	if _, _, err := scn.PrimitiveTriangles(0, 0); err == nil {
+		t.Errorf("Point primitive read as triangles\n")
+	}
+	scn, _ = quad(`{"attributes": {"POSITION": 1}, "indices": 0, "mode": 5}`)
+	if _, indices, err := scn.PrimitiveTriangles(0, 0); err != nil || len(indices) != 12 {
+		t.Errorf("Strip quad %v error %v\n", indices, err)
+	}
+
+	//Absent fields are not written back
+	prim := scn.Root.Meshes[0].Primitives[0]
+	prim.Indices, prim.Mode = nil, nil
+	out, err := json.Marshal(prim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(out, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["indices"]; ok {
+		t.Errorf("Absent indices written %s\n", out)
+	}
+	if _, ok := fields["mode"]; ok {
+		t.Errorf("Absent mode written %s\n", out)
+	}
+}
@@ -109,7 +109,7 @@ func TestGLTFImport(t *testing.T) {
 				mat := prims[j].Material
 				attr := prims[j].Attributes
 				if i == 0 {
-					if indices != 3 {
+					if indices == nil || *indices != 3 {
 						t.Errorf("Cube mesh indices buffer not found\n")
 					}
 					if mat != 0 {
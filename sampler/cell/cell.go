@@ -0,0 +1,99 @@
+//Uniform cell list neighbor sampler. Particles are binned into cubic cells of the
+//kernel support so that every neighbor within the support lies in the 27 cells
+//around a particle. Periodic domain axes wrap the cell indices
+package cell
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/model"
+)
+
+type CellSampler struct {
+	Cells     map[[3]int][]int
+	Size      [3]float32 //Cell edge length per axis
+	Dims      [3]int     //Cell count per periodic axis
+	domain    *grid.Domain
+	particles *model.ParticleArray
+}
+
+//New creates a cell sampler with cells of edge length h. Domain may be nil for an
+//unbounded non periodic search
+func New(particles *model.ParticleArray, h float32, domain *grid.Domain) *CellSampler {
+	s := CellSampler{}
+	s.Cells = make(map[[3]int][]int, particles.Total()/4+1)
+	s.particles = particles
+	s.domain = domain
+	for k := 0; k < 3; k++ {
+		s.Size[k] = h
+		if domain != nil && domain.Periodic[k] {
+			l := domain.Max[k] - domain.Min[k]
+			s.Dims[k] = int(math.Floor(float64(l / h)))
+			if s.Dims[k] < 1 {
+				s.Dims[k] = 1
+			}
+			s.Size[k] = l / float32(s.Dims[k])
+		}
+	}
+	s.UpdateSampler()
+	return &s
+}
+
+//Cell returns the wrapped cell coordinate of a position
+func (s *CellSampler) Cell(pos []float32) [3]int {
+	c := [3]int{}
+	for k := 0; k < 3; k++ {
+		origin := float32(0.0)
+		if s.domain != nil {
+			origin = s.domain.Min[k]
+		}
+		c[k] = int(math.Floor(float64((pos[k] - origin) / s.Size[k])))
+	}
+	return s.wrap(c)
+}
+
+func (s *CellSampler) wrap(c [3]int) [3]int {
+	for k := 0; k < 3; k++ {
+		if s.Dims[k] > 0 {
+			c[k] = ((c[k] % s.Dims[k]) + s.Dims[k]) % s.Dims[k]
+		}
+	}
+	return c
+}
+
+//UpdateSampler rebins all particles including boundary particles
+func (s *CellSampler) UpdateSampler() {
+	for key := range s.Cells {
+		delete(s.Cells, key)
+	}
+	for i := 0; i < s.particles.Total(); i++ {
+		c := s.Cell(s.particles.Position(i))
+		s.Cells[c] = append(s.Cells[c], i)
+	}
+}
+
+//GetSamples returns the particles in the 27 cells around particle x, including x
+func (s *CellSampler) GetSamples(x int) []int {
+	return s.GetSamplesFromPosition(s.particles.Position(x))
+}
+
+//GetSamplesFromPosition returns the particles in the 27 cells around pos
+func (s *CellSampler) GetSamplesFromPosition(pos []float32) []int {
+	center := s.Cell(pos)
+	samples := make([]int, 0, 64)
+	visited := make(map[[3]int]bool, 27)
+	for i := -1; i <= 1; i++ {
+		for j := -1; j <= 1; j++ {
+			for k := -1; k <= 1; k++ {
+				c := s.wrap([3]int{center[0] + i, center[1] + j, center[2] + k})
+				if visited[c] {
+					continue
+				}
+				visited[c] = true
+				samples = append(samples, s.Cells[c]...)
+			}
+		}
+	}
+	return samples
+}
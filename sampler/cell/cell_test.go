@@ -0,0 +1,50 @@
+package cell
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+)
+
+func TestPeriodicNeighbors(t *testing.T) {
+	n := 400
+	h := float32(0.3)
+	parts := model.NewParticleArray(n, 0, h, 1.0, 1.0)
+	r := rand.New(rand.NewSource(7))
+	pos := parts.Positions()
+	for i := range pos {
+		pos[i] = r.Float32()*2 - 1
+	}
+	domain := grid.NewDomain(vector.Vec{-1, -1, -1}, vector.Vec{1, 1, 1})
+	domain.SetPeriodic(true, false, true)
+	sampler := New(&parts, h, domain)
+
+	for i := 0; i < n; i++ {
+		found := make(map[int]bool)
+		for _, j := range sampler.GetSamples(i) {
+			found[j] = true
+		}
+		for j := 0; j < n; j++ {
+			if vector.Mag(domain.Separation(parts.Position(i), parts.Position(j))) < h && !found[j] {
+				t.Fatalf("Particle %d missing neighbor %d within the kernel support\n", i, j)
+			}
+		}
+	}
+
+	//Wrapped neighbors across the x axis
+	pos[0], pos[1], pos[2] = 0.95, 0, 0
+	pos[3], pos[4], pos[5] = -0.95, 0, 0
+	sampler.UpdateSampler()
+	wrapped := false
+	for _, j := range sampler.GetSamples(0) {
+		if j == 1 {
+			wrapped = true
+		}
+	}
+	if !wrapped {
+		t.Errorf("Periodic neighbor across the domain wrap not sampled\n")
+	}
+}
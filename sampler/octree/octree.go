@@ -0,0 +1,156 @@
+//Adaptive octree neighbor sampler. Particles are held in a geom.OctalTree whose
+//leaves split with the local particle count, so highly non uniform distributions
+//such as splashes over a resting pool keep short candidate lists where a uniform
+//cell list would either waste memory or overfill its cells
+package octree
+
+import (
+	"sort"
+
+	"github.com/andewx/dieselfluid/geom"
+	"github.com/andewx/dieselfluid/model"
+)
+
+//Sampler Thread Messages
+const THREAD_RUN_SAMPLER = 60
+const THREAD_STOP_SAMPLER = 61
+
+type OctreeSampler struct {
+	Tree      *geom.OctalTree
+	Radius    float32 //Neighbor query radius, the kernel support
+	particles *model.ParticleArray
+}
+
+//New creates an octree sampler returning the particles within h, leaves holding
+//up to leafSize particles
+func New(particles *model.ParticleArray, h float32, leafSize int) *OctreeSampler {
+	s := OctreeSampler{}
+	s.Tree = geom.NewOctalTree([3]float32{0, 0, 0}, [3]float32{1, 1, 1}, geom.MORTON_MAX_DEPTH, leafSize)
+	s.Radius = h
+	s.particles = particles
+	s.UpdateSampler()
+	return &s
+}
+
+//UpdateSampler rebuilds the tree when the particle count changed and otherwise
+//moves the particles incrementally, including boundary particles
+func (s *OctreeSampler) UpdateSampler() {
+	if s.Tree.Len() != s.particles.Total() {
+		s.Tree.Build(s.particles.Positions()[:s.particles.Total()*3])
+		return
+	}
+	for i := 0; i < s.particles.Total(); i++ {
+		s.Tree.UpdatePoint(s.particles.Position(i), i)
+	}
+}
+
+//Run updates the sampler on THREAD_RUN_SAMPLER messages until stopped or closed
+func (s *OctreeSampler) Run(status chan int) {
+	for st := range status {
+		if st == THREAD_STOP_SAMPLER {
+			return
+		}
+		if st == THREAD_RUN_SAMPLER {
+			s.UpdateSampler()
+		}
+	}
+}
+
+//Hash returns the Morton key of the leaf containing pos
+func (s *OctreeSampler) Hash(pos [3]float32) int {
+	return int(s.Tree.Leaf(pos[:]))
+}
+
+//GetSamples returns the particles within the radius of particle x, including x
+func (s *OctreeSampler) GetSamples(x int) []int {
+	return s.Tree.Radius(s.particles.Position(x), s.Radius)
+}
+
+//GetSamplesFromPosition returns the particles within the radius of pos
+func (s *OctreeSampler) GetSamplesFromPosition(pos []float32) []int {
+	return s.Tree.Radius(pos, s.Radius)
+}
+
+//GetRegionalSamples returns the particles in the cell width levels above the
+//leaf key hash together with its adjacent leaves. Widths reaching above the
+//root return every particle in the tree
+func (s *OctreeSampler) GetRegionalSamples(hash int, width int) []int {
+	k := geom.Morton(hash)
+	depth := k.Depth() - width
+	if depth < 0 {
+		depth = 0
+	}
+	return s.Tree.GetNeighbors(k.Ancestor(depth))
+}
+
+func (s *OctreeSampler) leaves() []geom.Morton {
+	keys := make([]geom.Morton, 0, len(s.Tree.Map))
+	for k := range s.Tree.Map {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a int, b int) bool { return keys[a] < keys[b] })
+	return keys
+}
+
+//GetData returns the particle lists of the leaves in Morton order
+func (s *OctreeSampler) GetData() [][]int {
+	keys := s.leaves()
+	data := make([][]int, len(keys))
+	for i, k := range keys {
+		data[i] = s.Tree.Map[k]
+	}
+	return data
+}
+
+//GetData1D returns the leaf lists padded with -1 to BucketSize entries each
+func (s *OctreeSampler) GetData1D() []int {
+	size := s.BucketSize()
+	data := s.GetData()
+	flat := make([]int, len(data)*size)
+	for i, ids := range data {
+		for j := 0; j < size; j++ {
+			flat[i*size+j] = -1
+			if j < len(ids) {
+				flat[i*size+j] = ids[j]
+			}
+		}
+	}
+	return flat
+}
+
+//GetElements returns the number of sampled particles
+func (s *OctreeSampler) GetElements() int {
+	return s.Tree.Len()
+}
+
+//GetVectors returns the leaf cell centroids in Morton order
+func (s *OctreeSampler) GetVectors() []float32 {
+	keys := s.leaves()
+	vectors := make([]float32, 0, len(keys)*3)
+	for _, k := range keys {
+		c := s.Tree.GetCentroid(k)
+		vectors = append(vectors, c[:]...)
+	}
+	return vectors
+}
+
+//GetHashSize returns the number of bits of the leaf keys
+func (s *OctreeSampler) GetHashSize() int {
+	return 3*s.Tree.MaxDepth + 1
+}
+
+//GetBuckets returns the number of leaves
+func (s *OctreeSampler) GetBuckets() int {
+	return len(s.Tree.Map)
+}
+
+//BucketSize returns the largest leaf particle count
+func (s *OctreeSampler) BucketSize() int {
+	size := 0
+	for _, ids := range s.Tree.Map {
+		if len(ids) > size {
+			size = len(ids)
+		}
+	}
+	return size
+}
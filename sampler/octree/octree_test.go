@@ -0,0 +1,95 @@
+package octree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+	"github.com/andewx/dieselfluid/sampler"
+)
+
+var _ sampler.Sampler = (*OctreeSampler)(nil)
+
+func TestOctreeSampler(t *testing.T) {
+	n := 600
+	h := float32(0.1)
+	parts := model.NewParticleArray(n, 0, h, 1000, 0.01)
+	r := rand.New(rand.NewSource(11))
+	pos := parts.Positions()
+	for i := 0; i < n; i++ {
+		//A dense droplet above a sparse spray
+		scale, offset := float32(0.3), float32(0)
+		if i%3 == 0 {
+			scale, offset = 4, -2
+		}
+		for a := 0; a < 3; a++ {
+			pos[i*3+a] = r.Float32()*scale + offset
+		}
+	}
+	s := New(&parts, h, 8)
+
+	check := func() {
+		for i := 0; i < n; i++ {
+			found := make(map[int]bool)
+			for _, j := range s.GetSamples(i) {
+				found[j] = true
+			}
+			for j := 0; j < n; j++ {
+				if (vector.Dist(parts.Position(i), parts.Position(j)) <= h) != found[j] {
+					t.Fatalf("Particle %d neighbor %d sampled %v\n", i, j, found[j])
+				}
+			}
+		}
+	}
+	check()
+	for i := range pos {
+		pos[i] += r.Float32()*0.05 - 0.025
+	}
+	s.UpdateSampler()
+	check()
+
+	status := make(chan int)
+	done := make(chan bool)
+	go func() { s.Run(status); done <- true }()
+	status <- THREAD_RUN_SAMPLER
+	status <- THREAD_STOP_SAMPLER
+	<-done
+
+	if s.GetElements() != n || len(s.GetData1D()) != s.GetBuckets()*s.BucketSize() || len(s.GetVectors()) != 3*s.GetBuckets() {
+		t.Errorf("Sampler holds %d particles in %d leaves\n", s.GetElements(), s.GetBuckets())
+	}
+	leaf := s.Hash([3]float32{pos[0], pos[1], pos[2]})
+	if len(s.GetRegionalSamples(leaf, 1)) == 0 {
+		t.Errorf("No regional samples around leaf %d\n", leaf)
+	}
+	if all := s.GetRegionalSamples(leaf, 64); len(all) != n {
+		t.Errorf("Regional samples above the root %d of %d\n", len(all), n)
+	}
+}
+
+func TestOctreeDensity(t *testing.T) {
+	dx := float32(0.05)
+	parts := model.NewParticleArray(6*6*6, 0, 2*dx, 1/(dx*dx*dx), 1000*dx*dx*dx)
+	i := 0
+	for x := 0; x < 6; x++ {
+		for y := 0; y < 6; y++ {
+			for z := 0; z < 6; z++ {
+				parts.Set(i, model.Particle{Position: [3]float32{float32(x) * dx, float32(y) * dx, float32(z) * dx}})
+				i++
+			}
+		}
+	}
+	core := sph.New(&parts, 2*dx)
+	core.DensityAll()
+	cell := append([]float32{}, parts.Densities()...)
+	core.Field().SetNeighborhood(New(&parts, 2*dx, 8))
+	core.DensityAll()
+	for i, rho := range parts.Densities() {
+		if math.Abs(float64(rho-cell[i])) > 1e-3*float64(cell[i]) {
+			t.Fatalf("Octree density %f cell list density %f\n", rho, cell[i])
+		}
+	}
+}
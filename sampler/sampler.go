@@ -16,3 +16,11 @@ type Sampler interface {
 	GetData1D() []int
 	GetSamplesFromPosition(pos []float32) []int
 }
+
+//Neighborhood is the subset of sampler queries particle fields rely on for
+//neighbor candidate lists
+type Neighborhood interface {
+	UpdateSampler()
+	GetSamples(i int) []int
+	GetSamplesFromPosition(pos []float32) []int
+}
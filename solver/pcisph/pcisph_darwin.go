@@ -42,6 +42,7 @@ func (pci *PciMethod) Run(message chan string, hasGL bool, mRender *render.Rende
 
 	for !done {
 		pci.system.DensityAll()
+		pci.system.ForceFieldsAll()
 		pci.system.ViscousAll()
 		max_error_ratio := float32(0.0)
 		density_error := float32(0.0)
@@ -0,0 +1,300 @@
+package validation
+
+import (
+	"math"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/force"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+const (
+	DENSITY = 1000.0
+	GRAVITY = 9.81
+	GAMMA   = 7.0
+)
+
+//Martin and Moyce 1952 dam break surge front for a square column, non dimensional
+//time T = t sqrt(2g/a) against front position Z = x/a
+var MartinMoyceT = []float64{0.41, 0.84, 1.19, 1.43, 1.63, 1.83, 1.98, 2.20, 2.32, 2.51, 2.65, 2.81, 2.97, 3.11}
+var MartinMoyceZ = []float64{1.11, 1.22, 1.44, 1.67, 1.89, 2.11, 2.33, 2.56, 2.78, 3.00, 3.22, 3.44, 3.67, 3.89}
+
+//lattice returns the cell centers of a block of n cells of spacing dx from min
+func lattice(min vector.Vec, n [3]int, dx float32) []float32 {
+	positions := make([]float32, 0, n[0]*n[1]*n[2]*3)
+	for x := 0; x < n[0]; x++ {
+		for y := 0; y < n[1]; y++ {
+			for z := 0; z < n[2]; z++ {
+				positions = append(positions, min[0]+(float32(x)+0.5)*dx, min[1]+(float32(y)+0.5)*dx, min[2]+(float32(z)+0.5)*dx)
+			}
+		}
+	}
+	return positions
+}
+
+//system builds an SPH system of fluid particles at rest with smoothing length 2dx
+//over the boundary particles. Density is the initial fluid density at a position
+func system(fluid []float32, walls []float32, dx float32, density func(pos []float32) float32) *sph.SPH {
+	n := len(fluid) / 3
+	parts := model.NewParticleArray(n, 0, 2*dx, 1/(dx*dx*dx), DENSITY*dx*dx*dx)
+	for i := 0; i < n; i++ {
+		particle := model.Particle{}
+		copy(particle.Position[:], fluid[i*3:i*3+3])
+		particle.Density = density(particle.Position[:])
+		parts.Set(i, particle)
+	}
+	if len(walls) > 0 {
+		parts.AddBoundaryParticles(walls)
+	}
+	core := sph.New(&parts, 2*dx)
+	return &core
+}
+
+//viscosity returns the viscous coefficient giving kinematic viscosity nu. The
+//viscous force is the coefficient times sum_j m_j (v_j - v_i)/rho_j O2D(r), and
+//the O2D constant 90/(pi h^5) is twice the 45/(pi h^5) of the Muller et al. 2003
+//viscosity laplacian whose second moment recovers the velocity laplacian, so the
+//coefficient per unit mass is nu/2
+func viscosity(nu float32, core *sph.SPH) float32 {
+	return 0.5 * nu * core.Particles().Mass()
+}
+
+//tait returns the weakly compressible density at pressure p
+func tait(p float64, sos float64) float32 {
+	return float32(DENSITY * math.Pow(1+GAMMA*p/(DENSITY*sos*sos), 1/GAMMA))
+}
+
+//Hydrostatic column periodic in x and z over a floor. The pressure against depth
+//must approach the hydrostatic profile rho0 g (H - y)
+func Hydrostatic(solver Factory) Result {
+	const dx = float32(0.05)
+	const nx, ny, walls = 4, 12, 3
+	height := float64(dx * ny)
+	sos := 10 * math.Sqrt(GRAVITY*height)
+	core := system(lattice(vector.Vec{0, 0, 0}, [3]int{nx, ny, nx}, dx),
+		lattice(vector.Vec{0, -walls * dx, 0}, [3]int{nx, walls, nx}, dx), dx,
+		func(pos []float32) float32 { return tait(DENSITY*GRAVITY*(height-float64(pos[1])), sos) })
+	domain := grid.NewDomain(vector.Vec{0, -1, 0}, vector.Vec{nx * dx, 2, nx * dx})
+	domain.SetPeriodic(true, false, true)
+	core.SetDomain(domain)
+	core.SetEquationOfState(float32(sos), GAMMA)
+	core.SetDensityDiffusion(sph.DIFFUSION_ANTUONO, 0.1)
+	core.SetViscosity(viscosity(0.5, core))
+
+	s := solver(core)
+	for step := 0; step < 80; step++ {
+		s.Step()
+	}
+	core.PressureAll()
+
+	r := Result{}
+	parts := core.Particles()
+	sums, counts := make([]float64, ny), make([]int, ny)
+	for i := 0; i < parts.N(); i++ {
+		layer := int(math.Floor(float64(parts.Position(i)[1] / dx)))
+		if layer >= 0 && layer < ny {
+			sums[layer] += float64(parts.Get(i).Press)
+			counts[layer]++
+		}
+	}
+	for layer := 0; layer < ny; layer++ {
+		if counts[layer] == 0 {
+			continue
+		}
+		depth := height - (float64(layer)+0.5)*float64(dx)
+		r.Samples = append(r.Samples, depth)
+		r.Measured = append(r.Measured, sums[layer]/float64(counts[layer]))
+		r.Reference = append(r.Reference, DENSITY*GRAVITY*depth)
+	}
+	return r
+}
+
+//DamBreak collapses a square water column of width a against a wall on a dry
+//no-slip floor in a slab periodic in z. The surge front is compared with Martin
+//and Moyce
+func DamBreak(solver Factory) Result {
+	return damBreak(solver, false)
+}
+
+//DamBreak3D collapses the water column in a tank closed by no-slip side walls
+//in z. The surge front is compared with Martin and Moyce
+func DamBreak3D(solver Factory) Result {
+	return damBreak(solver, true)
+}
+
+func damBreak(solver Factory, closed bool) Result {
+	const dx = float32(0.0125)
+	const n, nz, walls = 8, 6, 3
+	const tmax = 2.0
+	a := float64(dx * n)
+	sos := 10 * math.Sqrt(2*GRAVITY*a)
+	var boundary []float32
+	domain := grid.NewDomain(vector.Vec{-1, -1, 0}, vector.Vec{2, 2, nz * dx})
+	if closed {
+		floor := lattice(vector.Vec{-walls * dx, -walls * dx, -walls * dx}, [3]int{4*n + walls, walls, nz + 2*walls}, dx)
+		wall := lattice(vector.Vec{-walls * dx, 0, -walls * dx}, [3]int{walls, 2 * n, nz + 2*walls}, dx)
+		near := lattice(vector.Vec{0, 0, -walls * dx}, [3]int{4 * n, 2 * n, walls}, dx)
+		far := lattice(vector.Vec{0, 0, nz * dx}, [3]int{4 * n, 2 * n, walls}, dx)
+		boundary = append(append(append(floor, wall...), near...), far...)
+		domain = grid.NewDomain(vector.Vec{-1, -1, -walls * dx}, vector.Vec{2, 2, (nz + walls) * dx})
+	} else {
+		floor := lattice(vector.Vec{-walls * dx, -walls * dx, 0}, [3]int{4*n + walls, walls, nz}, dx)
+		wall := lattice(vector.Vec{-walls * dx, 0, 0}, [3]int{walls, 2 * n, nz}, dx)
+		boundary = append(floor, wall...)
+		domain.SetPeriodic(false, false, true)
+	}
+	core := system(lattice(vector.Vec{0, 0, 0}, [3]int{n, n, nz}, dx), boundary, dx,
+		func(pos []float32) float32 { return tait(DENSITY*GRAVITY*(a-float64(pos[1])), sos) })
+	core.SetDomain(domain)
+	core.SetEquationOfState(float32(sos), GAMMA)
+	core.SetDensityDiffusion(sph.DIFFUSION_ANTUONO, 0.1)
+	core.SetViscosity(viscosity(1e-2, core))
+	core.SetArtificialPressure(0.2, 4, dx)
+	core.SetNoSlip(true)
+
+	s := solver(core)
+	scale := math.Sqrt(2 * GRAVITY / a)
+	times, fronts := []float64{0}, []float64{1}
+	for times[len(times)-1] < tmax {
+		s.Step()
+		parts := core.Particles()
+		front := float32(0)
+		for i := 0; i < parts.N(); i++ {
+			if x := parts.Position(i)[0]; x > front {
+				front = x
+			}
+		}
+		times = append(times, float64(core.Elapsed())*scale)
+		fronts = append(fronts, float64(front+0.5*dx)/a)
+	}
+
+	r := Result{}
+	for k, T := range MartinMoyceT {
+		if T > tmax {
+			break
+		}
+		r.Samples = append(r.Samples, T)
+		r.Measured = append(r.Measured, interpolate(times, fronts, T))
+		r.Reference = append(r.Reference, MartinMoyceZ[k])
+	}
+	return r
+}
+
+//interpolate samples the piecewise linear curve (xs, ys) at x
+func interpolate(xs []float64, ys []float64, x float64) float64 {
+	for i := 1; i < len(xs); i++ {
+		if xs[i] >= x {
+			u := (x - xs[i-1]) / (xs[i] - xs[i-1])
+			return ys[i-1] + u*(ys[i]-ys[i-1])
+		}
+	}
+	return ys[len(ys)-1]
+}
+
+//Poiseuille flow between plates at y = 0 and y = L driven from rest by a body
+//force F along x. The velocity profile is compared with the transient series
+//solution of Morris et al. 1997. The plates hold the no-slip ghost velocities
+func Poiseuille(solver Factory) Result {
+	const dx = float32(0.05)
+	const nx, ny, walls = 4, 10, 3
+	const nu, f, tmax = 0.05, 0.4, 0.5
+	l := float64(dx * ny)
+	sos := 10 * f * l * l / (8 * nu)
+	plates := append(lattice(vector.Vec{0, -walls * dx, 0}, [3]int{nx, walls, nx}, dx),
+		lattice(vector.Vec{0, ny * dx, 0}, [3]int{nx, walls, nx}, dx)...)
+	core := system(lattice(vector.Vec{0, 0, 0}, [3]int{nx, ny, nx}, dx), plates, dx,
+		func(pos []float32) float32 { return DENSITY })
+	domain := grid.NewDomain(vector.Vec{0, -1, 0}, vector.Vec{nx * dx, 2, nx * dx})
+	domain.SetPeriodic(true, false, true)
+	core.SetDomain(domain)
+	core.SetEquationOfState(float32(sos), GAMMA)
+	core.SetDensityDiffusion(sph.DIFFUSION_ANTUONO, 0.1)
+	core.SetViscosity(viscosity(nu, core))
+	core.SetNoSlip(true)
+	core.ClearForceFields()
+	core.AddForceField(force.NewGravity(vector.Vec{f, 0, 0}))
+
+	s := solver(core)
+	for core.Elapsed() < tmax {
+		s.Step()
+	}
+
+	t := float64(core.Elapsed())
+	r := Result{}
+	parts := core.Particles()
+	sums, counts := make([]float64, ny), make([]int, ny)
+	for i := 0; i < parts.N(); i++ {
+		layer := int(math.Floor(float64(parts.Position(i)[1] / dx)))
+		if layer >= 0 && layer < ny {
+			sums[layer] += float64(parts.Velocity(i)[0])
+			counts[layer]++
+		}
+	}
+	for layer := 0; layer < ny; layer++ {
+		if counts[layer] == 0 {
+			continue
+		}
+		y := (float64(layer) + 0.5) * float64(dx)
+		u := f / (2 * nu) * y * (l - y)
+		for k := 0; k < 32; k++ {
+			m := float64(2*k + 1)
+			u -= 4 * f * l * l / (nu * math.Pow(math.Pi*m, 3)) * math.Sin(math.Pi*y*m/l) * math.Exp(-m*m*math.Pi*math.Pi*nu*t/(l*l))
+		}
+		r.Samples = append(r.Samples, y)
+		r.Measured = append(r.Measured, sums[layer]/float64(counts[layer]))
+		r.Reference = append(r.Reference, u)
+	}
+	return r
+}
+
+//TaylorGreen vortex in a fully periodic slab. The kinetic energy must decay as
+//exp(-4 nu k^2 t) for the vortex wave number k
+func TaylorGreen(solver Factory) Result {
+	const dx = float32(0.05)
+	const n, nz = 12, 4
+	const u0, nu, tmax = 0.1, 0.005, 0.5
+	l := float64(dx * n)
+	k := 2 * math.Pi / l
+	sos := 10 * u0
+	core := system(lattice(vector.Vec{0, 0, 0}, [3]int{n, n, nz}, dx), nil, dx,
+		func(pos []float32) float32 {
+			x, y := k*float64(pos[0]), k*float64(pos[1])
+			return tait(-DENSITY*u0*u0/4*(math.Cos(2*x)+math.Cos(2*y)), sos)
+		})
+	parts := core.Particles()
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		x, y := k*float64(particle.Position[0]), k*float64(particle.Position[1])
+		particle.Velocity = [3]float32{float32(-u0 * math.Cos(x) * math.Sin(y)), float32(u0 * math.Sin(x) * math.Cos(y)), 0}
+		parts.Set(i, particle)
+	}
+	domain := grid.NewDomain(vector.Vec{0, 0, 0}, vector.Vec{n * dx, n * dx, nz * dx})
+	domain.SetPeriodic(true, true, true)
+	core.SetDomain(domain)
+	core.SetEquationOfState(float32(sos), GAMMA)
+	core.SetDensityDiffusion(sph.DIFFUSION_ANTUONO, 0.1)
+	core.SetViscosity(viscosity(nu, core))
+	core.ClearForceFields()
+
+	kinetic := func() float64 {
+		e := 0.0
+		for i := 0; i < parts.N(); i++ {
+			v := parts.Velocity(i)
+			e += 0.5 * float64(parts.ParticleMass(i)) * float64(v[0]*v[0]+v[1]*v[1]+v[2]*v[2])
+		}
+		return e
+	}
+	e0 := kinetic()
+	s := solver(core)
+	r := Result{}
+	for core.Elapsed() < tmax {
+		s.Step()
+		t := float64(core.Elapsed())
+		r.Samples = append(r.Samples, t)
+		r.Measured = append(r.Measured, kinetic()/e0)
+		r.Reference = append(r.Reference, math.Exp(-4*nu*k*k*t))
+	}
+	return r
+}
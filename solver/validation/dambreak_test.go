@@ -0,0 +1,8 @@
+//go:build validation
+// +build validation
+
+package validation
+
+func init() {
+	dambreaks = true
+}
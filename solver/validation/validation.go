@@ -0,0 +1,132 @@
+//Analytic validation of the SPH solvers. Canonical cases are run headlessly for
+//every registered solver and compared to analytic solutions or experimental
+//reference curves by relative error norms, so physics regressions fail go test.
+//The slow dam breaks only run with go test -tags validation
+package validation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/andewx/dieselfluid/model/sph"
+	"github.com/andewx/dieselfluid/solver/wcsph"
+)
+
+//Stepper advances an SPH system by a single time step
+type Stepper interface {
+	Step()
+	Core() *sph.SPH
+}
+
+//Factory wraps a configured SPH system into a solver
+type Factory func(core *sph.SPH) Stepper
+
+var registry = map[string]Factory{}
+
+//The GPU PCISPH method needs a GL context and registers no headless solver
+func init() {
+	Register("wcsph", func(core *sph.SPH) Stepper { return wcsph.New(core) })
+}
+
+//Register adds a solver to the validation runs
+func Register(name string, factory Factory) {
+	registry[name] = factory
+}
+
+//Solvers returns the sorted names of the registered solvers
+func Solvers() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Result compares the measured curve of a case against its reference. Samples is
+//the abscissa shared by both curves, for instance time or wall distance
+type Result struct {
+	Case      string
+	Solver    string
+	Samples   []float64
+	Measured  []float64
+	Reference []float64
+	L2        float64 //Relative root mean square error
+	Linf      float64 //Relative maximum error
+	Tolerance float64 //Bound on the relative L2 error
+}
+
+func (r Result) Passed() bool {
+	return r.L2 <= r.Tolerance && !math.IsNaN(r.L2)
+}
+
+func (r Result) String() string {
+	status := "PASS"
+	if !r.Passed() {
+		status = "FAIL"
+	}
+	return fmt.Sprintf("%s %s/%s: L2 %.4f Linf %.4f tolerance %.4f", status, r.Case, r.Solver, r.L2, r.Linf, r.Tolerance)
+}
+
+//Case is a canonical validation problem. Run builds the system, advances it with
+//the solver and returns the measured and reference curves
+type Case struct {
+	Name      string
+	Tolerance float64
+	Run       func(solver Factory) Result
+}
+
+//Cases returns the canonical validation cases
+func Cases() []Case {
+	return []Case{
+		{"hydrostatic", 0.1, Hydrostatic},
+		{"dambreak", 0.15, DamBreak},
+		{"dambreak3d", 0.15, DamBreak3D},
+		{"poiseuille", 0.05, Poiseuille},
+		{"taylorgreen", 0.1, TaylorGreen},
+	}
+}
+
+//Run runs a case with the named solver
+func Run(c Case, solver string) (Result, error) {
+	factory, ok := registry[solver]
+	if !ok {
+		return Result{}, fmt.Errorf("Unknown solver %s", solver)
+	}
+	r := c.Run(factory)
+	r.Case, r.Solver, r.Tolerance = c.Name, solver, c.Tolerance
+	r.L2, r.Linf = Norms(r.Measured, r.Reference)
+	return r, nil
+}
+
+//RunAll runs every case for every registered solver
+func RunAll() []Result {
+	results := make([]Result, 0)
+	for _, c := range Cases() {
+		for _, solver := range Solvers() {
+			r, _ := Run(c, solver)
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+//Norms returns the root mean square and maximum errors of measured against
+//reference relative to the largest reference magnitude
+func Norms(measured []float64, reference []float64) (float64, float64) {
+	if len(measured) != len(reference) || len(reference) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	scale, sum, max := 0.0, 0.0, 0.0
+	for i := range reference {
+		scale = math.Max(scale, math.Abs(reference[i]))
+		err := math.Abs(measured[i] - reference[i])
+		sum += err * err
+		max = math.Max(max, err)
+	}
+	if scale == 0 {
+		scale = 1
+	}
+	return math.Sqrt(sum/float64(len(reference))) / scale, max / scale
+}
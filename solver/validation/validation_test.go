@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNorms(t *testing.T) {
+	l2, linf := Norms([]float64{1, 2, 5}, []float64{1, 2, 4})
+	if math.Abs(l2-0.25/math.Sqrt(3)) > 1e-9 || linf != 0.25 {
+		t.Errorf("Norms expected 0.1443 0.25 got %f %f\n", l2, linf)
+	}
+}
+
+//Dam breaks run for minutes and are enabled by the validation build tag
+var dambreaks = false
+
+//Runs every case for every registered solver, skipped in short mode. The dam
+//breaks only run with go test -tags validation
+func TestValidation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Validation cases skipped in short mode")
+	}
+	for _, c := range Cases() {
+		if !dambreaks && strings.HasPrefix(c.Name, "dambreak") {
+			continue
+		}
+		for _, solver := range Solvers() {
+			r, err := Run(c, solver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Log(r)
+			if !r.Passed() {
+				t.Errorf("%s\nmeasured  %v\nreference %v\n", r, r.Measured, r.Reference)
+			}
+		}
+	}
+}
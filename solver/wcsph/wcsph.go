@@ -1,13 +1,55 @@
 package wcsph
 
 import (
-	"github.com/andewx/dieselfluid/math/vector"
 	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/diagnostics"
 	"github.com/andewx/dieselfluid/model/sph"
 )
 
 type WCSPH struct {
-	core sph.SPH
+	core *sph.SPH
+}
+
+func New(core *sph.SPH) *WCSPH {
+	return &WCSPH{core}
+}
+
+func (p WCSPH) Core() *sph.SPH {
+	return p.core
+}
+
+//Step advances the simulation by a single CFL time step. Density is evolved with
+//the continuity equation when density diffusion is enabled and summed otherwise
+func (p WCSPH) Step() {
+	p.core.NN()
+	p.core.ForceFieldsAll()
+	if p.core.DensityDiffusion() != sph.DIFFUSION_NONE {
+		p.core.ContinuityAll()
+	} else {
+		p.core.DensityAll()
+	}
+	p.core.ThermalAll()
+	p.core.PressureAll()
+	p.core.BoundaryPressureAll()
+	p.core.ViscousAll()
+	p.core.GradientPressureForce()
+	p.core.StressAll()
+	p.core.Update()
+	p.core.KinematicAll()
+	p.core.BoundaryAll()
+}
+
+//RunMonitored advances the simulation by steps recording diagnostics after each
+//step. The run stops early returning the *diagnostics.BlowUp report when the
+//monitor detects an unstable state
+func (p WCSPH) RunMonitored(steps int, monitor *diagnostics.Monitor) error {
+	for step := 0; step < steps; step++ {
+		p.Step()
+		if _, err := monitor.Record(p.core); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 //---------SPHCore Run Methods------------------------//
@@ -15,11 +57,7 @@ func (p WCSPH) Run() {
 	done := false
 
 	for !done {
-		p.core.DensityAll()
-		p.core.ExternalAll(vector.Vec{0, -9.81, 0})
-		p.core.PressureAll()
-		p.core.Update()
-		p.core.CFL()
+		p.Step()
 	}
 
 	return
@@ -40,11 +78,7 @@ func (p WCSPH) Run_(t chan int) {
 
 		//Executes full in frame computation loop.core.
 		if sync {
-			p.core.DensityAll()
-			p.core.ExternalAll(vector.Vec{0, -9.81, 0})
-			p.core.PressureAll()
-			p.core.Update()
-			p.core.CFL()
+			p.Step()
 
 			//Channel Monitor - Monitor Blocking I/O Request
 			status := <-t
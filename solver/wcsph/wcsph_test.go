@@ -0,0 +1,126 @@
+package wcsph
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andewx/dieselfluid/geom/grid"
+	"github.com/andewx/dieselfluid/math/vector"
+	"github.com/andewx/dieselfluid/model"
+	"github.com/andewx/dieselfluid/model/sph"
+)
+
+//Hydrostatic column over a wall of boundary particles, periodic in x and z. The
+//pressure must stay a linear function of depth with slope rho0 g
+func TestHydrostatic(t *testing.T) {
+	const dx = float32(0.05)
+	const nx, ny, walls = 6, 16, 3
+	const d0 = float32(1000.0)
+	height := dx * ny
+	sos := 10 * float32(math.Sqrt(9.81*float64(height)))
+
+	parts := model.NewParticleArray(nx*nx*ny, 0, 2*dx, 1/(dx*dx*dx), d0*dx*dx*dx)
+	i := 0
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			for z := 0; z < nx; z++ {
+				particle := model.Particle{}
+				particle.Position = [3]float32{(float32(x) + 0.5) * dx, (float32(y) + 0.5) * dx, (float32(z) + 0.5) * dx}
+				depth := height - particle.Position[1]
+				particle.Density = d0 * float32(math.Pow(float64(1+7*9.81*depth/(sos*sos)), 1.0/7.0))
+				parts.Set(i, particle)
+				i++
+			}
+		}
+	}
+	floor := make([]float32, 0, nx*nx*walls*3)
+	for y := 0; y < walls; y++ {
+		for x := 0; x < nx; x++ {
+			for z := 0; z < nx; z++ {
+				floor = append(floor, (float32(x)+0.5)*dx, -(float32(y)+0.5)*dx, (float32(z)+0.5)*dx)
+			}
+		}
+	}
+	parts.AddBoundaryParticles(floor)
+
+	core := sph.New(&parts, 2*dx)
+	domain := grid.NewDomain(vector.Vec{0, -1, 0}, vector.Vec{nx * dx, 2, nx * dx})
+	domain.SetPeriodic(true, false, true)
+	core.SetDomain(domain)
+	core.SetEquationOfState(sos, 7.0)
+	core.SetDensityDiffusion(sph.DIFFUSION_ANTUONO, 0.1)
+	core.SetViscosity(0.05)
+
+	solver := New(&core)
+	for step := 0; step < 100; step++ {
+		solver.Step()
+	}
+	core.PressureAll()
+
+	//Least squares fit of pressure against depth
+	n := float64(parts.N())
+	var sx, sy, sxx, sxy, syy float64
+	for i := 0; i < parts.N(); i++ {
+		particle := parts.Get(i)
+		x := float64(height - particle.Position[1])
+		y := float64(particle.Press)
+		sx, sy, sxx, sxy, syy = sx+x, sy+y, sxx+x*x, sxy+x*y, syy+y*y
+	}
+	slope := (n*sxy - sx*sy) / (n*sxx - sx*sx)
+	r := (n*sxy - sx*sy) / math.Sqrt((n*sxx-sx*sx)*(n*syy-sy*sy))
+	expected := float64(d0) * 9.81
+	if math.Abs(slope-expected)/expected > 0.1 || r*r < 0.95 {
+		t.Errorf("Hydrostatic pressure slope %f expected %f, R^2 %f\n", slope, expected, r*r)
+	}
+}
+
+//noisyBlock returns the pressure variance of a fully periodic block at rest whose
+//densities carry random noise after steps with the density diffusion mode
+func noisyBlock(mode int, delta float32, steps int) float64 {
+	const dx = float32(0.05)
+	const n = 6
+	const d0 = float32(1000.0)
+	parts := model.NewParticleArray(n*n*n, 0, 2*dx, 1/(dx*dx*dx), d0*dx*dx*dx)
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < parts.N(); i++ {
+		particle := model.Particle{}
+		particle.Position = [3]float32{(float32(i/(n*n)) + 0.5) * dx, (float32(i/n%n) + 0.5) * dx, (float32(i%n) + 0.5) * dx}
+		particle.Density = d0 * (1 + 0.01*(rng.Float32()-0.5))
+		parts.Set(i, particle)
+	}
+	core := sph.New(&parts, 2*dx)
+	domain := grid.NewDomain(vector.Vec{0, 0, 0}, vector.Vec{n * dx, n * dx, n * dx})
+	domain.SetPeriodic(true, true, true)
+	core.SetDomain(domain)
+	core.SetEquationOfState(10, 7.0)
+	core.SetDensityDiffusion(mode, delta)
+	core.SetViscosity(0)
+	core.ClearForceFields()
+
+	solver := New(&core)
+	for step := 0; step < steps; step++ {
+		solver.Step()
+	}
+	core.PressureAll()
+	mean, sq := 0.0, 0.0
+	for i := 0; i < parts.N(); i++ {
+		p := float64(parts.Get(i).Press)
+		mean, sq = mean+p, sq+p*p
+	}
+	mean /= float64(parts.N())
+	return sq/float64(parts.N()) - mean*mean
+}
+
+//Density diffusion smooths seeded density noise which the bare continuity
+//equation only carries around as pressure waves
+func TestDensityDiffusion(t *testing.T) {
+	initial := noisyBlock(sph.DIFFUSION_MOLTENI, 0, 0)
+	for _, mode := range []int{sph.DIFFUSION_MOLTENI, sph.DIFFUSION_ANTUONO} {
+		bare := noisyBlock(mode, 0, 10)
+		diffused := noisyBlock(mode, 0.1, 10)
+		if diffused > 0.2*bare || diffused > 0.2*initial {
+			t.Errorf("Diffusion %d pressure variance %f not below the bare continuity %f\n", mode, diffused, bare)
+		}
+	}
+}